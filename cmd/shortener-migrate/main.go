@@ -0,0 +1,11 @@
+package main
+
+import (
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+
+	"github.com/msmkdenis/yap-shortener/internal/app/migrate"
+)
+
+func main() {
+	migrate.Run()
+}
@@ -0,0 +1,27 @@
+package integrationurltests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/repository/db"
+	"github.com/msmkdenis/yap-shortener/internal/repository/repotest"
+	"github.com/msmkdenis/yap-shortener/internal/service"
+)
+
+func TestPostgresURLRepository_Conformance(t *testing.T) {
+	container, pool, err := setupTestDatabase()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, container.Terminate(context.Background()))
+	})
+
+	repository := db.NewPostgresURLRepository(pool, zap.NewNop())
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		require.NoError(t, repository.DeleteAll(context.Background()))
+		return repository
+	})
+}
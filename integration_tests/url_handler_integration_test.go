@@ -3,11 +3,13 @@ package integrationurltests
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/labstack/echo/v4"
@@ -20,10 +22,13 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/msmkdenis/yap-shortener/internal/api/httphandlers"
+	"github.com/msmkdenis/yap-shortener/internal/api/openapi"
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/internal/config"
 	"github.com/msmkdenis/yap-shortener/internal/middleware"
 	"github.com/msmkdenis/yap-shortener/internal/repository/db"
 	"github.com/msmkdenis/yap-shortener/internal/service"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
 )
 
@@ -65,23 +70,37 @@ func (s *IntegrationTestSuite) SetupTest() {
 	jwtManager := jwtgen.InitJWTManager(cfgMock.TokenName, cfgMock.SecretKey, logger)
 	jwtCheckerCreator := middleware.InitJWTCheckerCreator(jwtManager, logger)
 	jwtAuth := middleware.InitJWTAuth(jwtManager, logger)
-	s.urlService = service.NewURLService(s.urlRepository, logger)
+	authorizer := middleware.InitAuthorizer(logger)
+	spec, err := openapi.Load()
+	if err != nil {
+		logger.Error("Unable to load openapi spec", zap.Error(err))
+	}
+	validator, err := middleware.InitRequestValidator(spec, logger)
+	if err != nil {
+		logger.Error("Unable to initialize request validator", zap.Error(err))
+	}
+	deprecation, err := middleware.InitDeprecation(cfgMock.LegacySunset, logger)
+	if err != nil {
+		logger.Error("Unable to initialize deprecation middleware", zap.Error(err))
+	}
+	s.urlService = service.NewURLService(s.urlRepository, 0, service.RedirectConfig{}, nil, logger)
 	s.echo = echo.New()
 	s.endpoint, err = s.container.Endpoint(context.Background(), "httphandlers")
 	if err != nil {
 		logger.Error("Unable to get endpoint", zap.Error(err))
 	}
-	s.urlHandler = httphandlers.NewURLShorten(s.echo, s.urlService, s.endpoint, cfgMock.TrustedSubnet, jwtCheckerCreator, jwtAuth, logger, &sync.WaitGroup{})
+	s.urlHandler = httphandlers.NewURLShorten(s.echo, s.urlService, s.endpoint, nil, clientip.NewResolver(nil), cfgMock.LegacyListing, jwtCheckerCreator, jwtAuth, authorizer, validator, deprecation, middleware.InitIdempotency(time.Hour, 0, logger), logger, &sync.WaitGroup{})
 }
 
 func (s *IntegrationTestSuite) TestAddURL() {
 	body := BODY
+	var cookies []*http.Cookie
+	var shortURL []byte
 
 	testCases := []struct {
 		name         string
 		method       string
-		header       http.Header
-		prepare      func()
+		sameUser     bool
 		path         string
 		body         string
 		expectedCode int
@@ -93,36 +112,58 @@ func (s *IntegrationTestSuite) TestAddURL() {
 			path:         s.endpoint + "/",
 			body:         body,
 			expectedCode: http.StatusCreated,
-			expectedBody: []byte(fmt.Sprintf("%s/Yzk4NGQwNmFhZmJlY2Y2YmM1NTU2OWY5NjQxNDhlYTM=", s.endpoint)),
 		},
 		{
 			name:         "Empty request - 400",
 			method:       http.MethodPost,
+			sameUser:     true,
 			path:         s.endpoint + "/",
 			body:         "",
 			expectedCode: http.StatusBadRequest,
-			expectedBody: []byte("Error: Unable to handle empty request"),
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "Unable to handle empty request"), "/"),
 		},
 		{
 			name:         "UrlAlreadyExists - 409",
 			method:       http.MethodPost,
+			sameUser:     true,
 			path:         s.endpoint + "/",
 			body:         body,
 			expectedCode: http.StatusConflict,
-			expectedBody: []byte(fmt.Sprintf("%s/Yzk4NGQwNmFhZmJlY2Y2YmM1NTU2OWY5NjQxNDhlYTM=", s.endpoint)),
+		},
+		{
+			name:         "Other user gets own url - 201",
+			method:       http.MethodPost,
+			path:         s.endpoint + "/",
+			body:         body,
+			expectedCode: http.StatusCreated,
 		},
 	}
 	for _, tc := range testCases {
 		s.T().Run(tc.name, func(t *testing.T) {
 			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBuffer([]byte(tc.body)))
-
-			req.Header = tc.header
+			if tc.sameUser {
+				for _, cookie := range cookies {
+					req.AddCookie(cookie)
+				}
+			}
 			rec := httptest.NewRecorder()
 
 			s.echo.ServeHTTP(rec, req)
 
 			assert.Equal(t, tc.expectedCode, rec.Code)
-			assert.Equal(t, tc.expectedBody, rec.Body.Bytes())
+			switch {
+			case tc.expectedBody != nil:
+				assert.Equal(t, tc.expectedBody, rec.Body.Bytes())
+			case tc.sameUser:
+				assert.Equal(t, shortURL, rec.Body.Bytes())
+			default:
+				assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), []byte(s.endpoint+"/")))
+				assert.NotEqual(t, shortURL, rec.Body.Bytes())
+			}
+			if shortURL == nil {
+				cookies = rec.Result().Cookies()
+				shortURL = rec.Body.Bytes()
+			}
 		})
 	}
 }
@@ -146,6 +187,7 @@ func (s *IntegrationTestSuite) TestAddURL_Context() {
 			path:         s.endpoint + "/",
 			body:         body,
 			expectedCode: http.StatusInternalServerError,
+			expectedBody: problemBody(apierr.New(apierr.CodeInternal, ""), "/"),
 		},
 		{
 			name:         "Bad request - 400 (unable to read body)",
@@ -153,7 +195,7 @@ func (s *IntegrationTestSuite) TestAddURL_Context() {
 			path:         s.endpoint + "/",
 			body:         "",
 			expectedCode: http.StatusBadRequest,
-			expectedBody: []byte("Error: Unable to handle empty request"),
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "Unable to handle empty request"), "/"),
 		},
 	}
 	for _, tc := range testCases {
@@ -239,3 +281,10 @@ func initPostgresPool(uri string, logger *zap.Logger) *db.PostgresPool {
 	logger.Info("Connected to database", zap.String("DSN", uri))
 	return postgresPool
 }
+
+// problemBody returns expected problem details body of response to request with path.
+func problemBody(p *apierr.Problem, path string) []byte {
+	p.Instance = path
+	body, _ := json.Marshal(p)
+	return body
+}
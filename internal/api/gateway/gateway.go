@@ -0,0 +1,114 @@
+// Package gateway serves the gRPC URL shortener service as JSON over HTTP.
+//
+// Routes are generated by gRPC-Gateway from HTTP annotations of shortener.proto and are mounted under Prefix,
+// requests are proxied to the gRPC server, so both APIs share one implementation, interceptors included.
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
+)
+
+// Prefix is a path prefix of transcoded routes.
+const Prefix = "/v2/"
+
+// headerIdempotencyKey is passed to gRPC metadata for replaying retried requests.
+const headerIdempotencyKey = "Idempotency-Key"
+
+// Gateway represents gRPC-Gateway handler.
+type Gateway struct {
+	tokenName string
+	clientIP  *clientip.Resolver
+	logger    *zap.Logger
+}
+
+// NewGateway creates a new Gateway instance
+//
+// Registers transcoded routes under Prefix, requests are sent to gRPC server over conn.
+// Client IP of requests is resolved by clientIP, gRPC server must trust the gateway as a proxy to honor it.
+func NewGateway(e *echo.Echo, conn *grpc.ClientConn, tokenName string, clientIP *clientip.Resolver, logger *zap.Logger) (*Gateway, error) {
+	g := &Gateway{
+		tokenName: tokenName,
+		clientIP:  clientIP,
+		logger:    logger,
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithMetadata(g.metadata),
+		runtime.WithForwardResponseOption(g.setCookie),
+		runtime.WithErrorHandler(g.handleError),
+	)
+
+	if err := pb.RegisterURLShortenerHandler(context.Background(), mux, conn); err != nil {
+		return nil, apperr.NewValueError("unable to register gateway handlers", apperr.Caller(), err)
+	}
+
+	e.Any(Prefix+"*", echo.WrapHandler(mux))
+
+	return g, nil
+}
+
+// metadata passes token cookie and Idempotency-Key header to gRPC metadata.
+//
+// Resolved client IP is appended to X-Forwarded-For passed by gRPC-Gateway with the request remote address,
+// so gRPC server resolves the same client IP and ignores X-Forwarded-For hops prepended by clients.
+func (g *Gateway) metadata(_ context.Context, r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	if cookie, err := r.Cookie(g.tokenName); err == nil {
+		md.Set(g.tokenName, cookie.Value)
+	}
+	if ip := g.clientIP.FromRequest(r); ip != "" {
+		md.Set(clientip.HeaderForwardedFor, ip)
+	}
+	if key := r.Header.Get(headerIdempotencyKey); key != "" {
+		md.Set(headerIdempotencyKey, key)
+	}
+	return md
+}
+
+// setCookie sets token issued by gRPC server as a cookie.
+//
+// It is called for every message of a stream, so the token is removed from metadata once set.
+func (g *Gateway) setCookie(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	if token := md.HeaderMD.Get(g.tokenName); len(token) > 0 {
+		http.SetCookie(w, &http.Cookie{Name: g.tokenName, Value: token[0], Path: "/"})
+		md.HeaderMD.Delete(g.tokenName)
+	}
+	w.Header().Del(runtime.MetadataHeaderPrefix + g.tokenName)
+	return nil
+}
+
+// handleError writes gRPC errors as problem details, with the same codes and HTTP statuses as legacy routes.
+func (g *Gateway) handleError(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	problem := apierr.FromStatus(status.Convert(err))
+	if problem.Code == apierr.CodeInternal {
+		g.logger.Error("gateway request failed", zap.String("path", r.URL.Path), zap.Error(err))
+	}
+
+	if err := apierr.WriteResponse(w, r, problem); err != nil {
+		g.logger.Error("unable to write problem", zap.Error(err))
+	}
+}
@@ -0,0 +1,261 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
+)
+
+const tokenName = "token"
+
+// fakeShortener echoes metadata it got, so the test can check what gateway passes to gRPC.
+type fakeShortener struct {
+	pb.UnimplementedURLShortenerServer
+}
+
+func (f *fakeShortener) PostURL(ctx context.Context, in *pb.PostURLRequest) (*pb.PostURLResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return &pb.PostURLResponse{ShortUrl: in.Url + "#" + strings.Join(md.Get(tokenName), ",")}, nil
+}
+
+func (f *fakeShortener) PostBatchURLs(ctx context.Context, in *pb.PostBatchURLRequest) (*pb.PostBatchURLResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	urls := make([]*pb.BatchURLResponse, 0, len(in.BatchUrls))
+	for _, url := range in.BatchUrls {
+		urls = append(urls, &pb.BatchURLResponse{ShortenedURL: url.OriginalUrl + "#" + strings.Join(md.Get(headerIdempotencyKey), ",")})
+	}
+	return &pb.PostBatchURLResponse{BatchUrls: urls}, nil
+}
+
+func (f *fakeShortener) GetURL(_ context.Context, in *pb.GetURLRequest) (*pb.GetURLResponse, error) {
+	return nil, apierr.New(apierr.CodeURLDeleted, "URL with id "+in.ShortUrl+" deleted")
+}
+
+// GetStats is available to client 192.168.1.1 only, the client IP is resolved as by gRPC server trusting the gateway.
+func (f *fakeShortener) GetStats(ctx context.Context, _ *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	hops := strings.Split(strings.Join(md.Get(clientip.HeaderForwardedFor), ","), ",")
+	if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "192.168.1.1" {
+		return nil, apierr.Field(clientip.HeaderForwardedFor, "must end with trusted client IP, got "+ip)
+	}
+	return &pb.GetStatsResponse{Urls: 2, Users: 1}, nil
+}
+
+func (f *fakeShortener) ExportURLs(_ *pb.ExportURLsRequest, stream pb.URLShortener_ExportURLsServer) error {
+	if err := stream.SetHeader(metadata.Pairs(tokenName, "issued")); err != nil {
+		return err
+	}
+	return stream.Send(&pb.URLByUserID{ShortUrl: "http://localhost/1", OriginalUrl: "http://example.com"})
+}
+
+type GatewayTestSuite struct {
+	suite.Suite
+	server *grpc.Server
+	conn   *grpc.ClientConn
+	echo   *echo.Echo
+}
+
+func TestGatewaySuite(t *testing.T) {
+	suite.Run(t, new(GatewayTestSuite))
+}
+
+func (s *GatewayTestSuite) SetupSuite() {
+	s.server = grpc.NewServer()
+	pb.RegisterURLShortenerServer(s.server, &fakeShortener{})
+
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+
+	var err error
+	s.conn, err = grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(s.T(), err)
+
+	s.echo = echo.New()
+	// Requests of httptest come from 192.0.2.1, trusted as a proxy passing X-Real-IP.
+	proxies, err := clientip.ParseSubnets("192.0.2.0/24")
+	require.NoError(s.T(), err)
+	_, err = NewGateway(s.echo, s.conn, tokenName, clientip.NewResolver(proxies), zap.NewNop())
+	require.NoError(s.T(), err)
+}
+
+func (s *GatewayTestSuite) TearDownSuite() {
+	_ = s.conn.Close()
+	s.server.Stop()
+}
+
+func (s *GatewayTestSuite) TestTranscoding() {
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		cookie         string
+		realIP         string
+		idempotencyKey string
+		expectedCode   int
+		expectedBody   string
+		expectedCookie string
+	}{
+		{
+			name:         "Token cookie is passed as metadata",
+			method:       http.MethodPost,
+			path:         "/v2/urls",
+			body:         `{"url":"http://example.com"}`,
+			cookie:       "secret",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url":"http://example.com#secret"}`,
+		},
+		{
+			name:         "Client IP is passed as metadata",
+			method:       http.MethodGet,
+			path:         "/v2/internal/stats",
+			realIP:       "192.168.1.1",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"urls":2,"users":1}`,
+		},
+		{
+			name:           "Idempotency-Key header is passed as metadata",
+			method:         http.MethodPost,
+			path:           "/v2/urls/batch",
+			body:           `{"batch_urls":[{"correlation_id":"1","original_url":"http://example.com"}]}`,
+			idempotencyKey: "key",
+			expectedCode:   http.StatusOK,
+			expectedBody:   `{"batch_urls":[{"ShortenedURL":"http://example.com#key"}]}`,
+		},
+		{
+			name:           "Stream header token is set as cookie",
+			method:         http.MethodGet,
+			path:           "/v2/user/urls/export",
+			expectedCode:   http.StatusOK,
+			expectedBody:   `{"result":{"short_url":"http://localhost/1","original_url":"http://example.com"}}` + "\n",
+			expectedCookie: "issued",
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if test.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: tokenName, Value: test.cookie})
+			}
+			if test.realIP != "" {
+				request.Header.Set(clientip.HeaderRealIP, test.realIP)
+			}
+			if test.idempotencyKey != "" {
+				request.Header.Set(headerIdempotencyKey, test.idempotencyKey)
+			}
+			w := httptest.NewRecorder()
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.JSONEq(t, test.expectedBody, w.Body.String())
+			assert.Empty(t, w.Header().Get("Grpc-Metadata-Token"))
+			if test.expectedCookie != "" {
+				cookies := w.Result().Cookies()
+				require.Len(t, cookies, 1)
+				assert.Equal(t, test.expectedCookie, cookies[0].Value)
+			}
+		})
+	}
+}
+
+func (s *GatewayTestSuite) TestErrors() {
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		remoteAddr         string
+		headers            map[string]string
+		expectedCode       int
+		expectedProblem    apierr.Code
+		expectedViolations []apierr.FieldViolation
+	}{
+		{
+			name:            "Deleted URL is 410 as on legacy routes",
+			method:          http.MethodGet,
+			path:            "/v2/urls/abc",
+			expectedCode:    http.StatusGone,
+			expectedProblem: apierr.CodeURLDeleted,
+		},
+		{
+			name:               "Field violations are kept",
+			method:             http.MethodGet,
+			path:               "/v2/internal/stats",
+			expectedCode:       http.StatusBadRequest,
+			expectedProblem:    apierr.CodeInvalidArgument,
+			expectedViolations: []apierr.FieldViolation{{Field: clientip.HeaderForwardedFor, Description: "must end with trusted client IP, got 192.0.2.1"}},
+		},
+		{
+			name:               "Client IP headers of untrusted peer are ignored",
+			method:             http.MethodGet,
+			path:               "/v2/internal/stats",
+			remoteAddr:         "203.0.113.1:1234",
+			headers:            map[string]string{clientip.HeaderForwardedFor: "192.168.1.1", clientip.HeaderRealIP: "192.168.1.1"},
+			expectedCode:       http.StatusBadRequest,
+			expectedProblem:    apierr.CodeInvalidArgument,
+			expectedViolations: []apierr.FieldViolation{{Field: clientip.HeaderForwardedFor, Description: "must end with trusted client IP, got 203.0.113.1"}},
+		},
+		{
+			name:            "Unimplemented method",
+			method:          http.MethodGet,
+			path:            "/v2/ping",
+			expectedCode:    http.StatusMethodNotAllowed,
+			expectedProblem: apierr.CodeMethodNotAllowed,
+		},
+		{
+			name:            "Unknown route",
+			method:          http.MethodGet,
+			path:            "/v2/unknown/route",
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: apierr.CodeNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, http.NoBody)
+			if test.remoteAddr != "" {
+				request.RemoteAddr = test.remoteAddr
+			}
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, apierr.ContentTypeProblemJSON, w.Header().Get(echo.HeaderContentType))
+
+			var problem apierr.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, test.expectedProblem, problem.Code)
+			assert.Equal(t, test.path, problem.Instance)
+			assert.Equal(t, test.expectedViolations, problem.Violations)
+		})
+	}
+}
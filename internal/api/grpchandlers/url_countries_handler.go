@@ -0,0 +1,35 @@
+package grpchandlers
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/middleware"
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// ListCountryRedirects handles gRPC ListCountryRedirects request
+func (h *URLShorten) ListCountryRedirects(ctx context.Context, in *pb.ListCountryRedirectsRequest) (*pb.ListCountryRedirectsResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	redirects, err := h.urlService.GetCountryRedirects(ctx, userID, in.Id)
+	if err != nil {
+		h.logger.Warn("unable to get country redirects", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	countries := make([]*pb.CountryRedirects, 0, len(redirects))
+	for _, r := range redirects {
+		countries = append(countries, &pb.CountryRedirects{Country: r.Country, Redirects: r.Redirects})
+	}
+	return &pb.ListCountryRedirectsResponse{Countries: countries}, nil
+}
@@ -4,74 +4,148 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
+	"net/url"
 	"sync"
 
 	"github.com/labstack/gommon/log"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/internal/dto"
 	"github.com/msmkdenis/yap-shortener/internal/middleware"
 	"github.com/msmkdenis/yap-shortener/internal/model"
 	pb "github.com/msmkdenis/yap-shortener/internal/proto"
 	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
 	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
 	"github.com/msmkdenis/yap-shortener/pkg/workerpool"
 )
 
 type URLShorten struct {
-	urlService    URLShortenerService
-	urlPrefix     string
-	trustedSubnet string
-	jwtManager    *jwtgen.JWTManager
-	logger        *zap.Logger
-	wg            *sync.WaitGroup
+	urlService     URLShortenerService
+	urlPrefix      string
+	trustedSubnets clientip.Subnets
+	clientIP       *clientip.Resolver
+	legacyListing  bool
+	jwtManager     *jwtgen.JWTManager
+	logger         *zap.Logger
+	wg             *sync.WaitGroup
 	pb.UnimplementedURLShortenerServer
 }
 
 // URLShortenerService represents URL service interface.
 type URLShortenerService interface {
 	Add(ctx context.Context, s string, host string, userID string) (*model.URL, error)
-	AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string) ([]dto.URLBatchResponse, error)
+	AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string, atomic bool) ([]dto.URLBatchResponse, error)
+	Import(ctx context.Context, next func() (dto.URLBatchRequest, error), host string, userID string) (*dto.URLImportSummary, error)
 	GetAll(ctx context.Context) ([]string, error)
-	GetAllByUserID(ctx context.Context, userID string) ([]dto.URLBatchResponseByUserID, error)
+	GetAllURLs(ctx context.Context, query dto.URLQuery) (*dto.URLRecordPage, error)
+	GetAllByUserID(ctx context.Context, userID string, query dto.URLQuery) (*dto.URLPage, error)
+	Export(ctx context.Context, userID string, filter model.URLFilter, fn func(dto.URLBatchResponseByUserID) error) error
 	DeleteAll(ctx context.Context) error
 	DeleteURLByUserID(ctx context.Context, userID string, shortURLs string) error
-	GetByyID(ctx context.Context, key string) (string, error)
+	RestoreURLByUserID(ctx context.Context, userID string, shortURL string) error
+	UpdateURL(ctx context.Context, userID string, id string, original string) (*model.URL, error)
+	UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings) (*model.URL, error)
+	GetRedirect(ctx context.Context, key string, visit model.Visit) (*model.Redirect, error)
+	GetRules(ctx context.Context, userID string, id string) ([]model.RedirectRule, error)
+	SetRules(ctx context.Context, userID string, id string, rules []model.RedirectRule) ([]model.RedirectRule, error)
+	AddRule(ctx context.Context, userID string, id string, rule model.RedirectRule) (*model.RedirectRule, error)
+	UpdateRule(ctx context.Context, userID string, id string, ruleID string, rule model.RedirectRule) (*model.RedirectRule, error)
+	DeleteRule(ctx context.Context, userID string, id string, ruleID string) error
+	GetCountryRedirects(ctx context.Context, userID string, id string) ([]model.CountryRedirects, error)
 	GetStats(ctx context.Context) (*dto.URLStats, error)
 	Ping(ctx context.Context) error
 }
 
 // NewURLShorten creates a new gRPC URLShorten instance
-func NewURLShorten(service URLShortenerService, urlPrefix string, trustedSubnet string, jwtManager *jwtgen.JWTManager, logger *zap.Logger, wg *sync.WaitGroup) *URLShorten {
+//
+// Client IP of requests is resolved by clientIP from the peer address and metadata of trusted proxies.
+func NewURLShorten(service URLShortenerService, urlPrefix string, trustedSubnets clientip.Subnets, clientIP *clientip.Resolver, legacyListing bool, jwtManager *jwtgen.JWTManager, logger *zap.Logger, wg *sync.WaitGroup) *URLShorten {
 	handler := &URLShorten{
-		urlService:    service,
-		urlPrefix:     urlPrefix,
-		trustedSubnet: trustedSubnet,
-		jwtManager:    jwtManager,
-		logger:        logger,
-		wg:            wg,
+		urlService:     service,
+		urlPrefix:      urlPrefix,
+		trustedSubnets: trustedSubnets,
+		clientIP:       clientIP,
+		legacyListing:  legacyListing,
+		jwtManager:     jwtManager,
+		logger:         logger,
+		wg:             wg,
 	}
 
 	return handler
 }
 
 // GetListURLs handles gRPC GetListURLs request
-func (h *URLShorten) GetListURLs(ctx context.Context, _ *pb.GetListURLsRequest) (*pb.GetListURLsResponse, error) {
+//
+// Returns a page of URL records of all users to trusted subnet callers,
+// in legacy listing mode returns all original URLs to anyone.
+func (h *URLShorten) GetListURLs(ctx context.Context, in *pb.GetListURLsRequest) (*pb.GetListURLsResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
+	if h.legacyListing {
+		return h.getListURLsLegacy(ctx, md)
+	}
+
+	if err := h.checkTrustedSubnet(ctx); err != nil {
+		return nil, err
+	}
+
+	query := dto.URLQuery{
+		Limit:     int(in.Limit),
+		PageToken: in.PageToken,
+		Sort:      in.Sort,
+		Deleted:   in.Deleted,
+		Search:    in.Search,
+		UserID:    in.UserId,
+	}
+	if in.CreatedFrom != nil {
+		query.CreatedFrom = in.CreatedFrom.AsTime()
+	}
+	if in.CreatedTo != nil {
+		query.CreatedTo = in.CreatedTo.AsTime()
+	}
+
+	page, err := h.urlService.GetAllURLs(ctx, query)
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("GRPCBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	if err != nil {
+		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	records := make([]*pb.URLRecord, 0, len(page.URLs))
+	for _, url := range page.URLs {
+		records = append(records, &pb.URLRecord{
+			Id:            url.ID,
+			OriginalUrl:   url.OriginalURL,
+			ShortUrl:      url.ShortURL,
+			CorrelationId: url.CorrelationID,
+			UserId:        url.UserID,
+			DeletedFlag:   url.DeletedFlag,
+			CreatedAt:     timestamppb.New(url.CreatedAt),
+		})
+	}
+
+	return &pb.GetListURLsResponse{Records: records, NextPageToken: page.NextPageToken}, nil
+}
+
+// getListURLsLegacy returns all original URLs.
+func (h *URLShorten) getListURLsLegacy(ctx context.Context, md metadata.MD) (*pb.GetListURLsResponse, error) {
 	urls, err := h.urlService.GetAll(ctx)
 	if err != nil {
-		h.logger.Info("GetUrls", zap.Error(err))
-		return nil, err
+		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
 	}
 
 	response := &pb.GetListURLsResponse{
@@ -81,7 +155,7 @@ func (h *URLShorten) GetListURLs(ctx context.Context, _ *pb.GetListURLsRequest)
 	err = grpc.SendHeader(ctx, md)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return response, nil
@@ -91,35 +165,35 @@ func (h *URLShorten) GetListURLs(ctx context.Context, _ *pb.GetListURLsRequest)
 func (h *URLShorten) PostURL(ctx context.Context, in *pb.PostURLRequest) (*pb.PostURLResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
 	if in.Url == "" {
-		h.logger.Info("GRPCBadRequest", zap.Error(status.Error(codes.InvalidArgument, "empty url")))
-		return nil, status.Error(codes.InvalidArgument, "empty url")
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("url", "must not be empty")))
+		return nil, apierr.Field("url", "must not be empty")
 	}
 
 	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	url, err := h.urlService.Add(ctx, in.Url, h.urlPrefix, userID)
 	if err != nil && !errors.Is(err, urlErr.ErrURLAlreadyExists) {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	if errors.Is(err, urlErr.ErrURLAlreadyExists) {
 		h.logger.Warn("GRPCConflict: url already exists", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return &pb.PostURLResponse{ShortUrl: url.Shortened}, status.Error(codes.AlreadyExists, "url already exists")
+		return &pb.PostURLResponse{ShortUrl: url.Shortened}, apierr.FromError(err)
 	}
 
 	err = grpc.SendHeader(ctx, md)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return &pb.PostURLResponse{ShortUrl: url.Shortened}, nil
@@ -129,12 +203,12 @@ func (h *URLShorten) PostURL(ctx context.Context, in *pb.PostURLRequest) (*pb.Po
 func (h *URLShorten) PostBatchURLs(ctx context.Context, in *pb.PostBatchURLRequest) (*pb.PostBatchURLResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
 	if len(in.BatchUrls) == 0 {
-		h.logger.Info("GRPCBadRequest", zap.Error(status.Error(codes.InvalidArgument, "empty batch urls")))
-		return nil, status.Error(codes.InvalidArgument, "empty batch urls")
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("batch_urls", "must not be empty")))
+		return nil, apierr.Field("batch_urls", "must not be empty")
 	}
 
 	urls := make([]dto.URLBatchRequest, 0, len(in.BatchUrls))
@@ -148,13 +222,18 @@ func (h *URLShorten) PostBatchURLs(ctx context.Context, in *pb.PostBatchURLReque
 	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	savedURLs, err := h.urlService.AddAll(ctx, urls, h.urlPrefix, userID, in.Atomic)
+	if errors.Is(err, urlErr.ErrDuplicatedKeys) || errors.Is(err, urlErr.ErrInvalidBatchItem) {
+		h.logger.Info("GRPCBadRequest: invalid batch", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
 	}
 
-	savedURLs, err := h.urlService.AddAll(ctx, urls, h.urlPrefix, userID)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	batchURLs := make([]*pb.BatchURLResponse, 0, len(savedURLs))
@@ -162,53 +241,129 @@ func (h *URLShorten) PostBatchURLs(ctx context.Context, in *pb.PostBatchURLReque
 		batchURLs = append(batchURLs, &pb.BatchURLResponse{
 			CorrelationId: v.CorrelationID,
 			ShortenedURL:  v.ShortenedURL,
+			Status:        v.Status,
+			Error:         v.Error,
 		})
 	}
 
 	err = grpc.SendHeader(ctx, md)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return &pb.PostBatchURLResponse{BatchUrls: batchURLs}, nil
 }
 
-// GetURL handles gRPC GetURL request
+// ImportURLs handles gRPC client-streaming ImportURLs request
+//
+// Rows are saved in chunks while messages are received, summary is sent when client closes the stream.
+func (h *URLShorten) ImportURLs(stream pb.URLShortener_ImportURLsServer) error {
+	ctx := stream.Context()
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.New(apierr.CodeInternal, "")
+	}
+
+	var rows []*pb.BatchURLRequest
+	next := func() (dto.URLBatchRequest, error) {
+		for len(rows) == 0 {
+			in, err := stream.Recv()
+			if err != nil {
+				return dto.URLBatchRequest{}, err
+			}
+			rows = in.Urls
+		}
+
+		row := rows[0]
+		rows = rows[1:]
+		return dto.URLBatchRequest{
+			CorrelationID: row.CorrelationId,
+			OriginalURL:   row.OriginalUrl,
+		}, nil
+	}
+
+	summary, err := h.urlService.Import(ctx, next, h.urlPrefix, userID)
+	if err != nil {
+		h.logger.Error("GRPCInternalServerError: import failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.New(apierr.CodeInternal, "")
+	}
+
+	importErrors := make([]*pb.ImportURLError, 0, len(summary.Errors))
+	for _, v := range summary.Errors {
+		importErrors = append(importErrors, &pb.ImportURLError{
+			Row:           uint32(v.Row),
+			CorrelationId: v.CorrelationID,
+			Error:         v.Error,
+		})
+	}
+
+	return stream.SendAndClose(&pb.ImportURLsResponse{
+		Total:    uint32(summary.Total),
+		Imported: uint32(summary.Imported),
+		Existing: uint32(summary.Existing),
+		Failed:   uint32(summary.Failed),
+		Errors:   importErrors,
+	})
+}
+
+// GetURL handles gRPC GetURL request, the URL is chosen by redirect rules and built by query rules of the short URL
+// for the visit described by the request.
 func (h *URLShorten) GetURL(ctx context.Context, in *pb.GetURLRequest) (*pb.GetURLResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
 	if in.ShortUrl == "" {
-		h.logger.Info("GRPCBadRequest", zap.Error(status.Error(codes.InvalidArgument, "empty url")))
-		return nil, status.Error(codes.InvalidArgument, "empty url")
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("url", "must not be empty")))
+		return nil, apierr.Field("url", "must not be empty")
 	}
 
-	originalURL, err := h.urlService.GetByyID(ctx, in.ShortUrl)
+	query, err := url.ParseQuery(in.Query)
+	if err != nil {
+		h.logger.Info("GRPCBadRequest", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.Field("query", "must be valid URL query")
+	}
+
+	clientIP := h.clientIP.FromContext(ctx)
+	visit := model.Visit{
+		Query:          query,
+		UserAgent:      in.UserAgent,
+		AcceptLanguage: in.AcceptLanguage,
+		Referrer:       in.Referrer,
+		ClientID:       clientIP,
+		IP:             clientIP,
+	}
+
+	redirect, err := h.urlService.GetRedirect(ctx, in.ShortUrl, visit)
 
 	switch {
 	case errors.Is(err, urlErr.ErrURLNotFound):
 		h.logger.Info("StatusBadRequest: url not found", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.NotFound, fmt.Sprintf("URL with id %s not found", in.ShortUrl))
+		return nil, apierr.New(apierr.CodeURLNotFound, fmt.Sprintf("URL with id %s not found", in.ShortUrl))
 
 	case errors.Is(err, urlErr.ErrURLDeleted):
 		h.logger.Info("StatusBadRequest: url not found", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Unavailable, fmt.Sprintf("URL with id %s has been deleted", in.ShortUrl))
+		return nil, apierr.New(apierr.CodeURLDeleted, fmt.Sprintf("URL with id %s has been deleted", in.ShortUrl))
+
+	case errors.Is(err, urlErr.ErrCountryBlocked):
+		h.logger.Info("GRPCPermissionDenied: url blocked in visitor country", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
 
 	case err != nil:
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
-	md.Append("Location", originalURL)
+	md.Append("Location", redirect.Location)
 	err = grpc.SendHeader(ctx, md)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
-	return &pb.GetURLResponse{Url: originalURL}, nil
+	return &pb.GetURLResponse{Url: redirect.Location}, nil
 }
 
 // Ping handles gRPC Ping request
@@ -216,7 +371,7 @@ func (h *URLShorten) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingRespo
 	err := h.urlService.Ping(ctx)
 	if err != nil {
 		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal database error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return &pb.PingResponse{}, nil
@@ -226,40 +381,76 @@ func (h *URLShorten) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingRespo
 func (h *URLShorten) DeleteAllURLs(ctx context.Context, _ *pb.DeleteAllURLsRequest) (*pb.DeleteAllURLsResponse, error) {
 	if err := h.urlService.DeleteAll(ctx); err != nil {
 		h.logger.Error("StatusInternalServerError: Unknown error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return &pb.DeleteAllURLsResponse{}, nil
 }
 
 // GetURLsByUserID handles gRPC GetURLsByUserID request
-func (h *URLShorten) GetURLsByUserID(ctx context.Context, _ *pb.GetURLsByUserIDRequest) (*pb.GetURLsByUserIDResponse, error) {
+func (h *URLShorten) GetURLsByUserID(ctx context.Context, in *pb.GetURLsByUserIDRequest) (*pb.GetURLsByUserIDResponse, error) {
 	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	page, err := h.urlService.GetAllByUserID(ctx, userID, dto.URLQuery{
+		Limit:     int(in.Limit),
+		PageToken: in.PageToken,
+		Sort:      in.Sort,
+		Deleted:   in.Deleted,
+		Search:    in.Search,
+		Unpaged:   true,
+	})
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("GRPCBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
 	}
 
-	savedURLs, err := h.urlService.GetAllByUserID(ctx, userID)
 	if err != nil && !errors.Is(err, urlErr.ErrURLNotFound) {
 		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	if errors.Is(err, urlErr.ErrURLNotFound) {
 		h.logger.Warn("StatusNoContent: urls not found", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.NotFound, "urls not found")
+		return nil, apierr.FromError(err)
 	}
 
-	urls := make([]*pb.URLByUserID, 0, len(savedURLs))
-	for _, url := range savedURLs {
+	urls := make([]*pb.URLByUserID, 0, len(page.URLs))
+	for _, url := range page.URLs {
 		urls = append(urls, &pb.URLByUserID{
 			ShortUrl:    url.ShortURL,
 			OriginalUrl: url.OriginalURL,
 			DeletedFlag: url.DeletedFlag,
 		})
 	}
-	return &pb.GetURLsByUserIDResponse{Urls: urls}, nil
+	return &pb.GetURLsByUserIDResponse{Urls: urls, NextPageToken: page.NextPageToken}, nil
+}
+
+// ExportURLs handles gRPC server-streaming ExportURLs request
+func (h *URLShorten) ExportURLs(in *pb.ExportURLsRequest, stream pb.URLShortener_ExportURLsServer) error {
+	ctx := stream.Context()
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.New(apierr.CodeInternal, "")
+	}
+
+	err := h.urlService.Export(ctx, userID, model.URLFilter{Deleted: in.Deleted, Search: in.Search}, func(url dto.URLBatchResponseByUserID) error {
+		return stream.Send(&pb.URLByUserID{
+			ShortUrl:    url.ShortURL,
+			OriginalUrl: url.OriginalURL,
+			DeletedFlag: url.DeletedFlag,
+		})
+	})
+	if err != nil {
+		h.logger.Error("GRPCInternalServerError: export failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.New(apierr.CodeInternal, "")
+	}
+
+	return nil
 }
 
 // DeleteURLsByUserID handles gRPC DeleteURLsByUserID request
@@ -267,62 +458,122 @@ func (h *URLShorten) DeleteURLsByUserID(ctx context.Context, in *pb.DeleteURLsBy
 	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	if len(in.ShortUrls) == 0 {
-		h.logger.Info("GRPCBadRequest", zap.Error(status.Error(codes.InvalidArgument, "empty batch urls")))
-		return nil, status.Error(codes.InvalidArgument, "empty batch urls")
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("batch_urls", "must not be empty")))
+		return nil, apierr.Field("batch_urls", "must not be empty")
 	}
 
-	workerPool := workerpool.NewWorkerPool(100, h.logger)
-	workerPool.Start()
-	defer workerPool.Stop()
-
-	h.wg.Add(len(in.ShortUrls))
-	for _, shortURL := range in.ShortUrls {
-		log.Info("Submitting task", zap.String("delete shortURL", shortURL))
-		url := shortURL
-		userID := userID
-		workerPool.Submit(func() error {
-			defer h.wg.Done()
-			return h.urlService.DeleteURLByUserID(context.WithoutCancel(ctx), userID, url)
-		})
-	}
+	h.submitUserURLs(ctx, userID, in.ShortUrls, "delete", h.urlService.DeleteURLByUserID)
 
 	return &pb.DeleteURLsByUserIDResponse{}, nil
 }
 
-// GetStats handles gRPC GetStats request
-func (h *URLShorten) GetStats(ctx context.Context, _ *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
-	if h.trustedSubnet == "" {
-		return nil, status.Error(codes.PermissionDenied, "stats not available without trusted subnet")
+// RestoreURLsByUserID handles gRPC RestoreURLsByUserID request
+func (h *URLShorten) RestoreURLsByUserID(ctx context.Context, in *pb.RestoreURLsByUserIDRequest) (*pb.RestoreURLsByUserIDResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
-	md, ok := metadata.FromIncomingContext(ctx)
+	if len(in.ShortUrls) == 0 {
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("short_urls", "must not be empty")))
+		return nil, apierr.Field("short_urls", "must not be empty")
+	}
+
+	h.submitUserURLs(ctx, userID, in.ShortUrls, "restore", h.urlService.RestoreURLByUserID)
+
+	return &pb.RestoreURLsByUserIDResponse{}, nil
+}
+
+// UpdateURL handles gRPC UpdateURL request
+func (h *URLShorten) UpdateURL(ctx context.Context, in *pb.UpdateURLRequest) (*pb.UpdateURLResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
-	ip := md.Get("X-Real-IP")
-	if len(ip) == 0 {
-		return nil, status.Errorf(codes.InvalidArgument, "missing X-Real-IP")
+	url, err := h.urlService.UpdateURL(ctx, userID, in.Id, in.OriginalUrl)
+	if errors.Is(err, urlErr.ErrInvalidURL) {
+		h.logger.Info("GRPCBadRequest: invalid url", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.Field("original_url", "must be valid URL")
 	}
 
-	_, ipNet, err := net.ParseCIDR(h.trustedSubnet)
 	if err != nil {
+		h.logger.Warn("unable to update url", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return &pb.UpdateURLResponse{ShortUrl: url.Shortened, OriginalUrl: url.Original}, nil
+}
+
+// UpdateRedirect handles gRPC UpdateRedirect request
+func (h *URLShorten) UpdateRedirect(ctx context.Context, in *pb.UpdateRedirectRequest) (*pb.UpdateRedirectResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	updated, err := h.urlService.UpdateRedirect(ctx, userID, in.Id, model.RedirectSettings{
+		Code:         int(in.RedirectCode),
+		CacheControl: in.CacheControl,
+		QueryRules: model.QueryRules{
+			Params:      in.QueryParams,
+			PassThrough: in.QueryPassThrough,
+		},
+		CountryAccess: model.CountryAccess{
+			Allow: in.AllowedCountries,
+			Deny:  in.DeniedCountries,
+		},
+	})
+	if err != nil {
+		h.logger.Warn("unable to update redirect", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return &pb.UpdateRedirectResponse{
+		ShortUrl:         updated.Shortened,
+		RedirectCode:     uint32(updated.RedirectCode),
+		CacheControl:     updated.CacheControl,
+		QueryParams:      updated.QueryRules.Params,
+		QueryPassThrough: updated.QueryRules.PassThrough,
+		AllowedCountries: updated.CountryAccess.Allow,
+		DeniedCountries:  updated.CountryAccess.Deny,
+	}, nil
+}
+
+// submitUserURLs processes URLs of the user by the worker pool, processing outlives the request.
+func (h *URLShorten) submitUserURLs(ctx context.Context, userID string, shortURLs []string, action string, process func(ctx context.Context, userID string, shortURL string) error) {
+	workerPool := workerpool.NewWorkerPool(100, h.logger)
+	workerPool.Start()
+	defer workerPool.Stop()
+
+	h.wg.Add(len(shortURLs))
+	for _, shortURL := range shortURLs {
+		log.Info("Submitting task", zap.String(action+" shortURL", shortURL))
+		url := shortURL
+		workerPool.Submit(func() error {
+			defer h.wg.Done()
+			return process(context.WithoutCancel(ctx), userID, url)
+		})
 	}
+}
 
-	if !ipNet.Contains(net.ParseIP(ip[0])) {
-		return nil, status.Error(codes.PermissionDenied, "internal error")
+// GetStats handles gRPC GetStats request
+func (h *URLShorten) GetStats(ctx context.Context, _ *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
+	if err := h.checkTrustedSubnet(ctx); err != nil {
+		return nil, err
 	}
 
 	stats, err := h.urlService.GetStats(ctx)
 	if err != nil {
 		h.logger.Error("StatusInternalServerError: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return nil, status.Error(codes.Internal, "internal error")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
 
 	return &pb.GetStatsResponse{
@@ -330,3 +581,12 @@ func (h *URLShorten) GetStats(ctx context.Context, _ *pb.GetStatsRequest) (*pb.G
 		Users: uint32(stats.Users),
 	}, nil
 }
+
+// checkTrustedSubnet returns status error unless client IP of the request belongs to trusted subnets.
+func (h *URLShorten) checkTrustedSubnet(ctx context.Context) error {
+	if !h.trustedSubnets.Contains(h.clientIP.FromContext(ctx)) {
+		return apierr.New(apierr.CodePermissionDenied, "available from trusted subnet only")
+	}
+
+	return nil
+}
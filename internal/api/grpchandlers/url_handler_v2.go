@@ -0,0 +1,130 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/internal/middleware"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	pbv2 "github.com/msmkdenis/yap-shortener/internal/proto/v2"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// URLShortenV2 implements proto.v2 URLShortener service.
+type URLShortenV2 struct {
+	urlService URLShortenerService
+	urlPrefix  string
+	logger     *zap.Logger
+	pbv2.UnimplementedURLShortenerServer
+}
+
+// NewURLShortenV2 creates a new gRPC URLShortenV2 instance
+func NewURLShortenV2(service URLShortenerService, urlPrefix string, logger *zap.Logger) *URLShortenV2 {
+	handler := &URLShortenV2{
+		urlService: service,
+		urlPrefix:  urlPrefix,
+		logger:     logger,
+	}
+
+	return handler
+}
+
+// CreateURL handles gRPC v2 CreateURL request
+//
+// Returns the existing URL with created set to false if the URL is already shortened.
+func (h *URLShortenV2) CreateURL(ctx context.Context, in *pbv2.CreateURLRequest) (*pbv2.CreateURLResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
+	}
+
+	if in.OriginalUrl == "" {
+		h.logger.Info("GRPCBadRequest", zap.Error(apierr.Field("original_url", "must not be empty")))
+		return nil, apierr.Field("original_url", "must not be empty")
+	}
+
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	url, err := h.urlService.Add(ctx, in.OriginalUrl, h.urlPrefix, userID)
+	if err != nil && !errors.Is(err, urlErr.ErrURLAlreadyExists) {
+		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	created := err == nil
+
+	if err = grpc.SendHeader(ctx, md); err != nil {
+		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	return &pbv2.CreateURLResponse{Url: newURLV2(url), Created: created}, nil
+}
+
+// ListURLs handles gRPC v2 ListURLs request
+//
+// Returns a page of URLs of the user, the page is empty if user has no URLs.
+func (h *URLShortenV2) ListURLs(ctx context.Context, in *pbv2.ListURLsRequest) (*pbv2.ListURLsResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	page, err := h.urlService.GetAllURLs(ctx, dto.URLQuery{
+		Limit:     int(in.Limit),
+		PageToken: in.PageToken,
+		Sort:      in.Sort,
+		Deleted:   in.Deleted,
+		Search:    in.Search,
+		UserID:    userID,
+	})
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("GRPCBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	if err != nil {
+		h.logger.Error("GRPCInternalServerError: internal error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	urls := make([]*pbv2.URL, 0, len(page.URLs))
+	for _, record := range page.URLs {
+		urls = append(urls, &pbv2.URL{
+			Id:            record.ID,
+			ShortUrl:      record.ShortURL,
+			OriginalUrl:   record.OriginalURL,
+			CorrelationId: record.CorrelationID,
+			Deleted:       record.DeletedFlag,
+			CreatedAt:     timestamppb.New(record.CreatedAt),
+		})
+	}
+
+	return &pbv2.ListURLsResponse{Urls: urls, NextPageToken: page.NextPageToken}, nil
+}
+
+// newURLV2 converts URL model to proto.v2 URL.
+func newURLV2(url *model.URL) *pbv2.URL {
+	return &pbv2.URL{
+		Id:            url.ID,
+		ShortUrl:      url.Shortened,
+		OriginalUrl:   url.Original,
+		CorrelationId: url.CorrelationID,
+		Deleted:       url.DeletedFlag,
+		CreatedAt:     timestamppb.New(url.CreatedAt),
+	}
+}
@@ -0,0 +1,167 @@
+package grpchandlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/middleware"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// ListRules handles gRPC ListRules request
+func (h *URLShorten) ListRules(ctx context.Context, in *pb.ListRulesRequest) (*pb.ListRulesResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	rules, err := h.urlService.GetRules(ctx, userID, in.Id)
+	if err != nil {
+		h.logger.Warn("unable to get rules", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return &pb.ListRulesResponse{Rules: toPBRules(rules)}, nil
+}
+
+// SetRules handles gRPC SetRules request
+func (h *URLShorten) SetRules(ctx context.Context, in *pb.SetRulesRequest) (*pb.SetRulesResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	rules := make([]model.RedirectRule, len(in.Rules))
+	for i, rule := range in.Rules {
+		rules[i] = toRedirectRule(rule)
+	}
+
+	rules, err := h.urlService.SetRules(ctx, userID, in.Id, rules)
+	if err != nil {
+		h.logger.Warn("unable to set rules", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return &pb.SetRulesResponse{Rules: toPBRules(rules)}, nil
+}
+
+// AddRule handles gRPC AddRule request
+func (h *URLShorten) AddRule(ctx context.Context, in *pb.AddRuleRequest) (*pb.RedirectRule, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	if in.Rule == nil {
+		return nil, apierr.Field("rule", "must be set")
+	}
+
+	rule, err := h.urlService.AddRule(ctx, userID, in.Id, toRedirectRule(in.Rule))
+	if err != nil {
+		h.logger.Warn("unable to add rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return toPBRule(*rule), nil
+}
+
+// UpdateRule handles gRPC UpdateRule request
+func (h *URLShorten) UpdateRule(ctx context.Context, in *pb.UpdateRuleRequest) (*pb.RedirectRule, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	if in.Rule == nil {
+		return nil, apierr.Field("rule", "must be set")
+	}
+
+	rule, err := h.urlService.UpdateRule(ctx, userID, in.Id, in.RuleId, toRedirectRule(in.Rule))
+	if err != nil {
+		h.logger.Warn("unable to update rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return toPBRule(*rule), nil
+}
+
+// DeleteRule handles gRPC DeleteRule request
+func (h *URLShorten) DeleteRule(ctx context.Context, in *pb.DeleteRuleRequest) (*pb.DeleteRuleResponse, error) {
+	userID, ok := ctx.Value(middleware.UserIDContextKey("userID")).(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	if err := h.urlService.DeleteRule(ctx, userID, in.Id, in.RuleId); err != nil {
+		h.logger.Warn("unable to delete rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return nil, apierr.FromError(err)
+	}
+
+	return &pb.DeleteRuleResponse{}, nil
+}
+
+// toRedirectRule converts redirect rule of the request to the model.
+func toRedirectRule(rule *pb.RedirectRule) model.RedirectRule {
+	redirectRule := model.RedirectRule{
+		ID:            rule.Id,
+		Destination:   rule.Destination,
+		Devices:       rule.Devices,
+		Languages:     rule.Languages,
+		ReferrerHosts: rule.ReferrerHosts,
+		Percent:       int(rule.Percent),
+	}
+	if rule.From != nil {
+		from := rule.From.AsTime()
+		redirectRule.From = &from
+	}
+	if rule.Until != nil {
+		until := rule.Until.AsTime()
+		redirectRule.Until = &until
+	}
+
+	return redirectRule
+}
+
+// toPBRule converts redirect rule of the model to the response.
+func toPBRule(rule model.RedirectRule) *pb.RedirectRule {
+	return &pb.RedirectRule{
+		Id:            rule.ID,
+		Destination:   rule.Destination,
+		Devices:       rule.Devices,
+		Languages:     rule.Languages,
+		ReferrerHosts: rule.ReferrerHosts,
+		From:          toTimestamp(rule.From),
+		Until:         toTimestamp(rule.Until),
+		Percent:       uint32(rule.Percent),
+	}
+}
+
+// toPBRules converts redirect rules of the model to the response.
+func toPBRules(rules []model.RedirectRule) []*pb.RedirectRule {
+	pbRules := make([]*pb.RedirectRule, len(rules))
+	for i, rule := range rules {
+		pbRules[i] = toPBRule(rule)
+	}
+	return pbRules
+}
+
+// toTimestamp converts optional time to timestamp, nil time is nil timestamp.
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,63 @@
+package httphandlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+)
+
+// exportFlushRows is a number of exported rows written before response is flushed to client.
+const exportFlushRows = 100
+
+// exportEncoder writes exported URLs to response one by one.
+type exportEncoder interface {
+	Encode(url dto.URLBatchResponseByUserID) error
+	Flush() error
+}
+
+// ndjsonEncoder writes URLs as newline delimited JSON.
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{encoder: json.NewEncoder(w)}
+}
+
+// Encode writes URL as a single JSON line.
+func (e *ndjsonEncoder) Encode(url dto.URLBatchResponseByUserID) error {
+	return e.encoder.Encode(url)
+}
+
+// Flush does nothing, json.Encoder writes every line immediately.
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+// csvEncoder writes URLs as CSV with short_url, original_url and deleted_flag columns.
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{writer: csv.NewWriter(w)}
+}
+
+// WriteHeader writes CSV header record.
+func (e *csvEncoder) WriteHeader() error {
+	return e.writer.Write([]string{"short_url", "original_url", "deleted_flag"})
+}
+
+// Encode writes URL as a single CSV record.
+func (e *csvEncoder) Encode(url dto.URLBatchResponseByUserID) error {
+	return e.writer.Write([]string{url.ShortURL, url.OriginalURL, strconv.FormatBool(url.DeletedFlag)})
+}
+
+// Flush writes buffered records to the underlying writer.
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -0,0 +1,85 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+// maxImportLineSize limits size of a single NDJSON line.
+const maxImportLineSize = 1024 * 1024
+
+// ndjsonSource returns rows read from newline delimited JSON, one dto.URLBatchRequest per line.
+//
+// Empty lines are skipped, malformed lines are reported as invalid rows.
+func ndjsonSource(r io.Reader) func() (dto.URLBatchRequest, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxImportLineSize)
+
+	return func() (dto.URLBatchRequest, error) {
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			var row dto.URLBatchRequest
+			if err := json.Unmarshal(line, &row); err != nil {
+				return dto.URLBatchRequest{}, fmt.Errorf("%w: malformed json", urlErr.ErrInvalidImportRow)
+			}
+			return row, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return dto.URLBatchRequest{}, err
+		}
+		return dto.URLBatchRequest{}, io.EOF
+	}
+}
+
+// csvSource returns rows read from CSV with correlation_id and original_url columns.
+//
+// The first record is skipped when it is a header.
+func csvSource(r io.Reader) func() (dto.URLBatchRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	first := true
+
+	return func() (dto.URLBatchRequest, error) {
+		for {
+			record, err := reader.Read()
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return dto.URLBatchRequest{}, fmt.Errorf("%w: %s", urlErr.ErrInvalidImportRow, parseErr.Err)
+			}
+			if err != nil {
+				return dto.URLBatchRequest{}, err
+			}
+
+			if first {
+				first = false
+				if len(record) == 2 && strings.TrimSpace(record[0]) == "correlation_id" && strings.TrimSpace(record[1]) == "original_url" {
+					continue
+				}
+			}
+
+			if len(record) != 2 {
+				return dto.URLBatchRequest{}, fmt.Errorf("%w: expected 2 fields, got %d", urlErr.ErrInvalidImportRow, len(record))
+			}
+
+			return dto.URLBatchRequest{
+				CorrelationID: strings.TrimSpace(record[0]),
+				OriginalURL:   strings.TrimSpace(record[1]),
+			}, nil
+		}
+	}
+}
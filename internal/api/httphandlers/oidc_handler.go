@@ -0,0 +1,180 @@
+package httphandlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+	"github.com/msmkdenis/yap-shortener/pkg/oidc"
+)
+
+const (
+	loginCookieName   = "oidc_login"
+	loginCookiePath   = "/api/auth/oidc"
+	loginCookieMaxAge = 600
+)
+
+// OIDCProvider represents OpenID Connect provider interface.
+type OIDCProvider interface {
+	Issuer() string
+	AuthCodeURL(state, nonce, codeChallenge string) string
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*oidc.IDTokenClaims, error)
+}
+
+// OIDCAuth represents OpenID Connect login handler struct.
+type OIDCAuth struct {
+	provider   OIDCProvider
+	jwtManager *jwtgen.JWTManager
+	// secureCookies is set if the service is served over https, cookies are then sent over https only.
+	secureCookies bool
+	logger        *zap.Logger
+}
+
+// loginSession is kept in a short-lived cookie between login and callback requests.
+type loginSession struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// NewOIDCAuth creates a new OIDCAuth instance
+//
+// Registers OpenID Connect login handlers. Cookies are secure if the base URL is https or the request came over TLS.
+func NewOIDCAuth(e *echo.Echo, provider OIDCProvider, jwtManager *jwtgen.JWTManager, baseURL string, logger *zap.Logger) *OIDCAuth {
+	handler := &OIDCAuth{
+		provider:      provider,
+		jwtManager:    jwtManager,
+		secureCookies: strings.HasPrefix(strings.ToLower(baseURL), "https://"),
+		logger:        logger,
+	}
+
+	e.GET(loginCookiePath+"/login", handler.Login)
+	e.GET(loginCookiePath+"/callback", handler.Callback)
+
+	return handler
+}
+
+// Login redirects user agent to the provider authorization endpoint.
+func (h *OIDCAuth) Login(c echo.Context) error {
+	var session loginSession
+	for _, v := range []*string{&session.State, &session.Nonce, &session.Verifier} {
+		s, err := oidc.NewRandomString()
+		if err != nil {
+			h.logger.Error("StatusInternalServerError: unable to start login", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+			return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+		}
+		*v = s
+	}
+
+	value, err := json.Marshal(session)
+	if err != nil {
+		h.logger.Error("StatusInternalServerError: unable to start login", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     loginCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     loginCookiePath,
+		MaxAge:   loginCookieMaxAge,
+		Secure:   h.secure(c),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.Redirect(http.StatusFound, h.provider.AuthCodeURL(session.State, session.Nonce, oidc.CodeChallengeS256(session.Verifier)))
+}
+
+// Callback exchanges authorization code, validates ID token and issues shortener token.
+func (h *OIDCAuth) Callback(c echo.Context) error {
+	if errParam := c.QueryParam("error"); errParam != "" {
+		h.logger.Info("StatusUnauthorized: provider returned error", zap.String("error", errParam))
+		return apierr.Write(c, apierr.New(apierr.CodeUnauthenticated, "login failed: "+errParam))
+	}
+
+	session, err := h.loginSession(c)
+	if err != nil || session.State != c.QueryParam("state") {
+		h.logger.Info("StatusBadRequest: invalid login state", zap.Error(err))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "invalid login state"))
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     loginCookieName,
+		Path:     loginCookiePath,
+		MaxAge:   -1,
+		Secure:   h.secure(c),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	rawIDToken, err := h.provider.Exchange(c.Request().Context(), c.QueryParam("code"), session.Verifier)
+	if err != nil {
+		h.logger.Warn("StatusUnauthorized: code exchange failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeUnauthenticated, "login failed"))
+	}
+
+	claims, err := h.provider.VerifyIDToken(c.Request().Context(), rawIDToken, session.Nonce)
+	if err != nil {
+		h.logger.Warn("StatusUnauthorized: id token verification failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeUnauthenticated, "login failed"))
+	}
+
+	userID := UserIDFromSubject(h.provider.Issuer(), claims.Subject)
+	token, err := h.jwtManager.BuildJWTStringWithUserID(userID)
+	if err != nil {
+		h.logger.Error("StatusInternalServerError: unable to create token", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     h.jwtManager.TokenName,
+		Value:    token,
+		Path:     "/",
+		Secure:   h.secure(c),
+		HttpOnly: true,
+	})
+	h.logger.Info("logged in with OIDC", zap.String("userID", userID))
+
+	return c.JSON(http.StatusOK, dto.AuthResponse{UserID: userID, Token: token})
+}
+
+// secure reports whether cookies of the request must be sent over https only.
+func (h *OIDCAuth) secure(c echo.Context) bool {
+	return h.secureCookies || c.IsTLS()
+}
+
+func (h *OIDCAuth) loginSession(c echo.Context) (*loginSession, error) {
+	cookie, err := c.Cookie(loginCookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var session loginSession
+	if err = json.Unmarshal(value, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// UserIDFromSubject maps provider subject to a stable shortener user ID.
+func UserIDFromSubject(issuer, subject string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(issuer+"#"+subject)).String()
+}
@@ -0,0 +1,136 @@
+package httphandlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+	"github.com/msmkdenis/yap-shortener/pkg/oidc"
+	"github.com/msmkdenis/yap-shortener/pkg/oidc/oidctest"
+)
+
+func TestOIDCAuth(t *testing.T) {
+	server := oidctest.NewServer("shortener", "secret", "user-42")
+	defer server.Close()
+
+	logger := zap.NewNop()
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "shortener",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/callback",
+	}, logger)
+	require.NoError(t, err)
+
+	jwtManager := jwtgen.InitJWTManager(cfgMock.TokenName, cfgMock.SecretKey, logger)
+	e := echo.New()
+	NewOIDCAuth(e, provider, jwtManager, "http://localhost:8080", logger)
+
+	login := func(t *testing.T) (*http.Cookie, string) {
+		request := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", http.NoBody)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		require.Equal(t, http.StatusFound, w.Code)
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		defer w.Result().Body.Close()
+
+		callback, loginErr := server.Login(w.Header().Get("Location"))
+		require.NoError(t, loginErr)
+		return cookies[0], callback.RequestURI()
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		cookie, callback := login(t)
+
+		request := httptest.NewRequest(http.MethodGet, callback, http.NoBody)
+		request.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response dto.AuthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, UserIDFromSubject(server.URL, "user-42"), response.UserID)
+
+		userID, err := jwtManager.GetUserID(response.Token)
+		require.NoError(t, err)
+		assert.Equal(t, response.UserID, userID)
+	})
+
+	t.Run("Cookies", func(t *testing.T) {
+		cookie, callback := login(t)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.False(t, cookie.Secure, "cookies of http service must not be secure")
+
+		request := httptest.NewRequest(http.MethodGet, callback, http.NoBody)
+		request.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		require.Equal(t, http.StatusOK, w.Code)
+		for _, cookie := range w.Result().Cookies() {
+			assert.True(t, cookie.HttpOnly, cookie.Name)
+			assert.False(t, cookie.Secure, cookie.Name)
+		}
+	})
+
+	t.Run("Secure cookies", func(t *testing.T) {
+		secureEcho := echo.New()
+		NewOIDCAuth(secureEcho, provider, jwtManager, "https://short.example.com", logger)
+		tlsEcho := echo.New()
+		NewOIDCAuth(tlsEcho, provider, jwtManager, "http://localhost:8080", logger)
+
+		for name, test := range map[string]struct {
+			echo    *echo.Echo
+			request *http.Request
+		}{
+			"https base URL": {echo: secureEcho, request: httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", http.NoBody)},
+			"TLS request":    {echo: tlsEcho, request: httptest.NewRequest(http.MethodGet, "https://localhost:8080/api/auth/oidc/login", http.NoBody)},
+		} {
+			w := httptest.NewRecorder()
+			test.echo.ServeHTTP(w, test.request)
+			require.Equal(t, http.StatusFound, w.Code, name)
+
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1, name)
+			assert.True(t, cookies[0].Secure, name)
+			assert.True(t, cookies[0].HttpOnly, name)
+			assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite, name)
+		}
+	})
+
+	t.Run("Same subject maps to same user", func(t *testing.T) {
+		assert.Equal(t, UserIDFromSubject(server.URL, "user-42"), UserIDFromSubject(server.URL, "user-42"))
+		assert.NotEqual(t, UserIDFromSubject(server.URL, "user-42"), UserIDFromSubject(server.URL, "user-43"))
+	})
+
+	t.Run("Missing login cookie", func(t *testing.T) {
+		_, callback := login(t)
+
+		request := httptest.NewRequest(http.MethodGet, callback, http.NoBody)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Provider error", func(t *testing.T) {
+		cookie, _ := login(t)
+
+		request := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?error=access_denied", http.NoBody)
+		request.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,36 @@
+package httphandlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// GetCountryRedirects returns redirects of the user's URL by country from the most redirected country.
+func (h *URLShorten) GetCountryRedirects(c echo.Context) error {
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	redirects, err := h.urlService.GetCountryRedirects(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		h.logger.Warn("unable to get country redirects", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	response := dto.CountryRedirects{Countries: make([]dto.CountryRedirect, len(redirects))}
+	for i, r := range redirects {
+		response.Countries[i] = dto.CountryRedirect{Country: r.Country, Redirects: r.Redirects}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
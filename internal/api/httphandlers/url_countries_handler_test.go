@@ -0,0 +1,76 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+func (s *URLHandlerTestSuite) TestGetCountryRedirects() {
+	testCases := []struct {
+		name         string
+		token        bool
+		prepare      func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success",
+			token:        true,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"countries": [{"country": "US", "redirects": 3}, {"country": "ZZ", "redirects": 1}]}`,
+			prepare: func() {
+				s.urlService.EXPECT().GetCountryRedirects(gomock.Any(), "token", "NjQyYTU").
+					Return([]model.CountryRedirects{{Country: "US", Redirects: 3}, {Country: model.UnknownCountry, Redirects: 1}}, nil)
+			},
+		},
+		{
+			name:         "Success - no redirects",
+			token:        true,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"countries": []}`,
+			prepare: func() {
+				s.urlService.EXPECT().GetCountryRedirects(gomock.Any(), "token", "NjQyYTU").Return(nil, nil)
+			},
+		},
+		{
+			name:         "NotFound",
+			token:        true,
+			expectedCode: http.StatusNotFound,
+			prepare: func() {
+				s.urlService.EXPECT().GetCountryRedirects(gomock.Any(), "token", "NjQyYTU").Return(nil, urlErr.ErrURLNotFound)
+			},
+		},
+		{
+			name:         "Unauthorized",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			}
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/user/urls/NjQyYTU/countries", http.NoBody)
+			if test.token {
+				s.setToken(request, "token")
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			s.ctrl.Finish()
+		})
+	}
+}
@@ -3,105 +3,200 @@ package httphandlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/yap-shortener/internal/api/openapi"
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/internal/dto"
 	"github.com/msmkdenis/yap-shortener/internal/middleware"
 	"github.com/msmkdenis/yap-shortener/internal/model"
 	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
 	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 	"github.com/msmkdenis/yap-shortener/pkg/workerpool"
 )
 
 const (
-	ContentTypeJSON         = "application/json"
-	MsgInvalidContentType   = "Content-Type header is not application/json"
-	MsgUnsupportedMediaType = "StatusUnsupportedMediaType: "
+	ContentTypeJSON             = "application/json"
+	ContentTypeNDJSON           = "application/x-ndjson"
+	ContentTypeCSV              = "text/csv"
+	MsgInvalidImportContentType = "Content-Type header is not application/x-ndjson or text/csv"
+	MsgUnsupportedMediaType     = "StatusUnsupportedMediaType: "
+)
+
+// Cache-Control and Vary headers of responses depending on the visitor.
+const (
+	cacheControlVisitor = "private, no-store"
+	varyVisitor         = "User-Agent, Accept-Language, Referer"
 )
 
 // URLShorten represents URL handler struct.
 type URLShorten struct {
-	urlService    URLShortenerService
-	urlPrefix     string
-	trustedSubnet string
-	logger        *zap.Logger
-	wg            *sync.WaitGroup
+	urlService     URLShortenerService
+	urlPrefix      string
+	trustedSubnets clientip.Subnets
+	legacyListing  bool
+	logger         *zap.Logger
+	wg             *sync.WaitGroup
 }
 
 // URLShortenerService represents URL service interface.
 type URLShortenerService interface {
 	Add(ctx context.Context, s string, host string, userID string) (*model.URL, error)
-	AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string) ([]dto.URLBatchResponse, error)
+	AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string, atomic bool) ([]dto.URLBatchResponse, error)
+	Import(ctx context.Context, next func() (dto.URLBatchRequest, error), host string, userID string) (*dto.URLImportSummary, error)
 	GetAll(ctx context.Context) ([]string, error)
-	GetAllByUserID(ctx context.Context, userID string) ([]dto.URLBatchResponseByUserID, error)
+	GetAllURLs(ctx context.Context, query dto.URLQuery) (*dto.URLRecordPage, error)
+	GetAllByUserID(ctx context.Context, userID string, query dto.URLQuery) (*dto.URLPage, error)
+	Export(ctx context.Context, userID string, filter model.URLFilter, fn func(dto.URLBatchResponseByUserID) error) error
 	DeleteAll(ctx context.Context) error
 	DeleteURLByUserID(ctx context.Context, userID string, shortURLs string) error
+	RestoreURLByUserID(ctx context.Context, userID string, shortURL string) error
+	UpdateURL(ctx context.Context, userID string, id string, original string) (*model.URL, error)
+	UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings) (*model.URL, error)
 	GetByyID(ctx context.Context, key string) (string, error)
+	GetRedirect(ctx context.Context, key string, visit model.Visit) (*model.Redirect, error)
+	GetRules(ctx context.Context, userID string, id string) ([]model.RedirectRule, error)
+	SetRules(ctx context.Context, userID string, id string, rules []model.RedirectRule) ([]model.RedirectRule, error)
+	AddRule(ctx context.Context, userID string, id string, rule model.RedirectRule) (*model.RedirectRule, error)
+	UpdateRule(ctx context.Context, userID string, id string, ruleID string, rule model.RedirectRule) (*model.RedirectRule, error)
+	DeleteRule(ctx context.Context, userID string, id string, ruleID string) error
+	GetCountryRedirects(ctx context.Context, userID string, id string) ([]model.CountryRedirects, error)
 	GetStats(ctx context.Context) (*dto.URLStats, error)
 	Ping(ctx context.Context) error
 }
 
 // NewURLShorten creates a new URLShorten instance
 //
-// Registers the URL shortener service httphandlers handlers.
-func NewURLShorten(e *echo.Echo, service URLShortenerService, urlPrefix string, trustedSubnet string, jwtCheckerCreator *middleware.JWTCheckerCreator, jwtAuth *middleware.JWTAuth, logger *zap.Logger, wg *sync.WaitGroup) *URLShorten {
+// Registers the URL shortener service httphandlers handlers and API docs,
+// every registered route is expected to be described in the OpenAPI specification.
+// JSON API is served under /api/v1 (current payloads) and /api/v2 (URLV2 payloads),
+// unversioned /api routes mirror /api/v1 and are marked deprecated.
+// Client IP of requests is resolved by clientIP, so echo.Context RealIP honors headers of trusted proxies only.
+// Creating routes replay responses of requests retried with the same Idempotency-Key.
+func NewURLShorten(e *echo.Echo, service URLShortenerService, urlPrefix string, trustedSubnets clientip.Subnets, clientIP *clientip.Resolver, legacyListing bool, jwtCheckerCreator *middleware.JWTCheckerCreator, jwtAuth *middleware.JWTAuth, authorizer *middleware.Authorizer, validator *middleware.RequestValidator, deprecation *middleware.Deprecation, idempotency *middleware.Idempotency, logger *zap.Logger, wg *sync.WaitGroup) *URLShorten {
 	handler := &URLShorten{
-		urlService:    service,
-		urlPrefix:     urlPrefix,
-		trustedSubnet: trustedSubnet,
-		logger:        logger,
-		wg:            wg,
+		urlService:     service,
+		urlPrefix:      urlPrefix,
+		trustedSubnets: trustedSubnets,
+		legacyListing:  legacyListing,
+		logger:         logger,
+		wg:             wg,
 	}
 
-	requestLogger := middleware.InitRequestLogger(logger)
+	e.IPExtractor = clientIP.FromRequest
+	requestLogger := middleware.InitRequestLogger(clientIP, logger)
 
 	e.Use(requestLogger.RequestLogger())
 	e.Use(middleware.Compress())
 	e.Use(middleware.Decompress())
 
-	public := e.Group("/", jwtCheckerCreator.JWTCheckOrCreate())
-	public.POST("api/shorten", handler.AddShorten)
-	public.POST("", handler.AddURL)
-	public.POST("api/shorten/batch", handler.AddBatch)
+	e.GET(openapi.SpecPath, openapi.SpecHandler)
+	e.GET(openapi.DocsPath+"*", openapi.DocsHandler)
+
+	deprecated := deprecation.Deprecate("/api/", "/api/v1/")
+	idempotent := idempotency.Idempotent()
+
+	public := e.Group("/", jwtCheckerCreator.JWTCheckOrCreate(), validator.Validate())
+	public.POST("api/shorten", handler.AddShorten, deprecated, idempotent)
+	public.POST("", handler.AddURL, idempotent)
+	public.POST("api/shorten/batch", handler.AddBatch, deprecated, idempotent)
+	public.POST("api/shorten/import", handler.ImportURLs, deprecated)
 
 	public.GET("*", handler.FindURL)
+	public.HEAD("*", handler.FindURL)
 	public.GET("", handler.FindAll)
 	public.GET("ping", handler.Ping)
 
-	public.DELETE("", handler.ClearAll)
-
-	protected := e.Group("/api/user", jwtAuth.JWTAuth())
-	protected.GET("/urls", handler.FindAllURLByUserID)
-	protected.DELETE("/urls", handler.DeleteAllURLsByUserID)
-
-	e.GET("/api/internal/stats", handler.GetStats)
+	protected := e.Group("/api/user", jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	protected.GET("/urls", handler.FindAllURLByUserID, deprecated)
+	protected.GET("/urls/export", handler.ExportURLs, deprecated)
+	protected.DELETE("/urls", handler.DeleteAllURLsByUserID, deprecated)
+	protected.POST("/urls/restore", handler.RestoreURLsByUserID, deprecated)
+	protected.PATCH("/urls/:id", handler.UpdateURL, deprecated)
+	protected.PUT("/urls/:id/redirect", handler.UpdateRedirect, deprecated)
+
+	e.DELETE("/", handler.ClearAll, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	e.GET("/api/internal/stats", handler.GetStats, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate(), deprecated)
+
+	// Versioned groups have no group middleware, so unknown paths are still handled by FindURL.
+	v1 := e.Group("/api/v1")
+	v1.POST("/shorten", handler.AddShorten, jwtCheckerCreator.JWTCheckOrCreate(), validator.Validate(), idempotent)
+	v1.POST("/shorten/batch", handler.AddBatch, jwtCheckerCreator.JWTCheckOrCreate(), validator.Validate(), idempotent)
+	v1.POST("/shorten/import", handler.ImportURLs, jwtCheckerCreator.JWTCheckOrCreate(), validator.Validate())
+	v1.GET("/user/urls", handler.FindAllURLByUserIDV1, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.GET("/user/urls/export", handler.ExportURLs, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.DELETE("/user/urls", handler.DeleteAllURLsByUserID, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.POST("/user/urls/restore", handler.RestoreURLsByUserID, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.PATCH("/user/urls/:id", handler.UpdateURL, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.PUT("/user/urls/:id/redirect", handler.UpdateRedirect, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.GET("/user/urls/:id/rules", handler.GetRules, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.PUT("/user/urls/:id/rules", handler.SetRules, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.POST("/user/urls/:id/rules", handler.AddRule, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.PUT("/user/urls/:id/rules/:rule_id", handler.UpdateRule, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.DELETE("/user/urls/:id/rules/:rule_id", handler.DeleteRule, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.GET("/user/urls/:id/countries", handler.GetCountryRedirects, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+	v1.GET("/internal/stats", handler.GetStats, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
+
+	v2 := e.Group("/api/v2")
+	v2.POST("/urls", handler.AddURLV2, jwtCheckerCreator.JWTCheckOrCreate(), validator.Validate(), idempotent)
+	v2.GET("/user/urls", handler.FindAllURLByUserIDV2, jwtAuth.JWTAuth(), authorizer.Authorize(), validator.Validate())
 
 	return handler
 }
 
-// FindAllURLByUserID retrieves all URLs for a given user ID.
+// FindAllURLByUserID retrieves URLs for a given user ID.
+//
+// Supports the same query parameters as FindAllURLByUserIDV1,
+// but lists all URLs when neither limit nor cursor is set as legacy clients expect.
 func (h *URLShorten) FindAllURLByUserID(c echo.Context) error {
+	return h.findAllURLByUserID(c, true)
+}
+
+// FindAllURLByUserIDV1 retrieves a page of URLs for a given user ID.
+//
+// Supports limit, cursor, sort (created_at or -created_at), deleted and search query parameters,
+// the next page is referenced in Link header.
+func (h *URLShorten) FindAllURLByUserIDV1(c echo.Context) error {
+	return h.findAllURLByUserID(c, false)
+}
+
+// findAllURLByUserID retrieves URLs for a given user ID, unpaged lists all of them when neither limit nor cursor is set.
+func (h *URLShorten) findAllURLByUserID(c echo.Context, unpaged bool) error {
 	userID, ok := c.Get("userID").(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return c.NoContent(http.StatusInternalServerError)
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	query, param, err := parseURLQuery(c)
+	if err != nil {
+		h.logger.Info("StatusBadRequest: invalid "+param+" parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field(param, err.Error()))
+	}
+	query.Unpaged = unpaged
+
+	page, err := h.urlService.GetAllByUserID(c.Request().Context(), userID, query)
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("StatusBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
-	savedURLs, err := h.urlService.GetAllByUserID(c.Request().Context(), userID)
 	if err != nil && !errors.Is(err, urlErr.ErrURLNotFound) {
 		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	if errors.Is(err, urlErr.ErrURLNotFound) {
@@ -109,158 +204,316 @@ func (h *URLShorten) FindAllURLByUserID(c echo.Context) error {
 		return c.NoContent(http.StatusNoContent)
 	}
 
-	return c.JSON(http.StatusOK, savedURLs)
+	setNextLink(c, page.NextPageToken)
+
+	return c.JSON(http.StatusOK, page.URLs)
 }
 
-// GetStats returns URL stats.
-func (h *URLShorten) GetStats(c echo.Context) error {
-	if h.trustedSubnet == "" {
-		return c.NoContent(http.StatusForbidden)
+// ExportURLs streams all URLs of the user as NDJSON (default) or CSV (format=csv).
+//
+// URLs can be filtered with deleted=true|false and search query parameters.
+func (h *URLShorten) ExportURLs(c echo.Context) error {
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 	}
 
-	ip := c.Request().Header.Get("X-Real-IP")
-	if ip == "" {
-		return c.NoContent(http.StatusForbidden)
+	deleted, err := parseDeleted(c)
+	if err != nil {
+		h.logger.Info("StatusBadRequest: invalid deleted parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field("deleted", err.Error()))
+	}
+	filter := model.URLFilter{Deleted: deleted, Search: c.QueryParam("search")}
+
+	var encoder exportEncoder
+	switch c.QueryParam("format") {
+	case "", "ndjson":
+		c.Response().Header().Set(echo.HeaderContentType, ContentTypeNDJSON)
+		c.Response().WriteHeader(http.StatusOK)
+		encoder = newNDJSONEncoder(c.Response())
+	case "csv":
+		c.Response().Header().Set(echo.HeaderContentType, ContentTypeCSV)
+		c.Response().WriteHeader(http.StatusOK)
+		csvEncoder := newCSVEncoder(c.Response())
+		if err := csvEncoder.WriteHeader(); err != nil {
+			h.logger.Error("unable to write export header", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+			return nil
+		}
+		encoder = csvEncoder
+	default:
+		h.logger.Info("StatusBadRequest: unsupported export format", zap.String("format", c.QueryParam("format")))
+		return apierr.Write(c, apierr.Field("format", "must be ndjson or csv"))
+	}
+
+	// Status is already sent, so errors are only logged and the response is cut short.
+	rows := 0
+	err = h.urlService.Export(c.Request().Context(), userID, filter, func(url dto.URLBatchResponseByUserID) error {
+		if err := encoder.Encode(url); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := encoder.Flush(); err != nil {
+				return err
+			}
+			c.Response().Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = encoder.Flush()
 	}
-
-	_, ipNet, err := net.ParseCIDR(h.trustedSubnet)
 	if err != nil {
-		h.logger.Error("StatusInternalServerError: unable to parse CIDR", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.NoContent(http.StatusInternalServerError)
+		h.logger.Error("export failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
 	}
 
-	if !ipNet.Contains(net.ParseIP(ip)) {
-		return c.NoContent(http.StatusForbidden)
+	return nil
+}
+
+// GetStats returns URL stats, available for admins and stats readers from trusted subnet.
+func (h *URLShorten) GetStats(c echo.Context) error {
+	if !h.trustedSubnets.Contains(c.RealIP()) {
+		return apierr.Write(c, apierr.New(apierr.CodePermissionDenied, "available from trusted subnet only"))
 	}
 
 	stats, err := h.urlService.GetStats(c.Request().Context())
 	if err != nil {
 		h.logger.Error("StatusInternalServerError: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.JSON(http.StatusInternalServerError, err)
+		return apierr.Write(c, apierr.FromError(err))
 	}
 	return c.JSON(http.StatusOK, stats)
 }
 
 // DeleteAllURLsByUserID deletes all URLs associated with a user ID.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
 func (h *URLShorten) DeleteAllURLsByUserID(c echo.Context) error {
-	header := c.Request().Header.Get("Content-Type")
-	if header != ContentTypeJSON {
-		msg := MsgInvalidContentType
-		h.logger.Error(MsgUnsupportedMediaType + msg)
-		return c.String(http.StatusUnsupportedMediaType, msg)
+	var shortURLs []string
+	if err := bindJSON(c, &shortURLs); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
-	body, err := io.ReadAll(c.Request().Body)
-	if err != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, fmt.Sprintf("Error: Unknown error, unable to read request %s", err))
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 	}
 
+	h.submitUserURLs(c.Request().Context(), userID, shortURLs, "delete", h.urlService.DeleteURLByUserID)
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// RestoreURLsByUserID restores deleted URLs associated with a user ID.
+//
+// URLs are restored asynchronously like they are deleted, only URLs deleted within the retention period are restored.
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) RestoreURLsByUserID(c echo.Context) error {
 	var shortURLs []string
-	err = json.Unmarshal(body, &shortURLs)
-	if err != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: Unknown error, unable to read request")
+	if err := bindJSON(c, &shortURLs); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	h.submitUserURLs(c.Request().Context(), userID, shortURLs, "restore", h.urlService.RestoreURLByUserID)
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// UpdateURL changes original URL of the user's URL, the short URL stays the same.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) UpdateURL(c echo.Context) error {
+	var urlRequest dto.URLRequest
+	if err := bindJSON(c, &urlRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
 	userID, ok := c.Get("userID").(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return c.NoContent(http.StatusInternalServerError)
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	url, err := h.urlService.UpdateURL(c.Request().Context(), userID, c.Param("id"), urlRequest.URL)
+	if errors.Is(err, urlErr.ErrInvalidURL) {
+		h.logger.Info("StatusBadRequest: invalid url", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field("url", "must be valid URL"))
+	}
+
+	if err != nil {
+		h.logger.Warn("unable to update url", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusOK, dto.URLBatchResponseByUserID{
+		ShortURL:    url.Shortened,
+		OriginalURL: url.Original,
+	})
+}
+
+// UpdateRedirect sets redirect status, Cache-Control header, query rules and country access of the user's URL,
+// zero values mean server defaults.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) UpdateRedirect(c echo.Context) error {
+	var redirectRequest dto.URLRedirect
+	if err := bindJSON(c, &redirectRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	url, err := h.urlService.UpdateRedirect(c.Request().Context(), userID, c.Param("id"), model.RedirectSettings{
+		Code:         redirectRequest.RedirectCode,
+		CacheControl: redirectRequest.CacheControl,
+		QueryRules: model.QueryRules{
+			Params:      redirectRequest.QueryParams,
+			PassThrough: redirectRequest.QueryPassThrough,
+		},
+		CountryAccess: model.CountryAccess{
+			Allow: redirectRequest.AllowedCountries,
+			Deny:  redirectRequest.DeniedCountries,
+		},
+	})
+	if err != nil {
+		h.logger.Warn("unable to update redirect", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusOK, dto.URLRedirect{
+		ShortURL:         url.Shortened,
+		RedirectCode:     url.RedirectCode,
+		CacheControl:     url.CacheControl,
+		QueryParams:      url.QueryRules.Params,
+		QueryPassThrough: url.QueryRules.PassThrough,
+		AllowedCountries: url.CountryAccess.Allow,
+		DeniedCountries:  url.CountryAccess.Deny,
+	})
+}
+
+// submitUserURLs processes URLs of the user by the worker pool, processing outlives the request.
+func (h *URLShorten) submitUserURLs(ctx context.Context, userID string, shortURLs []string, action string, process func(ctx context.Context, userID string, shortURL string) error) {
 	workerPool := workerpool.NewWorkerPool(100, h.logger)
 	workerPool.Start()
 	defer workerPool.Stop()
 
 	h.wg.Add(len(shortURLs))
 	for _, shortURL := range shortURLs {
-		log.Info("Submitting task", zap.String("delete shortURL", shortURL))
+		log.Info("Submitting task", zap.String(action+" shortURL", shortURL))
 		url := shortURL
-		userID := userID
 		workerPool.Submit(func() error {
 			defer h.wg.Done()
-			return h.urlService.DeleteURLByUserID(context.WithoutCancel(c.Request().Context()), userID, url)
+			return process(context.WithoutCancel(ctx), userID, url)
 		})
 	}
-
-	return c.NoContent(http.StatusAccepted)
 }
 
-// AddBatch handles the addition of a batch of URLs.
+// AddBatch handles the addition of a batch of URLs, every item of the response has its own status.
+//
+// With atomic=true query parameter any invalid item fails the whole batch.
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
 func (h *URLShorten) AddBatch(c echo.Context) error {
-	header := c.Request().Header.Get("Content-Type")
-	if header != ContentTypeJSON {
-		msg := MsgInvalidContentType
-		h.logger.Error(MsgUnsupportedMediaType + msg)
-		return c.String(http.StatusUnsupportedMediaType, msg)
+	var urlBatchRequest []dto.URLBatchRequest
+	if err := bindJSON(c, &urlBatchRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
-	body, err := io.ReadAll(c.Request().Body)
-	if err != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, fmt.Sprintf("Error: Unknown error, unable to read request %s", err))
+	if len(urlBatchRequest) == 0 {
+		h.logger.Error("StatusBadRequest: empty batch request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), urlErr.ErrEmptyRequest)))
+		return apierr.Write(c, apierr.New(apierr.CodeEmptyRequest, "empty batch request"))
 	}
 
-	var urlBatchRequest []dto.URLBatchRequest
-	err = json.Unmarshal(body, &urlBatchRequest)
+	atomic, err := parseBool(c, "atomic")
 	if err != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: Unknown error, unable to read request")
-	}
-
-	if len(urlBatchRequest) == 0 {
-		h.logger.Error("StatusBadRequest: empty batch request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: empty batch request")
+		h.logger.Info("StatusBadRequest: invalid atomic parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field("atomic", err.Error()))
 	}
 
 	userID := c.Get("userID").(string)
-	savedURLs, err := h.urlService.AddAll(c.Request().Context(), urlBatchRequest, h.urlPrefix, userID)
+	savedURLs, err := h.urlService.AddAll(c.Request().Context(), urlBatchRequest, h.urlPrefix, userID, atomic)
 	if err != nil {
 		h.logger.Error("StatusInternalServerError: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	return c.JSON(http.StatusCreated, savedURLs)
 }
 
-// AddShorten handles the addition of a single URL (got as json).
-func (h *URLShorten) AddShorten(c echo.Context) error {
-	header := c.Request().Header.Get("Content-Type")
-	if header != ContentTypeJSON {
-		msg := MsgInvalidContentType
+// ImportURLs handles import of URLs sent as NDJSON or CSV stream.
+//
+// Rows are saved in chunks while the body is read, the response is an import summary with per-row errors.
+// If import stops, the summary of already saved rows is the result member of the problem.
+func (h *URLShorten) ImportURLs(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get("Content-Type"))
+
+	var next func() (dto.URLBatchRequest, error)
+	switch mediaType {
+	case ContentTypeNDJSON:
+		next = ndjsonSource(c.Request().Body)
+	case ContentTypeCSV:
+		next = csvSource(c.Request().Body)
+	default:
+		msg := MsgInvalidImportContentType
 		h.logger.Error(MsgUnsupportedMediaType + msg)
-		return c.String(http.StatusUnsupportedMediaType, msg)
+		return apierr.Write(c, apierr.New(apierr.CodeUnsupportedMediaType, msg))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 	}
 
-	body, readBodyErr := io.ReadAll(c.Request().Body)
-	if readBodyErr != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), readBodyErr)))
-		return c.String(http.StatusBadRequest, fmt.Sprintf("Error: Unknown error, unable to read request %s", readBodyErr))
+	summary, err := h.urlService.Import(c.Request().Context(), next, h.urlPrefix, userID)
+	if err != nil {
+		h.logger.Error("StatusInternalServerError: import failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, "import stopped, rows of the summary are saved").WithResult(summary))
 	}
 
+	return c.JSON(http.StatusOK, summary)
+}
+
+// AddShorten handles the addition of a single URL (got as json).
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) AddShorten(c echo.Context) error {
 	var urlRequest dto.URLRequest
-	unmarshalErr := json.Unmarshal(body, &urlRequest)
-	if unmarshalErr != nil {
-		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), unmarshalErr)))
-		return c.String(http.StatusBadRequest, "Error: Unknown error, unable to read request")
+	if err := bindJSON(c, &urlRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
 	if err := h.checkRequest(urlRequest.URL); err != nil {
 		h.logger.Error("StatusBadRequest: unable to handle empty request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: Unable to handle empty request")
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	userID, ok := c.Get("userID").(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return c.NoContent(http.StatusInternalServerError)
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 	}
 
 	url, err := h.urlService.Add(c.Request().Context(), urlRequest.URL, h.urlPrefix, userID)
 	if err != nil && !errors.Is(err, urlErr.ErrURLAlreadyExists) {
 		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	response := &dto.URLResponse{
@@ -280,24 +533,24 @@ func (h *URLShorten) AddURL(c echo.Context) error {
 	body, readErr := io.ReadAll(c.Request().Body)
 	if readErr != nil {
 		h.logger.Error("StatusBadRequest: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), readErr)))
-		return c.String(http.StatusBadRequest, "Error: Unknown error, unable to read request")
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
 	}
 
 	if err := h.checkRequest(string(body)); err != nil {
 		h.logger.Error("StatusBadRequest: unable to handle empty request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: Unable to handle empty request")
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	userID, ok := c.Get("userID").(string)
 	if !ok {
 		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
-		return c.NoContent(http.StatusInternalServerError)
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 	}
 
 	url, err := h.urlService.Add(c.Request().Context(), string(body), h.urlPrefix, userID)
 	if err != nil && !errors.Is(err, urlErr.ErrURLAlreadyExists) {
 		h.logger.Error("StatusInternalServerError: Unknown error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	if errors.Is(err, urlErr.ErrURLAlreadyExists) {
@@ -311,68 +564,204 @@ func (h *URLShorten) AddURL(c echo.Context) error {
 
 // ClearAll deletes all data and returns an error if any.
 //
-// Deletes all saved urls.
+// Deletes all saved urls, available for admins only.
 func (h *URLShorten) ClearAll(c echo.Context) error {
 	if err := h.urlService.DeleteAll(c.Request().Context()); err != nil {
 		h.logger.Error("StatusInternalServerError: Unknown error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	return c.String(http.StatusOK, "All data deleted")
 }
 
-// FindAll retrieves all URLs.
+// FindAll retrieves a page of URL records of all users, available from trusted subnet only.
 //
-// Retrieves all saved urls.
+// Supports limit, cursor, sort (created_at or -created_at), user_id, deleted, search,
+// created_from and created_to (RFC 3339) query parameters, the next page is referenced in Link header.
+// In legacy listing mode returns all original URLs as plain text to anyone.
 func (h *URLShorten) FindAll(c echo.Context) error {
+	if h.legacyListing {
+		return h.findAllLegacy(c)
+	}
+
+	if !h.trustedSubnets.Contains(c.RealIP()) {
+		return apierr.Write(c, apierr.New(apierr.CodePermissionDenied, "available from trusted subnet only"))
+	}
+
+	query, param, err := parseURLQuery(c)
+	if err != nil {
+		h.logger.Info("StatusBadRequest: invalid "+param+" parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field(param, err.Error()))
+	}
+	query.UserID = c.QueryParam("user_id")
+
+	if query.CreatedFrom, err = parseTime(c, "created_from"); err != nil {
+		h.logger.Info("StatusBadRequest: invalid created_from parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field("created_from", "must be RFC 3339 time"))
+	}
+	if query.CreatedTo, err = parseTime(c, "created_to"); err != nil {
+		h.logger.Info("StatusBadRequest: invalid created_to parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field("created_to", "must be RFC 3339 time"))
+	}
+
+	page, err := h.urlService.GetAllURLs(c.Request().Context(), query)
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("StatusBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	if err != nil {
+		h.logger.Error("StatusInternalServerError: Unknown error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	setNextLink(c, page.NextPageToken)
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// findAllLegacy retrieves all original URLs joined as plain text.
+func (h *URLShorten) findAllLegacy(c echo.Context) error {
 	urls, err := h.urlService.GetAll(c.Request().Context())
 	if err != nil {
 		h.logger.Error("StatusInternalServerError: Unknown error:", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusInternalServerError, fmt.Sprintf("Unknown error: %s", err))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
 	return c.String(http.StatusOK, strings.Join(urls, ", "))
 }
 
-// FindURL finds the URL based on the given ID from echo context.
+// FindURL redirects to the original URL by the ID from the request path.
 //
-// Finds the URL based on the given ID.
+// The location is chosen by redirect rules of the URL for the request, its query is built by query rules of the URL.
+// Redirect status and Cache-Control header are set by the URL or server defaults, HEAD requests get the same headers without a body.
+// Visitors of countries not allowed by the URL get 451 Unavailable For Legal Reasons.
+// Visitor dependent redirects and 451 responses are never stored by caches, which key them by the URL only.
 func (h *URLShorten) FindURL(c echo.Context) error {
 	id := (strings.Split(c.Request().URL.Path, "/"))[1]
 
 	if err := h.checkRequest(id); err != nil {
 		h.logger.Error("StatusBadRequest: Unable to handle empty request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		return c.String(http.StatusBadRequest, "Error: Unable to handle empty request")
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
-	originalURL, err := h.urlService.GetByyID(c.Request().Context(), id)
-
-	var message string
-	var status int
+	redirect, err := h.urlService.GetRedirect(c.Request().Context(), id, newVisit(c))
 
 	switch {
 	case errors.Is(err, urlErr.ErrURLNotFound):
 		h.logger.Info("StatusBadRequest: url not found", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		status = http.StatusBadRequest
-		message = fmt.Sprintf("URL with id %s not found", id)
+		return apierr.Write(c, apierr.New(apierr.CodeURLNotFound, fmt.Sprintf("URL with id %s not found", id)).WithStatus(http.StatusBadRequest))
 
 	case errors.Is(err, urlErr.ErrURLDeleted):
 		h.logger.Info("StatusBadRequest: url not found", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		status = http.StatusGone
-		message = fmt.Sprintf("URL with id %s has been deleted", id)
+		return apierr.Write(c, apierr.New(apierr.CodeURLDeleted, fmt.Sprintf("URL with id %s has been deleted", id)))
+
+	case errors.Is(err, urlErr.ErrCountryBlocked):
+		h.logger.Info("StatusUnavailableForLegalReasons: url blocked in visitor country", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		c.Response().Header().Set(echo.HeaderCacheControl, cacheControlVisitor)
+		return apierr.Write(c, apierr.FromError(err))
 
 	case err != nil:
 		h.logger.Error("InternalServerError", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
-		status = http.StatusInternalServerError
-		message = fmt.Sprintf("Unknown error: %s", err)
+		return apierr.Write(c, apierr.FromError(err))
+	}
 
-	default:
-		c.Response().Header().Set("Location", originalURL)
-		status = http.StatusTemporaryRedirect
-		message = ""
+	c.Response().Header().Set("Location", redirect.Location)
+	switch {
+	case redirect.VisitorDependent:
+		c.Response().Header().Set(echo.HeaderCacheControl, cacheControlVisitor)
+		c.Response().Header().Set(echo.HeaderVary, varyVisitor)
+	case redirect.CacheControl != "":
+		c.Response().Header().Set(echo.HeaderCacheControl, redirect.CacheControl)
+	}
+	return c.NoContent(redirect.Code)
+}
+
+// newVisit returns visit of the short URL described by the request.
+func newVisit(c echo.Context) model.Visit {
+	return model.Visit{
+		Query:          c.QueryParams(),
+		UserAgent:      c.Request().UserAgent(),
+		AcceptLanguage: c.Request().Header.Get("Accept-Language"),
+		Referrer:       c.Request().Referer(),
+		ClientID:       c.RealIP(),
+		IP:             c.RealIP(),
+		Head:           c.Request().Method == http.MethodHead,
+	}
+}
+
+// parseURLQuery parses limit, cursor, sort, deleted and search query parameters,
+// on error returns the name of invalid parameter.
+func parseURLQuery(c echo.Context) (dto.URLQuery, string, error) {
+	query := dto.URLQuery{
+		PageToken: c.QueryParam("cursor"),
+		Sort:      c.QueryParam("sort"),
+		Search:    c.QueryParam("search"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return query, "limit", err
+		}
+		query.Limit = v
 	}
 
-	return c.String(status, message)
+	deleted, err := parseDeleted(c)
+	if err != nil {
+		return query, "deleted", err
+	}
+	query.Deleted = deleted
+
+	return query, "", nil
+}
+
+// setNextLink references the next page in Link header, if there is one.
+func setNextLink(c echo.Context, pageToken string) {
+	if pageToken == "" {
+		return
+	}
+
+	next := *c.Request().URL
+	values := next.Query()
+	values.Set("cursor", pageToken)
+	next.RawQuery = values.Encode()
+	c.Response().Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+}
+
+// parseDeleted parses optional deleted query parameter.
+func parseDeleted(c echo.Context) (*bool, error) {
+	deleted := c.QueryParam("deleted")
+	if deleted == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseBool(deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// parseBool parses optional bool query parameter, returns false if it is not set.
+func parseBool(c echo.Context, name string) (bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// parseTime parses optional RFC 3339 time query parameter, returns zero time if it is not set.
+func parseTime(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
 }
 
 // checkRequest checks if the request is empty.
@@ -388,11 +777,16 @@ func (h *URLShorten) checkRequest(s string) error {
 //
 // Check database connection.
 func (h *URLShorten) Ping(c echo.Context) error {
-	status := http.StatusOK
 	err := h.urlService.Ping(c.Request().Context())
 	if err != nil {
-		status = http.StatusInternalServerError
+		h.logger.Error("StatusInternalServerError: ping failed", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
 	}
 
-	return c.NoContent(status)
+	return c.NoContent(http.StatusOK)
+}
+
+// bindJSON decodes JSON request body into v, Content-Type is checked by RequestValidator.
+func bindJSON(c echo.Context, v any) error {
+	return (&echo.DefaultBinder{}).BindBody(c, v)
 }
@@ -1,14 +1,19 @@
 package httphandlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -16,17 +21,23 @@ import (
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 
+	"github.com/msmkdenis/yap-shortener/internal/api/openapi"
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/internal/config"
 	"github.com/msmkdenis/yap-shortener/internal/dto"
 	"github.com/msmkdenis/yap-shortener/internal/middleware"
 	mock "github.com/msmkdenis/yap-shortener/internal/mocks"
 	"github.com/msmkdenis/yap-shortener/internal/model"
 	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
 )
 
 const URL = "http://localhost:8080"
 
+const msgInvalidContentType = "Content-Type header is not application/json"
+
 var cfgMock = &config.Config{
 	URLServer:       "8080",
 	URLPrefix:       "http://localhost:8080",
@@ -38,10 +49,14 @@ var cfgMock = &config.Config{
 
 type URLHandlerTestSuite struct {
 	suite.Suite
-	h          *URLShorten
-	urlService *mock.MockURLService
-	echo       *echo.Echo
-	ctrl       *gomock.Controller
+	h           *URLShorten
+	spec        *openapi3.T
+	validator   *middleware.RequestValidator
+	deprecation *middleware.Deprecation
+	jwtManager  *jwtgen.JWTManager
+	urlService  *mock.MockURLService
+	echo        *echo.Echo
+	ctrl        *gomock.Controller
 }
 
 func TestSuite(t *testing.T) {
@@ -52,12 +67,117 @@ func (s *URLHandlerTestSuite) SetupTest() {
 	cfgMock.URLPrefix = "http://localhost:8080"
 	logger, _ := zap.NewProduction()
 	jwtManager := jwtgen.InitJWTManager(cfgMock.TokenName, cfgMock.SecretKey, logger)
+	s.jwtManager = jwtManager
 	jwtCheckerCreator := middleware.InitJWTCheckerCreator(jwtManager, logger)
 	jwtAuth := middleware.InitJWTAuth(jwtManager, logger)
+	authorizer := middleware.InitAuthorizer(logger)
+	spec, err := openapi.Load()
+	s.Require().NoError(err)
+	s.spec = spec
+	s.validator, err = middleware.InitRequestValidator(spec, logger)
+	s.Require().NoError(err)
+	s.deprecation, err = middleware.InitDeprecation("2030-01-01T00:00:00Z", logger)
+	s.Require().NoError(err)
 	s.ctrl = gomock.NewController(s.T())
 	s.echo = echo.New()
 	s.urlService = mock.NewMockURLService(s.ctrl)
-	s.h = NewURLShorten(s.echo, s.urlService, cfgMock.URLPrefix, cfgMock.TrustedSubnet, jwtCheckerCreator, jwtAuth, logger, &sync.WaitGroup{})
+	// Requests of httptest come from 192.0.2.1, trusted as a proxy passing X-Real-IP.
+	proxies, err := clientip.ParseSubnets("192.0.2.0/24")
+	s.Require().NoError(err)
+	s.h = NewURLShorten(s.echo, s.urlService, cfgMock.URLPrefix, nil, clientip.NewResolver(proxies), cfgMock.LegacyListing, jwtCheckerCreator, jwtAuth, authorizer, s.validator, s.deprecation, middleware.InitIdempotency(time.Hour, 0, logger), logger, &sync.WaitGroup{})
+}
+
+func (s *URLHandlerTestSuite) TestRoutesDescribedInSpec() {
+	for _, route := range s.echo.Routes() {
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+
+		path := routeToSpecPath(route.Path)
+		pathItem := s.spec.Paths.Find(path)
+		if s.NotNilf(pathItem, "route %s %s is missing in openapi spec", route.Method, path) {
+			s.NotNilf(pathItem.GetOperation(route.Method), "route %s %s is missing in openapi spec", route.Method, path)
+		}
+	}
+}
+
+func (s *URLHandlerTestSuite) TestAuthenticatedRoutesHaveRoles() {
+	logger := zap.NewNop()
+	jwtManager := jwtgen.InitJWTManager(cfgMock.TokenName, cfgMock.SecretKey, logger)
+	e := echo.New()
+	// Public routes reach handlers of the service without implementation, their panics are ignored.
+	NewURLShorten(e, struct{ URLShortenerService }{}, cfgMock.URLPrefix, nil, clientip.NewResolver(nil), false,
+		middleware.InitJWTCheckerCreator(jwtManager, logger), middleware.InitJWTAuth(jwtManager, logger), middleware.InitAuthorizer(logger),
+		s.validator, s.deprecation, middleware.InitIdempotency(time.Hour, 0, logger), logger, &sync.WaitGroup{})
+
+	authenticated := 0
+	for _, route := range e.Routes() {
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+
+		path := strings.NewReplacer(":id", "id", ":rule_id", "rule", "*", "id").Replace(route.Path)
+		w := httptest.NewRecorder()
+		func() {
+			defer func() { _ = recover() }()
+			e.ServeHTTP(w, httptest.NewRequest(route.Method, path, http.NoBody))
+		}()
+		if w.Code != http.StatusUnauthorized {
+			continue
+		}
+
+		authenticated++
+		_, ok := middleware.RouteRoles(route.Method, route.Path)
+		s.Truef(ok, "authenticated route %s %s has no roles", route.Method, route.Path)
+	}
+	s.NotZero(authenticated)
+}
+
+func (s *URLHandlerTestSuite) TestOpenAPIDocs() {
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+		expectedType string
+	}{
+		{name: "Spec", path: openapi.SpecPath, expectedCode: http.StatusOK, expectedType: echo.MIMEApplicationJSON},
+		{name: "Swagger UI", path: openapi.DocsPath, expectedCode: http.StatusOK, expectedType: "text/html; charset=utf-8"},
+		{name: "Swagger UI initializer", path: openapi.DocsPath + "swagger-initializer.js", expectedCode: http.StatusOK, expectedType: echo.MIMEApplicationJavaScript},
+		{name: "Missing file", path: openapi.DocsPath + "missing.js", expectedCode: http.StatusNotFound},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.echo.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, http.NoBody))
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedType != "" {
+				assert.Equal(t, test.expectedType, w.Header().Get(echo.HeaderContentType))
+			}
+		})
+	}
+}
+
+// setToken sets cookie with token of the user to the request.
+func (s *URLHandlerTestSuite) setToken(request *http.Request, userID string) {
+	token, err := s.jwtManager.BuildJWTStringWithUserID(userID)
+	s.Require().NoError(err)
+	request.AddCookie(&http.Cookie{Name: cfgMock.TokenName, Value: token})
+}
+
+// routeToSpecPath converts echo route path to openapi path template.
+func routeToSpecPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case segment == "*":
+			segments[i] = "{any}"
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
 func (s *URLHandlerTestSuite) TestDeleteAllURLsByUserID_Unauthorized() {
@@ -107,7 +227,7 @@ func (s *URLHandlerTestSuite) TestDeleteAllURLsByUserID_WrongMediaType() {
 			method:       http.MethodDelete,
 			expectedCode: http.StatusUnsupportedMediaType,
 			path:         "http://localhost:8080/api/user/urls",
-			expectedBody: "",
+			expectedBody: problemBody(apierr.New(apierr.CodeUnsupportedMediaType, msgInvalidContentType), "/api/user/urls"),
 		},
 	}
 
@@ -120,10 +240,11 @@ func (s *URLHandlerTestSuite) TestDeleteAllURLsByUserID_WrongMediaType() {
 			l := s.echo.NewContext(request, w)
 			l.Set("userID", "token")
 
-			err := s.h.DeleteAllURLsByUserID(l)
+			err := s.validator.Validate()(s.h.DeleteAllURLsByUserID)(l)
 			assert.NoError(t, err)
 
 			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedBody, w.Body.String())
 			s.ctrl.Finish()
 		})
 	}
@@ -210,6 +331,325 @@ func (s *URLHandlerTestSuite) TestDeleteAllURLsByUserID_Success() {
 	}
 }
 
+func (s *URLHandlerTestSuite) TestRestoreURLsByUserID() {
+	testCases := []struct {
+		name         string
+		path         string
+		token        bool
+		requestBody  string
+		prepare      func()
+		expectedCode int
+	}{
+		{
+			name:         "Success",
+			path:         "/api/v1/user/urls/restore",
+			token:        true,
+			requestBody:  `["NjQyYTU", "OWUyMzI"]`,
+			expectedCode: http.StatusAccepted,
+			prepare: func() {
+				s.urlService.EXPECT().RestoreURLByUserID(gomock.Any(), "token", "NjQyYTU").Return(nil)
+				s.urlService.EXPECT().RestoreURLByUserID(gomock.Any(), "token", "OWUyMzI").Return(nil)
+			},
+		},
+		{
+			name:         "Success - deprecated route",
+			path:         "/api/user/urls/restore",
+			token:        true,
+			requestBody:  `["NjQyYTU"]`,
+			expectedCode: http.StatusAccepted,
+			prepare: func() {
+				s.urlService.EXPECT().RestoreURLByUserID(gomock.Any(), "token", "NjQyYTU").Return(nil)
+			},
+		},
+		{
+			name:         "BadRequest - not an array",
+			path:         "/api/v1/user/urls/restore",
+			token:        true,
+			requestBody:  `{"id": "NjQyYTU"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Unauthorized",
+			path:         "/api/v1/user/urls/restore",
+			requestBody:  `["NjQyYTU"]`,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			} else {
+				s.urlService.EXPECT().RestoreURLByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.requestBody))
+			request.Header.Set("Content-Type", "application/json")
+			if test.token {
+				s.setToken(request, "token")
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+			s.h.wg.Wait()
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			s.ctrl.Finish()
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestUpdateURL() {
+	updated := &model.URL{
+		ID:        "NjQyYTU",
+		Original:  "https://example.com/edited",
+		Shortened: URL + "/NjQyYTU",
+		UserID:    "token",
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        bool
+		requestBody  string
+		prepare      func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/NjQyYTU", "original_url": "https://example.com/edited"}`,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "https://example.com/edited").Return(updated, nil)
+			},
+		},
+		{
+			name:         "Success - deprecated route",
+			path:         "/api/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/NjQyYTU", "original_url": "https://example.com/edited"}`,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "https://example.com/edited").Return(updated, nil)
+			},
+		},
+		{
+			name:         "BadRequest - invalid url",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "not url"}`,
+			expectedCode: http.StatusBadRequest,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "not url").
+					Return(nil, apperr.NewValueError("invalid original url", apperr.Caller(), urlErr.ErrInvalidURL))
+			},
+		},
+		{
+			name:         "BadRequest - missing url",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "NotFound",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusNotFound,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "https://example.com/edited").Return(nil, urlErr.ErrURLNotFound)
+			},
+		},
+		{
+			name:         "Gone",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusGone,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "https://example.com/edited").Return(nil, urlErr.ErrURLDeleted)
+			},
+		},
+		{
+			name:         "Conflict",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			token:        true,
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusConflict,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), "token", "NjQyYTU", "https://example.com/edited").Return(nil, urlErr.ErrURLAlreadyExists)
+			},
+		},
+		{
+			name:         "Unauthorized",
+			path:         "/api/v1/user/urls/NjQyYTU",
+			requestBody:  `{"url": "https://example.com/edited"}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			} else {
+				s.urlService.EXPECT().UpdateURL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+			request := httptest.NewRequest(http.MethodPatch, test.path, strings.NewReader(test.requestBody))
+			request.Header.Set("Content-Type", "application/json")
+			if test.token {
+				s.setToken(request, "token")
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			s.ctrl.Finish()
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestUpdateRedirect() {
+	updated := &model.URL{
+		ID:           "NjQyYTU",
+		Shortened:    URL + "/NjQyYTU",
+		RedirectCode: http.StatusPermanentRedirect,
+		CacheControl: "public, max-age=86400",
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		token        bool
+		requestBody  string
+		prepare      func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"redirect_code": 308, "cache_control": "public, max-age=86400"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/NjQyYTU", "redirect_code": 308, "cache_control": "public, max-age=86400"}`,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{Code: 308, CacheControl: "public, max-age=86400"}).Return(updated, nil)
+			},
+		},
+		{
+			name:         "Success - deprecated route",
+			path:         "/api/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"redirect_code": 308, "cache_control": "public, max-age=86400"}`,
+			expectedCode: http.StatusOK,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{Code: 308, CacheControl: "public, max-age=86400"}).Return(updated, nil)
+			},
+		},
+		{
+			name:         "Success - query rules",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"redirect_code": 0, "cache_control": "", "query_params": {"utm_source": "{ref}"}, "query_pass_through": true}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/NjQyYTU", "redirect_code": 0, "cache_control": "", "query_params": {"utm_source": "{ref}"}, "query_pass_through": true}`,
+			prepare: func() {
+				rules := model.QueryRules{Params: map[string]string{"utm_source": "{ref}"}, PassThrough: true}
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{QueryRules: rules}).
+					Return(&model.URL{ID: "NjQyYTU", Shortened: URL + "/NjQyYTU", QueryRules: rules}, nil)
+			},
+		},
+		{
+			name:         "Success - country access",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"denied_countries": ["kp", "SY"]}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/NjQyYTU", "redirect_code": 0, "cache_control": "", "denied_countries": ["KP", "SY"]}`,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{CountryAccess: model.CountryAccess{Deny: []string{"kp", "SY"}}}).
+					Return(&model.URL{ID: "NjQyYTU", Shortened: URL + "/NjQyYTU", CountryAccess: model.CountryAccess{Deny: []string{"KP", "SY"}}}, nil)
+			},
+		},
+		{
+			name:         "BadRequest - invalid country",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"allowed_countries": ["USA"]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "BadRequest - code out of spec",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"redirect_code": 200}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "BadRequest - invalid cache control",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{"cache_control": "max-age=soon"}`,
+			expectedCode: http.StatusBadRequest,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{CacheControl: "max-age=soon"}).
+					Return(nil, apperr.NewValueError("invalid cache control", apperr.Caller(), urlErr.ErrInvalidRedirect))
+			},
+		},
+		{
+			name:         "NotFound",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			token:        true,
+			requestBody:  `{}`,
+			expectedCode: http.StatusNotFound,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), "token", "NjQyYTU", model.RedirectSettings{}).Return(nil, urlErr.ErrURLNotFound)
+			},
+		},
+		{
+			name:         "Unauthorized",
+			path:         "/api/v1/user/urls/NjQyYTU/redirect",
+			requestBody:  `{}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			} else {
+				s.urlService.EXPECT().UpdateRedirect(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			}
+			request := httptest.NewRequest(http.MethodPut, test.path, strings.NewReader(test.requestBody))
+			request.Header.Set("Content-Type", "application/json")
+			if test.token {
+				s.setToken(request, "token")
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			s.ctrl.Finish()
+		})
+	}
+}
+
 func (s *URLHandlerTestSuite) TestFindAllURLByUserID_Unauthorized() {
 	defer func(echo *echo.Echo) {
 		err := echo.Close()
@@ -263,7 +703,7 @@ func (s *URLHandlerTestSuite) TestFindAllURLByUserID_NoContent() {
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().GetAllByUserID(gomock.Any(), gomock.Any()).Times(1).Return(nil, urlErr.ErrURLNotFound)
+			s.urlService.EXPECT().GetAllByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil, urlErr.ErrURLNotFound)
 			request := httptest.NewRequest(test.method, test.path, strings.NewReader(""))
 			w := httptest.NewRecorder()
 			l := s.echo.NewContext(request, w)
@@ -279,6 +719,219 @@ func (s *URLHandlerTestSuite) TestFindAllURLByUserID_NoContent() {
 	}
 }
 
+func (s *URLHandlerTestSuite) TestFindAllURLByUserID_Page() {
+	urls := []dto.URLBatchResponseByUserID{{ShortURL: URL + "/1", OriginalURL: "http://example.com/1"}}
+	deleted := false
+
+	testCases := []struct {
+		name          string
+		path          string
+		v1            bool
+		expectedQuery dto.URLQuery
+		page          *dto.URLPage
+		err           error
+		expectedCode  int
+		expectedLink  string
+	}{
+		{
+			name:          "Next page",
+			path:          "http://localhost:8080/api/user/urls?limit=1&sort=created_at&deleted=false&search=example",
+			expectedQuery: dto.URLQuery{Limit: 1, Sort: "created_at", Deleted: &deleted, Search: "example", Unpaged: true},
+			page:          &dto.URLPage{URLs: urls, NextPageToken: "token"},
+			expectedCode:  http.StatusOK,
+			expectedLink:  "</api/user/urls?cursor=token&deleted=false&limit=1&search=example&sort=created_at>; rel=\"next\"",
+		},
+		{
+			name:          "Last page",
+			path:          "http://localhost:8080/api/user/urls?cursor=token",
+			expectedQuery: dto.URLQuery{PageToken: "token", Unpaged: true},
+			page:          &dto.URLPage{URLs: urls},
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "All URLs",
+			path:          "http://localhost:8080/api/user/urls",
+			expectedQuery: dto.URLQuery{Unpaged: true},
+			page:          &dto.URLPage{URLs: urls},
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "V1 first page",
+			path:          "http://localhost:8080/api/v1/user/urls",
+			v1:            true,
+			expectedQuery: dto.URLQuery{},
+			page:          &dto.URLPage{URLs: urls, NextPageToken: "token"},
+			expectedCode:  http.StatusOK,
+			expectedLink:  "</api/v1/user/urls?cursor=token>; rel=\"next\"",
+		},
+		{
+			name:          "Invalid query",
+			path:          "http://localhost:8080/api/user/urls?sort=unknown",
+			expectedQuery: dto.URLQuery{Sort: "unknown", Unpaged: true},
+			err:           urlErr.ErrInvalidQuery,
+			expectedCode:  http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid limit",
+			path:         "http://localhost:8080/api/user/urls?limit=ten",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.page != nil || test.err != nil {
+				s.urlService.EXPECT().GetAllByUserID(gomock.Any(), "token", test.expectedQuery).Times(1).Return(test.page, test.err)
+			}
+			request := httptest.NewRequest(http.MethodGet, test.path, http.NoBody)
+			w := httptest.NewRecorder()
+			l := s.echo.NewContext(request, w)
+			l.Set("userID", "token")
+
+			handler := s.h.FindAllURLByUserID
+			if test.v1 {
+				handler = s.h.FindAllURLByUserIDV1
+			}
+			err := handler(l)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedLink, w.Header().Get("Link"))
+			if test.expectedCode == http.StatusOK {
+				var result []dto.URLBatchResponseByUserID
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+				assert.Equal(t, urls, result)
+			}
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestFindAll() {
+	trustedSubnets, err := clientip.ParseSubnets("10.10.0.0/16,192.168.1.0/24")
+	s.Require().NoError(err)
+	s.h.trustedSubnets = trustedSubnets
+	createdAt := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	records := []dto.URLRecord{{ID: "1", ShortURL: URL + "/1", OriginalURL: "http://example.com/1", UserID: "user", CreatedAt: createdAt}}
+	deleted := true
+
+	testCases := []struct {
+		name          string
+		path          string
+		remoteAddr    string
+		realIP        string
+		expectedQuery dto.URLQuery
+		page          *dto.URLRecordPage
+		err           error
+		expectedCode  int
+		expectedLink  string
+	}{
+		{
+			name:   "Filtered next page",
+			path:   "http://localhost:8080/?limit=1&user_id=user&deleted=true&created_from=2023-10-01T00:00:00Z&created_to=2023-11-01T00:00:00Z",
+			realIP: "192.168.1.10",
+			expectedQuery: dto.URLQuery{
+				Limit:       1,
+				UserID:      "user",
+				Deleted:     &deleted,
+				CreatedFrom: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:   time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC),
+			},
+			page:         &dto.URLRecordPage{URLs: records, NextPageToken: "token"},
+			expectedCode: http.StatusOK,
+			expectedLink: "</?created_from=2023-10-01T00%3A00%3A00Z&created_to=2023-11-01T00%3A00%3A00Z&cursor=token&deleted=true&limit=1&user_id=user>; rel=\"next\"",
+		},
+		{
+			name:         "Untrusted subnet",
+			path:         "http://localhost:8080/",
+			realIP:       "10.0.0.1",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Missing X-Real-IP",
+			path:         "http://localhost:8080/",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "X-Real-IP of untrusted proxy",
+			path:         "http://localhost:8080/",
+			remoteAddr:   "203.0.113.1:1234",
+			realIP:       "192.168.1.10",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:          "Second trusted subnet",
+			path:          "http://localhost:8080/",
+			realIP:        "10.10.1.1",
+			expectedQuery: dto.URLQuery{},
+			page:          &dto.URLRecordPage{URLs: records},
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "Invalid created_from",
+			path:         "http://localhost:8080/?created_from=yesterday",
+			realIP:       "192.168.1.10",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:          "Invalid query",
+			path:          "http://localhost:8080/?sort=unknown",
+			realIP:        "192.168.1.10",
+			expectedQuery: dto.URLQuery{Sort: "unknown"},
+			err:           urlErr.ErrInvalidQuery,
+			expectedCode:  http.StatusBadRequest,
+		},
+		{
+			name:          "Internal error",
+			path:          "http://localhost:8080/",
+			realIP:        "192.168.1.10",
+			err:           errors.New("repository error"),
+			expectedCode:  http.StatusInternalServerError,
+			expectedQuery: dto.URLQuery{},
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.page != nil || test.err != nil {
+				s.urlService.EXPECT().GetAllURLs(gomock.Any(), test.expectedQuery).Times(1).Return(test.page, test.err)
+			}
+			request := httptest.NewRequest(http.MethodGet, test.path, http.NoBody)
+			if test.remoteAddr != "" {
+				request.RemoteAddr = test.remoteAddr
+			}
+			if test.realIP != "" {
+				request.Header.Set("X-Real-IP", test.realIP)
+			}
+			w := httptest.NewRecorder()
+
+			err := s.h.FindAll(s.echo.NewContext(request, w))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedLink, w.Header().Get("Link"))
+			if test.expectedCode == http.StatusOK {
+				var result dto.URLRecordPage
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+				assert.Equal(t, *test.page, result)
+			}
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestFindAll_Legacy() {
+	s.h.legacyListing = true
+	s.urlService.EXPECT().GetAll(gomock.Any()).Times(1).Return([]string{"http://example.com/1", "http://example.com/2"}, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", http.NoBody)
+	w := httptest.NewRecorder()
+
+	err := s.h.FindAll(s.echo.NewContext(request, w))
+	require.NoError(s.T(), err)
+
+	assert.Equal(s.T(), http.StatusOK, w.Code)
+	assert.Equal(s.T(), "http://example.com/1, http://example.com/2", w.Body.String())
+}
+
 func (s *URLHandlerTestSuite) TestAddBatch_WrongMediaType() {
 	testCases := []struct {
 		name         string
@@ -293,19 +946,19 @@ func (s *URLHandlerTestSuite) TestAddBatch_WrongMediaType() {
 			method:       http.MethodPost,
 			expectedCode: http.StatusUnsupportedMediaType,
 			path:         "http://localhost:8080/api/shorten/batch",
-			expectedBody: "Content-Type header is not application/json",
+			expectedBody: problemBody(apierr.New(apierr.CodeUnsupportedMediaType, msgInvalidContentType), "/api/shorten/batch"),
 		},
 	}
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().AddAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			s.urlService.EXPECT().AddAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			request := httptest.NewRequest(test.method, test.path, strings.NewReader(""))
 			w := httptest.NewRecorder()
 			l := s.echo.NewContext(request, w)
 			l.Set("userID", "token")
 
-			err := s.h.AddBatch(l)
+			err := s.validator.Validate()(s.h.AddBatch)(l)
 			require.NoError(t, err)
 
 			assert.Equal(t, test.expectedCode, w.Code)
@@ -330,13 +983,13 @@ func (s *URLHandlerTestSuite) TestAddBatch_EmptyRequest() {
 			expectedCode: http.StatusBadRequest,
 			path:         "http://localhost:8080/api/shorten/batch",
 			body:         []string{},
-			expectedBody: "Error: empty batch request",
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "empty batch request"), "/api/shorten/batch"),
 		},
 	}
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().AddAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			s.urlService.EXPECT().AddAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			body, jsonErr := json.Marshal(test.body)
 			require.NoError(t, jsonErr)
 			request := httptest.NewRequest(test.method, test.path, strings.NewReader(string(body)))
@@ -359,10 +1012,12 @@ func (s *URLHandlerTestSuite) TestAddBatch_Success() {
 	shortURL1 := dto.URLBatchResponse{
 		CorrelationID: "1",
 		ShortenedURL:  "1",
+		Status:        dto.BatchItemCreated,
 	}
 	shortURL2 := dto.URLBatchResponse{
 		CorrelationID: "2",
-		ShortenedURL:  "2",
+		Status:        dto.BatchItemInvalid,
+		Error:         "invalid original_url",
 	}
 	shortURLs := []dto.URLBatchResponse{shortURL1, shortURL2}
 
@@ -382,6 +1037,7 @@ func (s *URLHandlerTestSuite) TestAddBatch_Success() {
 		body         []dto.URLBatchRequest
 		expectedCode int
 		path         string
+		atomic       bool
 		expectedBody []dto.URLBatchResponse
 	}{
 		{
@@ -392,11 +1048,20 @@ func (s *URLHandlerTestSuite) TestAddBatch_Success() {
 			body:         request,
 			expectedBody: shortURLs,
 		},
+		{
+			name:         "Success atomic",
+			method:       http.MethodPost,
+			expectedCode: http.StatusCreated,
+			path:         "http://localhost:8080/api/shorten/batch?atomic=true",
+			body:         request,
+			atomic:       true,
+			expectedBody: shortURLs,
+		},
 	}
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().AddAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(shortURLs, nil)
+			s.urlService.EXPECT().AddAll(gomock.Any(), request, cfgMock.URLPrefix, "token", test.atomic).Times(1).Return(shortURLs, nil)
 			body, jsonErr := json.Marshal(test.body)
 			require.NoError(t, jsonErr)
 			req := httptest.NewRequest(test.method, test.path, strings.NewReader(string(body)))
@@ -419,6 +1084,56 @@ func (s *URLHandlerTestSuite) TestAddBatch_Success() {
 	}
 }
 
+func (s *URLHandlerTestSuite) TestAddBatch_Atomic() {
+	request := []dto.URLBatchRequest{{CorrelationID: "1", OriginalURL: "not url"}}
+	batchErr := apperr.NewValueError("item 0: invalid original_url", apperr.Caller(), urlErr.ErrInvalidBatchItem)
+
+	testCases := []struct {
+		name         string
+		path         string
+		prepare      func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Invalid batch item",
+			path: "http://localhost:8080/api/shorten/batch?atomic=true",
+			prepare: func() {
+				s.urlService.EXPECT().AddAll(gomock.Any(), request, cfgMock.URLPrefix, "token", true).Times(1).Return(nil, batchErr)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: problemBody(apierr.New(apierr.CodeInvalidBatchItem, "item 0: invalid original_url"), "/api/shorten/batch"),
+		},
+		{
+			name:         "Invalid atomic parameter",
+			path:         "http://localhost:8080/api/shorten/batch?atomic=maybe",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: problemBody(apierr.Field("atomic", `strconv.ParseBool: parsing "maybe": invalid syntax`), "/api/shorten/batch"),
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			}
+			body, jsonErr := json.Marshal(request)
+			require.NoError(t, jsonErr)
+			req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			l := s.echo.NewContext(req, w)
+			l.Set("userID", "token")
+
+			err := s.h.AddBatch(l)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func (s *URLHandlerTestSuite) TestAddShorten_EmptyRequest() {
 	testCases := []struct {
 		name         string
@@ -434,7 +1149,7 @@ func (s *URLHandlerTestSuite) TestAddShorten_EmptyRequest() {
 			expectedCode: http.StatusBadRequest,
 			path:         "http://localhost:8080/api/shorten",
 			body:         dto.URLRequest{},
-			expectedBody: "Error: Unable to handle empty request",
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "Unable to handle empty request"), "/api/shorten"),
 		},
 	}
 
@@ -473,7 +1188,7 @@ func (s *URLHandlerTestSuite) TestAddShorten_WrongMediaType() {
 			method:       http.MethodPost,
 			expectedCode: http.StatusUnsupportedMediaType,
 			path:         "http://localhost:8080/api/shorten",
-			expectedBody: "Content-Type header is not application/json",
+			expectedBody: problemBody(apierr.New(apierr.CodeUnsupportedMediaType, msgInvalidContentType), "/api/shorten"),
 		},
 	}
 
@@ -485,7 +1200,7 @@ func (s *URLHandlerTestSuite) TestAddShorten_WrongMediaType() {
 			l := s.echo.NewContext(request, w)
 			l.Set("userID", "token")
 
-			err := s.h.AddShorten(l)
+			err := s.validator.Validate()(s.h.AddShorten)(l)
 			require.NoError(t, err)
 
 			assert.Equal(t, test.expectedCode, w.Code)
@@ -513,7 +1228,7 @@ func (s *URLHandlerTestSuite) TestAddShorten_InternalServerError() {
 			expectedCode: http.StatusInternalServerError,
 			path:         "http://localhost:8080/api/shorten",
 			body:         requestBody,
-			expectedBody: "Unknown error: internal server error",
+			expectedBody: problemBody(apierr.New(apierr.CodeInternal, ""), "/api/shorten"),
 		},
 	}
 
@@ -651,7 +1366,7 @@ func (s *URLHandlerTestSuite) TestAddURL_EmptyRequest() {
 			expectedCode: http.StatusBadRequest,
 			path:         "http://localhost:8080/",
 			body:         "",
-			expectedBody: "Error: Unable to handle empty request",
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "Unable to handle empty request"), "/"),
 		},
 	}
 
@@ -692,7 +1407,7 @@ func (s *URLHandlerTestSuite) TestAddURL_InternalServerError() {
 			expectedCode: http.StatusInternalServerError,
 			path:         "http://localhost:8080/api/shorten",
 			body:         requestBody,
-			expectedBody: "Unknown error: internal server error",
+			expectedBody: problemBody(apierr.New(apierr.CodeInternal, ""), "/api/shorten"),
 		},
 	}
 
@@ -818,7 +1533,7 @@ func (s *URLHandlerTestSuite) TestClearALL_InternalServerError() {
 			expectedCode: http.StatusInternalServerError,
 			path:         "http://localhost:8080/",
 			body:         "",
-			expectedBody: "Unknown error: internal server error",
+			expectedBody: problemBody(apierr.New(apierr.CodeInternal, ""), "/"),
 		},
 	}
 
@@ -878,9 +1593,118 @@ func (s *URLHandlerTestSuite) TestClearALL_Success() {
 }
 
 func (s *URLHandlerTestSuite) TestFindURL_Success() {
-	url := &model.URL{Original: URL, Shortened: "test"}
-	responseBody := url.Original
+	testCases := []struct {
+		name                 string
+		method               string
+		query                string
+		headers              map[string]string
+		expectedVisit        model.Visit
+		redirect             *model.Redirect
+		expectedCode         int
+		expectedCacheControl string
+		expectedVary         string
+	}{
+		{
+			name:          "Success",
+			method:        http.MethodGet,
+			expectedVisit: model.Visit{Query: url.Values{}, ClientID: "192.0.2.1", IP: "192.0.2.1"},
+			redirect:      &model.Redirect{Location: URL, Code: http.StatusTemporaryRedirect},
+			expectedCode:  http.StatusTemporaryRedirect,
+		},
+		{
+			name:                 "Permanent cacheable redirect",
+			method:               http.MethodGet,
+			expectedVisit:        model.Visit{Query: url.Values{}, ClientID: "192.0.2.1", IP: "192.0.2.1"},
+			redirect:             &model.Redirect{Location: URL, Code: http.StatusPermanentRedirect, CacheControl: "public, max-age=86400"},
+			expectedCode:         http.StatusPermanentRedirect,
+			expectedCacheControl: "public, max-age=86400",
+		},
+		{
+			name:                 "HEAD",
+			method:               http.MethodHead,
+			expectedVisit:        model.Visit{Query: url.Values{}, ClientID: "192.0.2.1", IP: "192.0.2.1", Head: true},
+			redirect:             &model.Redirect{Location: URL, Code: http.StatusFound, CacheControl: "no-store"},
+			expectedCode:         http.StatusFound,
+			expectedCacheControl: "no-store",
+		},
+		{
+			name:                 "Visitor dependent redirect",
+			method:               http.MethodGet,
+			expectedVisit:        model.Visit{Query: url.Values{}, ClientID: "192.0.2.1", IP: "192.0.2.1"},
+			redirect:             &model.Redirect{Location: URL, Code: http.StatusMovedPermanently, CacheControl: "public, max-age=86400", VisitorDependent: true},
+			expectedCode:         http.StatusMovedPermanently,
+			expectedCacheControl: "private, no-store",
+			expectedVary:         "User-Agent, Accept-Language, Referer",
+		},
+		{
+			name:          "Visit query",
+			method:        http.MethodGet,
+			query:         "?ref=newsletter&tag=a&tag=b",
+			expectedVisit: model.Visit{Query: url.Values{"ref": {"newsletter"}, "tag": {"a", "b"}}, ClientID: "192.0.2.1", IP: "192.0.2.1"},
+			redirect:      &model.Redirect{Location: URL, Code: http.StatusTemporaryRedirect},
+			expectedCode:  http.StatusTemporaryRedirect,
+		}, {
+			name:   "Visitor headers",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent":      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)",
+				"Accept-Language": "de-DE,de;q=0.9",
+				"Referer":         "https://news.example.com/post",
+				"X-Real-IP":       "203.0.113.7",
+			},
+			expectedVisit: model.Visit{
+				Query:          url.Values{},
+				UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)",
+				AcceptLanguage: "de-DE,de;q=0.9",
+				Referrer:       "https://news.example.com/post",
+				ClientID:       "203.0.113.7",
+				IP:             "203.0.113.7",
+			},
+			redirect:     &model.Redirect{Location: URL, Code: http.StatusTemporaryRedirect},
+			expectedCode: http.StatusTemporaryRedirect,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			s.urlService.EXPECT().GetRedirect(gomock.Any(), "test", test.expectedVisit).Times(1).Return(test.redirect, nil)
+			request := httptest.NewRequest(test.method, "http://localhost:8080/test"+test.query, http.NoBody)
+			for name, value := range test.headers {
+				request.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, URL, w.Header().Get("Location"))
+			assert.Equal(t, test.expectedCacheControl, w.Header().Get(echo.HeaderCacheControl))
+			assert.Equal(t, test.expectedVary, w.Header().Get(echo.HeaderVary))
+			assert.Empty(t, w.Body.String())
+			s.ctrl.Finish()
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestFindURL_CountryBlocked() {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		s.T().Run(method, func(t *testing.T) {
+			s.urlService.EXPECT().GetRedirect(gomock.Any(), "test", gomock.Any()).Times(1).
+				Return(nil, apperr.NewValueError("url with id test is unavailable in country \"KP\"", apperr.Caller(), urlErr.ErrCountryBlocked))
+			request := httptest.NewRequest(method, "http://localhost:8080/test", http.NoBody)
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, http.StatusUnavailableForLegalReasons, w.Code)
+			assert.Empty(t, w.Header().Get("Location"))
+			assert.Equal(t, "private, no-store", w.Header().Get(echo.HeaderCacheControl))
+			s.ctrl.Finish()
+		})
+	}
+}
 
+func (s *URLHandlerTestSuite) TestFindURL_EmptyRequest() {
 	testCases := []struct {
 		name         string
 		method       string
@@ -890,17 +1714,17 @@ func (s *URLHandlerTestSuite) TestFindURL_Success() {
 		expectedBody string
 	}{
 		{
-			name:         "Success",
+			name:         "BadRequest - empty request",
 			method:       http.MethodPost,
-			expectedCode: http.StatusTemporaryRedirect,
-			path:         "http://localhost:8080/test",
-			expectedBody: "",
+			expectedCode: http.StatusBadRequest,
+			path:         "http://localhost:8080/",
+			expectedBody: problemBody(apierr.New(apierr.CodeEmptyRequest, "Unable to handle empty request"), "/"),
 		},
 	}
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().GetByyID(gomock.Any(), url.Shortened).Times(1).Return(responseBody, nil)
+			s.urlService.EXPECT().GetRedirect(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
 			w := httptest.NewRecorder()
 			l := s.echo.NewContext(request, w)
@@ -913,45 +1737,188 @@ func (s *URLHandlerTestSuite) TestFindURL_Success() {
 
 			assert.Equal(t, test.expectedCode, w.Code)
 			assert.Equal(t, test.expectedBody, w.Body.String())
-			s.ctrl.Finish()
+			defer s.ctrl.Finish()
 		})
 	}
 }
 
-func (s *URLHandlerTestSuite) TestFindURL_EmptyRequest() {
+func (s *URLHandlerTestSuite) TestImportURLs() {
 	testCases := []struct {
 		name         string
-		method       string
+		contentType  string
 		body         string
+		expectedRows []string
 		expectedCode int
-		path         string
-		expectedBody string
 	}{
 		{
-			name:         "BadRequest - empty request",
-			method:       http.MethodPost,
-			expectedCode: http.StatusBadRequest,
-			path:         "http://localhost:8080/",
-			expectedBody: "Error: Unable to handle empty request",
+			name:         "NDJSON",
+			contentType:  "application/x-ndjson",
+			body:         "{\"correlation_id\":\"1\",\"original_url\":\"http://example.com/1\"}\n\n{malformed}\n{\"correlation_id\":\"2\",\"original_url\":\"http://example.com/2\"}\n",
+			expectedRows: []string{"1 http://example.com/1", "invalid import row: malformed json", "2 http://example.com/2"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "CSV with header",
+			contentType:  "text/csv; charset=utf-8",
+			body:         "correlation_id,original_url\n1,http://example.com/1\n2\n3,http://example.com/3\n",
+			expectedRows: []string{"1 http://example.com/1", "invalid import row: expected 2 fields, got 1", "3 http://example.com/3"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "CSV without header",
+			contentType:  "text/csv",
+			body:         "1,http://example.com/1\n",
+			expectedRows: []string{"1 http://example.com/1"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Unsupported media type",
+			contentType:  "application/json",
+			body:         "[]",
+			expectedCode: http.StatusUnsupportedMediaType,
 		},
 	}
 
 	for _, test := range testCases {
 		s.T().Run(test.name, func(t *testing.T) {
-			s.urlService.EXPECT().GetByyID(gomock.Any(), gomock.Any()).Times(0)
-			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			var rows []string
+			if test.expectedRows != nil {
+				s.urlService.EXPECT().Import(gomock.Any(), gomock.Any(), URL, "token").Times(1).
+					DoAndReturn(func(_ context.Context, next func() (dto.URLBatchRequest, error), _ string, _ string) (*dto.URLImportSummary, error) {
+						for {
+							row, err := next()
+							if errors.Is(err, io.EOF) {
+								break
+							}
+							if err != nil {
+								rows = append(rows, err.Error())
+								continue
+							}
+							rows = append(rows, row.CorrelationID+" "+row.OriginalURL)
+						}
+						return &dto.URLImportSummary{Total: len(rows)}, nil
+					})
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/shorten/import", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
 			w := httptest.NewRecorder()
-			l := s.echo.NewContext(request, w)
-			request.Header.Set("Content-Type", "application/json")
+			l := s.echo.NewContext(req, w)
 			l.Set("userID", "token")
+
+			err := s.h.ImportURLs(l)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedRows, rows)
+			if test.expectedCode == http.StatusOK {
+				var summary dto.URLImportSummary
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+				assert.Equal(t, len(test.expectedRows), summary.Total)
+			}
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestImportURLs_Stopped() {
+	summary := &dto.URLImportSummary{Total: 3, Imported: 2}
+	s.urlService.EXPECT().Import(gomock.Any(), gomock.Any(), URL, "token").Times(1).Return(summary, errors.New("db down"))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/shorten/import", strings.NewReader("1,http://example.com/1\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	l := s.echo.NewContext(req, w)
+	l.Set("userID", "token")
+
+	err := s.h.ImportURLs(l)
+	s.Require().NoError(err)
+
+	s.Equal(http.StatusInternalServerError, w.Code)
+	s.Equal(apierr.ContentTypeProblemJSON, w.Header().Get(echo.HeaderContentType))
+	expected := apierr.New(apierr.CodeInternal, "import stopped, rows of the summary are saved").WithResult(summary)
+	s.JSONEq(problemBody(expected, "/api/shorten/import"), w.Body.String())
+}
+
+func (s *URLHandlerTestSuite) TestExportURLs() {
+	urls := []dto.URLBatchResponseByUserID{
+		{ShortURL: URL + "/1", OriginalURL: "http://example.com/1"},
+		{ShortURL: URL + "/2", OriginalURL: "http://example.com/2", DeletedFlag: true},
+	}
+	deleted := true
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedFilter model.URLFilter
+		expectedType   string
+		expectedCode   int
+		expectedBody   string
+	}{
+		{
+			name:         "NDJSON",
+			expectedType: "application/x-ndjson",
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"short_url\":\"http://localhost:8080/1\",\"original_url\":\"http://example.com/1\"}\n" +
+				"{\"short_url\":\"http://localhost:8080/2\",\"original_url\":\"http://example.com/2\",\"deleted_flag\":true}\n",
+		},
+		{
+			name:           "CSV deleted only",
+			query:          "?format=csv&deleted=true",
+			expectedFilter: model.URLFilter{Deleted: &deleted},
+			expectedType:   "text/csv",
+			expectedCode:   http.StatusOK,
+			expectedBody: "short_url,original_url,deleted_flag\n" +
+				"http://localhost:8080/1,http://example.com/1,false\n" +
+				"http://localhost:8080/2,http://example.com/2,true\n",
+		},
+		{
+			name:         "Invalid filter",
+			query:        "?deleted=maybe",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: problemBody(apierr.Field("deleted", `strconv.ParseBool: parsing "maybe": invalid syntax`), "/api/user/urls/export"),
+		},
+		{
+			name:         "Unsupported format",
+			query:        "?format=xml",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: problemBody(apierr.Field("format", "must be ndjson or csv"), "/api/user/urls/export"),
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.expectedCode == http.StatusOK {
+				s.urlService.EXPECT().Export(gomock.Any(), "token", test.expectedFilter, gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, _ string, _ model.URLFilter, fn func(dto.URLBatchResponseByUserID) error) error {
+						for _, url := range urls {
+							if err := fn(url); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/urls/export"+test.query, http.NoBody)
+			w := httptest.NewRecorder()
+			l := s.echo.NewContext(req, w)
 			l.Set("userID", "token")
 
-			err := s.h.FindURL(l)
+			err := s.h.ExportURLs(l)
 			require.NoError(t, err)
 
 			assert.Equal(t, test.expectedCode, w.Code)
 			assert.Equal(t, test.expectedBody, w.Body.String())
-			defer s.ctrl.Finish()
+			if test.expectedType != "" {
+				assert.Equal(t, test.expectedType, w.Header().Get("Content-Type"))
+			}
 		})
 	}
 }
+
+// problemBody returns expected problem details body of response to request with path.
+func problemBody(p *apierr.Problem, path string) string {
+	p.Instance = path
+	body, _ := json.Marshal(p)
+	return string(body)
+}
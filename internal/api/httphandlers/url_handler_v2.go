@@ -0,0 +1,107 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// AddURLV2 handles the addition of a single URL (got as json) and returns the saved URL.
+//
+// Returns 201 if the URL is created and 200 with the existing URL if it is already shortened.
+func (h *URLShorten) AddURLV2(c echo.Context) error {
+	var urlRequest dto.URLRequest
+	if err := bindJSON(c, &urlRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+	}
+
+	if err := h.checkRequest(urlRequest.URL); err != nil {
+		h.logger.Error("StatusBadRequest: unable to handle empty request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	url, err := h.urlService.Add(c.Request().Context(), urlRequest.URL, h.urlPrefix, userID)
+	if err != nil && !errors.Is(err, urlErr.ErrURLAlreadyExists) {
+		h.logger.Error("StatusInternalServerError: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	if errors.Is(err, urlErr.ErrURLAlreadyExists) {
+		return c.JSON(http.StatusOK, newURLV2(url))
+	}
+
+	return c.JSON(http.StatusCreated, newURLV2(url))
+}
+
+// FindAllURLByUserIDV2 retrieves a page of URLs for a given user ID.
+//
+// Supports the same query parameters as FindAllURLByUserID, the page is empty if user has no URLs.
+func (h *URLShorten) FindAllURLByUserIDV2(c echo.Context) error {
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	query, param, err := parseURLQuery(c)
+	if err != nil {
+		h.logger.Info("StatusBadRequest: invalid "+param+" parameter", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.Field(param, err.Error()))
+	}
+	query.UserID = userID
+
+	page, err := h.urlService.GetAllURLs(c.Request().Context(), query)
+	if errors.Is(err, urlErr.ErrInvalidQuery) {
+		h.logger.Info("StatusBadRequest: invalid query", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	if err != nil {
+		h.logger.Error("StatusInternalServerError: unknown error", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	response := dto.URLPageV2{URLs: make([]dto.URLV2, 0, len(page.URLs)), NextPageToken: page.NextPageToken}
+	for _, record := range page.URLs {
+		response.URLs = append(response.URLs, dto.URLV2{
+			ID:            record.ID,
+			ShortURL:      record.ShortURL,
+			OriginalURL:   record.OriginalURL,
+			CorrelationID: record.CorrelationID,
+			Deleted:       record.DeletedFlag,
+			CreatedAt:     record.CreatedAt,
+		})
+	}
+
+	setNextLink(c, page.NextPageToken)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// newURLV2 converts URL model to URLV2.
+func newURLV2(url *model.URL) dto.URLV2 {
+	return dto.URLV2{
+		ID:            url.ID,
+		ShortURL:      url.Shortened,
+		OriginalURL:   url.Original,
+		CorrelationID: url.CorrelationID,
+		Deleted:       url.DeletedFlag,
+		CreatedAt:     url.CreatedAt,
+	}
+}
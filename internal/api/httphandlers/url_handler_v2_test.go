@@ -0,0 +1,162 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+func (s *URLHandlerTestSuite) TestAddURLV2() {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	url := &model.URL{
+		ID:        "Yzk4NGQwNmFhZmJlY2Y2YmM1NTU2OWY5NjQxNDhlYTM",
+		Original:  URL,
+		Shortened: URL + "/Yzk4NGQwNmFhZmJlY2Y2YmM1NTU2OWY5NjQxNDhlYTM",
+		UserID:    "token",
+		CreatedAt: createdAt,
+	}
+	expected := dto.URLV2{
+		ID:          url.ID,
+		ShortURL:    url.Shortened,
+		OriginalURL: url.Original,
+		CreatedAt:   createdAt,
+	}
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{name: "Created", expectedCode: http.StatusCreated},
+		{name: "Already exists", err: urlErr.ErrURLAlreadyExists, expectedCode: http.StatusOK},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			s.urlService.EXPECT().Add(gomock.Any(), URL, cfgMock.URLPrefix, "token").Times(1).Return(url, test.err)
+			request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v2/urls", strings.NewReader(`{"url":"`+URL+`"}`))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			l := s.echo.NewContext(request, w)
+			l.Set("userID", "token")
+
+			err := s.h.AddURLV2(l)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			var result dto.URLV2
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, expected, result)
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestFindAllURLByUserIDV2() {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	page := &dto.URLRecordPage{
+		URLs: []dto.URLRecord{
+			{ID: "id1", OriginalURL: URL, ShortURL: URL + "/id1", UserID: "token", DeletedFlag: true, CreatedAt: createdAt},
+		},
+		NextPageToken: "next",
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		expectedQuery dto.URLQuery
+		page          *dto.URLRecordPage
+		err           error
+		expectedCode  int
+		expectedBody  *dto.URLPageV2
+	}{
+		{
+			name:          "Page",
+			path:          "http://localhost:8080/api/v2/user/urls?limit=1",
+			expectedQuery: dto.URLQuery{Limit: 1, UserID: "token"},
+			page:          page,
+			expectedCode:  http.StatusOK,
+			expectedBody: &dto.URLPageV2{
+				URLs:          []dto.URLV2{{ID: "id1", ShortURL: URL + "/id1", OriginalURL: URL, Deleted: true, CreatedAt: createdAt}},
+				NextPageToken: "next",
+			},
+		},
+		{
+			name:          "Empty page",
+			path:          "http://localhost:8080/api/v2/user/urls",
+			expectedQuery: dto.URLQuery{UserID: "token"},
+			page:          &dto.URLRecordPage{},
+			expectedCode:  http.StatusOK,
+			expectedBody:  &dto.URLPageV2{URLs: []dto.URLV2{}},
+		},
+		{
+			name:          "Invalid query",
+			path:          "http://localhost:8080/api/v2/user/urls?sort=unknown",
+			expectedQuery: dto.URLQuery{Sort: "unknown", UserID: "token"},
+			err:           urlErr.ErrInvalidQuery,
+			expectedCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			s.urlService.EXPECT().GetAllURLs(gomock.Any(), test.expectedQuery).Times(1).Return(test.page, test.err)
+			request := httptest.NewRequest(http.MethodGet, test.path, http.NoBody)
+			w := httptest.NewRecorder()
+			l := s.echo.NewContext(request, w)
+			l.Set("userID", "token")
+
+			err := s.h.FindAllURLByUserIDV2(l)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != nil {
+				var result dto.URLPageV2
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+				assert.Equal(t, *test.expectedBody, result)
+			}
+		})
+	}
+}
+
+func (s *URLHandlerTestSuite) TestDeprecatedRoutes() {
+	testCases := []struct {
+		name               string
+		path               string
+		expectedDeprecated bool
+	}{
+		{name: "Unversioned route", path: "/api/shorten", expectedDeprecated: true},
+		{name: "Versioned route", path: "/api/v1/shorten"},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			s.urlService.EXPECT().Add(gomock.Any(), URL, cfgMock.URLPrefix, gomock.Any()).Times(1).Return(&model.URL{Shortened: URL + "/id"}, nil)
+			request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(`{"url":"`+URL+`"}`))
+			request.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			if test.expectedDeprecated {
+				assert.Equal(t, "true", w.Header().Get("Deprecation"))
+				assert.Equal(t, "Tue, 01 Jan 2030 00:00:00 GMT", w.Header().Get("Sunset"))
+				assert.Equal(t, "</api/v1/shorten>; rel=\"successor-version\"", w.Header().Get("Link"))
+			} else {
+				assert.Empty(t, w.Header().Get("Deprecation"))
+				assert.Empty(t, w.Header().Get("Link"))
+			}
+		})
+	}
+}
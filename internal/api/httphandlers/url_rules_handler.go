@@ -0,0 +1,166 @@
+package httphandlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// GetRules returns redirect rules of the user's URL in evaluation order.
+func (h *URLShorten) GetRules(c echo.Context) error {
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	rules, err := h.urlService.GetRules(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		h.logger.Warn("unable to get rules", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusOK, toRedirectRulesDTO(rules))
+}
+
+// SetRules replaces redirect rules of the user's URL, the order of rules is the evaluation order.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) SetRules(c echo.Context) error {
+	var rulesRequest dto.RedirectRules
+	if err := bindJSON(c, &rulesRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	rules := make([]model.RedirectRule, len(rulesRequest.Rules))
+	for i, rule := range rulesRequest.Rules {
+		rules[i] = toRedirectRule(rule)
+	}
+
+	rules, err := h.urlService.SetRules(c.Request().Context(), userID, c.Param("id"), rules)
+	if err != nil {
+		h.logger.Warn("unable to set rules", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusOK, toRedirectRulesDTO(rules))
+}
+
+// AddRule appends a redirect rule to rules of the user's URL.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) AddRule(c echo.Context) error {
+	var ruleRequest dto.RedirectRule
+	if err := bindJSON(c, &ruleRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	rule, err := h.urlService.AddRule(c.Request().Context(), userID, c.Param("id"), toRedirectRule(ruleRequest))
+	if err != nil {
+		h.logger.Warn("unable to add rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusCreated, toRedirectRuleDTO(*rule))
+}
+
+// UpdateRule replaces a redirect rule of the user's URL keeping its position.
+//
+// Content-Type and body are validated against the OpenAPI specification by RequestValidator.
+func (h *URLShorten) UpdateRule(c echo.Context) error {
+	var ruleRequest dto.RedirectRule
+	if err := bindJSON(c, &ruleRequest); err != nil {
+		h.logger.Error("StatusBadRequest: unable to read request", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+	}
+
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	rule, err := h.urlService.UpdateRule(c.Request().Context(), userID, c.Param("id"), c.Param("rule_id"), toRedirectRule(ruleRequest))
+	if err != nil {
+		h.logger.Warn("unable to update rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.JSON(http.StatusOK, toRedirectRuleDTO(*rule))
+}
+
+// DeleteRule deletes a redirect rule of the user's URL.
+func (h *URLShorten) DeleteRule(c echo.Context) error {
+	userID, ok := c.Get("userID").(string)
+	if !ok {
+		h.logger.Error("Internal server error", zap.Error(urlErr.ErrUnableToGetUserIDFromContext))
+		return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
+	}
+
+	err := h.urlService.DeleteRule(c.Request().Context(), userID, c.Param("id"), c.Param("rule_id"))
+	if err != nil {
+		h.logger.Warn("unable to delete rule", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+		return apierr.Write(c, apierr.FromError(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// toRedirectRule converts redirect rule of the request to the model.
+func toRedirectRule(rule dto.RedirectRule) model.RedirectRule {
+	return model.RedirectRule{
+		ID:            rule.ID,
+		Destination:   rule.Destination,
+		Devices:       rule.Devices,
+		Languages:     rule.Languages,
+		ReferrerHosts: rule.ReferrerHosts,
+		From:          rule.From,
+		Until:         rule.Until,
+		Percent:       rule.Percent,
+	}
+}
+
+// toRedirectRuleDTO converts redirect rule of the model to the response.
+func toRedirectRuleDTO(rule model.RedirectRule) dto.RedirectRule {
+	return dto.RedirectRule{
+		ID:            rule.ID,
+		Destination:   rule.Destination,
+		Devices:       rule.Devices,
+		Languages:     rule.Languages,
+		ReferrerHosts: rule.ReferrerHosts,
+		From:          rule.From,
+		Until:         rule.Until,
+		Percent:       rule.Percent,
+	}
+}
+
+// toRedirectRulesDTO converts redirect rules of the model to the response, no rules are an empty list.
+func toRedirectRulesDTO(rules []model.RedirectRule) dto.RedirectRules {
+	response := dto.RedirectRules{Rules: make([]dto.RedirectRule, len(rules))}
+	for i, rule := range rules {
+		response.Rules[i] = toRedirectRuleDTO(rule)
+	}
+	return response
+}
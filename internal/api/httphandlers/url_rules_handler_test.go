@@ -0,0 +1,207 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+func (s *URLHandlerTestSuite) TestRules() {
+	launch := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	iosRule := model.RedirectRule{ID: "r1", Destination: "https://apps.apple.com/app/id1", Devices: []string{model.DeviceIOS}}
+	launchRule := model.RedirectRule{ID: "r2", Destination: "http://example.com/launch", From: &launch, Percent: 50}
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		token        bool
+		requestBody  string
+		prepare      func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "List",
+			method:       http.MethodGet,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"rules": [
+				{"id": "r1", "destination": "https://apps.apple.com/app/id1", "devices": ["ios"]},
+				{"id": "r2", "destination": "http://example.com/launch", "from": "2030-01-02T03:04:05Z", "percent": 50}
+			]}`,
+			prepare: func() {
+				s.urlService.EXPECT().GetRules(gomock.Any(), "token", "NjQyYTU").Return([]model.RedirectRule{iosRule, launchRule}, nil)
+			},
+		},
+		{
+			name:         "List - no rules",
+			method:       http.MethodGet,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"rules": []}`,
+			prepare: func() {
+				s.urlService.EXPECT().GetRules(gomock.Any(), "token", "NjQyYTU").Return(nil, nil)
+			},
+		},
+		{
+			name:         "List - deleted URL",
+			method:       http.MethodGet,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			expectedCode: http.StatusGone,
+			prepare: func() {
+				s.urlService.EXPECT().GetRules(gomock.Any(), "token", "NjQyYTU").Return(nil, urlErr.ErrURLDeleted)
+			},
+		},
+		{
+			name:   "Set",
+			method: http.MethodPut,
+			path:   "/api/v1/user/urls/NjQyYTU/rules",
+			token:  true,
+			requestBody: `{"rules": [
+				{"id": "r1", "destination": "https://apps.apple.com/app/id1", "devices": ["ios"]},
+				{"destination": "http://example.com/launch", "from": "2030-01-02T03:04:05Z", "percent": 50}
+			]}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"rules": [
+				{"id": "r1", "destination": "https://apps.apple.com/app/id1", "devices": ["ios"]},
+				{"id": "r2", "destination": "http://example.com/launch", "from": "2030-01-02T03:04:05Z", "percent": 50}
+			]}`,
+			prepare: func() {
+				requested := []model.RedirectRule{iosRule, launchRule}
+				requested[1].ID = ""
+				s.urlService.EXPECT().SetRules(gomock.Any(), "token", "NjQyYTU", requested).Return([]model.RedirectRule{iosRule, launchRule}, nil)
+			},
+		},
+		{
+			name:         "Set - unknown device",
+			method:       http.MethodPut,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			requestBody:  `{"rules": [{"destination": "http://example.com", "devices": ["blackberry"]}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Set - invalid rule",
+			method:       http.MethodPut,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			requestBody:  `{"rules": [{"destination": "not a url"}]}`,
+			expectedCode: http.StatusBadRequest,
+			prepare: func() {
+				s.urlService.EXPECT().SetRules(gomock.Any(), "token", "NjQyYTU", []model.RedirectRule{{Destination: "not a url"}}).
+					Return(nil, apperr.NewValueError("destination must be valid URL", apperr.Caller(), urlErr.ErrInvalidRule))
+			},
+		},
+		{
+			name:         "Add",
+			method:       http.MethodPost,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			requestBody:  `{"destination": "https://apps.apple.com/app/id1", "devices": ["ios"]}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id": "r1", "destination": "https://apps.apple.com/app/id1", "devices": ["ios"]}`,
+			prepare: func() {
+				requested := iosRule
+				requested.ID = ""
+				s.urlService.EXPECT().AddRule(gomock.Any(), "token", "NjQyYTU", requested).Return(&iosRule, nil)
+			},
+		},
+		{
+			name:         "Add - missing destination",
+			method:       http.MethodPost,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			token:        true,
+			requestBody:  `{"devices": ["ios"]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Update",
+			method:       http.MethodPut,
+			path:         "/api/v1/user/urls/NjQyYTU/rules/r1",
+			token:        true,
+			requestBody:  `{"destination": "https://apps.apple.com/app/id1", "devices": ["ios"]}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id": "r1", "destination": "https://apps.apple.com/app/id1", "devices": ["ios"]}`,
+			prepare: func() {
+				requested := iosRule
+				requested.ID = ""
+				s.urlService.EXPECT().UpdateRule(gomock.Any(), "token", "NjQyYTU", "r1", requested).Return(&iosRule, nil)
+			},
+		},
+		{
+			name:         "Update - rule not found",
+			method:       http.MethodPut,
+			path:         "/api/v1/user/urls/NjQyYTU/rules/r9",
+			token:        true,
+			requestBody:  `{"destination": "http://example.com"}`,
+			expectedCode: http.StatusNotFound,
+			prepare: func() {
+				s.urlService.EXPECT().UpdateRule(gomock.Any(), "token", "NjQyYTU", "r9", model.RedirectRule{Destination: "http://example.com"}).
+					Return(nil, urlErr.ErrRuleNotFound)
+			},
+		},
+		{
+			name:         "Delete",
+			method:       http.MethodDelete,
+			path:         "/api/v1/user/urls/NjQyYTU/rules/r1",
+			token:        true,
+			expectedCode: http.StatusNoContent,
+			prepare: func() {
+				s.urlService.EXPECT().DeleteRule(gomock.Any(), "token", "NjQyYTU", "r1").Return(nil)
+			},
+		},
+		{
+			name:         "Delete - URL not found",
+			method:       http.MethodDelete,
+			path:         "/api/v1/user/urls/NjQyYTU/rules/r1",
+			token:        true,
+			expectedCode: http.StatusNotFound,
+			prepare: func() {
+				s.urlService.EXPECT().DeleteRule(gomock.Any(), "token", "NjQyYTU", "r1").Return(urlErr.ErrURLNotFound)
+			},
+		},
+		{
+			name:         "Unauthorized",
+			method:       http.MethodGet,
+			path:         "/api/v1/user/urls/NjQyYTU/rules",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range testCases {
+		s.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			}
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.requestBody))
+			if test.requestBody != "" {
+				request.Header.Set("Content-Type", "application/json")
+			}
+			if test.token {
+				s.setToken(request, "token")
+			}
+			w := httptest.NewRecorder()
+
+			s.echo.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, w.Body.String())
+			}
+			s.ctrl.Finish()
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package openapi provides the OpenAPI 3 specification of the HTTP API and Swagger UI handlers.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	swaggerFiles "github.com/swaggo/files/v2"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// SpecPath is a path the specification is served at.
+	SpecPath = "/api/openapi.json"
+	// DocsPath is a path prefix Swagger UI is served at.
+	DocsPath = "/api/docs/"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// swaggerInitializer replaces bundled Swagger UI initializer to load SpecPath.
+//
+//go:embed swagger-initializer.js
+var swaggerInitializer []byte
+
+// Load parses and validates the embedded specification.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to load openapi spec", apperr.Caller(), err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return doc, nil
+}
+
+// SpecHandler serves the specification as is.
+func SpecHandler(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, spec)
+}
+
+// DocsHandler serves Swagger UI files, the route is expected to end with a wildcard.
+func DocsHandler(c echo.Context) error {
+	file := c.Param("*")
+	switch file {
+	case "":
+		file = "index.html"
+	case "swagger-initializer.js":
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJavaScript, swaggerInitializer)
+	}
+
+	return echo.StaticFileHandler(file, swaggerFiles.FS)(c)
+}
@@ -0,0 +1,330 @@
+// Package apierr maps application errors to stable API error codes.
+//
+// Errors are returned over HTTP as RFC 7807 application/problem+json bodies
+// and over gRPC as statuses with errdetails.ErrorInfo and errdetails.BadRequest details.
+// Only the code and a safe detail are returned to clients, internal errors are to be logged by callers.
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// ContentTypeProblemJSON is a media type of problem details body.
+const ContentTypeProblemJSON = "application/problem+json"
+
+// Domain is reported in gRPC ErrorInfo details.
+const Domain = "yap-shortener"
+
+// Code is a stable machine-readable error code.
+type Code string
+
+// Codes
+const (
+	CodeInvalidArgument      Code = "INVALID_ARGUMENT"
+	CodeInvalidQuery         Code = "INVALID_QUERY"
+	CodeEmptyRequest         Code = "EMPTY_REQUEST"
+	CodeDuplicatedKeys       Code = "DUPLICATED_KEYS"
+	CodeInvalidImportRow     Code = "INVALID_IMPORT_ROW"
+	CodeInvalidBatchItem     Code = "INVALID_BATCH_ITEM"
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodePayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	CodeURLNotFound          Code = "URL_NOT_FOUND"
+	CodeURLDeleted           Code = "URL_DELETED"
+	CodeURLAlreadyExists     Code = "URL_ALREADY_EXISTS"
+	CodeRuleNotFound         Code = "RULE_NOT_FOUND"
+	CodeCountryBlocked       Code = "COUNTRY_BLOCKED"
+	CodeUnauthenticated      Code = "UNAUTHENTICATED"
+	CodePermissionDenied     Code = "PERMISSION_DENIED"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeMethodNotAllowed     Code = "METHOD_NOT_ALLOWED"
+	CodeIdempotencyKeyReused Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestInProgress    Code = "REQUEST_IN_PROGRESS"
+	CodeUnavailable          Code = "UNAVAILABLE"
+	CodeInternal             Code = "INTERNAL"
+)
+
+// codeInfo describes default HTTP status, gRPC code and title of error code.
+type codeInfo struct {
+	status   int
+	grpcCode codes.Code
+	title    string
+}
+
+var codeInfos = map[Code]codeInfo{
+	CodeInvalidArgument:      {http.StatusBadRequest, codes.InvalidArgument, "Invalid argument"},
+	CodeInvalidQuery:         {http.StatusBadRequest, codes.InvalidArgument, "Invalid query"},
+	CodeEmptyRequest:         {http.StatusBadRequest, codes.InvalidArgument, "Empty request"},
+	CodeDuplicatedKeys:       {http.StatusBadRequest, codes.InvalidArgument, "Duplicated keys"},
+	CodeInvalidImportRow:     {http.StatusBadRequest, codes.InvalidArgument, "Invalid import row"},
+	CodeInvalidBatchItem:     {http.StatusBadRequest, codes.InvalidArgument, "Invalid batch item"},
+	CodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, codes.InvalidArgument, "Unsupported media type"},
+	CodePayloadTooLarge:      {http.StatusRequestEntityTooLarge, codes.ResourceExhausted, "Payload too large"},
+	CodeURLNotFound:          {http.StatusNotFound, codes.NotFound, "URL not found"},
+	CodeURLDeleted:           {http.StatusGone, codes.NotFound, "URL deleted"},
+	CodeURLAlreadyExists:     {http.StatusConflict, codes.AlreadyExists, "URL already exists"},
+	CodeRuleNotFound:         {http.StatusNotFound, codes.NotFound, "Redirect rule not found"},
+	CodeCountryBlocked:       {http.StatusUnavailableForLegalReasons, codes.PermissionDenied, "Unavailable in visitor country"},
+	CodeUnauthenticated:      {http.StatusUnauthorized, codes.Unauthenticated, "Unauthenticated"},
+	CodePermissionDenied:     {http.StatusForbidden, codes.PermissionDenied, "Permission denied"},
+	CodeNotFound:             {http.StatusNotFound, codes.NotFound, "Not found"},
+	CodeMethodNotAllowed:     {http.StatusMethodNotAllowed, codes.Unimplemented, "Method not allowed"},
+	CodeIdempotencyKeyReused: {http.StatusUnprocessableEntity, codes.FailedPrecondition, "Idempotency key reused"},
+	CodeRequestInProgress:    {http.StatusConflict, codes.Aborted, "Request in progress"},
+	CodeUnavailable:          {http.StatusServiceUnavailable, codes.Unavailable, "Service unavailable"},
+	CodeInternal:             {http.StatusInternalServerError, codes.Internal, "Internal error"},
+}
+
+// sentinels maps urlerr sentinel errors to codes, the first match wins.
+var sentinels = []struct {
+	err  error
+	code Code
+}{
+	{urlErr.ErrInvalidQuery, CodeInvalidQuery},
+	{urlErr.ErrInvalidURL, CodeInvalidArgument},
+	{urlErr.ErrInvalidRedirect, CodeInvalidArgument},
+	{urlErr.ErrInvalidRule, CodeInvalidArgument},
+	{urlErr.ErrEmptyRequest, CodeEmptyRequest},
+	{urlErr.ErrDuplicatedKeys, CodeDuplicatedKeys},
+	{urlErr.ErrInvalidImportRow, CodeInvalidImportRow},
+	{urlErr.ErrInvalidBatchItem, CodeInvalidBatchItem},
+	{urlErr.ErrURLNotFound, CodeURLNotFound},
+	{urlErr.ErrURLDeleted, CodeURLDeleted},
+	{urlErr.ErrURLAlreadyExists, CodeURLAlreadyExists},
+	{urlErr.ErrRuleNotFound, CodeRuleNotFound},
+	{urlErr.ErrCountryBlocked, CodeCountryBlocked},
+}
+
+// FieldViolation describes an invalid request field.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Problem represents RFC 7807 problem details extended with error code and field violations.
+//
+// Problem implements error and can be returned from gRPC handlers as is.
+type Problem struct {
+	Type       string           `json:"type"`
+	Title      string           `json:"title"`
+	Status     int              `json:"status"`
+	Detail     string           `json:"detail,omitempty"`
+	Instance   string           `json:"instance,omitempty"`
+	Code       Code             `json:"code"`
+	Violations []FieldViolation `json:"violations,omitempty"`
+	// Result is an extension member with partial result of the failed operation, it is not sent over gRPC.
+	Result any `json:"result,omitempty"`
+}
+
+// New creates a new Problem with default status of the code.
+func New(code Code, detail string, violations ...FieldViolation) *Problem {
+	info, ok := codeInfos[code]
+	if !ok {
+		code, info = CodeInternal, codeInfos[CodeInternal]
+	}
+
+	return &Problem{
+		Type:       "urn:" + Domain + ":error:" + strings.ToLower(strings.ReplaceAll(string(code), "_", "-")),
+		Title:      info.title,
+		Status:     info.status,
+		Detail:     detail,
+		Code:       code,
+		Violations: violations,
+	}
+}
+
+// Field creates a new INVALID_ARGUMENT Problem for a single invalid field.
+func Field(field string, description string) *Problem {
+	return New(CodeInvalidArgument, "invalid "+field, FieldViolation{Field: field, Description: description})
+}
+
+// FromError maps err to Problem.
+//
+// Errors wrapping urlerr sentinels get their codes, the detail is a message of apperr.ValueError
+// (which never contains caller location) or the sentinel text. Any other error is INTERNAL without detail.
+func FromError(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	for _, s := range sentinels {
+		if !errors.Is(err, s.err) {
+			continue
+		}
+
+		detail := s.err.Error()
+		var valueErr *apperr.ValueError
+		if errors.As(err, &valueErr) && errors.Is(valueErr, s.err) {
+			detail = valueErr.Message()
+		}
+		return New(s.code, detail)
+	}
+
+	return New(CodeInternal, "")
+}
+
+// WithStatus returns a copy of Problem with HTTP status overridden.
+func (p *Problem) WithStatus(status int) *Problem {
+	problem := *p
+	problem.Status = status
+	return &problem
+}
+
+// WithResult returns a copy of Problem with partial result of the failed operation.
+func (p *Problem) WithResult(result any) *Problem {
+	problem := *p
+	problem.Result = result
+	return &problem
+}
+
+// Error returns a string representing the problem.
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return string(p.Code)
+	}
+	return string(p.Code) + ": " + p.Detail
+}
+
+// GRPCStatus converts Problem to gRPC status with ErrorInfo and BadRequest details.
+func (p *Problem) GRPCStatus() *status.Status {
+	message := p.Detail
+	if message == "" {
+		message = p.Title
+	}
+
+	st := status.New(codeInfos[p.Code].grpcCode, message)
+	info := &errdetails.ErrorInfo{Reason: string(p.Code), Domain: Domain}
+
+	withDetails, err := st.WithDetails(info)
+	if len(p.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range p.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		withDetails, err = st.WithDetails(info, badRequest)
+	}
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// FromStatus maps gRPC status back to Problem.
+//
+// Statuses created by GRPCStatus keep their code, violations and HTTP status,
+// others are mapped by gRPC code.
+func FromStatus(st *status.Status) *Problem {
+	code := CodeInternal
+	switch st.Code() {
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		code = CodeInvalidArgument
+	case codes.NotFound:
+		code = CodeNotFound
+	case codes.AlreadyExists:
+		code = CodeURLAlreadyExists
+	case codes.Unauthenticated:
+		code = CodeUnauthenticated
+	case codes.PermissionDenied:
+		code = CodePermissionDenied
+	case codes.Unimplemented:
+		code = CodeMethodNotAllowed
+	}
+
+	var violations []FieldViolation
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if _, ok := codeInfos[Code(d.Reason)]; ok && d.Domain == Domain {
+				code = Code(d.Reason)
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				violations = append(violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		}
+	}
+
+	detail := st.Message()
+	if code == CodeInternal || detail == codeInfos[code].title {
+		detail = ""
+	}
+	return New(code, detail, violations...)
+}
+
+// Write writes Problem as application/problem+json response.
+func Write(c echo.Context, p *Problem) error {
+	return WriteResponse(c.Response(), c.Request(), p)
+}
+
+// WriteResponse writes Problem as application/problem+json response to plain http.ResponseWriter.
+func WriteResponse(w http.ResponseWriter, r *http.Request, p *Problem) error {
+	problem := *p
+	problem.Instance = r.URL.Path
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set(echo.HeaderContentType, ContentTypeProblemJSON)
+	w.WriteHeader(problem.Status)
+	_, err = w.Write(body)
+	return err
+}
+
+// HTTPErrorHandler writes errors returned by handlers and middlewares as problem details.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := FromError(err)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		problem = fromHTTPError(httpErr)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		_ = c.NoContent(problem.Status)
+		return
+	}
+	_ = Write(c, problem)
+}
+
+// fromHTTPError maps echo.HTTPError, returned by router and echo middlewares, to Problem.
+func fromHTTPError(err *echo.HTTPError) *Problem {
+	var code Code
+	switch err.Code {
+	case http.StatusNotFound:
+		code = CodeNotFound
+	case http.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case http.StatusUnauthorized:
+		code = CodeUnauthenticated
+	case http.StatusForbidden:
+		code = CodePermissionDenied
+	case http.StatusUnsupportedMediaType:
+		code = CodeUnsupportedMediaType
+	case http.StatusInternalServerError:
+		return New(CodeInternal, "")
+	default:
+		code = CodeInvalidArgument
+	}
+
+	detail, _ := err.Message.(string)
+	return New(code, detail).WithStatus(err.Code)
+}
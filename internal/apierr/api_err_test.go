@@ -0,0 +1,178 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+func TestFromError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   Code
+		expectedStatus int
+		expectedDetail string
+	}{
+		{
+			name:           "Value error with sentinel",
+			err:            fmt.Errorf("%s %w", apperr.Caller(), apperr.NewValueError("negative limit", apperr.Caller(), urlErr.ErrInvalidQuery)),
+			expectedCode:   CodeInvalidQuery,
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: "negative limit",
+		},
+		{
+			name:           "Wrapped sentinel",
+			err:            fmt.Errorf("%s %w", apperr.Caller(), urlErr.ErrURLAlreadyExists),
+			expectedCode:   CodeURLAlreadyExists,
+			expectedStatus: http.StatusConflict,
+			expectedDetail: urlErr.ErrURLAlreadyExists.Error(),
+		},
+		{
+			name:           "Value error without sentinel",
+			err:            apperr.NewValueError("query failed", apperr.Caller(), errors.New("connection refused")),
+			expectedCode:   CodeInternal,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Problem",
+			err:            fmt.Errorf("wrapped: %w", Field("limit", "must be positive")),
+			expectedCode:   CodeInvalidArgument,
+			expectedStatus: http.StatusBadRequest,
+			expectedDetail: "invalid limit",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			problem := FromError(test.err)
+			assert.Equal(t, test.expectedCode, problem.Code)
+			assert.Equal(t, test.expectedStatus, problem.Status)
+			assert.Equal(t, test.expectedDetail, problem.Detail)
+			assert.NotContains(t, problem.Detail, "api_err_test.go")
+		})
+	}
+}
+
+func TestGRPCStatus(t *testing.T) {
+	st := status.Convert(Field("url", "must not be empty"))
+
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "invalid url", st.Message())
+	require.Len(t, st.Details(), 2)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, string(CodeInvalidArgument), info.Reason)
+	assert.Equal(t, Domain, info.Domain)
+
+	badRequest, ok := st.Details()[1].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.FieldViolations, 1)
+	assert.Equal(t, "url", badRequest.FieldViolations[0].Field)
+	assert.Equal(t, "must not be empty", badRequest.FieldViolations[0].Description)
+}
+
+func TestFromStatus(t *testing.T) {
+	testCases := []struct {
+		name               string
+		status             *status.Status
+		expectedCode       Code
+		expectedStatus     int
+		expectedDetail     string
+		expectedViolations []FieldViolation
+	}{
+		{
+			name:           "Problem status",
+			status:         New(CodeURLDeleted, "URL with id abc deleted").GRPCStatus(),
+			expectedCode:   CodeURLDeleted,
+			expectedStatus: http.StatusGone,
+			expectedDetail: "URL with id abc deleted",
+		},
+		{
+			name:               "Problem status with violations",
+			status:             Field("url", "must not be empty").GRPCStatus(),
+			expectedCode:       CodeInvalidArgument,
+			expectedStatus:     http.StatusBadRequest,
+			expectedDetail:     "invalid url",
+			expectedViolations: []FieldViolation{{Field: "url", Description: "must not be empty"}},
+		},
+		{
+			name:           "Problem status without detail",
+			status:         New(CodeUnauthenticated, "").GRPCStatus(),
+			expectedCode:   CodeUnauthenticated,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Plain status",
+			status:         status.New(codes.PermissionDenied, "denied"),
+			expectedCode:   CodePermissionDenied,
+			expectedStatus: http.StatusForbidden,
+			expectedDetail: "denied",
+		},
+		{
+			name:           "Unknown status",
+			status:         status.New(codes.Unavailable, "connection refused"),
+			expectedCode:   CodeInternal,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			problem := FromStatus(test.status)
+			assert.Equal(t, test.expectedCode, problem.Code)
+			assert.Equal(t, test.expectedStatus, problem.Status)
+			assert.Equal(t, test.expectedDetail, problem.Detail)
+			assert.Equal(t, test.expectedViolations, problem.Violations)
+		})
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/internal", func(c echo.Context) error {
+		return apperr.NewValueError("query failed", apperr.Caller(), errors.New("connection refused"))
+	})
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedCode   Code
+	}{
+		{name: "Unknown route", path: "/unknown/route", expectedStatus: http.StatusNotFound, expectedCode: CodeNotFound},
+		{name: "Internal error", path: "/internal", expectedStatus: http.StatusInternalServerError, expectedCode: CodeInternal},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, http.NoBody))
+
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, ContentTypeProblemJSON, w.Header().Get(echo.HeaderContentType))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, test.expectedCode, problem.Code)
+			assert.Equal(t, test.expectedStatus, problem.Status)
+			assert.Equal(t, test.path, problem.Instance)
+			assert.NotContains(t, w.Body.String(), "connection refused")
+		})
+	}
+}
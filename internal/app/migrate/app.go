@@ -0,0 +1,204 @@
+// Package migrate implements shortener-migrate command managing the database schema.
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/repository/db"
+)
+
+const usage = `Usage: shortener-migrate [flags] command [arg]
+
+Commands:
+  up [N]      apply all or N up migrations
+  down [N]    apply all or N down migrations
+  goto V      migrate up or down to version V
+  force V     set version V and clear dirty state without running migrations (-1 means no version)
+  status      print the database version and embedded migrations
+
+Flags:
+`
+
+var errUsage = errors.New("invalid usage")
+
+// Run runs migration command from command line arguments, exits with non-zero code on error.
+func Run() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("shortener-migrate", flag.ContinueOnError)
+	dsn := flags.String("d", "", "Enter url to connect database as host=host port=port user=postgres password=postgres dbname=dbname sslmode=disable Or use DATABASE_DSN env")
+	dryRun := flags.Bool("dry-run", false, "Print SQL of migrations which would be run without running them")
+	yes := flags.Bool("y", false, "Do not ask confirmation before applying all down migrations")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if envDataBaseDSN := os.Getenv("DATABASE_DSN"); envDataBaseDSN != "" {
+		*dsn = envDataBaseDSN
+	}
+	if *dsn == "" {
+		fmt.Fprintln(flags.Output(), "database DSN is required")
+		return errUsage
+	}
+
+	command, arg, err := parseCommand(flags.Args())
+	if err != nil {
+		fmt.Fprintln(flags.Output(), err)
+		flags.Usage()
+		return errUsage
+	}
+
+	migrations, err := db.NewMigrations(*dsn, zap.NewNop())
+	if err != nil {
+		return err
+	}
+	defer migrations.Close()
+
+	if *dryRun {
+		return plan(migrations, command, arg, stdout)
+	}
+
+	switch command {
+	case "up":
+		return migrations.Up(arg)
+	case "down":
+		if arg == 0 && !*yes && !confirm(stdin, stdout, "Apply all down migrations? [y/N] ") {
+			return errors.New("aborted")
+		}
+		return migrations.Down(arg)
+	case "goto":
+		return migrations.Goto(uint(arg))
+	case "force":
+		return migrations.Force(arg)
+	default:
+		return status(migrations, stdout)
+	}
+}
+
+// parseCommand validates command and its argument, missing optional argument is zero.
+func parseCommand(args []string) (string, int, error) {
+	if len(args) == 0 {
+		return "", 0, errors.New("command is required")
+	}
+
+	command := args[0]
+	var arg int
+	switch command {
+	case "status":
+		if len(args) != 1 {
+			return "", 0, fmt.Errorf("%s takes no arguments", command)
+		}
+		return command, 0, nil
+	case "up", "down", "goto", "force":
+	default:
+		return "", 0, fmt.Errorf("unknown command %q", command)
+	}
+
+	required := command == "goto" || command == "force"
+	switch {
+	case len(args) > 2:
+		return "", 0, fmt.Errorf("%s takes at most one argument", command)
+	case len(args) == 1 && required:
+		return "", 0, fmt.Errorf("%s requires version", command)
+	case len(args) == 2:
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid %s argument %q", command, args[1])
+		}
+		arg = v
+	}
+
+	if arg < 0 && command != "force" || arg < -1 {
+		return "", 0, fmt.Errorf("invalid %s argument %d", command, arg)
+	}
+	if arg == 0 && command == "goto" {
+		return "", 0, errors.New("goto requires positive version, use down to revert all migrations")
+	}
+
+	return command, arg, nil
+}
+
+// plan prints SQL of migrations the command would run.
+func plan(migrations *db.Migrations, command string, arg int, stdout io.Writer) error {
+	var planned []db.PlannedMigration
+	var err error
+	switch command {
+	case "up":
+		planned, err = migrations.PlanUp(arg)
+	case "down":
+		planned, err = migrations.PlanDown(arg)
+	case "goto":
+		planned, err = migrations.PlanGoto(uint(arg))
+	case "force":
+		fmt.Fprintf(stdout, "-- version would be forced to %d\n", arg)
+		return nil
+	default:
+		return status(migrations, stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(planned) == 0 {
+		fmt.Fprintln(stdout, "-- no change")
+		return nil
+	}
+
+	for _, migration := range planned {
+		direction := "down"
+		if migration.Up {
+			direction = "up"
+		}
+		fmt.Fprintf(stdout, "-- %d %s (%s)\n%s\n\n", migration.Version, migration.Identifier, direction, strings.TrimSpace(migration.SQL))
+	}
+
+	return nil
+}
+
+// status prints the database version and embedded migrations.
+func status(migrations *db.Migrations, stdout io.Writer) error {
+	migrationStatus, err := migrations.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "version: %d\n", migrationStatus.Version)
+	fmt.Fprintf(stdout, "dirty: %t\n", migrationStatus.Dirty)
+	for _, migration := range migrationStatus.Migrations {
+		mark := " "
+		if migration.Applied {
+			mark = "x"
+		}
+		fmt.Fprintf(stdout, "[%s] %d %s\n", mark, migration.Version, migration.Identifier)
+	}
+
+	return nil
+}
+
+// confirm asks a question and reports whether the answer is yes.
+func confirm(stdin io.Reader, stdout io.Writer, question string) bool {
+	fmt.Fprint(stdout, question)
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -18,21 +18,33 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/crypto/acme/autocert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/msmkdenis/yap-shortener/internal/api/gateway"
 	"github.com/msmkdenis/yap-shortener/internal/api/grpchandlers"
 	"github.com/msmkdenis/yap-shortener/internal/api/httphandlers"
+	"github.com/msmkdenis/yap-shortener/internal/api/openapi"
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/internal/config"
 	"github.com/msmkdenis/yap-shortener/internal/middleware"
 	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	pbv2 "github.com/msmkdenis/yap-shortener/internal/proto/v2"
 	"github.com/msmkdenis/yap-shortener/internal/repository/db"
 	"github.com/msmkdenis/yap-shortener/internal/repository/file"
 	"github.com/msmkdenis/yap-shortener/internal/repository/memory"
 	"github.com/msmkdenis/yap-shortener/internal/service"
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 	"github.com/msmkdenis/yap-shortener/pkg/echopprof"
+	"github.com/msmkdenis/yap-shortener/pkg/geoip"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+	"github.com/msmkdenis/yap-shortener/pkg/oidc"
 )
 
+// gatewayBufferSize is a buffer size of the in-process listener gRPC-Gateway connects over.
+const gatewayBufferSize = 1 << 20
+
 // URLShortenerRun runs the URL shortener service. Graceful shutdown is implemented.
 //
 // It does not take any parameters and does not return any values.
@@ -45,27 +57,106 @@ func URLShortenerRun() {
 	cfg := *config.NewConfig(logger)
 
 	jwtManager := jwtgen.InitJWTManager(cfg.TokenName, cfg.SecretKey, logger)
+	for _, userID := range cfg.AdminUsers {
+		jwtManager.GrantRoles(userID, jwtgen.RoleAdmin)
+	}
+	for _, userID := range cfg.StatsReaders {
+		jwtManager.GrantRoles(userID, jwtgen.RoleStatsReader)
+	}
 	jwtCheckerCreator := middleware.InitJWTCheckerCreator(jwtManager, logger)
 	jwtAuth := middleware.InitJWTAuth(jwtManager, logger)
-	repository := initRepository(&cfg, logger)
-	urlService := service.NewURLService(repository, logger)
+	authorizer := middleware.InitAuthorizer(logger)
+	spec, err := openapi.Load()
+	if err != nil {
+		logger.Fatal("Unable to load openapi spec", zap.Error(err))
+	}
+	validator, err := middleware.InitRequestValidator(spec, logger)
+	if err != nil {
+		logger.Fatal("Unable to initialize request validator", zap.Error(err))
+	}
+	deprecation, err := middleware.InitDeprecation(cfg.LegacySunset, logger)
+	if err != nil {
+		logger.Fatal("Unable to initialize deprecation middleware", zap.Error(err))
+	}
+	idempotency := middleware.InitIdempotency(cfg.IdempotencyTTL, cfg.IdempotencyMaxBody, logger)
+	repository, locker := initRepository(&cfg, logger)
+	redirect := service.RedirectConfig{Code: cfg.RedirectCode, CacheControl: cfg.RedirectCacheControl}
+	if err = redirect.Validate(); err != nil {
+		logger.Fatal("Invalid redirect config", zap.Error(err))
+	}
+	geoDatabase := initGeoIP(&cfg, logger)
+	var countries service.CountryResolver
+	if geoDatabase != nil {
+		countries = geoDatabase
+	}
+	trustedSubnets, proxies := initClientIP(&cfg, logger)
+	urlService := service.NewURLService(repository, cfg.RetentionPeriod, redirect, countries, logger)
+	retention := service.NewRetention(repository, locker, service.RetentionConfig{
+		Period:    cfg.RetentionPeriod,
+		Interval:  cfg.RetentionInterval,
+		BatchSize: cfg.RetentionBatchSize,
+	}, logger)
 
 	e := echo.New()
+	e.HTTPErrorHandler = apierr.HTTPErrorHandler
 	echopprof.Wrap(e)
+	e.GET("/debug/vars", middleware.VarsHandler(), jwtAuth.JWTAuth(), authorizer.Authorize())
 	wgHTTP := &sync.WaitGroup{}
-	httphandlers.NewURLShorten(e, urlService, cfg.URLPrefix, cfg.TrustedSubnet, jwtCheckerCreator, jwtAuth, logger, wgHTTP)
+	clientIP := clientip.NewResolver(proxies)
+	httphandlers.NewURLShorten(e, urlService, cfg.URLPrefix, trustedSubnets, clientIP, cfg.LegacyListing, jwtCheckerCreator, jwtAuth, authorizer, validator, deprecation, idempotency, logger, wgHTTP)
+	if cfg.OIDCIssuer != "" {
+		httphandlers.NewOIDCAuth(e, initOIDCProvider(&cfg, logger), jwtManager, cfg.URLPrefix, logger)
+	}
 
 	listener, err := net.Listen("tcp", cfg.GRPCServer)
 	if err != nil {
 		logger.Fatal("Unable to create listener", zap.Error(err))
 	}
+	// gRPC-Gateway calls the gRPC server over an in-process listener, only it is trusted to pass client IP
+	// besides the configured proxies connecting to the public listener.
+	gatewayListener := bufconn.Listen(gatewayBufferSize)
+	recoverer := middleware.InitRecoverer(logger)
+	grpcClientIP := clientip.NewResolver(proxies).TrustNetwork(gatewayListener.Addr().Network())
+	requestLogger := middleware.InitRequestLogger(grpcClientIP, logger)
+	grpcMetrics := middleware.InitGRPCMetrics()
 	serverGrpc := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(jwtAuth.GRPCJWTAuth, jwtCheckerCreator.GRPCJWTCheckOrCreate),
+		grpc.ChainUnaryInterceptor(
+			requestLogger.GRPCRequestLogger,
+			grpcMetrics.GRPCMetrics,
+			recoverer.GRPCRecovery,
+			jwtAuth.GRPCJWTAuth,
+			jwtCheckerCreator.GRPCJWTCheckOrCreate,
+			authorizer.GRPCAuthorize,
+			idempotency.GRPCIdempotency,
+		),
+		grpc.ChainStreamInterceptor(
+			requestLogger.GRPCStreamRequestLogger,
+			grpcMetrics.GRPCStreamMetrics,
+			recoverer.GRPCStreamRecovery,
+			jwtAuth.GRPCStreamJWTAuth,
+			jwtCheckerCreator.GRPCStreamJWTCheckOrCreate,
+			authorizer.GRPCStreamAuthorize,
+		),
 	)
 	wgGRPC := &sync.WaitGroup{}
-	pb.RegisterURLShortenerServer(serverGrpc, grpchandlers.NewURLShorten(urlService, cfg.URLPrefix, cfg.TrustedSubnet, jwtManager, logger, wgGRPC))
+	pb.RegisterURLShortenerServer(serverGrpc, grpchandlers.NewURLShorten(urlService, cfg.URLPrefix, trustedSubnets, grpcClientIP, cfg.LegacyListing, jwtManager, logger, wgGRPC))
+	pbv2.RegisterURLShortenerServer(serverGrpc, grpchandlers.NewURLShortenV2(urlService, cfg.URLPrefix, logger))
 	reflection.Register(serverGrpc)
 
+	gatewayConn, err := grpc.Dial(gatewayListener.Addr().String(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return gatewayListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		logger.Fatal("Unable to dial gRPC server for gateway", zap.Error(err))
+	}
+	defer gatewayConn.Close()
+	if _, err = gateway.NewGateway(e, gatewayConn, cfg.TokenName, clientIP, logger); err != nil {
+		logger.Fatal("Unable to initialize gRPC gateway", zap.Error(err))
+	}
+
 	httpServerCtx, httpServerStopCtx := context.WithCancel(context.Background())
 	grpcServerCtx, grpcServerStopCtx := context.WithCancel(context.Background())
 
@@ -81,6 +172,37 @@ func URLShortenerRun() {
 		close(quit)
 	}()
 
+	// Запустили очистку удаленных URL, останавливается по сигналу
+	retentionCtx, retentionStop := context.WithCancel(context.Background())
+	wgRetention := &sync.WaitGroup{}
+	wgRetention.Add(1)
+	go func() {
+		defer wgRetention.Done()
+		retention.Run(retentionCtx)
+	}()
+	go func() {
+		<-quit
+		retentionStop()
+	}()
+
+	// Запустили перезагрузку базы GeoIP при изменении файла, останавливается по сигналу
+	if geoDatabase != nil {
+		wgRetention.Add(1)
+		go func() {
+			defer wgRetention.Done()
+			geoDatabase.Run(retentionCtx, cfg.GeoIPReloadInterval)
+		}()
+	}
+
+	// Запустили запись переходов по странам, останавливается по сигналу
+	if countries != nil {
+		wgRetention.Add(1)
+		go func() {
+			defer wgRetention.Done()
+			urlService.RunCountryRedirects(retentionCtx, service.DefaultCountryRedirectsInterval)
+		}()
+	}
+
 	// Запустили сервер gRPC
 	go func() {
 		logger.Info(fmt.Sprintf("gRPC server starting on port %s", cfg.GRPCServer))
@@ -89,6 +211,13 @@ func URLShortenerRun() {
 		}
 	}()
 
+	// Запустили сервер gRPC для gRPC-Gateway
+	go func() {
+		if errGRPC := serverGrpc.Serve(gatewayListener); errGRPC != nil {
+			logger.Fatal("Unable to start gRPC server for gateway", zap.Error(errGRPC))
+		}
+	}()
+
 	// Запустили сервер HTTP
 	go func() {
 		if cfg.EnableHTTPS == "true" {
@@ -152,11 +281,69 @@ func URLShortenerRun() {
 
 	wgHTTP.Wait()
 	wgGRPC.Wait()
+	wgRetention.Wait()
 	<-httpServerCtx.Done()
 	<-grpcServerCtx.Done()
+
+	// Записали переходы по странам, подсчитанные после последней записи
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer flushCancel()
+	if err = urlService.FlushCountryRedirects(flushCtx); err != nil {
+		logger.Error("Unable to flush redirects by country", zap.Error(err))
+	}
+}
+
+func initOIDCProvider(cfg *config.Config, logger *zap.Logger) *oidc.Provider {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Unable to initialize OIDC provider", zap.Error(err))
+	}
+
+	return provider
+}
+
+// initClientIP returns the configured trusted subnets and subnets of trusted proxies.
+func initClientIP(cfg *config.Config, logger *zap.Logger) (clientip.Subnets, clientip.Subnets) {
+	trustedSubnets, err := clientip.ParseSubnets(cfg.TrustedSubnet)
+	if err != nil {
+		logger.Fatal("Invalid trusted subnet", zap.Error(err))
+	}
+
+	proxies, err := clientip.ParseSubnets(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
+	return trustedSubnets, proxies
+}
+
+// initGeoIP returns the configured GeoIP database, nil if none is configured.
+func initGeoIP(cfg *config.Config, logger *zap.Logger) *geoip.Database {
+	if cfg.GeoIPDatabase == "" {
+		logger.Info("GeoIP database is not configured, countries of visitors are not resolved")
+		return nil
+	}
+
+	database, err := geoip.Open(cfg.GeoIPDatabase, logger)
+	if err != nil {
+		logger.Fatal("Unable to open GeoIP database", zap.Error(err))
+	}
+
+	logger.Info("GeoIP database loaded", zap.String("path", cfg.GeoIPDatabase))
+	return database
 }
 
-func initRepository(cfg *config.Config, logger *zap.Logger) service.URLRepository {
+// initRepository returns the configured repository and the lock guarding retention runs of replicas,
+// the lock is nil for repositories local to the process.
+func initRepository(cfg *config.Config, logger *zap.Logger) (service.URLRepository, service.Locker) {
 	switch cfg.RepositoryType {
 	case config.DataBaseRepository:
 		postgresPool, err := db.NewPostgresPool(cfg.DataBaseDSN, logger)
@@ -164,18 +351,22 @@ func initRepository(cfg *config.Config, logger *zap.Logger) service.URLRepositor
 			logger.Fatal("Unable to connect to database", zap.Error(err))
 		}
 
-		migrations, err := db.NewMigrations(cfg.DataBaseDSN, logger)
-		if err != nil {
-			logger.Fatal("Unable to create migrations", zap.Error(err))
-		}
+		if cfg.NoAutoMigrate {
+			logger.Info("Auto migration is disabled")
+		} else {
+			migrations, err := db.NewMigrations(cfg.DataBaseDSN, logger)
+			if err != nil {
+				logger.Fatal("Unable to create migrations", zap.Error(err))
+			}
 
-		err = migrations.MigrateUp()
-		if err != nil {
-			logger.Fatal("Unable to up migrations", zap.Error(err))
+			err = migrations.MigrateUp()
+			if err != nil {
+				logger.Fatal("Unable to up migrations", zap.Error(err))
+			}
 		}
 
 		logger.Info("Connected to database", zap.String("DSN", cfg.DataBaseDSN))
-		return db.NewPostgresURLRepository(postgresPool, logger)
+		return db.NewPostgresURLRepository(postgresPool, logger), db.NewAdvisoryLock(postgresPool, service.RetentionLockKey, logger)
 
 	case config.FileRepository:
 		repository, err := file.NewFileURLRepository(cfg.FileStoragePath, logger)
@@ -184,10 +375,10 @@ func initRepository(cfg *config.Config, logger *zap.Logger) service.URLRepositor
 		}
 
 		logger.Info("Connected/created file", zap.String("FilePath", cfg.FileStoragePath))
-		return repository
+		return repository, nil
 
 	default:
 		logger.Info("Using memory storage")
-		return memory.NewURLRepository(logger)
+		return memory.NewURLRepository(logger), nil
 	}
 }
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"flag"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -20,30 +23,68 @@ const (
 )
 
 type jsonConfig struct {
-	URLServer       string `json:"url_server"`
-	URLPrefix       string `json:"url_prefix"`
-	FileStoragePath string `json:"file_storage_path"`
-	DataBaseDSN     string `json:"database_dsn"`
-	SecretKey       string `json:"secret_key"`
-	TokenName       string `json:"token_name"`
-	EnableHTTPS     string `json:"enable_https"`
-	TrustedSubnet   string `json:"trusted_subnet"`
-	GRPCServer      string `json:"grpc_server"`
+	URLServer            string `json:"url_server"`
+	URLPrefix            string `json:"url_prefix"`
+	FileStoragePath      string `json:"file_storage_path"`
+	DataBaseDSN          string `json:"database_dsn"`
+	SecretKey            string `json:"secret_key"`
+	TokenName            string `json:"token_name"`
+	EnableHTTPS          string `json:"enable_https"`
+	TrustedSubnet        string `json:"trusted_subnet"`
+	TrustedProxies       string `json:"trusted_proxies"`
+	GRPCServer           string `json:"grpc_server"`
+	OIDCIssuer           string `json:"oidc_issuer"`
+	OIDCClientID         string `json:"oidc_client_id"`
+	OIDCClientSecret     string `json:"oidc_client_secret"`
+	OIDCRedirectURL      string `json:"oidc_redirect_url"`
+	AdminUsers           string `json:"admin_users"`
+	StatsReaders         string `json:"stats_readers"`
+	LegacyListing        bool   `json:"legacy_listing"`
+	LegacySunset         string `json:"legacy_sunset"`
+	IdempotencyTTL       string `json:"idempotency_ttl"`
+	IdempotencyMaxBody   int64  `json:"idempotency_max_body"`
+	NoAutoMigrate        bool   `json:"no_auto_migrate"`
+	RetentionPeriod      string `json:"retention_period"`
+	RetentionInterval    string `json:"retention_interval"`
+	RetentionBatchSize   int    `json:"retention_batch_size"`
+	RedirectCode         int    `json:"redirect_code"`
+	RedirectCacheControl string `json:"redirect_cache_control"`
+	GeoIPDatabase        string `json:"geoip_database"`
+	GeoIPReloadInterval  string `json:"geoip_reload_interval"`
 }
 
 // Config represents the configuration for the application.
 type Config struct {
-	URLServer       string
-	URLPrefix       string
-	FileStoragePath string
-	DataBaseDSN     string
-	RepositoryType  Repository
-	SecretKey       string
-	TokenName       string
-	EnableHTTPS     string
-	ConfigFile      string
-	TrustedSubnet   string
-	GRPCServer      string
+	URLServer            string
+	URLPrefix            string
+	FileStoragePath      string
+	DataBaseDSN          string
+	RepositoryType       Repository
+	SecretKey            string
+	TokenName            string
+	EnableHTTPS          string
+	ConfigFile           string
+	TrustedSubnet        string
+	TrustedProxies       string
+	GRPCServer           string
+	OIDCIssuer           string
+	OIDCClientID         string
+	OIDCClientSecret     string
+	OIDCRedirectURL      string
+	AdminUsers           []string
+	StatsReaders         []string
+	LegacyListing        bool
+	LegacySunset         string
+	IdempotencyTTL       time.Duration
+	IdempotencyMaxBody   int64
+	NoAutoMigrate        bool
+	RetentionPeriod      time.Duration
+	RetentionInterval    time.Duration
+	RetentionBatchSize   int
+	RedirectCode         int
+	RedirectCacheControl string
+	GeoIPDatabase        string
+	GeoIPReloadInterval  time.Duration
 }
 
 // NewConfig creates a new Config instance with default values and returns a pointer to it.
@@ -95,11 +136,68 @@ func (c *Config) parseFlags() {
 	flag.StringVar(&ConfigFile, "c", "", "Enter path to config file Or use CONFIG env")
 
 	var TrustedSubnet string
-	flag.StringVar(&TrustedSubnet, "t", "127.0.0.1/24", "Enter trusted subnet Or use TRUSTED_SUBNET env")
+	flag.StringVar(&TrustedSubnet, "t", "127.0.0.1/24", "Enter comma separated trusted subnets Or use TRUSTED_SUBNET env")
+
+	var TrustedProxies string
+	flag.StringVar(&TrustedProxies, "trusted-proxies", "", "Enter comma separated subnets of proxies trusted to pass client IP in X-Forwarded-For, Forwarded and X-Real-IP headers Or use TRUSTED_PROXIES env")
 
 	var GRPCServer string
 	flag.StringVar(&GRPCServer, "g", ":3300", "Enter gRPC server address Or use GRPC_SERVER env")
 
+	var OIDCIssuer string
+	flag.StringVar(&OIDCIssuer, "oidc-issuer", "", "Enter OpenID Connect issuer URL to enable SSO login Or use OIDC_ISSUER env")
+
+	var OIDCClientID string
+	flag.StringVar(&OIDCClientID, "oidc-client-id", "", "Enter OpenID Connect client ID Or use OIDC_CLIENT_ID env")
+
+	var OIDCClientSecret string
+	flag.StringVar(&OIDCClientSecret, "oidc-client-secret", "", "Enter OpenID Connect client secret Or use OIDC_CLIENT_SECRET env")
+
+	var OIDCRedirectURL string
+	flag.StringVar(&OIDCRedirectURL, "oidc-redirect-url", "http://localhost:8080/api/auth/oidc/callback", "Enter OpenID Connect redirect URL Or use OIDC_REDIRECT_URL env")
+
+	var AdminUsers string
+	flag.StringVar(&AdminUsers, "admin-users", "", "Enter comma separated user IDs granted admin role Or use ADMIN_USERS env")
+
+	var StatsReaders string
+	flag.StringVar(&StatsReaders, "stats-readers", "", "Enter comma separated user IDs granted stats-reader role Or use STATS_READERS env")
+
+	var LegacyListing bool
+	flag.BoolVar(&LegacyListing, "legacy-listing", false, "Serve GET / as public plain text list of original URLs Or use LEGACY_LISTING env")
+
+	var LegacySunset string
+	flag.StringVar(&LegacySunset, "legacy-sunset", "", "Enter RFC 3339 time unversioned /api routes are removed at (Sunset header) Or use LEGACY_SUNSET env")
+
+	var IdempotencyTTL time.Duration
+	flag.DurationVar(&IdempotencyTTL, "idempotency-ttl", 24*time.Hour, "Enter how long responses are kept by Idempotency-Key Or use IDEMPOTENCY_TTL env")
+
+	var IdempotencyMaxBody int64
+	flag.Int64Var(&IdempotencyMaxBody, "idempotency-max-body", 0, "Enter maximum size in bytes of request bodies with Idempotency-Key (default 1048576) Or use IDEMPOTENCY_MAX_BODY env")
+
+	var NoAutoMigrate bool
+	flag.BoolVar(&NoAutoMigrate, "no-auto-migrate", false, "Do not apply database migrations at start, use shortener-migrate instead Or use NO_AUTO_MIGRATE env")
+
+	var RetentionPeriod time.Duration
+	flag.DurationVar(&RetentionPeriod, "retention-period", 0, "Enter how long deleted URLs are kept before permanent purge, 0 keeps them forever Or use RETENTION_PERIOD env")
+
+	var RetentionInterval time.Duration
+	flag.DurationVar(&RetentionInterval, "retention-interval", 0, "Enter mean interval between purges of deleted URLs (default 1h) Or use RETENTION_INTERVAL env")
+
+	var RetentionBatchSize int
+	flag.IntVar(&RetentionBatchSize, "retention-batch-size", 0, "Enter number of deleted URLs purged at once (default 1000) Or use RETENTION_BATCH_SIZE env")
+
+	var RedirectCode int
+	flag.IntVar(&RedirectCode, "redirect-code", 0, "Enter default HTTP status of redirects, one of 301, 302, 307, 308 (default 307) Or use REDIRECT_CODE env")
+
+	var RedirectCacheControl string
+	flag.StringVar(&RedirectCacheControl, "redirect-cache-control", "", "Enter default Cache-Control header of redirects, empty means no header Or use REDIRECT_CACHE_CONTROL env")
+
+	var GeoIPDatabase string
+	flag.StringVar(&GeoIPDatabase, "geoip-database", "", "Enter path to MaxMind DB file resolving countries of visitors, e.g. GeoLite2-Country.mmdb Or use GEOIP_DATABASE env")
+
+	var GeoIPReloadInterval time.Duration
+	flag.DurationVar(&GeoIPReloadInterval, "geoip-reload-interval", 0, "Enter interval GeoIP database file is checked for changes at (default 1m) Or use GEOIP_RELOAD_INTERVAL env")
+
 	flag.Parse()
 
 	c.URLServer = URLServer
@@ -111,7 +209,26 @@ func (c *Config) parseFlags() {
 	c.TokenName = TokenName
 	c.ConfigFile = ConfigFile
 	c.TrustedSubnet = TrustedSubnet
+	c.TrustedProxies = TrustedProxies
 	c.GRPCServer = GRPCServer
+	c.OIDCIssuer = OIDCIssuer
+	c.OIDCClientID = OIDCClientID
+	c.OIDCClientSecret = OIDCClientSecret
+	c.OIDCRedirectURL = OIDCRedirectURL
+	c.AdminUsers = splitList(AdminUsers)
+	c.StatsReaders = splitList(StatsReaders)
+	c.LegacyListing = LegacyListing
+	c.LegacySunset = LegacySunset
+	c.IdempotencyTTL = IdempotencyTTL
+	c.IdempotencyMaxBody = IdempotencyMaxBody
+	c.NoAutoMigrate = NoAutoMigrate
+	c.RetentionPeriod = RetentionPeriod
+	c.RetentionInterval = RetentionInterval
+	c.RetentionBatchSize = RetentionBatchSize
+	c.RedirectCode = RedirectCode
+	c.RedirectCacheControl = RedirectCacheControl
+	c.GeoIPDatabase = GeoIPDatabase
+	c.GeoIPReloadInterval = GeoIPReloadInterval
 }
 
 func (c *Config) parseEnv() {
@@ -151,9 +268,85 @@ func (c *Config) parseEnv() {
 		c.TrustedSubnet = envTrustedSubnet
 	}
 
+	if envTrustedProxies := os.Getenv("TRUSTED_PROXIES"); envTrustedProxies != "" {
+		c.TrustedProxies = envTrustedProxies
+	}
+
 	if envGRPCServer := os.Getenv("GRPC_SERVER"); envGRPCServer != "" {
 		c.GRPCServer = envGRPCServer
 	}
+
+	if envOIDCIssuer := os.Getenv("OIDC_ISSUER"); envOIDCIssuer != "" {
+		c.OIDCIssuer = envOIDCIssuer
+	}
+
+	if envOIDCClientID := os.Getenv("OIDC_CLIENT_ID"); envOIDCClientID != "" {
+		c.OIDCClientID = envOIDCClientID
+	}
+
+	if envOIDCClientSecret := os.Getenv("OIDC_CLIENT_SECRET"); envOIDCClientSecret != "" {
+		c.OIDCClientSecret = envOIDCClientSecret
+	}
+
+	if envOIDCRedirectURL := os.Getenv("OIDC_REDIRECT_URL"); envOIDCRedirectURL != "" {
+		c.OIDCRedirectURL = envOIDCRedirectURL
+	}
+
+	if envAdminUsers := os.Getenv("ADMIN_USERS"); envAdminUsers != "" {
+		c.AdminUsers = splitList(envAdminUsers)
+	}
+
+	if envStatsReaders := os.Getenv("STATS_READERS"); envStatsReaders != "" {
+		c.StatsReaders = splitList(envStatsReaders)
+	}
+
+	if envLegacyListing, err := strconv.ParseBool(os.Getenv("LEGACY_LISTING")); err == nil {
+		c.LegacyListing = envLegacyListing
+	}
+
+	if envLegacySunset := os.Getenv("LEGACY_SUNSET"); envLegacySunset != "" {
+		c.LegacySunset = envLegacySunset
+	}
+
+	if envIdempotencyTTL, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil {
+		c.IdempotencyTTL = envIdempotencyTTL
+	}
+
+	if envIdempotencyMaxBody, err := strconv.ParseInt(os.Getenv("IDEMPOTENCY_MAX_BODY"), 10, 64); err == nil {
+		c.IdempotencyMaxBody = envIdempotencyMaxBody
+	}
+
+	if envNoAutoMigrate, err := strconv.ParseBool(os.Getenv("NO_AUTO_MIGRATE")); err == nil {
+		c.NoAutoMigrate = envNoAutoMigrate
+	}
+
+	if envRetentionPeriod, err := time.ParseDuration(os.Getenv("RETENTION_PERIOD")); err == nil {
+		c.RetentionPeriod = envRetentionPeriod
+	}
+
+	if envRetentionInterval, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL")); err == nil {
+		c.RetentionInterval = envRetentionInterval
+	}
+
+	if envRetentionBatchSize, err := strconv.Atoi(os.Getenv("RETENTION_BATCH_SIZE")); err == nil {
+		c.RetentionBatchSize = envRetentionBatchSize
+	}
+
+	if envRedirectCode, err := strconv.Atoi(os.Getenv("REDIRECT_CODE")); err == nil {
+		c.RedirectCode = envRedirectCode
+	}
+
+	if envRedirectCacheControl := os.Getenv("REDIRECT_CACHE_CONTROL"); envRedirectCacheControl != "" {
+		c.RedirectCacheControl = envRedirectCacheControl
+	}
+
+	if envGeoIPDatabase := os.Getenv("GEOIP_DATABASE"); envGeoIPDatabase != "" {
+		c.GeoIPDatabase = envGeoIPDatabase
+	}
+
+	if envGeoIPReloadInterval, err := time.ParseDuration(os.Getenv("GEOIP_RELOAD_INTERVAL")); err == nil {
+		c.GeoIPReloadInterval = envGeoIPReloadInterval
+	}
 }
 
 func (c *Config) parseJSONConfig() error {
@@ -192,10 +385,94 @@ func (c *Config) parseJSONConfig() error {
 		c.TrustedSubnet = config.TrustedSubnet
 	}
 
+	if c.TrustedProxies == "" {
+		c.TrustedProxies = config.TrustedProxies
+	}
+
 	if c.GRPCServer == "" {
 		c.GRPCServer = config.GRPCServer
 	}
 
+	if c.OIDCIssuer == "" {
+		c.OIDCIssuer = config.OIDCIssuer
+	}
+
+	if c.OIDCClientID == "" {
+		c.OIDCClientID = config.OIDCClientID
+	}
+
+	if c.OIDCClientSecret == "" {
+		c.OIDCClientSecret = config.OIDCClientSecret
+	}
+
+	if c.OIDCRedirectURL == "" {
+		c.OIDCRedirectURL = config.OIDCRedirectURL
+	}
+
+	if len(c.AdminUsers) == 0 {
+		c.AdminUsers = splitList(config.AdminUsers)
+	}
+
+	if len(c.StatsReaders) == 0 {
+		c.StatsReaders = splitList(config.StatsReaders)
+	}
+
+	if !c.LegacyListing {
+		c.LegacyListing = config.LegacyListing
+	}
+
+	if c.LegacySunset == "" {
+		c.LegacySunset = config.LegacySunset
+	}
+
+	if c.IdempotencyTTL == 0 {
+		if ttl, err := time.ParseDuration(config.IdempotencyTTL); err == nil {
+			c.IdempotencyTTL = ttl
+		}
+	}
+
+	if c.IdempotencyMaxBody == 0 {
+		c.IdempotencyMaxBody = config.IdempotencyMaxBody
+	}
+
+	if !c.NoAutoMigrate {
+		c.NoAutoMigrate = config.NoAutoMigrate
+	}
+
+	if c.RetentionPeriod == 0 {
+		if period, err := time.ParseDuration(config.RetentionPeriod); err == nil {
+			c.RetentionPeriod = period
+		}
+	}
+
+	if c.RetentionInterval == 0 {
+		if interval, err := time.ParseDuration(config.RetentionInterval); err == nil {
+			c.RetentionInterval = interval
+		}
+	}
+
+	if c.RetentionBatchSize == 0 {
+		c.RetentionBatchSize = config.RetentionBatchSize
+	}
+
+	if c.RedirectCode == 0 {
+		c.RedirectCode = config.RedirectCode
+	}
+
+	if c.RedirectCacheControl == "" {
+		c.RedirectCacheControl = config.RedirectCacheControl
+	}
+
+	if c.GeoIPDatabase == "" {
+		c.GeoIPDatabase = config.GeoIPDatabase
+	}
+
+	if c.GeoIPReloadInterval == 0 {
+		if interval, err := time.ParseDuration(config.GeoIPReloadInterval); err == nil {
+			c.GeoIPReloadInterval = interval
+		}
+	}
+
 	return configFile.Close()
 }
 
@@ -208,3 +485,14 @@ func (c *Config) newRepositoryType() Repository {
 	}
 	return MemoryRepostiory
 }
+
+// splitList splits comma separated list skipping empty values.
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -1,6 +1,8 @@
 // Package dto contains data transfer objects.
 package dto
 
+import "time"
+
 // URLResponse represents URL response.
 type URLResponse struct {
 	Result string `json:"result,omitempty"`
@@ -17,10 +19,37 @@ type URLBatchRequest struct {
 	OriginalURL   string `json:"original_url,omitempty"`
 }
 
-// URLBatchResponse represents URL batch response.
+// Batch item statuses
+const (
+	BatchItemCreated = "created"
+	BatchItemExists  = "exists"
+	BatchItemInvalid = "invalid"
+)
+
+// URLBatchResponse represents URL batch response item, Error is set for invalid items only.
 type URLBatchResponse struct {
 	CorrelationID string `json:"correlation_id,omitempty"`
 	ShortenedURL  string `json:"short_url,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// URLImportError represents error of a single imported row.
+type URLImportError struct {
+	Row           int    `json:"row"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	Error         string `json:"error"`
+}
+
+// URLImportSummary represents result of URLs import.
+//
+// Errors describe the first of Failed rows only.
+type URLImportSummary struct {
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Existing int              `json:"existing"`
+	Failed   int              `json:"failed"`
+	Errors   []URLImportError `json:"errors,omitempty"`
 }
 
 // URLBatchResponseByUserID represents URL batch response by user ID.
@@ -30,8 +59,112 @@ type URLBatchResponseByUserID struct {
 	DeletedFlag bool   `json:"deleted_flag,omitempty"`
 }
 
+// URLRedirect represents redirect settings of URL, zero values mean server defaults.
+type URLRedirect struct {
+	ShortURL     string `json:"short_url,omitempty"`
+	RedirectCode int    `json:"redirect_code"`
+	CacheControl string `json:"cache_control"`
+	// QueryParams are set in the query of redirects, {name} placeholders are substituted with variables of the visit.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	// QueryPassThrough passes query of the visit to the original URL.
+	QueryPassThrough bool `json:"query_pass_through,omitempty"`
+	// AllowedCountries are the only countries of visitors redirected, DeniedCountries are not redirected.
+	AllowedCountries []string `json:"allowed_countries,omitempty"`
+	DeniedCountries  []string `json:"denied_countries,omitempty"`
+}
+
+// CountryRedirect represents a number of redirects of URL visitors from the country.
+type CountryRedirect struct {
+	Country   string `json:"country"`
+	Redirects int64  `json:"redirects"`
+}
+
+// CountryRedirects represents redirects of URL by country from the most redirected country.
+type CountryRedirects struct {
+	Countries []CountryRedirect `json:"countries"`
+}
+
+// RedirectRule represents a conditional redirect of URL to another destination, empty conditions match any visit.
+type RedirectRule struct {
+	ID            string     `json:"id,omitempty"`
+	Destination   string     `json:"destination"`
+	Devices       []string   `json:"devices,omitempty"`
+	Languages     []string   `json:"languages,omitempty"`
+	ReferrerHosts []string   `json:"referrer_hosts,omitempty"`
+	From          *time.Time `json:"from,omitempty"`
+	Until         *time.Time `json:"until,omitempty"`
+	Percent       int        `json:"percent,omitempty"`
+}
+
+// RedirectRules represents redirect rules of URL in evaluation order.
+type RedirectRules struct {
+	Rules []RedirectRule `json:"rules"`
+}
+
+// URLQuery represents query of a page of URLs.
+type URLQuery struct {
+	Limit     int
+	PageToken string
+	// Sort is created_at (oldest first) or -created_at (newest first, default).
+	Sort    string
+	Deleted *bool
+	Search  string
+	// UserID limits admin listing to a single user, it is ignored in user listings.
+	UserID string
+	// CreatedFrom (inclusive) and CreatedTo (exclusive) limit creation time when not zero.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Unpaged lists all matching URLs when neither Limit nor PageToken is set, as legacy listings did.
+	Unpaged bool
+}
+
+// URLPage represents a page of user URLs, NextPageToken is empty on the last page.
+type URLPage struct {
+	URLs          []URLBatchResponseByUserID
+	NextPageToken string
+}
+
+// URLRecord represents full URL record returned by admin listing.
+type URLRecord struct {
+	ID            string    `json:"id"`
+	OriginalURL   string    `json:"original_url"`
+	ShortURL      string    `json:"short_url"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	UserID        string    `json:"user_id"`
+	DeletedFlag   bool      `json:"deleted_flag"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// URLRecordPage represents a page of admin listing, NextPageToken is empty on the last page.
+type URLRecordPage struct {
+	URLs          []URLRecord `json:"urls"`
+	NextPageToken string      `json:"next_page_token,omitempty"`
+}
+
 // URLStats represents URL stats.
 type URLStats struct {
 	Urls  int
 	Users int
 }
+
+// AuthResponse represents response of successful login.
+type AuthResponse struct {
+	UserID string `json:"user_id,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// URLV2 represents URL returned by /api/v2 and proto.v2 API.
+type URLV2 struct {
+	ID            string    `json:"id"`
+	ShortURL      string    `json:"short_url"`
+	OriginalURL   string    `json:"original_url"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Deleted       bool      `json:"deleted"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// URLPageV2 represents a page of user URLs returned by /api/v2, NextPageToken is empty on the last page.
+type URLPageV2 struct {
+	URLs          []URLV2 `json:"urls"`
+	NextPageToken string  `json:"next_page_token,omitempty"`
+}
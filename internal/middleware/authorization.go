@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+)
+
+// routeRoles maps HTTP routes (method and registered path) to roles allowed to call them.
+//
+// Routes using Authorize must be listed here, unlisted routes are denied.
+var routeRoles = map[string][]jwtgen.Role{
+	http.MethodGet + " /api/user/urls":              {jwtgen.RoleUser},
+	http.MethodGet + " /api/user/urls/export":       {jwtgen.RoleUser},
+	http.MethodDelete + " /api/user/urls":           {jwtgen.RoleUser},
+	http.MethodPost + " /api/user/urls/restore":     {jwtgen.RoleUser},
+	http.MethodPatch + " /api/user/urls/:id":        {jwtgen.RoleUser},
+	http.MethodPut + " /api/user/urls/:id/redirect": {jwtgen.RoleUser},
+	http.MethodDelete + " /":                        {jwtgen.RoleAdmin},
+	http.MethodGet + " /api/internal/stats":         {jwtgen.RoleAdmin, jwtgen.RoleStatsReader},
+
+	http.MethodGet + " /api/v1/user/urls":                       {jwtgen.RoleUser},
+	http.MethodGet + " /api/v1/user/urls/export":                {jwtgen.RoleUser},
+	http.MethodDelete + " /api/v1/user/urls":                    {jwtgen.RoleUser},
+	http.MethodPost + " /api/v1/user/urls/restore":              {jwtgen.RoleUser},
+	http.MethodPatch + " /api/v1/user/urls/:id":                 {jwtgen.RoleUser},
+	http.MethodPut + " /api/v1/user/urls/:id/redirect":          {jwtgen.RoleUser},
+	http.MethodGet + " /api/v1/user/urls/:id/rules":             {jwtgen.RoleUser},
+	http.MethodPut + " /api/v1/user/urls/:id/rules":             {jwtgen.RoleUser},
+	http.MethodPost + " /api/v1/user/urls/:id/rules":            {jwtgen.RoleUser},
+	http.MethodPut + " /api/v1/user/urls/:id/rules/:rule_id":    {jwtgen.RoleUser},
+	http.MethodDelete + " /api/v1/user/urls/:id/rules/:rule_id": {jwtgen.RoleUser},
+	http.MethodGet + " /api/v1/user/urls/:id/countries":         {jwtgen.RoleUser},
+	http.MethodGet + " /api/v1/internal/stats":                  {jwtgen.RoleAdmin, jwtgen.RoleStatsReader},
+
+	http.MethodGet + " /api/v2/user/urls": {jwtgen.RoleUser},
+
+	http.MethodGet + " /debug/vars": {jwtgen.RoleAdmin},
+}
+
+// methodRoles maps full gRPC method names to roles allowed to call them.
+//
+// Methods listed here require a valid token, methods listed in publicMethods issue a new token when it is missing,
+// other methods are denied.
+var methodRoles = map[string][]jwtgen.Role{
+	"/proto.URLShortener/GetURLsByUserID":      {jwtgen.RoleUser},
+	"/proto.URLShortener/ExportURLs":           {jwtgen.RoleUser},
+	"/proto.URLShortener/DeleteURLsByUserID":   {jwtgen.RoleUser},
+	"/proto.URLShortener/RestoreURLsByUserID":  {jwtgen.RoleUser},
+	"/proto.URLShortener/UpdateURL":            {jwtgen.RoleUser},
+	"/proto.URLShortener/UpdateRedirect":       {jwtgen.RoleUser},
+	"/proto.URLShortener/ListRules":            {jwtgen.RoleUser},
+	"/proto.URLShortener/SetRules":             {jwtgen.RoleUser},
+	"/proto.URLShortener/AddRule":              {jwtgen.RoleUser},
+	"/proto.URLShortener/UpdateRule":           {jwtgen.RoleUser},
+	"/proto.URLShortener/DeleteRule":           {jwtgen.RoleUser},
+	"/proto.URLShortener/ListCountryRedirects": {jwtgen.RoleUser},
+	"/proto.URLShortener/DeleteAllURLs":        {jwtgen.RoleAdmin},
+	"/proto.URLShortener/GetStats":             {jwtgen.RoleAdmin, jwtgen.RoleStatsReader},
+
+	"/proto.v2.URLShortener/ListURLs": {jwtgen.RoleUser},
+}
+
+// publicMethods lists full gRPC method names available to any caller.
+var publicMethods = map[string]bool{
+	"/proto.URLShortener/GetListURLs":   true,
+	"/proto.URLShortener/PostURL":       true,
+	"/proto.URLShortener/PostBatchURLs": true,
+	"/proto.URLShortener/ImportURLs":    true,
+	"/proto.URLShortener/GetURL":        true,
+	"/proto.URLShortener/Ping":          true,
+
+	"/proto.v2.URLShortener/CreateURL": true,
+
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+}
+
+type RolesContextKey string
+
+// Authorizer represents role based authorization middleware.
+type Authorizer struct {
+	logger *zap.Logger
+}
+
+// InitAuthorizer returns a new instance of Authorizer.
+func InitAuthorizer(logger *zap.Logger) *Authorizer {
+	a := &Authorizer{
+		logger: logger,
+	}
+	return a
+}
+
+// Authorize checks that roles set in the context by JWT middleware allow to call the route. otherwise returns 403.
+//
+// Routes missing in routeRoles are denied.
+func (a *Authorizer) Authorize() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			required, ok := RouteRoles(c.Request().Method, c.Path())
+			if !ok {
+				a.logger.Error("authorization failed: route roles are not defined", zap.String("method", c.Request().Method), zap.String("route", c.Path()))
+				return apierr.Write(c, apierr.New(apierr.CodePermissionDenied, "permission denied"))
+			}
+
+			roles, _ := c.Get("roles").([]jwtgen.Role)
+			if !hasAnyRole(roles, required) {
+				a.logger.Info("authorization failed", zap.String("route", c.Path()), zap.Any("roles", roles))
+				return apierr.Write(c, apierr.New(apierr.CodePermissionDenied, "permission denied"))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GRPCAuthorize checks that roles set in the context by JWT interceptors allow to call the method. otherwise returns PermissionDenied.
+func (a *Authorizer) GRPCAuthorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// GRPCStreamAuthorize is a stream equivalent of GRPCAuthorize.
+func (a *Authorizer) GRPCStreamAuthorize(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+func (a *Authorizer) authorize(ctx context.Context, fullMethod string) error {
+	if publicMethods[fullMethod] {
+		return nil
+	}
+
+	required, ok := methodRoles[fullMethod]
+	if !ok {
+		a.logger.Error("authorization failed: method roles are not defined", zap.String("method", fullMethod))
+		return apierr.New(apierr.CodePermissionDenied, "permission denied")
+	}
+
+	roles, _ := ctx.Value(RolesContextKey("roles")).([]jwtgen.Role)
+	if !hasAnyRole(roles, required) {
+		a.logger.Info("authorization failed", zap.String("method", fullMethod), zap.Any("roles", roles))
+		return apierr.New(apierr.CodePermissionDenied, "permission denied")
+	}
+
+	return nil
+}
+
+// RouteRoles returns roles allowed to call the HTTP route of the method and registered path,
+// false if the route is not listed.
+func RouteRoles(method string, path string) ([]jwtgen.Role, bool) {
+	roles, ok := routeRoles[method+" "+path]
+	return roles, ok
+}
+
+// authRequired reports whether gRPC method requires a valid token.
+func authRequired(fullMethod string) bool {
+	_, ok := methodRoles[fullMethod]
+	return ok
+}
+
+func hasAnyRole(roles []jwtgen.Role, required []jwtgen.Role) bool {
+	claims := jwtgen.UserClaims{Roles: roles}
+	return claims.HasAnyRole(required...)
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	pbv2 "github.com/msmkdenis/yap-shortener/internal/proto/v2"
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+)
+
+func TestAuthorizer_Authorize(t *testing.T) {
+	logger := zap.NewNop()
+	jwtManager := jwtgen.InitJWTManager("token", "secret", logger)
+	jwtManager.GrantRoles("admin", jwtgen.RoleAdmin)
+	jwtManager.GrantRoles("reader", jwtgen.RoleStatsReader)
+
+	e := echo.New()
+	jwtAuth := InitJWTAuth(jwtManager, logger)
+	authorizer := InitAuthorizer(logger)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.DELETE("/", ok, jwtAuth.JWTAuth(), authorizer.Authorize())
+	e.GET("/api/internal/stats", ok, jwtAuth.JWTAuth(), authorizer.Authorize())
+	e.GET("/api/v1/internal/stats", ok, jwtAuth.JWTAuth(), authorizer.Authorize())
+	e.GET("/api/v1/unmapped", ok, jwtAuth.JWTAuth(), authorizer.Authorize())
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		userID       string
+		expectedCode int
+	}{
+		{name: "Admin clears all", method: http.MethodDelete, path: "/", userID: "admin", expectedCode: http.StatusOK},
+		{name: "User clears all", method: http.MethodDelete, path: "/", userID: "user", expectedCode: http.StatusForbidden},
+		{name: "Anonymous clears all", method: http.MethodDelete, path: "/", expectedCode: http.StatusUnauthorized},
+		{name: "Stats reader gets stats", method: http.MethodGet, path: "/api/internal/stats", userID: "reader", expectedCode: http.StatusOK},
+		{name: "Stats reader clears all", method: http.MethodDelete, path: "/", userID: "reader", expectedCode: http.StatusForbidden},
+		{name: "User gets stats", method: http.MethodGet, path: "/api/internal/stats", userID: "user", expectedCode: http.StatusForbidden},
+		{name: "Stats reader gets v1 stats", method: http.MethodGet, path: "/api/v1/internal/stats", userID: "reader", expectedCode: http.StatusOK},
+		{name: "User gets v1 stats", method: http.MethodGet, path: "/api/v1/internal/stats", userID: "user", expectedCode: http.StatusForbidden},
+		{name: "Admin calls unmapped route", method: http.MethodGet, path: "/api/v1/unmapped", userID: "admin", expectedCode: http.StatusForbidden},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, http.NoBody)
+			if test.userID != "" {
+				token, err := jwtManager.BuildJWTStringWithUserID(test.userID)
+				assert.NoError(t, err)
+				request.AddCookie(&http.Cookie{Name: "token", Value: token})
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, request)
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestAuthorizer_GRPCAuthorize(t *testing.T) {
+	authorizer := InitAuthorizer(zap.NewNop())
+	handler := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+
+	testCases := []struct {
+		name         string
+		method       string
+		roles        []jwtgen.Role
+		expectedCode codes.Code
+	}{
+		{name: "Admin deletes all", method: "/proto.URLShortener/DeleteAllURLs", roles: []jwtgen.Role{jwtgen.RoleUser, jwtgen.RoleAdmin}, expectedCode: codes.OK},
+		{name: "User deletes all", method: "/proto.URLShortener/DeleteAllURLs", roles: []jwtgen.Role{jwtgen.RoleUser}, expectedCode: codes.PermissionDenied},
+		{name: "Stats reader gets stats", method: "/proto.URLShortener/GetStats", roles: []jwtgen.Role{jwtgen.RoleStatsReader}, expectedCode: codes.OK},
+		{name: "Public method", method: "/proto.URLShortener/PostURL", expectedCode: codes.OK},
+		{name: "Unmapped method", method: "/proto.URLShortener/Unmapped", roles: []jwtgen.Role{jwtgen.RoleUser, jwtgen.RoleAdmin}, expectedCode: codes.PermissionDenied},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), RolesContextKey("roles"), test.roles)
+			_, err := authorizer.GRPCAuthorize(ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestMethodRolesCoverServices(t *testing.T) {
+	server := grpc.NewServer()
+	pb.RegisterURLShortenerServer(server, pb.UnimplementedURLShortenerServer{})
+	pbv2.RegisterURLShortenerServer(server, pbv2.UnimplementedURLShortenerServer{})
+	reflection.Register(server)
+
+	for service, info := range server.GetServiceInfo() {
+		for _, method := range info.Methods {
+			fullMethod := "/" + service + "/" + method.Name
+			_, private := methodRoles[fullMethod]
+			assert.Truef(t, private != publicMethods[fullMethod], "method %s must be listed either in methodRoles or in publicMethods", fullMethod)
+		}
+	}
+}
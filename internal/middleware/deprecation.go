@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// Deprecation represents middleware marking legacy routes deprecated, usage is published with expvar.
+type Deprecation struct {
+	sunset time.Time
+	usage  *expvar.Map
+	logger *zap.Logger
+}
+
+// InitDeprecation returns a new instance of Deprecation.
+//
+// Sunset is an optional RFC 3339 time the legacy routes are removed at.
+// Usage is published (once per process) as http_deprecated_requests_total (by method and route).
+func InitDeprecation(sunset string, logger *zap.Logger) (*Deprecation, error) {
+	d := &Deprecation{
+		usage:  publishedMap("http_deprecated_requests_total"),
+		logger: logger,
+	}
+
+	if sunset != "" {
+		t, err := time.Parse(time.RFC3339, sunset)
+		if err != nil {
+			return nil, apperr.NewValueError("invalid sunset time", apperr.Caller(), err)
+		}
+		d.sunset = t
+	}
+
+	return d, nil
+}
+
+// Deprecate sets Deprecation, Sunset and successor Link headers and counts usage of the route.
+//
+// Successor link is the request path with legacyPrefix replaced by successorPrefix,
+// usage is counted by the registered route, so path parameters don't multiply counters.
+func (d *Deprecation) Deprecate(legacyPrefix string, successorPrefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", "true")
+			if !d.sunset.IsZero() {
+				header.Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+			}
+			successor := successorPrefix + strings.TrimPrefix(c.Request().URL.EscapedPath(), legacyPrefix)
+			header.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+			d.usage.Add(c.Request().Method+" "+c.Path(), 1)
+			d.logger.Debug("deprecated route called", zap.String("route", c.Path()), zap.String("successor", successor))
+
+			return next(c)
+		}
+	}
+}
+
+// Usage returns number of requests to the deprecated route.
+func (d *Deprecation) Usage(method string, path string) int64 {
+	if v, ok := d.usage.Get(method + " " + path).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeprecation_Deprecate(t *testing.T) {
+	logger := zap.NewNop()
+	deprecation, err := InitDeprecation("2030-01-01T00:00:00+03:00", logger)
+	require.NoError(t, err)
+
+	e := echo.New()
+	deprecated := deprecation.Deprecate("/api/", "/api/v1/")
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/api/user/urls", ok, deprecated)
+	e.GET("/api/v1/user/urls", ok)
+
+	usage := deprecation.Usage(http.MethodGet, "/api/user/urls")
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/urls", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 31 Dec 2029 21:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, "</api/v1/user/urls>; rel=\"successor-version\"", w.Header().Get("Link"))
+	assert.Equal(t, usage+1, deprecation.Usage(http.MethodGet, "/api/user/urls"))
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/user/urls", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Equal(t, int64(0), deprecation.Usage(http.MethodGet, "/api/v1/user/urls"))
+}
+
+func TestDeprecation_DeprecateParameterizedRoute(t *testing.T) {
+	deprecation, err := InitDeprecation("", zap.NewNop())
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.PATCH("/api/user/urls/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, deprecation.Deprecate("/api/", "/api/v1/"))
+
+	usage := deprecation.Usage(http.MethodPatch, "/api/user/urls/:id")
+	for _, id := range []string{"abc", "a%2Fb"} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+id, http.NoBody))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "</api/v1/user/urls/"+id+">; rel=\"successor-version\"", w.Header().Get("Link"))
+	}
+	assert.Equal(t, usage+2, deprecation.Usage(http.MethodPatch, "/api/user/urls/:id"))
+}
+
+func TestInitDeprecation(t *testing.T) {
+	logger := zap.NewNop()
+
+	deprecation, err := InitDeprecation("", logger)
+	require.NoError(t, err)
+	e := echo.New()
+	e.GET("/api/shorten", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, deprecation.Deprecate("/api/", "/api/v1/"))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/shorten", http.NoBody))
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	_, err = InitDeprecation("next year", logger)
+	assert.Error(t, err)
+}
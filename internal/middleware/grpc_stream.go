@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// wrappedServerStream is a grpc.ServerStream with context replaced by interceptors.
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// WrapServerStream returns grpc.ServerStream which Context method returns ctx.
+//
+// Used by stream interceptors to pass values (e.g. userID) to stream handlers.
+func WrapServerStream(ctx context.Context, ss grpc.ServerStream) grpc.ServerStream {
+	return &wrappedServerStream{
+		ServerStream: ss,
+		ctx:          ctx,
+	}
+}
+
+// Context returns the context of the stream.
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+)
+
+const (
+	publicStream  = "/test.Stream/Public"
+	privateStream = "/test.Stream/Private"
+	adminStream   = "/test.Stream/Admin"
+	panicStream   = "/test.Stream/Panic"
+)
+
+type StreamInterceptorsTestSuite struct {
+	suite.Suite
+	jwtManager *jwtgen.JWTManager
+	metrics    *GRPCMetrics
+	server     *grpc.Server
+	conn       *grpc.ClientConn
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	suite.Run(t, new(StreamInterceptorsTestSuite))
+}
+
+// echoUserID replies to every received message with userID taken from the stream context.
+func echoUserID(_ interface{}, stream grpc.ServerStream) error {
+	userID, ok := stream.Context().Value(UserIDContextKey("userID")).(string)
+	if !ok {
+		return status.Error(codes.Internal, "no user id")
+	}
+
+	for {
+		var in wrapperspb.StringValue
+		if err := stream.RecvMsg(&in); err != nil {
+			return nil
+		}
+		if err := stream.SendMsg(wrapperspb.String(userID)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *StreamInterceptorsTestSuite) SetupSuite() {
+	methodRoles[privateStream] = []jwtgen.Role{jwtgen.RoleUser}
+	methodRoles[adminStream] = []jwtgen.Role{jwtgen.RoleAdmin}
+	publicMethods[publicStream] = true
+	publicMethods[panicStream] = true
+
+	logger := zap.NewNop()
+	s.jwtManager = jwtgen.InitJWTManager("token", "secret", logger)
+	s.metrics = InitGRPCMetrics()
+	jwtAuth := InitJWTAuth(s.jwtManager, logger)
+	jwtCheckerCreator := InitJWTCheckerCreator(s.jwtManager, logger)
+	authorizer := InitAuthorizer(logger)
+
+	s.server = grpc.NewServer(grpc.ChainStreamInterceptor(
+		InitRequestLogger(clientip.NewResolver(nil), logger).GRPCStreamRequestLogger,
+		s.metrics.GRPCStreamMetrics,
+		InitRecoverer(logger).GRPCStreamRecovery,
+		jwtAuth.GRPCStreamJWTAuth,
+		jwtCheckerCreator.GRPCStreamJWTCheckOrCreate,
+		authorizer.GRPCStreamAuthorize,
+	))
+	s.server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Stream",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{StreamName: "Public", Handler: echoUserID, ServerStreams: true, ClientStreams: true},
+			{StreamName: "Private", Handler: echoUserID, ServerStreams: true, ClientStreams: true},
+			{StreamName: "Admin", Handler: echoUserID, ServerStreams: true, ClientStreams: true},
+			{StreamName: "Panic", Handler: func(interface{}, grpc.ServerStream) error { panic("boom") }, ServerStreams: true, ClientStreams: true},
+		},
+	}, struct{}{})
+
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+
+	var err error
+	s.conn, err = grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(s.T(), err)
+}
+
+func (s *StreamInterceptorsTestSuite) TearDownSuite() {
+	delete(methodRoles, privateStream)
+	delete(methodRoles, adminStream)
+	delete(publicMethods, publicStream)
+	delete(publicMethods, panicStream)
+	_ = s.conn.Close()
+	s.server.Stop()
+}
+
+// call opens stream, sends one message and returns the reply, response header and error.
+func (s *StreamInterceptorsTestSuite) call(method string, token string) (string, metadata.MD, error) {
+	ctx := context.Background()
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "token", token)
+	}
+
+	stream, err := s.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = stream.SendMsg(wrapperspb.String("ping")); err != nil {
+		return "", nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return "", nil, err
+	}
+
+	var reply wrapperspb.StringValue
+	if err = stream.RecvMsg(&reply); err != nil {
+		return "", nil, err
+	}
+
+	header, err := stream.Header()
+	return reply.GetValue(), header, err
+}
+
+func (s *StreamInterceptorsTestSuite) TestPublicStream_CreatesToken() {
+	userID, header, err := s.call(publicStream, "")
+	require.NoError(s.T(), err)
+
+	tokens := header.Get("token")
+	require.Len(s.T(), tokens, 1)
+	tokenUserID, err := s.jwtManager.GetUserID(tokens[0])
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), tokenUserID, userID)
+}
+
+func (s *StreamInterceptorsTestSuite) TestPublicStream_KeepsToken() {
+	token, err := s.jwtManager.BuildJWTStringWithUserID("user")
+	require.NoError(s.T(), err)
+
+	userID, header, err := s.call(publicStream, token)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "user", userID)
+	assert.Empty(s.T(), header.Get("token"))
+}
+
+func (s *StreamInterceptorsTestSuite) TestPrivateStream() {
+	token, err := s.jwtManager.BuildJWTStringWithUserID("user")
+	require.NoError(s.T(), err)
+
+	_, _, err = s.call(privateStream, "")
+	assert.Equal(s.T(), codes.Unauthenticated, status.Code(err))
+
+	_, _, err = s.call(privateStream, "invalid")
+	assert.Equal(s.T(), codes.Unauthenticated, status.Code(err))
+
+	userID, _, err := s.call(privateStream, token)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "user", userID)
+}
+
+func (s *StreamInterceptorsTestSuite) TestAdminStream() {
+	s.jwtManager.GrantRoles("admin", jwtgen.RoleAdmin)
+	userToken, err := s.jwtManager.BuildJWTStringWithUserID("user")
+	require.NoError(s.T(), err)
+	adminToken, err := s.jwtManager.BuildJWTStringWithUserID("admin")
+	require.NoError(s.T(), err)
+
+	_, _, err = s.call(adminStream, userToken)
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+
+	userID, _, err := s.call(adminStream, adminToken)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "admin", userID)
+}
+
+func (s *StreamInterceptorsTestSuite) TestPanicStream_Recovered() {
+	before := s.metrics.Requests(panicStream, codes.Internal.String())
+
+	_, _, err := s.call(panicStream, "")
+	assert.Equal(s.T(), codes.Internal, status.Code(err))
+	assert.Equal(s.T(), before+1, s.metrics.Requests(panicStream, codes.Internal.String()))
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/pkg/idempotency"
+)
+
+// HeaderIdempotencyKey is a request header (and gRPC metadata key) with client generated idempotency key.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed is set on responses replayed from the store.
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+const (
+	maxIdempotencyKeyLength = 255
+	// maxIdempotencyRecords is a number of idempotency keys kept at once.
+	maxIdempotencyRecords = 100000
+	// maxIdempotentResponseSize is a size of the largest stored response, larger responses are not replayed.
+	maxIdempotentResponseSize = 64 << 10
+	// DefaultIdempotentRequestSize is a default size of the largest request body with idempotency key.
+	DefaultIdempotentRequestSize = 1 << 20
+)
+
+// idempotentMethods lists full gRPC method names accepting idempotency key metadata.
+var idempotentMethods = map[string]bool{
+	"/proto.URLShortener/PostURL":       true,
+	"/proto.URLShortener/PostBatchURLs": true,
+	"/proto.v2.URLShortener/CreateURL":  true,
+}
+
+// Idempotency represents middleware replaying responses of retried requests with the same idempotency key.
+type Idempotency struct {
+	store       *idempotency.Store
+	maxBodySize int64
+	logger      *zap.Logger
+}
+
+// httpResponse represents stored HTTP response.
+type httpResponse struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// grpcResponse represents stored gRPC response, handlers may return both response and error.
+type grpcResponse struct {
+	resp interface{}
+	err  error
+}
+
+// InitIdempotency returns a new instance of Idempotency, responses are kept in memory for ttl.
+// Request bodies with idempotency key are at most maxBodySize bytes, zero means DefaultIdempotentRequestSize.
+//
+// Responses are kept by a single instance only, see package idempotency.
+func InitIdempotency(ttl time.Duration, maxBodySize int64, logger *zap.Logger) *Idempotency {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultIdempotentRequestSize
+	}
+
+	i := &Idempotency{
+		store:       idempotency.NewStore(ttl, maxIdempotencyRecords),
+		maxBodySize: maxBodySize,
+		logger:      logger,
+	}
+	return i
+}
+
+// Idempotent stores response of the request with Idempotency-Key header per user and replays it on retries.
+//
+// Must be used after JWT middleware. Reusing the key with a different request returns 422,
+// retrying a request which is not completed yet returns 409, a new key is rejected with 503 while the store is full.
+// Request bodies larger than the limit are rejected with 413 before they are hashed.
+// Responses with 5xx statuses or larger than maxIdempotentResponseSize are not stored.
+func (i *Idempotency) Idempotent() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				return apierr.Write(c, apierr.Field(HeaderIdempotencyKey, "must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters"))
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, i.maxBodySize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				i.logger.Info("request is too large to be handled by idempotency key", zap.Int64("limit", tooLarge.Limit))
+				return apierr.Write(c, apierr.New(apierr.CodePayloadTooLarge, "request body with "+HeaderIdempotencyKey+" must be at most "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes"))
+			}
+			if err != nil {
+				i.logger.Info("unable to read request", zap.Error(err))
+				return apierr.Write(c, apierr.New(apierr.CodeInvalidArgument, "unable to read request"))
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			userID, _ := c.Get("userID").(string)
+			stored, err := i.store.Begin(userID, key, requestHash(c.Request().Method+" "+c.Path(), body))
+			if err != nil {
+				return apierr.Write(c, idempotencyProblem(err))
+			}
+
+			if response, ok := stored.(*httpResponse); ok {
+				c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+				return c.Blob(response.status, response.contentType, response.body)
+			}
+
+			writer := c.Response().Writer
+			recorder := &responseRecorder{ResponseWriter: writer, limit: maxIdempotentResponseSize}
+			c.Response().Writer = recorder
+			err = next(c)
+			c.Response().Writer = writer
+
+			if err != nil || c.Response().Status >= http.StatusInternalServerError {
+				i.store.Release(userID, key)
+				return err
+			}
+
+			if recorder.overflow {
+				i.logger.Warn("response is too large to be stored by idempotency key", zap.String("route", c.Path()))
+				i.store.Release(userID, key)
+				return nil
+			}
+
+			i.store.Complete(userID, key, &httpResponse{
+				status:      c.Response().Status,
+				contentType: c.Response().Header().Get(echo.HeaderContentType),
+				body:        recorder.body.Bytes(),
+			})
+			return nil
+		}
+	}
+}
+
+// GRPCIdempotency is a gRPC equivalent of Idempotent, the key is taken from idempotency-key metadata.
+//
+// Applies to methods listed in idempotentMethods only, replayed responses have idempotent-replayed header.
+func (i *Idempotency) GRPCIdempotency(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !idempotentMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(HeaderIdempotencyKey)
+	if len(keys) == 0 || keys[0] == "" {
+		return handler(ctx, req)
+	}
+	key := keys[0]
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, apierr.Field(HeaderIdempotencyKey, "must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+	}
+
+	message, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		i.logger.Error("unable to marshal request", zap.Error(err))
+		return nil, apierr.New(apierr.CodeInternal, "")
+	}
+
+	userID, _ := ctx.Value(UserIDContextKey("userID")).(string)
+	stored, err := i.store.Begin(userID, key, requestHash(info.FullMethod, body))
+	if err != nil {
+		return nil, idempotencyProblem(err)
+	}
+
+	if response, ok := stored.(*grpcResponse); ok {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(HeaderIdempotentReplayed, "true")); err != nil {
+			i.logger.Error("unable to set header", zap.Error(err))
+		}
+		return response.resp, response.err
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil && apierr.FromStatus(status.Convert(err)).Status >= http.StatusInternalServerError {
+		i.store.Release(userID, key)
+		return resp, err
+	}
+
+	if m, ok := resp.(proto.Message); ok && proto.Size(m) > maxIdempotentResponseSize {
+		i.logger.Warn("response is too large to be stored by idempotency key", zap.String("method", info.FullMethod))
+		i.store.Release(userID, key)
+		return resp, err
+	}
+
+	i.store.Complete(userID, key, &grpcResponse{resp: resp, err: err})
+	return resp, err
+}
+
+// idempotencyProblem maps store errors to problems.
+func idempotencyProblem(err error) *apierr.Problem {
+	switch {
+	case errors.Is(err, idempotency.ErrKeyReused):
+		return apierr.New(apierr.CodeIdempotencyKeyReused, "Idempotency-Key is already used with a different request")
+	case errors.Is(err, idempotency.ErrInProgress):
+		return apierr.New(apierr.CodeRequestInProgress, "request with the Idempotency-Key is in progress")
+	case errors.Is(err, idempotency.ErrStoreFull):
+		return apierr.New(apierr.CodeUnavailable, "too many requests with Idempotency-Key, retry later")
+	default:
+		return apierr.New(apierr.CodeInternal, "")
+	}
+}
+
+// requestHash returns hash of the request body sent to the route.
+func requestHash(route string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies written response body up to limit, overflow is set if the body is larger.
+type responseRecorder struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.overflow && r.body.Len()+len(b) <= r.limit {
+		r.body.Write(b)
+	} else {
+		r.overflow = true
+		r.body.Reset()
+	}
+	return r.ResponseWriter.Write(b)
+}
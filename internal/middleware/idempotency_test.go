@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/msmkdenis/yap-shortener/internal/proto"
+	idempotencystore "github.com/msmkdenis/yap-shortener/pkg/idempotency"
+)
+
+func TestIdempotency_Idempotent(t *testing.T) {
+	idempotency := InitIdempotency(time.Hour, 0, zap.NewNop())
+
+	calls := 0
+	e := echo.New()
+	setUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("userID", c.Request().Header.Get("X-User"))
+			return next(c)
+		}
+	}
+	e.POST("/api/shorten", func(c echo.Context) error {
+		calls++
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		if string(body) == "fail" {
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.String(http.StatusCreated, string(body)+"#"+time.Now().String())
+	}, setUser, idempotency.Idempotent())
+
+	send := func(user string, key string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		request.Header.Set("X-User", user)
+		if key != "" {
+			request.Header.Set(HeaderIdempotencyKey, key)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		return w
+	}
+
+	first := send("user", "key", "http://example.com")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	replayed := send("user", "key", "http://example.com")
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, first.Header().Get(echo.HeaderContentType), replayed.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "true", replayed.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, 1, calls)
+
+	reused := send("user", "key", "http://example.org")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Equal(t, 1, calls)
+
+	send("other user", "key", "http://example.org")
+	send("user", "", "http://example.com")
+	assert.Equal(t, 3, calls)
+
+	send("user", "failed", "fail")
+	retried := send("user", "failed", "fail")
+	assert.Equal(t, http.StatusInternalServerError, retried.Code)
+	assert.Equal(t, 5, calls)
+
+	_, err := idempotency.store.Begin("user", "pending", requestHash(http.MethodPost+" /api/shorten", []byte("http://example.com")))
+	require.NoError(t, err)
+	pending := send("user", "pending", "http://example.com")
+	assert.Equal(t, http.StatusConflict, pending.Code)
+
+	tooLong := send("user", strings.Repeat("k", maxIdempotencyKeyLength+1), "http://example.com")
+	assert.Equal(t, http.StatusBadRequest, tooLong.Code)
+	assert.Equal(t, 5, calls)
+}
+
+func TestIdempotency_Limits(t *testing.T) {
+	idempotency := &Idempotency{store: idempotencystore.NewStore(time.Hour, 1), maxBodySize: 4, logger: zap.NewNop()}
+
+	calls := 0
+	e := echo.New()
+	e.POST("/api/shorten", func(c echo.Context) error {
+		calls++
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusCreated, strings.Repeat(string(body), maxIdempotentResponseSize))
+	}, idempotency.Idempotent())
+
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		request.Header.Set(HeaderIdempotencyKey, key)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, request)
+		return w
+	}
+
+	tooLarge := send("large", "abcde")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, tooLarge.Code)
+	assert.Contains(t, tooLarge.Body.String(), "PAYLOAD_TOO_LARGE")
+	assert.Equal(t, 0, calls, "too large request must not be handled")
+
+	large := send("large", "ab")
+	assert.Equal(t, http.StatusCreated, large.Code)
+	assert.Equal(t, 2*maxIdempotentResponseSize, large.Body.Len())
+	retried := send("large", "ab")
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	assert.Empty(t, retried.Header().Get(HeaderIdempotentReplayed), "too large response must not be stored")
+	assert.Equal(t, 2, calls)
+
+	send("stored", "a")
+	full := send("new", "a")
+	assert.Equal(t, http.StatusServiceUnavailable, full.Code)
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdempotency_GRPCIdempotency(t *testing.T) {
+	idempotency := InitIdempotency(time.Hour, 0, zap.NewNop())
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return &pb.PostURLResponse{ShortUrl: req.(*pb.PostURLRequest).Url + "#" + time.Now().String()}, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.URLShortener/PostURL"}
+	call := func(key string, url string) (interface{}, error) {
+		ctx := context.WithValue(context.Background(), UserIDContextKey("userID"), "user")
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(HeaderIdempotencyKey, key))
+		return idempotency.GRPCIdempotency(ctx, &pb.PostURLRequest{Url: url}, info, handler)
+	}
+
+	first, err := call("key", "http://example.com")
+	require.NoError(t, err)
+
+	replayed, err := call("key", "http://example.com")
+	require.NoError(t, err)
+	assert.Same(t, first, replayed)
+	assert.Equal(t, 1, calls)
+
+	_, err = call("key", "http://example.org")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, 1, calls)
+
+	_, err = idempotency.GRPCIdempotency(context.Background(), &pb.PostURLRequest{Url: "http://example.com"}, &grpc.UnaryServerInfo{FullMethod: "/proto.URLShortener/GetURL"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
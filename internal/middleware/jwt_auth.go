@@ -2,23 +2,16 @@ package middleware
 
 import (
 	"context"
-	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
 )
 
-var authMandatoryMethods = map[string]struct{}{
-	"/proto.URLShortener/GetURLsByUserID":    {},
-	"/proto.URLShortener/DeleteURLsByUserID": {},
-}
-
 type UserIDContextKey string
 
 // JWTAuth represents JWT authentication middleware.
@@ -43,43 +36,70 @@ func (j *JWTAuth) JWTAuth() echo.MiddlewareFunc {
 			cookie, err := c.Request().Cookie(j.jwtManager.TokenName)
 			if err != nil {
 				j.logger.Info("authentification failed", zap.Error(err))
-				return c.NoContent(http.StatusUnauthorized)
+				return apierr.Write(c, apierr.New(apierr.CodeUnauthenticated, "authentication required"))
 			}
-			userID, err := j.jwtManager.GetUserID(cookie.Value)
+			claims, err := j.jwtManager.ParseToken(cookie.Value)
 			if err != nil {
 				j.logger.Info("authentification failed", zap.Error(err))
-				return c.NoContent(http.StatusUnauthorized)
+				return apierr.Write(c, apierr.New(apierr.CodeUnauthenticated, "authentication required"))
 			}
-			c.Set("userID", userID)
-			j.logger.Info("authenticated", zap.String("userID", userID))
+			c.Set("userID", claims.UserID)
+			c.Set("roles", claims.Roles)
+			j.logger.Info("authenticated", zap.String("userID", claims.UserID))
 			return next(c)
 		}
 	}
 }
 
-// GRPCJWTAuth checks token from gRPC metadata and sets userID in the context. otherwise returns 401.
+// GRPCJWTAuth checks token from gRPC metadata and sets userID and roles in the context. otherwise returns 401.
+//
+// Only methods requiring roles are checked.
 func (j *JWTAuth) GRPCJWTAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if _, ok := authMandatoryMethods[info.FullMethod]; !ok {
+	if !authRequired(info.FullMethod) {
 		return handler(ctx, req)
 	}
 
+	ctx, err := j.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// GRPCStreamJWTAuth is a stream equivalent of GRPCJWTAuth.
+func (j *JWTAuth) GRPCStreamJWTAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !authRequired(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	ctx, err := j.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, WrapServerStream(ctx, ss))
+}
+
+func (j *JWTAuth) authenticate(ctx context.Context) (context.Context, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
 	c := md.Get(j.jwtManager.TokenName)
 	if len(c) < 1 {
 		j.logger.Info("authentification failed")
-		return nil, status.Errorf(codes.Unauthenticated, "no token found")
+		return nil, apierr.New(apierr.CodeUnauthenticated, "no token found")
 	}
 
-	userID, err := j.jwtManager.GetUserID(c[0])
+	claims, err := j.jwtManager.ParseToken(c[0])
 	if err != nil {
 		j.logger.Info("authentification failed", zap.Error(err))
-		return nil, status.Errorf(codes.Unauthenticated, "authentification by UserID failed")
+		return nil, apierr.New(apierr.CodeUnauthenticated, "authentication required")
 	}
 
-	ctx = context.WithValue(ctx, UserIDContextKey("userID"), userID)
-	return handler(ctx, req)
+	ctx = context.WithValue(ctx, UserIDContextKey("userID"), claims.UserID)
+	ctx = context.WithValue(ctx, RolesContextKey("roles"), claims.Roles)
+	return ctx, nil
 }
@@ -7,17 +7,12 @@ import (
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
 	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
 )
 
-var jwtCheckerSkipMethods = map[string]struct{}{
-	"/proto.URLShortener/GetStats": {},
-}
-
 type TokenContextKey string
 
 // JWTCheckerCreator represents JWT checker creator middleware.
@@ -44,87 +39,111 @@ func (j *JWTCheckerCreator) JWTCheckOrCreate() echo.MiddlewareFunc {
 			if cookieErr != nil {
 				j.logger.Info("token not found, creating new token", zap.Error(cookieErr))
 				token := j.setCookieAndReturn(c)
-				newUserID, err := j.jwtManager.GetUserID(token)
+				newClaims, err := j.jwtManager.ParseToken(token)
 				if err != nil {
 					j.logger.Error("unable to parse UserID, while creating new token", zap.Error(err))
-					return c.NoContent(http.StatusInternalServerError)
+					return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 				}
-				c.Set("userID", newUserID)
-				j.logger.Info("token created", zap.String("userID", newUserID))
+				c.Set("userID", newClaims.UserID)
+				c.Set("roles", newClaims.Roles)
+				j.logger.Info("token created", zap.String("userID", newClaims.UserID))
 				return next(c)
 			}
 
-			userID, err := j.jwtManager.GetUserID(cookie.Value)
+			claims, err := j.jwtManager.ParseToken(cookie.Value)
 			if err != nil {
 				j.logger.Warn("unable to parse UserID, creating new token", zap.Error(err))
 				token := j.setCookieAndReturn(c)
-				newUserID, err := j.jwtManager.GetUserID(token)
+				newClaims, err := j.jwtManager.ParseToken(token)
 				if err != nil {
 					j.logger.Error("unable to parse UserID, while creating new token", zap.Error(err))
-					return c.NoContent(http.StatusInternalServerError)
+					return apierr.Write(c, apierr.New(apierr.CodeInternal, ""))
 				}
-				c.Set("userID", newUserID)
-				j.logger.Info("token created", zap.String("userID", newUserID))
+				c.Set("userID", newClaims.UserID)
+				c.Set("roles", newClaims.Roles)
+				j.logger.Info("token created", zap.String("userID", newClaims.UserID))
 				return next(c)
 			}
 
-			c.Set("userID", userID)
+			c.Set("userID", claims.UserID)
+			c.Set("roles", claims.Roles)
 			return next(c)
 		}
 	}
 }
 
-// JWTCheckOrCreate checks token from gRPC metadata and sets userID in the context.
+// GRPCJWTCheckOrCreate checks token from gRPC metadata and sets userID in the context.
 // Otherwise creates new token and sets it in the context.
 func (j *JWTCheckerCreator) GRPCJWTCheckOrCreate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if _, ok := authMandatoryMethods[info.FullMethod]; ok {
+	if authRequired(info.FullMethod) {
 		return handler(ctx, req)
 	}
 
-	if _, ok := jwtCheckerSkipMethods[info.FullMethod]; ok {
-		return handler(ctx, req)
+	ctx, err := j.checkOrCreate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// GRPCStreamJWTCheckOrCreate is a stream equivalent of GRPCJWTCheckOrCreate.
+//
+// Newly created token is sent to the client in the stream header.
+func (j *JWTCheckerCreator) GRPCStreamJWTCheckOrCreate(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if authRequired(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	ctx, err := j.checkOrCreate(ss.Context())
+	if err != nil {
+		return err
 	}
 
+	if token, ok := ctx.Value(TokenContextKey(j.jwtManager.TokenName)).(string); ok {
+		if err = ss.SetHeader(metadata.Pairs(j.jwtManager.TokenName, token)); err != nil {
+			j.logger.Error("unable to set token header", zap.Error(err))
+			return apierr.New(apierr.CodeInternal, "")
+		}
+	}
+
+	return handler(srv, WrapServerStream(ctx, ss))
+}
+
+func (j *JWTCheckerCreator) checkOrCreate(ctx context.Context) (context.Context, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "missing metadata")
+		return nil, apierr.New(apierr.CodeInvalidArgument, "missing metadata")
 	}
 
 	c := md.Get(j.jwtManager.TokenName)
 	if len(c) < 1 {
 		j.logger.Info("token not found, creating new token")
-		ctxWithID, err := j.setUserIDAndReturn(ctx, md)
-		if err != nil {
-			return nil, err
-		}
-		return handler(ctxWithID, req)
+		return j.setUserIDAndReturn(ctx, md)
 	}
 
-	userID, err := j.jwtManager.GetUserID(c[0])
+	claims, err := j.jwtManager.ParseToken(c[0])
 	if err != nil {
 		j.logger.Warn("unable to parse UserID, creating new token", zap.Error(err))
-		ctx, err = j.setUserIDAndReturn(ctx, md)
-		if err != nil {
-			return nil, err
-		}
-
-		return handler(ctx, req)
+		return j.setUserIDAndReturn(ctx, md)
 	}
 
-	ctx = context.WithValue(ctx, UserIDContextKey("userID"), userID)
+	ctx = context.WithValue(ctx, UserIDContextKey("userID"), claims.UserID)
+	ctx = context.WithValue(ctx, RolesContextKey("roles"), claims.Roles)
 	ctx = metadata.NewIncomingContext(ctx, md)
-	return handler(ctx, req)
+	return ctx, nil
 }
 
 func (j *JWTCheckerCreator) setUserIDAndReturn(ctx context.Context, md metadata.MD) (context.Context, error) {
 	cookie := j.makeCookie()
 	ctx = context.WithValue(ctx, TokenContextKey(j.jwtManager.TokenName), cookie.Value)
-	newUserID, err := j.jwtManager.GetUserID(cookie.Value)
+	newClaims, err := j.jwtManager.ParseToken(cookie.Value)
 	if err != nil {
 		j.logger.Error("unable to parse UserID, while creating new token", zap.Error(err))
-		return nil, status.Errorf(codes.Internal, "unable to parse UserID, while creating new token")
+		return nil, apierr.New(apierr.CodeInternal, "")
 	}
-	ctx = context.WithValue(ctx, UserIDContextKey("userID"), newUserID)
+	ctx = context.WithValue(ctx, UserIDContextKey("userID"), newClaims.UserID)
+	ctx = context.WithValue(ctx, RolesContextKey("roles"), newClaims.Roles)
 	md.Append(j.jwtManager.TokenName, cookie.Value)
 	ctx = metadata.NewIncomingContext(ctx, md)
 	return ctx, nil
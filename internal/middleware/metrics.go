@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCMetrics represents gRPC metrics middleware, metrics are published with expvar.
+type GRPCMetrics struct {
+	requests      *expvar.Map
+	durations     *expvar.Map
+	activeStreams *expvar.Map
+}
+
+// InitGRPCMetrics returns a new instance of GRPCMetrics.
+//
+// Metrics are published (once per process) as grpc_requests_total (by method and code),
+// grpc_request_duration_ms (by method) and grpc_active_streams (by method).
+func InitGRPCMetrics() *GRPCMetrics {
+	m := &GRPCMetrics{
+		requests:      publishedMap("grpc_requests_total"),
+		durations:     publishedMap("grpc_request_duration_ms"),
+		activeStreams: publishedMap("grpc_active_streams"),
+	}
+	return m
+}
+
+// GRPCMetrics counts unary requests and their duration.
+func (m *GRPCMetrics) GRPCMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observe(info.FullMethod, start, err)
+	return resp, err
+}
+
+// GRPCStreamMetrics counts streams, their duration and currently active streams.
+func (m *GRPCMetrics) GRPCStreamMetrics(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	m.activeStreams.Add(info.FullMethod, 1)
+	defer m.activeStreams.Add(info.FullMethod, -1)
+
+	err := handler(srv, ss)
+	m.observe(info.FullMethod, start, err)
+	return err
+}
+
+// Requests returns number of requests handled for the method with the status code.
+func (m *GRPCMetrics) Requests(fullMethod string, code string) int64 {
+	if v, ok := m.requests.Get(fullMethod + " " + code).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func (m *GRPCMetrics) observe(fullMethod string, start time.Time, err error) {
+	m.requests.Add(fullMethod+" "+status.Code(err).String(), 1)
+	m.durations.AddFloat(fullMethod, float64(time.Since(start).Microseconds())/1000)
+}
+
+// hiddenVars lists variables published with expvar which are not served by VarsHandler,
+// cmdline contains secrets passed as flags.
+var hiddenVars = map[string]bool{"cmdline": true}
+
+// VarsHandler serves variables published with expvar as JSON object like expvar.Handler, except hiddenVars.
+func VarsHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		vars := make(map[string]json.RawMessage)
+		expvar.Do(func(kv expvar.KeyValue) {
+			if !hiddenVars[kv.Key] {
+				vars[kv.Key] = json.RawMessage(kv.Value.String())
+			}
+		})
+		return c.JSON(http.StatusOK, vars)
+	}
+}
+
+func publishedMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	return expvar.NewMap(name)
+}
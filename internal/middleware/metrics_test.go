@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/jwtgen"
+)
+
+func TestVarsHandler(t *testing.T) {
+	logger := zap.NewNop()
+	jwtManager := jwtgen.InitJWTManager("token", "secret", logger)
+	jwtManager.GrantRoles("admin", jwtgen.RoleAdmin)
+	InitGRPCMetrics()
+
+	e := echo.New()
+	e.GET("/debug/vars", VarsHandler(), InitJWTAuth(jwtManager, logger).JWTAuth(), InitAuthorizer(logger).Authorize())
+
+	testCases := []struct {
+		name         string
+		userID       string
+		expectedCode int
+	}{
+		{name: "Admin", userID: "admin", expectedCode: http.StatusOK},
+		{name: "User", userID: "user", expectedCode: http.StatusForbidden},
+		{name: "Anonymous", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/debug/vars", http.NoBody)
+			if test.userID != "" {
+				token, err := jwtManager.BuildJWTStringWithUserID(test.userID)
+				require.NoError(t, err)
+				request.AddCookie(&http.Cookie{Name: "token", Value: token})
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedCode != http.StatusOK {
+				return
+			}
+
+			var vars map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+			require.NotNil(t, expvar.Get("cmdline"))
+			assert.NotContains(t, vars, "cmdline")
+			assert.Contains(t, vars, "memstats")
+			assert.Contains(t, vars, "grpc_requests_total")
+		})
+	}
+}
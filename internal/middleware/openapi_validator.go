@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// streamBodyExtension marks operations which body is streamed by handler and is not validated.
+const streamBodyExtension = "x-stream-body"
+
+// RequestValidator represents middleware validating requests against OpenAPI specification.
+type RequestValidator struct {
+	router routers.Router
+	logger *zap.Logger
+}
+
+// InitRequestValidator returns a new instance of RequestValidator.
+func InitRequestValidator(spec *openapi3.T, logger *zap.Logger) (*RequestValidator, error) {
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to create openapi router", apperr.Caller(), err)
+	}
+
+	v := &RequestValidator{
+		router: router,
+		logger: logger,
+	}
+	return v, nil
+}
+
+// Validate checks Content-Type, parameters and JSON body of requests to routes described in specification.
+//
+// Unsupported Content-Type results in 415, invalid parameters and body in 400 with field violations.
+// Requests to routes missing in specification are passed as is.
+func (v *RequestValidator) Validate() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route, pathParams, err := v.router.FindRoute(c.Request())
+			if err != nil || route.Operation == nil {
+				return next(c)
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    c.Request(),
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+					SkipSettingDefaults: true,
+					ExcludeRequestBody:  true,
+				},
+			}
+
+			if body := route.Operation.RequestBody; body != nil && body.Value != nil {
+				mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+				hasBody := body.Value.Required || c.Request().ContentLength != 0
+				if hasBody && body.Value.Content.Get(mediaType) == nil {
+					msg := fmt.Sprintf("Content-Type header is not %s", strings.Join(contentTypes(body.Value), " or "))
+					v.logger.Info("StatusUnsupportedMediaType: "+msg, zap.String("content_type", mediaType))
+					return apierr.Write(c, apierr.New(apierr.CodeUnsupportedMediaType, msg))
+				}
+
+				_, streamed := route.Operation.Extensions[streamBodyExtension]
+				input.Options.ExcludeRequestBody = streamed || mediaType != echo.MIMEApplicationJSON
+			}
+
+			if err := openapi3filter.ValidateRequest(c.Request().Context(), input); err != nil {
+				v.logger.Info("StatusBadRequest: request does not match openapi spec", zap.Error(fmt.Errorf("%s %w", apperr.Caller(), err)))
+				return apierr.Write(c, problemFromValidation(err))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// contentTypes returns sorted media types of request body.
+func contentTypes(body *openapi3.RequestBody) []string {
+	types := make([]string, 0, len(body.Content))
+	for mediaType := range body.Content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// problemFromValidation maps openapi3filter errors to INVALID_ARGUMENT Problem with field violations.
+//
+// Body fields are reported as JSON pointers prefixed with "body".
+func problemFromValidation(err error) *apierr.Problem {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return apierr.New(apierr.CodeInvalidArgument, "invalid request")
+	}
+
+	if requestErr.Parameter != nil {
+		description := requestErr.Reason
+		var schemaErr *openapi3.SchemaError
+		if errors.As(requestErr.Err, &schemaErr) {
+			description = schemaErr.Reason
+		} else if description == "" && requestErr.Err != nil {
+			description = requestErr.Err.Error()
+		}
+		return apierr.Field(requestErr.Parameter.Name, description)
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(requestErr.Err, &schemaErr) {
+		field := strings.Join(append([]string{"body"}, schemaErr.JSONPointer()...), "/")
+		return apierr.New(apierr.CodeInvalidArgument, "invalid request body", apierr.FieldViolation{Field: field, Description: schemaErr.Reason})
+	}
+
+	return apierr.New(apierr.CodeInvalidArgument, "invalid request body", apierr.FieldViolation{Field: "body", Description: requestErr.Reason})
+}
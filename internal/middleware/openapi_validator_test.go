@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/api/openapi"
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+)
+
+func TestRequestValidator_Validate(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+	validator, err := InitRequestValidator(spec, zap.NewNop())
+	require.NoError(t, err)
+
+	e := echo.New()
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.POST("/api/shorten", ok, validator.Validate())
+	e.POST("/api/shorten/batch", ok, validator.Validate())
+	e.POST("/api/shorten/import", ok, validator.Validate())
+	e.POST("/", ok, validator.Validate())
+	e.GET("/api/user/urls", ok, validator.Validate())
+	e.GET("/unknown", ok, validator.Validate())
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		contentType        string
+		body               string
+		expectedCode       int
+		expectedProblem    apierr.Code
+		expectedViolations []apierr.FieldViolation
+	}{
+		{
+			name:         "Valid JSON body",
+			method:       http.MethodPost,
+			path:         "/api/shorten",
+			contentType:  "application/json; charset=utf-8",
+			body:         `{"url":"http://example.com"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Wrong Content-Type",
+			method:          http.MethodPost,
+			path:            "/api/shorten",
+			contentType:     "text/plain",
+			body:            `{"url":"http://example.com"}`,
+			expectedCode:    http.StatusUnsupportedMediaType,
+			expectedProblem: apierr.CodeUnsupportedMediaType,
+		},
+		{
+			name:               "Missing required field",
+			method:             http.MethodPost,
+			path:               "/api/shorten",
+			contentType:        "application/json",
+			body:               `{}`,
+			expectedCode:       http.StatusBadRequest,
+			expectedProblem:    apierr.CodeInvalidArgument,
+			expectedViolations: []apierr.FieldViolation{{Field: "body/url", Description: `property "url" is missing`}},
+		},
+		{
+			name:               "Wrong item type",
+			method:             http.MethodPost,
+			path:               "/api/shorten/batch",
+			contentType:        "application/json",
+			body:               `[{"correlation_id":"1","original_url":1}]`,
+			expectedCode:       http.StatusBadRequest,
+			expectedProblem:    apierr.CodeInvalidArgument,
+			expectedViolations: []apierr.FieldViolation{{Field: "body/0/original_url", Description: `value must be a string`}},
+		},
+		{
+			name:         "Streamed body is not validated",
+			method:       http.MethodPost,
+			path:         "/api/shorten/import",
+			contentType:  "text/csv",
+			body:         "1,http://example.com\n",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Plain text body of any type",
+			method:       http.MethodPost,
+			path:         "/",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "http://example.com",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Invalid query parameter",
+			method:          http.MethodGet,
+			path:            "/api/user/urls?deleted=maybe",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: apierr.CodeInvalidArgument,
+		},
+		{
+			name:         "Route missing in spec",
+			method:       http.MethodGet,
+			path:         "/unknown",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if test.contentType != "" {
+				request.Header.Set(echo.HeaderContentType, test.contentType)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, request)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			if test.expectedProblem == "" {
+				return
+			}
+
+			var problem apierr.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, test.expectedProblem, problem.Code)
+			if test.expectedViolations != nil {
+				assert.Equal(t, test.expectedViolations, problem.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/msmkdenis/yap-shortener/internal/apierr"
+)
+
+// Recoverer represents panic recovery middleware.
+type Recoverer struct {
+	logger *zap.Logger
+}
+
+// InitRecoverer returns a new instance of Recoverer.
+func InitRecoverer(logger *zap.Logger) *Recoverer {
+	r := &Recoverer{
+		logger: logger,
+	}
+	return r
+}
+
+// GRPCRecovery recovers from panics in unary handlers and returns Internal error.
+func (r *Recoverer) GRPCRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(info.FullMethod, p)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// GRPCStreamRecovery recovers from panics in stream handlers and returns Internal error.
+func (r *Recoverer) GRPCStreamRecovery(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(info.FullMethod, p)
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+func (r *Recoverer) recovered(fullMethod string, p interface{}) error {
+	r.logger.Error("recovered from panic",
+		zap.String("method", fullMethod),
+		zap.Any("panic", p),
+		zap.ByteString("stack", debug.Stack()),
+	)
+	return apierr.New(apierr.CodeInternal, "")
+}
@@ -1,17 +1,23 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/msmkdenis/yap-shortener/pkg/clientip"
 )
 
 // RequestLogger represents request logger middleware.
 type (
 	RequestLogger struct {
 		ReqLogger *zap.Logger
+		clientIP  *clientip.Resolver
 	}
 
 	responseData struct {
@@ -26,9 +32,12 @@ type (
 )
 
 // InitRequestLogger returns a new instance of RequestLogger.
-func InitRequestLogger(logger *zap.Logger) *RequestLogger {
+//
+// Client IP of gRPC requests is resolved by clientIP, HTTP requests are logged with echo.Context RealIP.
+func InitRequestLogger(clientIP *clientip.Resolver, logger *zap.Logger) *RequestLogger {
 	l := &RequestLogger{
 		ReqLogger: logger,
+		clientIP:  clientIP,
 	}
 	return l
 }
@@ -72,6 +81,7 @@ func (r *RequestLogger) RequestLogger() echo.MiddlewareFunc {
 			r.ReqLogger.Info("request_logger",
 				zap.String("URI", uri),
 				zap.String("method", method),
+				zap.String("client_ip", c.RealIP()),
 				zap.Duration("duration", duration),
 				zap.Int("response_code", responseData.status),
 				zap.Int("response_body_size", responseData.size),
@@ -80,3 +90,28 @@ func (r *RequestLogger) RequestLogger() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// GRPCRequestLogger logs each unary gRPC request.
+func (r *RequestLogger) GRPCRequestLogger(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	r.logGRPC("grpc_request_logger", info.FullMethod, r.clientIP.FromContext(ctx), start, err)
+	return resp, err
+}
+
+// GRPCStreamRequestLogger logs each gRPC stream when it is finished.
+func (r *RequestLogger) GRPCStreamRequestLogger(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	r.logGRPC("grpc_stream_logger", info.FullMethod, r.clientIP.FromContext(ss.Context()), start, err)
+	return err
+}
+
+func (r *RequestLogger) logGRPC(msg string, fullMethod string, clientIP string, start time.Time, err error) {
+	r.ReqLogger.Info(msg,
+		zap.String("method", fullMethod),
+		zap.String("client_ip", clientIP),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("code", status.Code(err).String()),
+	)
+}
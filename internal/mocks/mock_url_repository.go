@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	model "github.com/msmkdenis/yap-shortener/internal/model"
@@ -35,6 +36,20 @@ func (m *MockURLRepository) EXPECT() *MockURLRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddCountryRedirects mocks base method.
+func (m *MockURLRepository) AddCountryRedirects(arg0 context.Context, arg1 []model.URLCountryRedirects) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCountryRedirects", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddCountryRedirects indicates an expected call of AddCountryRedirects.
+func (mr *MockURLRepositoryMockRecorder) AddCountryRedirects(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCountryRedirects", reflect.TypeOf((*MockURLRepository)(nil).AddCountryRedirects), arg0, arg1)
+}
+
 // DeleteAll mocks base method.
 func (m *MockURLRepository) DeleteAll(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -78,19 +93,34 @@ func (mr *MockURLRepositoryMockRecorder) Insert(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockURLRepository)(nil).Insert), arg0, arg1)
 }
 
-// InsertAllOrUpdate mocks base method.
-func (m *MockURLRepository) InsertAllOrUpdate(arg0 context.Context, arg1 []model.URL) ([]model.URL, error) {
+// InsertAll mocks base method.
+func (m *MockURLRepository) InsertAll(arg0 context.Context, arg1 []model.URL) ([]model.URL, []model.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "InsertAllOrUpdate", arg0, arg1)
+	ret := m.ctrl.Call(m, "InsertAll", arg0, arg1)
 	ret0, _ := ret[0].([]model.URL)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].([]model.URL)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
-// InsertAllOrUpdate indicates an expected call of InsertAllOrUpdate.
-func (mr *MockURLRepositoryMockRecorder) InsertAllOrUpdate(arg0, arg1 interface{}) *gomock.Call {
+// InsertAll indicates an expected call of InsertAll.
+func (mr *MockURLRepositoryMockRecorder) InsertAll(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAllOrUpdate", reflect.TypeOf((*MockURLRepository)(nil).InsertAllOrUpdate), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAll", reflect.TypeOf((*MockURLRepository)(nil).InsertAll), arg0, arg1)
+}
+
+// IterateByUserID mocks base method.
+func (m *MockURLRepository) IterateByUserID(arg0 context.Context, arg1 string, arg2 model.URLFilter, arg3 func(model.URL) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IterateByUserID", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IterateByUserID indicates an expected call of IterateByUserID.
+func (mr *MockURLRepositoryMockRecorder) IterateByUserID(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IterateByUserID", reflect.TypeOf((*MockURLRepository)(nil).IterateByUserID), arg0, arg1, arg2, arg3)
 }
 
 // Ping mocks base method.
@@ -107,34 +137,63 @@ func (mr *MockURLRepositoryMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockURLRepository)(nil).Ping), arg0)
 }
 
+// PurgeDeleted mocks base method.
+func (m *MockURLRepository) PurgeDeleted(arg0 context.Context, arg1 time.Time, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockURLRepositoryMockRecorder) PurgeDeleted(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockURLRepository)(nil).PurgeDeleted), arg0, arg1, arg2)
+}
+
+// RestoreURLByUserID mocks base method.
+func (m *MockURLRepository) RestoreURLByUserID(arg0 context.Context, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreURLByUserID", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreURLByUserID indicates an expected call of RestoreURLByUserID.
+func (mr *MockURLRepositoryMockRecorder) RestoreURLByUserID(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreURLByUserID", reflect.TypeOf((*MockURLRepository)(nil).RestoreURLByUserID), arg0, arg1, arg2, arg3)
+}
+
 // SelectAll mocks base method.
-func (m *MockURLRepository) SelectAll(arg0 context.Context) ([]model.URL, error) {
+func (m *MockURLRepository) SelectAll(arg0 context.Context, arg1 model.URLQuery) ([]model.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SelectAll", arg0)
+	ret := m.ctrl.Call(m, "SelectAll", arg0, arg1)
 	ret0, _ := ret[0].([]model.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SelectAll indicates an expected call of SelectAll.
-func (mr *MockURLRepositoryMockRecorder) SelectAll(arg0 interface{}) *gomock.Call {
+func (mr *MockURLRepositoryMockRecorder) SelectAll(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAll", reflect.TypeOf((*MockURLRepository)(nil).SelectAll), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAll", reflect.TypeOf((*MockURLRepository)(nil).SelectAll), arg0, arg1)
 }
 
 // SelectAllByUserID mocks base method.
-func (m *MockURLRepository) SelectAllByUserID(arg0 context.Context, arg1 string) ([]model.URL, error) {
+func (m *MockURLRepository) SelectAllByUserID(arg0 context.Context, arg1 string, arg2 model.URLQuery) ([]model.URL, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SelectAllByUserID", arg0, arg1)
+	ret := m.ctrl.Call(m, "SelectAllByUserID", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]model.URL)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SelectAllByUserID indicates an expected call of SelectAllByUserID.
-func (mr *MockURLRepositoryMockRecorder) SelectAllByUserID(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockURLRepositoryMockRecorder) SelectAllByUserID(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAllByUserID", reflect.TypeOf((*MockURLRepository)(nil).SelectAllByUserID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectAllByUserID", reflect.TypeOf((*MockURLRepository)(nil).SelectAllByUserID), arg0, arg1, arg2)
 }
 
 // SelectByID mocks base method.
@@ -152,6 +211,36 @@ func (mr *MockURLRepositoryMockRecorder) SelectByID(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectByID", reflect.TypeOf((*MockURLRepository)(nil).SelectByID), arg0, arg1)
 }
 
+// SelectCountryRedirects mocks base method.
+func (m *MockURLRepository) SelectCountryRedirects(arg0 context.Context, arg1 string) ([]model.CountryRedirects, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectCountryRedirects", arg0, arg1)
+	ret0, _ := ret[0].([]model.CountryRedirects)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectCountryRedirects indicates an expected call of SelectCountryRedirects.
+func (mr *MockURLRepositoryMockRecorder) SelectCountryRedirects(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectCountryRedirects", reflect.TypeOf((*MockURLRepository)(nil).SelectCountryRedirects), arg0, arg1)
+}
+
+// SelectEditsByID mocks base method.
+func (m *MockURLRepository) SelectEditsByID(arg0 context.Context, arg1 string) ([]model.URLEdit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectEditsByID", arg0, arg1)
+	ret0, _ := ret[0].([]model.URLEdit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SelectEditsByID indicates an expected call of SelectEditsByID.
+func (mr *MockURLRepositoryMockRecorder) SelectEditsByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectEditsByID", reflect.TypeOf((*MockURLRepository)(nil).SelectEditsByID), arg0, arg1)
+}
+
 // SelectStats mocks base method.
 func (m *MockURLRepository) SelectStats(arg0 context.Context) (*model.URLStats, error) {
 	m.ctrl.T.Helper()
@@ -166,3 +255,48 @@ func (mr *MockURLRepositoryMockRecorder) SelectStats(arg0 interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectStats", reflect.TypeOf((*MockURLRepository)(nil).SelectStats), arg0)
 }
+
+// UpdateOriginal mocks base method.
+func (m *MockURLRepository) UpdateOriginal(arg0 context.Context, arg1, arg2, arg3 string, arg4 time.Time) (*model.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOriginal", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*model.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOriginal indicates an expected call of UpdateOriginal.
+func (mr *MockURLRepositoryMockRecorder) UpdateOriginal(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOriginal", reflect.TypeOf((*MockURLRepository)(nil).UpdateOriginal), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UpdateRedirect mocks base method.
+func (m *MockURLRepository) UpdateRedirect(arg0 context.Context, arg1, arg2 string, arg3 model.RedirectSettings, arg4 time.Time) (*model.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRedirect", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*model.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRedirect indicates an expected call of UpdateRedirect.
+func (mr *MockURLRepositoryMockRecorder) UpdateRedirect(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRedirect", reflect.TypeOf((*MockURLRepository)(nil).UpdateRedirect), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UpdateRules mocks base method.
+func (m *MockURLRepository) UpdateRules(arg0 context.Context, arg1, arg2 string, arg3 func([]model.RedirectRule) ([]model.RedirectRule, error), arg4 time.Time) (*model.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRules", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*model.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRules indicates an expected call of UpdateRules.
+func (mr *MockURLRepositoryMockRecorder) UpdateRules(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRules", reflect.TypeOf((*MockURLRepository)(nil).UpdateRules), arg0, arg1, arg2, arg3, arg4)
+}
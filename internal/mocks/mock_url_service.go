@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/msmkdenis/yap-shortener/internal/handlers (interfaces: URLService)
+// Source: github.com/msmkdenis/yap-shortener/internal/api/httphandlers (interfaces: URLShortenerService)
 
 // Package mock is a generated GoMock package.
 package mock
@@ -13,7 +13,7 @@ import (
 	model "github.com/msmkdenis/yap-shortener/internal/model"
 )
 
-// MockURLService is a mock of URLService interface.
+// MockURLService is a mock of URLShortenerService interface.
 type MockURLService struct {
 	ctrl     *gomock.Controller
 	recorder *MockURLServiceMockRecorder
@@ -52,18 +52,33 @@ func (mr *MockURLServiceMockRecorder) Add(arg0, arg1, arg2, arg3 interface{}) *g
 }
 
 // AddAll mocks base method.
-func (m *MockURLService) AddAll(arg0 context.Context, arg1 []dto.URLBatchRequest, arg2, arg3 string) ([]dto.URLBatchResponse, error) {
+func (m *MockURLService) AddAll(arg0 context.Context, arg1 []dto.URLBatchRequest, arg2, arg3 string, arg4 bool) ([]dto.URLBatchResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddAll", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "AddAll", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].([]dto.URLBatchResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddAll indicates an expected call of AddAll.
-func (mr *MockURLServiceMockRecorder) AddAll(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockURLServiceMockRecorder) AddAll(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAll", reflect.TypeOf((*MockURLService)(nil).AddAll), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAll", reflect.TypeOf((*MockURLService)(nil).AddAll), arg0, arg1, arg2, arg3, arg4)
+}
+
+// AddRule mocks base method.
+func (m *MockURLService) AddRule(arg0 context.Context, arg1, arg2 string, arg3 model.RedirectRule) (*model.RedirectRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRule", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*model.RedirectRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddRule indicates an expected call of AddRule.
+func (mr *MockURLServiceMockRecorder) AddRule(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRule", reflect.TypeOf((*MockURLService)(nil).AddRule), arg0, arg1, arg2, arg3)
 }
 
 // DeleteAll mocks base method.
@@ -80,6 +95,20 @@ func (mr *MockURLServiceMockRecorder) DeleteAll(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAll", reflect.TypeOf((*MockURLService)(nil).DeleteAll), arg0)
 }
 
+// DeleteRule mocks base method.
+func (m *MockURLService) DeleteRule(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRule", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRule indicates an expected call of DeleteRule.
+func (mr *MockURLServiceMockRecorder) DeleteRule(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRule", reflect.TypeOf((*MockURLService)(nil).DeleteRule), arg0, arg1, arg2, arg3)
+}
+
 // DeleteURLByUserID mocks base method.
 func (m *MockURLService) DeleteURLByUserID(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -94,6 +123,20 @@ func (mr *MockURLServiceMockRecorder) DeleteURLByUserID(arg0, arg1, arg2 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteURLByUserID", reflect.TypeOf((*MockURLService)(nil).DeleteURLByUserID), arg0, arg1, arg2)
 }
 
+// Export mocks base method.
+func (m *MockURLService) Export(arg0 context.Context, arg1 string, arg2 model.URLFilter, arg3 func(dto.URLBatchResponseByUserID) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockURLServiceMockRecorder) Export(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockURLService)(nil).Export), arg0, arg1, arg2, arg3)
+}
+
 // GetAll mocks base method.
 func (m *MockURLService) GetAll(arg0 context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -110,18 +153,33 @@ func (mr *MockURLServiceMockRecorder) GetAll(arg0 interface{}) *gomock.Call {
 }
 
 // GetAllByUserID mocks base method.
-func (m *MockURLService) GetAllByUserID(arg0 context.Context, arg1 string) ([]dto.URLBatchResponseByUserID, error) {
+func (m *MockURLService) GetAllByUserID(arg0 context.Context, arg1 string, arg2 dto.URLQuery) (*dto.URLPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllByUserID", arg0, arg1)
-	ret0, _ := ret[0].([]dto.URLBatchResponseByUserID)
+	ret := m.ctrl.Call(m, "GetAllByUserID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dto.URLPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllByUserID indicates an expected call of GetAllByUserID.
-func (mr *MockURLServiceMockRecorder) GetAllByUserID(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockURLServiceMockRecorder) GetAllByUserID(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllByUserID", reflect.TypeOf((*MockURLService)(nil).GetAllByUserID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllByUserID", reflect.TypeOf((*MockURLService)(nil).GetAllByUserID), arg0, arg1, arg2)
+}
+
+// GetAllURLs mocks base method.
+func (m *MockURLService) GetAllURLs(arg0 context.Context, arg1 dto.URLQuery) (*dto.URLRecordPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllURLs", arg0, arg1)
+	ret0, _ := ret[0].(*dto.URLRecordPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllURLs indicates an expected call of GetAllURLs.
+func (mr *MockURLServiceMockRecorder) GetAllURLs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllURLs", reflect.TypeOf((*MockURLService)(nil).GetAllURLs), arg0, arg1)
 }
 
 // GetByyID mocks base method.
@@ -139,6 +197,51 @@ func (mr *MockURLServiceMockRecorder) GetByyID(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByyID", reflect.TypeOf((*MockURLService)(nil).GetByyID), arg0, arg1)
 }
 
+// GetCountryRedirects mocks base method.
+func (m *MockURLService) GetCountryRedirects(arg0 context.Context, arg1, arg2 string) ([]model.CountryRedirects, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCountryRedirects", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]model.CountryRedirects)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCountryRedirects indicates an expected call of GetCountryRedirects.
+func (mr *MockURLServiceMockRecorder) GetCountryRedirects(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCountryRedirects", reflect.TypeOf((*MockURLService)(nil).GetCountryRedirects), arg0, arg1, arg2)
+}
+
+// GetRedirect mocks base method.
+func (m *MockURLService) GetRedirect(arg0 context.Context, arg1 string, arg2 model.Visit) (*model.Redirect, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRedirect", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*model.Redirect)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRedirect indicates an expected call of GetRedirect.
+func (mr *MockURLServiceMockRecorder) GetRedirect(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRedirect", reflect.TypeOf((*MockURLService)(nil).GetRedirect), arg0, arg1, arg2)
+}
+
+// GetRules mocks base method.
+func (m *MockURLService) GetRules(arg0 context.Context, arg1, arg2 string) ([]model.RedirectRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRules", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]model.RedirectRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRules indicates an expected call of GetRules.
+func (mr *MockURLServiceMockRecorder) GetRules(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRules", reflect.TypeOf((*MockURLService)(nil).GetRules), arg0, arg1, arg2)
+}
+
 // GetStats mocks base method.
 func (m *MockURLService) GetStats(arg0 context.Context) (*dto.URLStats, error) {
 	m.ctrl.T.Helper()
@@ -154,6 +257,21 @@ func (mr *MockURLServiceMockRecorder) GetStats(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockURLService)(nil).GetStats), arg0)
 }
 
+// Import mocks base method.
+func (m *MockURLService) Import(arg0 context.Context, arg1 func() (dto.URLBatchRequest, error), arg2, arg3 string) (*dto.URLImportSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*dto.URLImportSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockURLServiceMockRecorder) Import(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockURLService)(nil).Import), arg0, arg1, arg2, arg3)
+}
+
 // Ping mocks base method.
 func (m *MockURLService) Ping(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -167,3 +285,77 @@ func (mr *MockURLServiceMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockURLService)(nil).Ping), arg0)
 }
+
+// RestoreURLByUserID mocks base method.
+func (m *MockURLService) RestoreURLByUserID(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreURLByUserID", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreURLByUserID indicates an expected call of RestoreURLByUserID.
+func (mr *MockURLServiceMockRecorder) RestoreURLByUserID(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreURLByUserID", reflect.TypeOf((*MockURLService)(nil).RestoreURLByUserID), arg0, arg1, arg2)
+}
+
+// SetRules mocks base method.
+func (m *MockURLService) SetRules(arg0 context.Context, arg1, arg2 string, arg3 []model.RedirectRule) ([]model.RedirectRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRules", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]model.RedirectRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRules indicates an expected call of SetRules.
+func (mr *MockURLServiceMockRecorder) SetRules(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRules", reflect.TypeOf((*MockURLService)(nil).SetRules), arg0, arg1, arg2, arg3)
+}
+
+// UpdateRedirect mocks base method.
+func (m *MockURLService) UpdateRedirect(arg0 context.Context, arg1, arg2 string, arg3 model.RedirectSettings) (*model.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRedirect", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*model.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRedirect indicates an expected call of UpdateRedirect.
+func (mr *MockURLServiceMockRecorder) UpdateRedirect(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRedirect", reflect.TypeOf((*MockURLService)(nil).UpdateRedirect), arg0, arg1, arg2, arg3)
+}
+
+// UpdateRule mocks base method.
+func (m *MockURLService) UpdateRule(arg0 context.Context, arg1, arg2, arg3 string, arg4 model.RedirectRule) (*model.RedirectRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRule", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*model.RedirectRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateRule indicates an expected call of UpdateRule.
+func (mr *MockURLServiceMockRecorder) UpdateRule(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRule", reflect.TypeOf((*MockURLService)(nil).UpdateRule), arg0, arg1, arg2, arg3, arg4)
+}
+
+// UpdateURL mocks base method.
+func (m *MockURLService) UpdateURL(arg0 context.Context, arg1, arg2, arg3 string) (*model.URL, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*model.URL)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURL indicates an expected call of UpdateURL.
+func (mr *MockURLServiceMockRecorder) UpdateURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURL", reflect.TypeOf((*MockURLService)(nil).UpdateURL), arg0, arg1, arg2, arg3)
+}
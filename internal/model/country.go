@@ -0,0 +1,62 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// UnknownCountry is a country code of visitors whose country is not resolved.
+const UnknownCountry = "ZZ"
+
+// CountryAccess restricts countries of visitors by ISO 3166-1 alpha-2 codes, zero value allows all countries.
+type CountryAccess struct {
+	// Allow lists the only allowed countries, visitors of unknown country are not allowed by a non-empty list.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists denied countries, visitors of unknown country are not denied.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Empty reports whether the access allows all countries.
+func (a CountryAccess) Empty() bool {
+	return len(a.Allow) == 0 && len(a.Deny) == 0
+}
+
+// Allowed reports whether visitors of the country are allowed, empty country is unknown.
+func (a CountryAccess) Allowed(country string) bool {
+	if len(a.Allow) > 0 && (country == "" || !slices.Contains(a.Allow, country)) {
+		return false
+	}
+
+	return country == "" || !slices.Contains(a.Deny, country)
+}
+
+// CountryRedirects represents a number of redirects of URL visitors from the country.
+type CountryRedirects struct {
+	Country   string `db:"country"`
+	Redirects int64  `db:"redirects"`
+}
+
+// URLCountryRedirects represents a number of redirects of the URL visitors from the country.
+type URLCountryRedirects struct {
+	URLID     string
+	Country   string
+	Redirects int64
+}
+
+// SortCountryRedirects returns redirects by country ordered by number of redirects
+// from the most redirected country, countries with equal numbers are ordered by code.
+func SortCountryRedirects(redirects map[string]int64) []CountryRedirects {
+	sorted := make([]CountryRedirects, 0, len(redirects))
+	for country, n := range redirects {
+		sorted = append(sorted, CountryRedirects{Country: country, Redirects: n})
+	}
+
+	slices.SortFunc(sorted, func(a, b CountryRedirects) int {
+		if a.Redirects != b.Redirects {
+			return cmp.Compare(b.Redirects, a.Redirects)
+		}
+		return strings.Compare(a.Country, b.Country)
+	})
+	return sorted
+}
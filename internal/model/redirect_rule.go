@@ -0,0 +1,165 @@
+package model
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device families of user agents matched by redirect rules.
+const (
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceWindows = "windows"
+	DeviceMacOS   = "macos"
+	DeviceLinux   = "linux"
+	DeviceOther   = "other"
+)
+
+// Devices are all device families in the order user agents are checked.
+var Devices = []string{DeviceIOS, DeviceAndroid, DeviceWindows, DeviceMacOS, DeviceLinux, DeviceOther}
+
+// RedirectRule represents a conditional redirect of URL to another destination.
+//
+// A rule matches a visit matching all its conditions, a list condition matches any of its values
+// and empty conditions match any visit.
+type RedirectRule struct {
+	ID          string `json:"id"`
+	Destination string `json:"destination"`
+	// Devices are device families of the visitor user agent.
+	Devices []string `json:"devices,omitempty"`
+	// Languages are language tags matched against the preferred language of the visitor, "en" matches "en-US".
+	Languages []string `json:"languages,omitempty"`
+	// ReferrerHosts are hosts of the visit referrer, subdomains match too.
+	ReferrerHosts []string `json:"referrer_hosts,omitempty"`
+	// From and Until limit time of the visit, From is inclusive and Until is exclusive.
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// Percent is a share of visitors matched for A/B tests, zero matches all visitors.
+	// Percentage rules take consecutive ranges of visitor buckets in rules order, so their shares don't overlap.
+	Percent int `json:"percent,omitempty"`
+}
+
+// Match reports whether the rule matches the visit at the time,
+// bucket is the visitor bucket counted from the start of the rule's range, the rule matches buckets in [0, Percent).
+func (r RedirectRule) Match(visit Visit, at time.Time, bucket int) bool {
+	if r.From != nil && at.Before(*r.From) {
+		return false
+	}
+
+	if r.Until != nil && !at.Before(*r.Until) {
+		return false
+	}
+
+	if len(r.Devices) > 0 && !slices.Contains(r.Devices, DeviceFamily(visit.UserAgent)) {
+		return false
+	}
+
+	if len(r.Languages) > 0 && !matchLanguage(r.Languages, PreferredLanguage(visit.AcceptLanguage)) {
+		return false
+	}
+
+	if len(r.ReferrerHosts) > 0 && !matchHost(r.ReferrerHosts, referrerHost(visit.Referrer)) {
+		return false
+	}
+
+	if r.Percent > 0 && (bucket < 0 || bucket >= r.Percent) {
+		return false
+	}
+
+	return true
+}
+
+// DeviceFamily returns device family of the user agent.
+func DeviceFamily(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "iPod"):
+		return DeviceIOS
+	case strings.Contains(userAgent, "Android"):
+		return DeviceAndroid
+	case strings.Contains(userAgent, "Windows"):
+		return DeviceWindows
+	case strings.Contains(userAgent, "Macintosh") || strings.Contains(userAgent, "Mac OS X"):
+		return DeviceMacOS
+	case strings.Contains(userAgent, "Linux") || strings.Contains(userAgent, "X11"):
+		return DeviceLinux
+	default:
+		return DeviceOther
+	}
+}
+
+// PreferredLanguage returns lower-cased language tag with the highest quality in Accept-Language header,
+// the first one wins among equal qualities, empty if there is none.
+func PreferredLanguage(acceptLanguage string) string {
+	preferred, preferredQuality := "", 0.0
+	for _, item := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(item, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+
+		if quality > preferredQuality {
+			preferred, preferredQuality = tag, quality
+		}
+	}
+
+	return preferred
+}
+
+// matchLanguage reports whether the language tag equals or is a subtag of any of the languages.
+func matchLanguage(languages []string, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	for _, language := range languages {
+		language = strings.ToLower(language)
+		if tag == language || strings.HasPrefix(tag, language+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchHost reports whether the host equals or is a subdomain of any of the hosts.
+func matchHost(hosts []string, host string) bool {
+	if host == "" {
+		return false
+	}
+
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// referrerHost returns lower-cased host of the referrer URL, empty if it is invalid.
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
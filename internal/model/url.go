@@ -1,14 +1,168 @@
 // Package model contains the model for the application.
 package model
 
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
 // URL represents the URL model.
 type URL struct {
-	ID            string `db:"id"`
-	Original      string `db:"original_url"`
-	Shortened     string `db:"short_url"`
-	CorrelationID string `db:"correlation_id"`
-	UserID        string `db:"user_id"`
-	DeletedFlag   bool   `db:"deleted_flag"`
+	ID            string    `db:"id"`
+	Original      string    `db:"original_url"`
+	Shortened     string    `db:"short_url"`
+	CorrelationID string    `db:"correlation_id"`
+	UserID        string    `db:"user_id"`
+	DeletedFlag   bool      `db:"deleted_flag"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+	// DeletedAt is a time the URL was deleted at, nil if DeletedFlag is not set.
+	DeletedAt *time.Time `db:"deleted_at"`
+	// RedirectCode is HTTP status of redirects to the original URL, zero means the server default.
+	RedirectCode int `db:"redirect_code"`
+	// CacheControl is Cache-Control header of redirects to the original URL, empty means the server default.
+	CacheControl string `db:"cache_control"`
+	// QueryRules builds query of redirects to the original URL.
+	QueryRules QueryRules `db:"query_rules"`
+	// Rules redirect visits to other destinations, the first matching rule wins.
+	Rules []RedirectRule `db:"redirect_rules"`
+	// CountryAccess restricts countries of visitors redirected to the original URL.
+	CountryAccess CountryAccess `db:"country_access"`
+}
+
+// VisitorDependent reports whether redirects of the URL may differ between visitors,
+// i.e. the URL has redirect rules, query rules or country access.
+func (u URL) VisitorDependent() bool {
+	return len(u.Rules) > 0 || !u.QueryRules.Empty() || !u.CountryAccess.Empty()
+}
+
+// MarkDeleted marks URL deleted at the time, update time never goes back.
+func (u *URL) MarkDeleted(at time.Time) {
+	u.DeletedFlag = true
+	u.DeletedAt = &at
+	if at.After(u.UpdatedAt) {
+		u.UpdatedAt = at
+	}
+}
+
+// Restore clears deletion of URL restored at the time, update time never goes back.
+func (u *URL) Restore(at time.Time) {
+	u.DeletedFlag = false
+	u.DeletedAt = nil
+	if at.After(u.UpdatedAt) {
+		u.UpdatedAt = at
+	}
+}
+
+// Edit changes original URL of URL edited at the time and returns the edit, update time never goes back.
+func (u *URL) Edit(original string, at time.Time) URLEdit {
+	edit := URLEdit{
+		URLID:       u.ID,
+		UserID:      u.UserID,
+		OldOriginal: u.Original,
+		NewOriginal: original,
+		EditedAt:    at,
+	}
+
+	u.Original = original
+	if at.After(u.UpdatedAt) {
+		u.UpdatedAt = at
+	}
+
+	return edit
+}
+
+// SetRedirect changes redirect settings of URL changed at the time, update time never goes back.
+func (u *URL) SetRedirect(settings RedirectSettings, at time.Time) {
+	u.RedirectCode = settings.Code
+	u.CacheControl = settings.CacheControl
+	u.QueryRules = QueryRules{
+		Params:      maps.Clone(settings.QueryRules.Params),
+		PassThrough: settings.QueryRules.PassThrough,
+	}
+	u.CountryAccess = CountryAccess{
+		Allow: slices.Clone(settings.CountryAccess.Allow),
+		Deny:  slices.Clone(settings.CountryAccess.Deny),
+	}
+	if at.After(u.UpdatedAt) {
+		u.UpdatedAt = at
+	}
+}
+
+// SetRules replaces redirect rules of URL changed at the time, update time never goes back.
+func (u *URL) SetRules(rules []RedirectRule, at time.Time) {
+	u.Rules = slices.Clone(rules)
+	if at.After(u.UpdatedAt) {
+		u.UpdatedAt = at
+	}
+}
+
+// DeletedBefore reports whether URL was soft-deleted before the time.
+func (u URL) DeletedBefore(t time.Time) bool {
+	return u.DeletedAt != nil && u.DeletedAt.Before(t)
+}
+
+// URLEdit represents a change of original URL of the URL.
+type URLEdit struct {
+	URLID       string    `db:"url_id"`
+	UserID      string    `db:"user_id"`
+	OldOriginal string    `db:"old_original_url"`
+	NewOriginal string    `db:"new_original_url"`
+	EditedAt    time.Time `db:"edited_at"`
+}
+
+// RedirectSettings represents redirect settings of URL, zero values mean server defaults.
+type RedirectSettings struct {
+	Code          int
+	CacheControl  string
+	QueryRules    QueryRules
+	CountryAccess CountryAccess
+}
+
+// QueryRules represents rules building query of redirects to the original URL.
+//
+// Query parameters of the original URL are overridden by passed through parameters of the visit,
+// which are overridden by Params.
+type QueryRules struct {
+	// Params are set in the query, {name} placeholders in values are substituted with variables of the visit,
+	// parameters with empty values after substitution are not set.
+	Params map[string]string `json:"params,omitempty"`
+	// PassThrough passes query parameters of the visit through to the original URL.
+	PassThrough bool `json:"pass_through,omitempty"`
+}
+
+// Empty reports whether the rules leave the original URL unchanged.
+func (r QueryRules) Empty() bool {
+	return len(r.Params) == 0 && !r.PassThrough
+}
+
+// Visit represents a visit of a short URL its redirect is resolved for.
+type Visit struct {
+	// Query is query of the short URL.
+	Query url.Values
+	// Country is ISO 3166-1 alpha-2 code of the visitor country, empty if unknown.
+	Country        string
+	UserAgent      string
+	AcceptLanguage string
+	Referrer       string
+	// ClientID identifies the visitor for percentage splits, e.g. client IP, empty if unknown.
+	ClientID string
+	// IP is the visitor IP address the country is resolved for, empty if unknown.
+	IP string
+	// Head is set for HEAD requests, which are not counted as redirects.
+	Head bool
+}
+
+// Redirect represents a redirect to the original URL.
+type Redirect struct {
+	Location     string
+	Code         int
+	CacheControl string
+	// VisitorDependent is set if the redirect depends on the visitor rather than the short URL only.
+	VisitorDependent bool
 }
 
 // URLStats represents the URL stats.
@@ -16,3 +170,77 @@ type URLStats struct {
 	Urls  int
 	Users int
 }
+
+// URLFilter represents filter of URLs, zero fields are not applied.
+type URLFilter struct {
+	UserID  string
+	Deleted *bool
+	Search  string
+	// CreatedFrom and CreatedTo limit creation time, CreatedFrom is inclusive and CreatedTo is exclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// Match reports whether URL satisfies the filter.
+func (f URLFilter) Match(url URL) bool {
+	if f.UserID != "" && url.UserID != f.UserID {
+		return false
+	}
+
+	if f.Deleted != nil && url.DeletedFlag != *f.Deleted {
+		return false
+	}
+
+	if !f.CreatedFrom.IsZero() && url.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+
+	if !f.CreatedTo.IsZero() && !url.CreatedAt.Before(f.CreatedTo) {
+		return false
+	}
+
+	if f.Search != "" && !strings.Contains(url.Original, f.Search) {
+		return false
+	}
+
+	return true
+}
+
+// URLCursor represents position of URL in a listing ordered by creation time and ID.
+type URLCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// URLQuery represents query of a page of URLs.
+type URLQuery struct {
+	URLFilter
+	// After is a position the page starts after, nil for the first page.
+	After *URLCursor
+	// Desc sorts URLs by creation time descending.
+	Desc bool
+	// Limit is a maximum number of URLs in the page, zero means no limit.
+	Limit int
+}
+
+// Match reports whether URL satisfies the filter and is placed after the cursor.
+func (q URLQuery) Match(url URL) bool {
+	if !q.URLFilter.Match(url) {
+		return false
+	}
+
+	if q.After == nil {
+		return true
+	}
+
+	return q.Less(URL{CreatedAt: q.After.CreatedAt, ID: q.After.ID}, url)
+}
+
+// Less reports whether URL a is placed before URL b in the listing.
+func (q URLQuery) Less(a, b URL) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt) != q.Desc
+	}
+
+	return a.ID != b.ID && (a.ID < b.ID) != q.Desc
+}
@@ -19,15 +19,26 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	URLShortener_GetListURLs_FullMethodName        = "/proto.URLShortener/GetListURLs"
-	URLShortener_PostURL_FullMethodName            = "/proto.URLShortener/PostURL"
-	URLShortener_PostBatchURLs_FullMethodName      = "/proto.URLShortener/PostBatchURLs"
-	URLShortener_GetURL_FullMethodName             = "/proto.URLShortener/GetURL"
-	URLShortener_Ping_FullMethodName               = "/proto.URLShortener/Ping"
-	URLShortener_DeleteAllURLs_FullMethodName      = "/proto.URLShortener/DeleteAllURLs"
-	URLShortener_GetURLsByUserID_FullMethodName    = "/proto.URLShortener/GetURLsByUserID"
-	URLShortener_DeleteURLsByUserID_FullMethodName = "/proto.URLShortener/DeleteURLsByUserID"
-	URLShortener_GetStats_FullMethodName           = "/proto.URLShortener/GetStats"
+	URLShortener_GetListURLs_FullMethodName          = "/proto.URLShortener/GetListURLs"
+	URLShortener_PostURL_FullMethodName              = "/proto.URLShortener/PostURL"
+	URLShortener_PostBatchURLs_FullMethodName        = "/proto.URLShortener/PostBatchURLs"
+	URLShortener_ImportURLs_FullMethodName           = "/proto.URLShortener/ImportURLs"
+	URLShortener_GetURL_FullMethodName               = "/proto.URLShortener/GetURL"
+	URLShortener_Ping_FullMethodName                 = "/proto.URLShortener/Ping"
+	URLShortener_DeleteAllURLs_FullMethodName        = "/proto.URLShortener/DeleteAllURLs"
+	URLShortener_GetURLsByUserID_FullMethodName      = "/proto.URLShortener/GetURLsByUserID"
+	URLShortener_ExportURLs_FullMethodName           = "/proto.URLShortener/ExportURLs"
+	URLShortener_DeleteURLsByUserID_FullMethodName   = "/proto.URLShortener/DeleteURLsByUserID"
+	URLShortener_RestoreURLsByUserID_FullMethodName  = "/proto.URLShortener/RestoreURLsByUserID"
+	URLShortener_UpdateURL_FullMethodName            = "/proto.URLShortener/UpdateURL"
+	URLShortener_UpdateRedirect_FullMethodName       = "/proto.URLShortener/UpdateRedirect"
+	URLShortener_ListRules_FullMethodName            = "/proto.URLShortener/ListRules"
+	URLShortener_SetRules_FullMethodName             = "/proto.URLShortener/SetRules"
+	URLShortener_AddRule_FullMethodName              = "/proto.URLShortener/AddRule"
+	URLShortener_UpdateRule_FullMethodName           = "/proto.URLShortener/UpdateRule"
+	URLShortener_DeleteRule_FullMethodName           = "/proto.URLShortener/DeleteRule"
+	URLShortener_ListCountryRedirects_FullMethodName = "/proto.URLShortener/ListCountryRedirects"
+	URLShortener_GetStats_FullMethodName             = "/proto.URLShortener/GetStats"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -37,11 +48,32 @@ type URLShortenerClient interface {
 	GetListURLs(ctx context.Context, in *GetListURLsRequest, opts ...grpc.CallOption) (*GetListURLsResponse, error)
 	PostURL(ctx context.Context, in *PostURLRequest, opts ...grpc.CallOption) (*PostURLResponse, error)
 	PostBatchURLs(ctx context.Context, in *PostBatchURLRequest, opts ...grpc.CallOption) (*PostBatchURLResponse, error)
+	// ImportURLs is served over HTTP as a newline-delimited stream of ImportURLsRequest.
+	ImportURLs(ctx context.Context, opts ...grpc.CallOption) (URLShortener_ImportURLsClient, error)
 	GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	DeleteAllURLs(ctx context.Context, in *DeleteAllURLsRequest, opts ...grpc.CallOption) (*DeleteAllURLsResponse, error)
 	GetURLsByUserID(ctx context.Context, in *GetURLsByUserIDRequest, opts ...grpc.CallOption) (*GetURLsByUserIDResponse, error)
+	// ExportURLs is served over HTTP as a newline-delimited stream of {"result": URLByUserID}.
+	ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (URLShortener_ExportURLsClient, error)
 	DeleteURLsByUserID(ctx context.Context, in *DeleteURLsByUserIDRequest, opts ...grpc.CallOption) (*DeleteURLsByUserIDResponse, error)
+	// RestoreURLsByUserID restores URLs deleted within the retention period asynchronously.
+	RestoreURLsByUserID(ctx context.Context, in *RestoreURLsByUserIDRequest, opts ...grpc.CallOption) (*RestoreURLsByUserIDResponse, error)
+	// UpdateURL changes original URL of the user's URL, the short URL stays the same.
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	// UpdateRedirect sets redirect status, Cache-Control header, query rules and country access of the user's URL.
+	UpdateRedirect(ctx context.Context, in *UpdateRedirectRequest, opts ...grpc.CallOption) (*UpdateRedirectResponse, error)
+	// ListRules returns redirect rules of the user's URL in evaluation order.
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	// SetRules replaces redirect rules of the user's URL, rules without IDs get new ones.
+	SetRules(ctx context.Context, in *SetRulesRequest, opts ...grpc.CallOption) (*SetRulesResponse, error)
+	// AddRule appends a redirect rule to rules of the user's URL.
+	AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*RedirectRule, error)
+	// UpdateRule replaces a redirect rule of the user's URL keeping its position.
+	UpdateRule(ctx context.Context, in *UpdateRuleRequest, opts ...grpc.CallOption) (*RedirectRule, error)
+	DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error)
+	// ListCountryRedirects returns redirects of the user's URL by country from the most redirected country.
+	ListCountryRedirects(ctx context.Context, in *ListCountryRedirectsRequest, opts ...grpc.CallOption) (*ListCountryRedirectsResponse, error)
 	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
 }
 
@@ -80,6 +112,40 @@ func (c *uRLShortenerClient) PostBatchURLs(ctx context.Context, in *PostBatchURL
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ImportURLs(ctx context.Context, opts ...grpc.CallOption) (URLShortener_ImportURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[0], URLShortener_ImportURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uRLShortenerImportURLsClient{stream}
+	return x, nil
+}
+
+type URLShortener_ImportURLsClient interface {
+	Send(*ImportURLsRequest) error
+	CloseAndRecv() (*ImportURLsResponse, error)
+	grpc.ClientStream
+}
+
+type uRLShortenerImportURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *uRLShortenerImportURLsClient) Send(m *ImportURLsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *uRLShortenerImportURLsClient) CloseAndRecv() (*ImportURLsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportURLsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *uRLShortenerClient) GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*GetURLResponse, error) {
 	out := new(GetURLResponse)
 	err := c.cc.Invoke(ctx, URLShortener_GetURL_FullMethodName, in, out, opts...)
@@ -116,6 +182,38 @@ func (c *uRLShortenerClient) GetURLsByUserID(ctx context.Context, in *GetURLsByU
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ExportURLs(ctx context.Context, in *ExportURLsRequest, opts ...grpc.CallOption) (URLShortener_ExportURLsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[1], URLShortener_ExportURLs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uRLShortenerExportURLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type URLShortener_ExportURLsClient interface {
+	Recv() (*URLByUserID, error)
+	grpc.ClientStream
+}
+
+type uRLShortenerExportURLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *uRLShortenerExportURLsClient) Recv() (*URLByUserID, error) {
+	m := new(URLByUserID)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *uRLShortenerClient) DeleteURLsByUserID(ctx context.Context, in *DeleteURLsByUserIDRequest, opts ...grpc.CallOption) (*DeleteURLsByUserIDResponse, error) {
 	out := new(DeleteURLsByUserIDResponse)
 	err := c.cc.Invoke(ctx, URLShortener_DeleteURLsByUserID_FullMethodName, in, out, opts...)
@@ -125,6 +223,87 @@ func (c *uRLShortenerClient) DeleteURLsByUserID(ctx context.Context, in *DeleteU
 	return out, nil
 }
 
+func (c *uRLShortenerClient) RestoreURLsByUserID(ctx context.Context, in *RestoreURLsByUserIDRequest, opts ...grpc.CallOption) (*RestoreURLsByUserIDResponse, error) {
+	out := new(RestoreURLsByUserIDResponse)
+	err := c.cc.Invoke(ctx, URLShortener_RestoreURLsByUserID_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateRedirect(ctx context.Context, in *UpdateRedirectRequest, opts ...grpc.CallOption) (*UpdateRedirectResponse, error) {
+	out := new(UpdateRedirectResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateRedirect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ListRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) SetRules(ctx context.Context, in *SetRulesRequest, opts ...grpc.CallOption) (*SetRulesResponse, error) {
+	out := new(SetRulesResponse)
+	err := c.cc.Invoke(ctx, URLShortener_SetRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*RedirectRule, error) {
+	out := new(RedirectRule)
+	err := c.cc.Invoke(ctx, URLShortener_AddRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateRule(ctx context.Context, in *UpdateRuleRequest, opts ...grpc.CallOption) (*RedirectRule, error) {
+	out := new(RedirectRule)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error) {
+	out := new(DeleteRuleResponse)
+	err := c.cc.Invoke(ctx, URLShortener_DeleteRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) ListCountryRedirects(ctx context.Context, in *ListCountryRedirectsRequest, opts ...grpc.CallOption) (*ListCountryRedirectsResponse, error) {
+	out := new(ListCountryRedirectsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ListCountryRedirects_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLShortenerClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
 	out := new(GetStatsResponse)
 	err := c.cc.Invoke(ctx, URLShortener_GetStats_FullMethodName, in, out, opts...)
@@ -141,11 +320,32 @@ type URLShortenerServer interface {
 	GetListURLs(context.Context, *GetListURLsRequest) (*GetListURLsResponse, error)
 	PostURL(context.Context, *PostURLRequest) (*PostURLResponse, error)
 	PostBatchURLs(context.Context, *PostBatchURLRequest) (*PostBatchURLResponse, error)
+	// ImportURLs is served over HTTP as a newline-delimited stream of ImportURLsRequest.
+	ImportURLs(URLShortener_ImportURLsServer) error
 	GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	DeleteAllURLs(context.Context, *DeleteAllURLsRequest) (*DeleteAllURLsResponse, error)
 	GetURLsByUserID(context.Context, *GetURLsByUserIDRequest) (*GetURLsByUserIDResponse, error)
+	// ExportURLs is served over HTTP as a newline-delimited stream of {"result": URLByUserID}.
+	ExportURLs(*ExportURLsRequest, URLShortener_ExportURLsServer) error
 	DeleteURLsByUserID(context.Context, *DeleteURLsByUserIDRequest) (*DeleteURLsByUserIDResponse, error)
+	// RestoreURLsByUserID restores URLs deleted within the retention period asynchronously.
+	RestoreURLsByUserID(context.Context, *RestoreURLsByUserIDRequest) (*RestoreURLsByUserIDResponse, error)
+	// UpdateURL changes original URL of the user's URL, the short URL stays the same.
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
+	// UpdateRedirect sets redirect status, Cache-Control header, query rules and country access of the user's URL.
+	UpdateRedirect(context.Context, *UpdateRedirectRequest) (*UpdateRedirectResponse, error)
+	// ListRules returns redirect rules of the user's URL in evaluation order.
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	// SetRules replaces redirect rules of the user's URL, rules without IDs get new ones.
+	SetRules(context.Context, *SetRulesRequest) (*SetRulesResponse, error)
+	// AddRule appends a redirect rule to rules of the user's URL.
+	AddRule(context.Context, *AddRuleRequest) (*RedirectRule, error)
+	// UpdateRule replaces a redirect rule of the user's URL keeping its position.
+	UpdateRule(context.Context, *UpdateRuleRequest) (*RedirectRule, error)
+	DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error)
+	// ListCountryRedirects returns redirects of the user's URL by country from the most redirected country.
+	ListCountryRedirects(context.Context, *ListCountryRedirectsRequest) (*ListCountryRedirectsResponse, error)
 	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
 	mustEmbedUnimplementedURLShortenerServer()
 }
@@ -163,6 +363,9 @@ func (UnimplementedURLShortenerServer) PostURL(context.Context, *PostURLRequest)
 func (UnimplementedURLShortenerServer) PostBatchURLs(context.Context, *PostBatchURLRequest) (*PostBatchURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PostBatchURLs not implemented")
 }
+func (UnimplementedURLShortenerServer) ImportURLs(URLShortener_ImportURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportURLs not implemented")
+}
 func (UnimplementedURLShortenerServer) GetURL(context.Context, *GetURLRequest) (*GetURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetURL not implemented")
 }
@@ -175,9 +378,39 @@ func (UnimplementedURLShortenerServer) DeleteAllURLs(context.Context, *DeleteAll
 func (UnimplementedURLShortenerServer) GetURLsByUserID(context.Context, *GetURLsByUserIDRequest) (*GetURLsByUserIDResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetURLsByUserID not implemented")
 }
+func (UnimplementedURLShortenerServer) ExportURLs(*ExportURLsRequest, URLShortener_ExportURLsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportURLs not implemented")
+}
 func (UnimplementedURLShortenerServer) DeleteURLsByUserID(context.Context, *DeleteURLsByUserIDRequest) (*DeleteURLsByUserIDResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteURLsByUserID not implemented")
 }
+func (UnimplementedURLShortenerServer) RestoreURLsByUserID(context.Context, *RestoreURLsByUserIDRequest) (*RestoreURLsByUserIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreURLsByUserID not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateRedirect(context.Context, *UpdateRedirectRequest) (*UpdateRedirectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRedirect not implemented")
+}
+func (UnimplementedURLShortenerServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedURLShortenerServer) SetRules(context.Context, *SetRulesRequest) (*SetRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRules not implemented")
+}
+func (UnimplementedURLShortenerServer) AddRule(context.Context, *AddRuleRequest) (*RedirectRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRule not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateRule(context.Context, *UpdateRuleRequest) (*RedirectRule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRule not implemented")
+}
+func (UnimplementedURLShortenerServer) DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedURLShortenerServer) ListCountryRedirects(context.Context, *ListCountryRedirectsRequest) (*ListCountryRedirectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCountryRedirects not implemented")
+}
 func (UnimplementedURLShortenerServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
@@ -248,6 +481,32 @@ func _URLShortener_PostBatchURLs_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ImportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(URLShortenerServer).ImportURLs(&uRLShortenerImportURLsServer{stream})
+}
+
+type URLShortener_ImportURLsServer interface {
+	SendAndClose(*ImportURLsResponse) error
+	Recv() (*ImportURLsRequest, error)
+	grpc.ServerStream
+}
+
+type uRLShortenerImportURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *uRLShortenerImportURLsServer) SendAndClose(m *ImportURLsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *uRLShortenerImportURLsServer) Recv() (*ImportURLsRequest, error) {
+	m := new(ImportURLsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _URLShortener_GetURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetURLRequest)
 	if err := dec(in); err != nil {
@@ -320,6 +579,27 @@ func _URLShortener_GetURLsByUserID_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ExportURLs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportURLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLShortenerServer).ExportURLs(m, &uRLShortenerExportURLsServer{stream})
+}
+
+type URLShortener_ExportURLsServer interface {
+	Send(*URLByUserID) error
+	grpc.ServerStream
+}
+
+type uRLShortenerExportURLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *uRLShortenerExportURLsServer) Send(m *URLByUserID) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _URLShortener_DeleteURLsByUserID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteURLsByUserIDRequest)
 	if err := dec(in); err != nil {
@@ -338,6 +618,168 @@ func _URLShortener_DeleteURLsByUserID_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_RestoreURLsByUserID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLsByUserIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).RestoreURLsByUserID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_RestoreURLsByUserID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).RestoreURLsByUserID(ctx, req.(*RestoreURLsByUserIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateRedirect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRedirectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateRedirect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateRedirect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateRedirect(ctx, req.(*UpdateRedirectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_SetRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).SetRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_SetRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).SetRules(ctx, req.(*SetRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_AddRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).AddRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_AddRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).AddRule(ctx, req.(*AddRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateRule(ctx, req.(*UpdateRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_DeleteRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).DeleteRule(ctx, req.(*DeleteRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_ListCountryRedirects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCountryRedirectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ListCountryRedirects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ListCountryRedirects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ListCountryRedirects(ctx, req.(*ListCountryRedirectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLShortener_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetStatsRequest)
 	if err := dec(in); err != nil {
@@ -395,11 +837,58 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteURLsByUserID",
 			Handler:    _URLShortener_DeleteURLsByUserID_Handler,
 		},
+		{
+			MethodName: "RestoreURLsByUserID",
+			Handler:    _URLShortener_RestoreURLsByUserID_Handler,
+		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _URLShortener_UpdateURL_Handler,
+		},
+		{
+			MethodName: "UpdateRedirect",
+			Handler:    _URLShortener_UpdateRedirect_Handler,
+		},
+		{
+			MethodName: "ListRules",
+			Handler:    _URLShortener_ListRules_Handler,
+		},
+		{
+			MethodName: "SetRules",
+			Handler:    _URLShortener_SetRules_Handler,
+		},
+		{
+			MethodName: "AddRule",
+			Handler:    _URLShortener_AddRule_Handler,
+		},
+		{
+			MethodName: "UpdateRule",
+			Handler:    _URLShortener_UpdateRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _URLShortener_DeleteRule_Handler,
+		},
+		{
+			MethodName: "ListCountryRedirects",
+			Handler:    _URLShortener_ListCountryRedirects_Handler,
+		},
 		{
 			MethodName: "GetStats",
 			Handler:    _URLShortener_GetStats_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportURLs",
+			Handler:       _URLShortener_ImportURLs_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportURLs",
+			Handler:       _URLShortener_ExportURLs_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "internal/proto/shortener.proto",
 }
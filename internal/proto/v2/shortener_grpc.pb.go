@@ -0,0 +1,146 @@
+// Code generated by protoc-gen-go-grpchandlers. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpchandlers v1.3.0
+// - protoc             v4.25.3
+// source: internal/proto/v2/shortener.proto
+
+package protov2
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpchandlers package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	URLShortener_CreateURL_FullMethodName = "/proto.v2.URLShortener/CreateURL"
+	URLShortener_ListURLs_FullMethodName  = "/proto.v2.URLShortener/ListURLs"
+)
+
+// URLShortenerClient is the client API for URLShortener service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type URLShortenerClient interface {
+	CreateURL(ctx context.Context, in *CreateURLRequest, opts ...grpc.CallOption) (*CreateURLResponse, error)
+	ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error)
+}
+
+type uRLShortenerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewURLShortenerClient(cc grpc.ClientConnInterface) URLShortenerClient {
+	return &uRLShortenerClient{cc}
+}
+
+func (c *uRLShortenerClient) CreateURL(ctx context.Context, in *CreateURLRequest, opts ...grpc.CallOption) (*CreateURLResponse, error) {
+	out := new(CreateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_CreateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error) {
+	out := new(ListURLsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ListURLs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// URLShortenerServer is the server API for URLShortener service.
+// All implementations must embed UnimplementedURLShortenerServer
+// for forward compatibility
+type URLShortenerServer interface {
+	CreateURL(context.Context, *CreateURLRequest) (*CreateURLResponse, error)
+	ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error)
+	mustEmbedUnimplementedURLShortenerServer()
+}
+
+// UnimplementedURLShortenerServer must be embedded to have forward compatible implementations.
+type UnimplementedURLShortenerServer struct {
+}
+
+func (UnimplementedURLShortenerServer) CreateURL(context.Context, *CreateURLRequest) (*CreateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateURL not implemented")
+}
+func (UnimplementedURLShortenerServer) ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListURLs not implemented")
+}
+func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
+
+// UnsafeURLShortenerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to URLShortenerServer will
+// result in compilation errors.
+type UnsafeURLShortenerServer interface {
+	mustEmbedUnimplementedURLShortenerServer()
+}
+
+func RegisterURLShortenerServer(s grpc.ServiceRegistrar, srv URLShortenerServer) {
+	s.RegisterService(&URLShortener_ServiceDesc, srv)
+}
+
+func _URLShortener_CreateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).CreateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_CreateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).CreateURL(ctx, req.(*CreateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_ListURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ListURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ListURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ListURLs(ctx, req.(*ListURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
+// It's only intended for direct use with grpchandlers.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var URLShortener_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.v2.URLShortener",
+	HandlerType: (*URLShortenerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateURL",
+			Handler:    _URLShortener_CreateURL_Handler,
+		},
+		{
+			MethodName: "ListURLs",
+			Handler:    _URLShortener_ListURLs_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "internal/proto/v2/shortener.proto",
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+//go:embed queries/try_advisory_lock.sql
+var tryAdvisoryLock string
+
+//go:embed queries/advisory_unlock.sql
+var advisoryUnlock string
+
+const unlockTimeout = 5 * time.Second
+
+// AdvisoryLock represents PostgreSQL session advisory lock shared by all replicas using the database.
+type AdvisoryLock struct {
+	PostgresPool *PostgresPool
+	key          int64
+	logger       *zap.Logger
+}
+
+// NewAdvisoryLock returns a new instance of AdvisoryLock with the given key.
+func NewAdvisoryLock(postgresPool *PostgresPool, key int64, logger *zap.Logger) *AdvisoryLock {
+	return &AdvisoryLock{
+		PostgresPool: postgresPool,
+		key:          key,
+		logger:       logger,
+	}
+}
+
+// TryLock acquires the lock if it is free, unlock releases it.
+//
+// Session lock is bound to a connection, so the connection is held out of the pool until unlock.
+func (l *AdvisoryLock) TryLock(ctx context.Context) (func(), bool, error) {
+	conn, err := l.PostgresPool.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, apperr.NewValueError("unable to acquire connection", apperr.Caller(), err)
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, tryAdvisoryLock, l.key).Scan(&acquired)
+	if err != nil {
+		conn.Release()
+		return nil, false, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		defer conn.Release()
+
+		unlockCtx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+
+		if _, errUnlock := conn.Exec(unlockCtx, advisoryUnlock, l.key); errUnlock != nil {
+			l.logger.Error("unable to release advisory lock, closing connection", zap.Error(errUnlock))
+			// Closed connection is destroyed by the pool, so the lock is released with the session.
+			if errClose := conn.Conn().Close(unlockCtx); errClose != nil {
+				l.logger.Error("unable to close connection", zap.Error(errClose))
+			}
+		}
+	}
+
+	return unlock, true, nil
+}
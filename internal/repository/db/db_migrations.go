@@ -3,10 +3,16 @@ package db
 
 import (
 	"embed"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
@@ -64,6 +70,271 @@ func (m *Migrations) MigrateUp() error {
 	return nil
 }
 
+// Up applies n up migrations, all pending migrations if n is zero.
+func (m *Migrations) Up(n int) error {
+	var err error
+	if n == 0 {
+		err = m.migrations.Up()
+	} else {
+		err = m.migrations.Steps(n)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return apperr.NewValueError("Unable to up migrations", apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Down applies n down migrations, all applied migrations if n is zero.
+func (m *Migrations) Down(n int) error {
+	var err error
+	if n == 0 {
+		err = m.migrations.Down()
+	} else {
+		err = m.migrations.Steps(-n)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return apperr.NewValueError("Unable to down migrations", apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Goto migrates up or down to the version.
+func (m *Migrations) Goto(version uint) error {
+	err := m.migrations.Migrate(version)
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return apperr.NewValueError(fmt.Sprintf("Unable to migrate to version %d", version), apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Force sets the version and clears dirty state without running migrations, -1 means no version.
+func (m *Migrations) Force(version int) error {
+	if err := m.migrations.Force(version); err != nil {
+		return apperr.NewValueError(fmt.Sprintf("Unable to force version %d", version), apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Close closes source and database connections.
+func (m *Migrations) Close() error {
+	sourceErr, dbErr := m.migrations.Close()
+	if err := errors.Join(sourceErr, dbErr); err != nil {
+		return apperr.NewValueError("Unable to close migrations", apperr.Caller(), err)
+	}
+	return nil
+}
+
+// Migration describes an embedded migration.
+type Migration struct {
+	Version    uint
+	Identifier string
+	Applied    bool
+}
+
+// MigrationStatus describes the database version and embedded migrations.
+type MigrationStatus struct {
+	// Version is zero if no migration is applied.
+	Version    uint
+	Dirty      bool
+	Migrations []Migration
+}
+
+// Status returns the database version and embedded migrations marked as applied up to the version.
+func (m *Migrations) Status() (*MigrationStatus, error) {
+	version, dirty, err := m.version()
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	for i := range migrations {
+		migrations[i].Applied = migrations[i].Version <= version
+	}
+
+	return &MigrationStatus{Version: version, Dirty: dirty, Migrations: migrations}, nil
+}
+
+// PlannedMigration is a migration which would be run with its SQL.
+type PlannedMigration struct {
+	Version    uint
+	Identifier string
+	Up         bool
+	SQL        string
+}
+
+// PlanUp returns migrations Up(n) would run.
+func (m *Migrations) PlanUp(n int) ([]PlannedMigration, error) {
+	return m.plan(func(version uint, migrations []Migration) ([]Migration, bool) {
+		return planUp(version, migrations, n), true
+	})
+}
+
+// PlanDown returns migrations Down(n) would run.
+func (m *Migrations) PlanDown(n int) ([]PlannedMigration, error) {
+	return m.plan(func(version uint, migrations []Migration) ([]Migration, bool) {
+		return planDown(version, migrations, n), false
+	})
+}
+
+// PlanGoto returns migrations Goto(target) would run, target must be a version of embedded migration.
+func (m *Migrations) PlanGoto(target uint) ([]PlannedMigration, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if !slices.ContainsFunc(migrations, func(migration Migration) bool { return migration.Version == target }) {
+		return nil, apperr.NewValueError(fmt.Sprintf("Unknown version %d", target), apperr.Caller(), fs.ErrNotExist)
+	}
+
+	return m.plan(func(version uint, migrations []Migration) ([]Migration, bool) {
+		return planGoto(version, migrations, target)
+	})
+}
+
+// plan selects migrations to run from embedded ones by the current version and reads their SQL.
+func (m *Migrations) plan(selectMigrations func(version uint, migrations []Migration) ([]Migration, bool)) ([]PlannedMigration, error) {
+	version, _, err := m.version()
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	selected, up := selectMigrations(version, migrations)
+	planned := make([]PlannedMigration, 0, len(selected))
+	for _, migration := range selected {
+		sql, err := readMigration(migration.Version, up)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		planned = append(planned, PlannedMigration{
+			Version:    migration.Version,
+			Identifier: migration.Identifier,
+			Up:         up,
+			SQL:        sql,
+		})
+	}
+
+	return planned, nil
+}
+
+// version returns the database version, zero if no migration is applied.
+func (m *Migrations) version() (uint, bool, error) {
+	version, dirty, err := m.migrations.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, apperr.NewValueError("Unable to get version", apperr.Caller(), err)
+	}
+	return version, dirty, nil
+}
+
+// planUp returns migrations above the version in ascending order, at most n if n is positive.
+func planUp(version uint, migrations []Migration, n int) []Migration {
+	var planned []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			planned = append(planned, migration)
+		}
+	}
+	return limit(planned, n)
+}
+
+// planDown returns migrations up to the version in descending order, at most n if n is positive.
+func planDown(version uint, migrations []Migration, n int) []Migration {
+	var planned []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if migrations[i].Version <= version {
+			planned = append(planned, migrations[i])
+		}
+	}
+	return limit(planned, n)
+}
+
+// planGoto returns migrations between the version and target, up migrations if target is above the version.
+func planGoto(version uint, migrations []Migration, target uint) ([]Migration, bool) {
+	if target >= version {
+		var planned []Migration
+		for _, migration := range planUp(version, migrations, 0) {
+			if migration.Version <= target {
+				planned = append(planned, migration)
+			}
+		}
+		return planned, true
+	}
+
+	var planned []Migration
+	for _, migration := range planDown(version, migrations, 0) {
+		if migration.Version > target {
+			planned = append(planned, migration)
+		}
+	}
+	return planned, false
+}
+
+func limit(migrations []Migration, n int) []Migration {
+	if n > 0 && len(migrations) > n {
+		return migrations[:n]
+	}
+	return migrations
+}
+
+// embeddedMigrations returns embedded migrations in ascending order of versions.
+func embeddedMigrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationsFS, "migration/*.up.sql")
+	if err != nil {
+		return nil, apperr.NewValueError("Unable to list migrations", apperr.Caller(), err)
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		parsed, err := source.DefaultParse(strings.TrimPrefix(file, "migration/"))
+		if err != nil {
+			return nil, apperr.NewValueError(fmt.Sprintf("Unable to parse migration %s", file), apperr.Caller(), err)
+		}
+		migrations = append(migrations, Migration{Version: parsed.Version, Identifier: parsed.Identifier})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// readMigration reads SQL of the embedded up or down migration.
+func readMigration(version uint, up bool) (string, error) {
+	driver, err := iofs.New(migrationsFS, "migration")
+	if err != nil {
+		return "", apperr.NewValueError("Unable to create iofs driver", apperr.Caller(), err)
+	}
+	defer driver.Close()
+
+	var r io.ReadCloser
+	if up {
+		r, _, err = driver.ReadUp(version)
+	} else {
+		r, _, err = driver.ReadDown(version)
+	}
+	if err != nil {
+		return "", apperr.NewValueError(fmt.Sprintf("Unable to read migration %d", version), apperr.Caller(), err)
+	}
+	defer r.Close()
+
+	sql, err := io.ReadAll(r)
+	if err != nil {
+		return "", apperr.NewValueError(fmt.Sprintf("Unable to read migration %d", version), apperr.Caller(), err)
+	}
+
+	return string(sql), nil
+}
+
 func dbURL(config *pgxpool.Config, sslMode string) string {
 	var dbURL strings.Builder
 
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := embeddedMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, uint(i+1), migration.Version, "versions must be consecutive")
+
+		_, err = readMigration(migration.Version, true)
+		assert.NoError(t, err)
+		_, err = readMigration(migration.Version, false)
+		assert.NoError(t, err, "every migration must have down migration")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	versions := func(migrations []Migration) []uint {
+		result := make([]uint, 0, len(migrations))
+		for _, migration := range migrations {
+			result = append(result, migration.Version)
+		}
+		return result
+	}
+
+	assert.Equal(t, []uint{1, 2, 3, 4}, versions(planUp(0, migrations, 0)))
+	assert.Equal(t, []uint{3}, versions(planUp(2, migrations, 1)))
+	assert.Empty(t, planUp(4, migrations, 0))
+
+	assert.Equal(t, []uint{2, 1}, versions(planDown(2, migrations, 0)))
+	assert.Equal(t, []uint{4, 3}, versions(planDown(4, migrations, 2)))
+	assert.Empty(t, planDown(0, migrations, 0))
+
+	planned, up := planGoto(1, migrations, 3)
+	assert.True(t, up)
+	assert.Equal(t, []uint{2, 3}, versions(planned))
+
+	planned, up = planGoto(4, migrations, 2)
+	assert.False(t, up)
+	assert.Equal(t, []uint{4, 3}, versions(planned))
+
+	planned, _ = planGoto(2, migrations, 2)
+	assert.Empty(t, planned)
+}
@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 
 	"github.com/msmkdenis/yap-shortener/internal/model"
@@ -22,11 +24,17 @@ var insertURLAndReturn string
 //go:embed queries/select_url_by_id.sql
 var selectURLByID string
 
-//go:embed queries/select_all_urls.sql
-var selectAllURLs string
+//go:embed queries/select_url_by_id_or_owner.sql
+var selectURLByIDOrOwner string
 
-//go:embed queries/select_all_urls_by_userid.sql
-var selectAllURLsByUserID string
+//go:embed queries/select_urls_page.sql
+var selectURLsPage string
+
+//go:embed queries/select_urls_page_desc.sql
+var selectURLsPageDesc string
+
+//go:embed queries/select_urls_by_userid_and_filter.sql
+var selectURLsByUserIDAndFilter string
 
 //go:embed queries/delete_all_urls.sql
 var deleteAllURLs string
@@ -37,15 +45,51 @@ var blockURLsByUserIDAndURLsIDs string
 //go:embed queries/set_true_deleted_to_urls_by_userid_and_urlsids.sql
 var setDeletedByUserIDandURLsIDs string
 
+//go:embed queries/restore_url_by_userid_and_urlid.sql
+var restoreURLByUserIDAndURLID string
+
+//go:embed queries/select_url_by_id_for_update.sql
+var selectURLByIDForUpdate string
+
+//go:embed queries/update_url_original.sql
+var updateURLOriginal string
+
+//go:embed queries/update_url_redirect.sql
+var updateURLRedirect string
+
+//go:embed queries/update_url_rules.sql
+var updateURLRules string
+
+//go:embed queries/add_url_country_redirects.sql
+var addURLCountryRedirects string
+
+//go:embed queries/select_url_country_redirects.sql
+var selectURLCountryRedirects string
+
+//go:embed queries/insert_url_edit.sql
+var insertURLEdit string
+
+//go:embed queries/select_url_edits_by_id.sql
+var selectURLEditsByID string
+
 //go:embed queries/create_tmp_table_like_url.sql
 var createTmpTableLikeURL string
 
-//go:embed queries/upsert_and_return_urls_from_tmp_table.sql
-var upsertAndReturnURLsFromTmpTable string
+//go:embed queries/insert_and_return_urls_from_tmp_table.sql
+var insertAndReturnURLsFromTmpTable string
+
+//go:embed queries/select_existing_urls_from_tmp_table.sql
+var selectExistingURLsFromTmpTable string
+
+//go:embed queries/purge_deleted_urls.sql
+var purgeDeletedURLs string
 
 //go:embed queries/select_stats.sql
 var selectStats string
 
+// uniqueViolation is PostgreSQL error code of unique constraint violation.
+const uniqueViolation = "23505"
+
 // PostgresURLRepository represents a PostgreSQL implementation of the URLRepository interface.
 type PostgresURLRepository struct {
 	PostgresPool *PostgresPool
@@ -78,7 +122,7 @@ func (r *PostgresURLRepository) DeleteURLByUserID(ctx context.Context, userID st
 	}
 
 	defer func() {
-		if errRollback := tx.Rollback(ctx); errRollback != nil {
+		if errRollback := tx.Rollback(ctx); errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
 			r.logger.Error("unable to rollback transaction", zap.Error(errRollback))
 		}
 	}()
@@ -111,19 +155,210 @@ func (r *PostgresURLRepository) DeleteURLByUserID(ctx context.Context, userID st
 	return nil
 }
 
-// SelectAllByUserID retrieves from PostgreSQL DB URLs by user ID.
-func (r *PostgresURLRepository) SelectAllByUserID(ctx context.Context, userID string) ([]model.URL, error) {
-	queryRows, err := r.PostgresPool.db.Query(ctx, selectAllURLsByUserID, userID)
+// RestoreURLByUserID restores in PostgreSQL DB URL of the user deleted not before deletedAfter,
+// zero deletedAfter restores URL deleted at any time.
+func (r *PostgresURLRepository) RestoreURLByUserID(ctx context.Context, userID string, shortURL string, deletedAfter time.Time) error {
+	r.logger.Info("RestoreURLByUserID", zap.String("userID", userID), zap.String("shortURL", shortURL))
+	_, err := r.PostgresPool.db.Exec(ctx, restoreURLByUserIDAndURLID, userID, shortURL, nullTime(deletedAfter))
+	if err != nil {
+		return apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// UpdateOriginal changes in PostgreSQL DB original URL of the user's URL and records the edit.
+//
+// Deleted URLs can not be edited, unchanged URL is returned as is without an edit.
+// Performed in a single transaction with the URL locked by select for update.
+func (r *PostgresURLRepository) UpdateOriginal(ctx context.Context, userID string, id string, original string, at time.Time) (*model.URL, error) {
+	tx, err := r.PostgresPool.db.Begin(ctx)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to start transaction", apperr.Caller(), err)
+	}
+
+	defer func() {
+		if errRollback := tx.Rollback(ctx); errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
+			r.logger.Error("unable to rollback transaction", zap.Error(errRollback))
+		}
+	}()
+
+	var url model.URL
+	err = tx.QueryRow(ctx, selectURLByIDForUpdate, id).
+		Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag, &url.CreatedAt, &url.UpdatedAt, &url.DeletedAt, &url.RedirectCode, &url.CacheControl, &url.QueryRules, &url.Rules, &url.CountryAccess)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && url.UserID != userID) {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	if url.Original == original {
+		return &url, nil
+	}
+
+	var updatedURL model.URL
+	err = tx.QueryRow(ctx, updateURLOriginal, id, original, at).
+		Scan(&updatedURL.ID, &updatedURL.Original, &updatedURL.Shortened, &updatedURL.CorrelationID, &updatedURL.UserID, &updatedURL.DeletedFlag, &updatedURL.CreatedAt, &updatedURL.UpdatedAt, &updatedURL.DeletedAt, &updatedURL.RedirectCode, &updatedURL.CacheControl, &updatedURL.QueryRules, &updatedURL.Rules, &updatedURL.CountryAccess)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	_, err = tx.Exec(ctx, insertURLEdit, id, userID, url.Original, original, at)
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, apperr.NewValueError("commit failed", apperr.Caller(), err)
+	}
+
+	return &updatedURL, nil
+}
+
+// UpdateRedirect changes in PostgreSQL DB redirect settings of the user's URL, deleted URLs can not be changed.
+func (r *PostgresURLRepository) UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings, at time.Time) (*model.URL, error) {
+	var url model.URL
+	err := r.PostgresPool.db.QueryRow(ctx, updateURLRedirect, id, userID, settings.Code, settings.CacheControl, settings.QueryRules, settings.CountryAccess, at).
+		Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag, &url.CreatedAt, &url.UpdatedAt, &url.DeletedAt, &url.RedirectCode, &url.CacheControl, &url.QueryRules, &url.Rules, &url.CountryAccess)
+	if err == nil {
+		return &url, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	// Nothing is updated, find out whether the URL is missing or deleted.
+	stored, err := r.SelectByID(ctx, id)
+	if err == nil && stored.UserID == userID && stored.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+	if err != nil && !errors.Is(err, urlErr.ErrURLNotFound) {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil, apperr.NewValueError(fmt.Sprintf("url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+}
+
+// UpdateRules applies update to redirect rules of the user's URL in PostgreSQL DB, deleted URLs can not be changed.
+//
+// Performed in a single transaction with the URL locked by select for update.
+func (r *PostgresURLRepository) UpdateRules(ctx context.Context, userID string, id string, update func([]model.RedirectRule) ([]model.RedirectRule, error), at time.Time) (*model.URL, error) {
+	tx, err := r.PostgresPool.db.Begin(ctx)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to start transaction", apperr.Caller(), err)
+	}
+
+	defer func() {
+		if errRollback := tx.Rollback(ctx); errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
+			r.logger.Error("unable to rollback transaction", zap.Error(errRollback))
+		}
+	}()
+
+	var url model.URL
+	err = tx.QueryRow(ctx, selectURLByIDForUpdate, id).
+		Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag, &url.CreatedAt, &url.UpdatedAt, &url.DeletedAt, &url.RedirectCode, &url.CacheControl, &url.QueryRules, &url.Rules, &url.CountryAccess)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && url.UserID != userID) {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	rules, err := update(url.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	var updatedURL model.URL
+	err = tx.QueryRow(ctx, updateURLRules, id, rules, at).
+		Scan(&updatedURL.ID, &updatedURL.Original, &updatedURL.Shortened, &updatedURL.CorrelationID, &updatedURL.UserID, &updatedURL.DeletedFlag, &updatedURL.CreatedAt, &updatedURL.UpdatedAt, &updatedURL.DeletedAt, &updatedURL.RedirectCode, &updatedURL.CacheControl, &updatedURL.QueryRules, &updatedURL.Rules, &updatedURL.CountryAccess)
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, apperr.NewValueError("commit failed", apperr.Caller(), err)
+	}
+
+	return &updatedURL, nil
+}
+
+// AddCountryRedirects adds in PostgreSQL DB numbers of redirects of URL visitors by country at once,
+// redirects of URLs not found are skipped.
+func (r *PostgresURLRepository) AddCountryRedirects(ctx context.Context, redirects []model.URLCountryRedirects) error {
+	if len(redirects) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(redirects))
+	countries := make([]string, len(redirects))
+	numbers := make([]int64, len(redirects))
+	for i, redirect := range redirects {
+		ids[i], countries[i], numbers[i] = redirect.URLID, redirect.Country, redirect.Redirects
+	}
+
+	if _, err := r.PostgresPool.db.Exec(ctx, addURLCountryRedirects, ids, countries, numbers); err != nil {
+		return apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// SelectCountryRedirects retrieves from PostgreSQL DB redirects of URL by country ordered by number of redirects.
+func (r *PostgresURLRepository) SelectCountryRedirects(ctx context.Context, id string) ([]model.CountryRedirects, error) {
+	queryRows, err := r.PostgresPool.db.Query(ctx, selectURLCountryRedirects, id)
 	if err != nil {
 		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
 	}
 	defer queryRows.Close()
 
-	urls, err := pgx.CollectRows(queryRows, pgx.RowToStructByPos[model.URL])
+	redirects, err := pgx.CollectRows(queryRows, pgx.RowToStructByPos[model.CountryRedirects])
+	if err != nil {
+		return nil, apperr.NewValueError("unable to collect rows", apperr.Caller(), err)
+	}
+
+	return redirects, nil
+}
+
+// SelectEditsByID retrieves from PostgreSQL DB edits of URL ordered by edit time.
+func (r *PostgresURLRepository) SelectEditsByID(ctx context.Context, id string) ([]model.URLEdit, error) {
+	queryRows, err := r.PostgresPool.db.Query(ctx, selectURLEditsByID, id)
+	if err != nil {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+	defer queryRows.Close()
+
+	edits, err := pgx.CollectRows(queryRows, pgx.RowToStructByPos[model.URLEdit])
 	if err != nil {
 		return nil, apperr.NewValueError("unable to collect rows", apperr.Caller(), err)
 	}
 
+	return edits, nil
+}
+
+// SelectAllByUserID retrieves from PostgreSQL DB a page of user URLs matching the query.
+func (r *PostgresURLRepository) SelectAllByUserID(ctx context.Context, userID string, query model.URLQuery) ([]model.URL, error) {
+	query.UserID = userID
+	urls, err := r.SelectAll(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
 	if len(urls) == 0 {
 		return nil, apperr.NewValueError(fmt.Sprintf("urls not found by user %s", userID), apperr.Caller(), urlErr.ErrURLNotFound)
 	}
@@ -131,24 +366,64 @@ func (r *PostgresURLRepository) SelectAllByUserID(ctx context.Context, userID st
 	return urls, nil
 }
 
+// IterateByUserID calls fn for every URL of the user matching the filter.
+//
+// Rows are read from the query result one by one, iteration stops on the first error returned by fn.
+func (r *PostgresURLRepository) IterateByUserID(ctx context.Context, userID string, filter model.URLFilter, fn func(model.URL) error) error {
+	queryRows, err := r.PostgresPool.db.Query(ctx, selectURLsByUserIDAndFilter, userID, filter.Deleted, filter.Search)
+	if err != nil {
+		return apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+	defer queryRows.Close()
+
+	for queryRows.Next() {
+		var url model.URL
+		err = queryRows.Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag, &url.CreatedAt, &url.UpdatedAt, &url.DeletedAt, &url.RedirectCode, &url.CacheControl, &url.QueryRules, &url.Rules, &url.CountryAccess)
+		if err != nil {
+			return apperr.NewValueError("unable to scan row", apperr.Caller(), err)
+		}
+		if err = fn(url); err != nil {
+			return fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+	}
+
+	if err = queryRows.Err(); err != nil {
+		return apperr.NewValueError("unable to read rows", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
 // Insert inserts to PostgreSQL DB URL.
+//
+// Stored URLs are never overwritten, if the ID is taken or the user already has the original URL
+// the stored URL is returned with urlerr.ErrURLAlreadyExists.
 func (r *PostgresURLRepository) Insert(ctx context.Context, url model.URL) (*model.URL, error) {
 	var savedURL model.URL
 	err := r.PostgresPool.db.QueryRow(ctx, insertURLAndReturn,
-		url.ID, url.Original, url.Shortened, url.UserID, url.DeletedFlag).
-		Scan(&savedURL.ID, &savedURL.Original, &savedURL.Shortened, &savedURL.UserID, &savedURL.DeletedFlag)
+		url.ID, url.Original, url.Shortened, url.CorrelationID, url.UserID, url.DeletedFlag, url.CreatedAt, url.UpdatedAt, url.DeletedAt, url.RedirectCode, url.CacheControl, url.QueryRules, url.Rules, url.CountryAccess).
+		Scan(&savedURL.ID, &savedURL.Original, &savedURL.Shortened, &savedURL.CorrelationID, &savedURL.UserID, &savedURL.DeletedFlag, &savedURL.CreatedAt, &savedURL.UpdatedAt, &savedURL.DeletedAt, &savedURL.RedirectCode, &savedURL.CacheControl, &savedURL.QueryRules, &savedURL.Rules, &savedURL.CountryAccess)
+	if err == nil {
+		return &savedURL, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	err = r.PostgresPool.db.QueryRow(ctx, selectURLByIDOrOwner, url.ID, url.UserID, url.Original).
+		Scan(&savedURL.ID, &savedURL.Original, &savedURL.Shortened, &savedURL.CorrelationID, &savedURL.UserID, &savedURL.DeletedFlag, &savedURL.CreatedAt, &savedURL.UpdatedAt, &savedURL.DeletedAt, &savedURL.RedirectCode, &savedURL.CacheControl, &savedURL.QueryRules, &savedURL.Rules, &savedURL.CountryAccess)
 	if err != nil {
 		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
 	}
 
-	return &savedURL, nil
+	return &savedURL, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
 }
 
 // SelectByID retrieves URL from PostgreSQL DB by ID.
 func (r *PostgresURLRepository) SelectByID(ctx context.Context, key string) (*model.URL, error) {
 	var url model.URL
 	err := r.PostgresPool.db.QueryRow(ctx, selectURLByID, key).
-		Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag)
+		Scan(&url.ID, &url.Original, &url.Shortened, &url.CorrelationID, &url.UserID, &url.DeletedFlag, &url.CreatedAt, &url.UpdatedAt, &url.DeletedAt, &url.RedirectCode, &url.CacheControl, &url.QueryRules, &url.Rules, &url.CountryAccess)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			err = apperr.NewValueError("url not found", apperr.Caller(), urlErr.ErrURLNotFound)
@@ -161,9 +436,26 @@ func (r *PostgresURLRepository) SelectByID(ctx context.Context, key string) (*mo
 	return &url, nil
 }
 
-// SelectAll retrieves all URL from PostgreSQL DB.
-func (r *PostgresURLRepository) SelectAll(ctx context.Context) ([]model.URL, error) {
-	queryRows, err := r.PostgresPool.db.Query(ctx, selectAllURLs)
+// SelectAll retrieves from PostgreSQL DB a page of URLs matching the query.
+func (r *PostgresURLRepository) SelectAll(ctx context.Context, query model.URLQuery) ([]model.URL, error) {
+	sql := selectURLsPage
+	if query.Desc {
+		sql = selectURLsPageDesc
+	}
+
+	var afterCreatedAt *time.Time
+	var afterID string
+	if query.After != nil {
+		afterCreatedAt, afterID = &query.After.CreatedAt, query.After.ID
+	}
+
+	var limit *int
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+
+	queryRows, err := r.PostgresPool.db.Query(ctx, sql, query.UserID, query.Deleted, query.Search,
+		nullTime(query.CreatedFrom), nullTime(query.CreatedTo), afterCreatedAt, afterID, limit)
 	if err != nil {
 		return nil, apperr.NewValueError("query failed", apperr.Caller(), err)
 	}
@@ -178,6 +470,8 @@ func (r *PostgresURLRepository) SelectAll(ctx context.Context) ([]model.URL, err
 }
 
 // SelectStats retrieves stats from PostgreSQL DB.
+//
+// Stats are read from counters maintained by url table triggers instead of scanning the table.
 func (r *PostgresURLRepository) SelectStats(ctx context.Context) (*model.URLStats, error) {
 	var urlStats model.URLStats
 	err := r.PostgresPool.db.QueryRow(ctx, selectStats).
@@ -200,24 +494,37 @@ func (r *PostgresURLRepository) DeleteAll(ctx context.Context) error {
 	return nil
 }
 
-// InsertAllOrUpdate upserts URLs to PostgreSQL DB.
+// PurgeDeleted permanently deletes from PostgreSQL DB at most limit URLs soft-deleted before the time.
+//
+// Rows locked by concurrent transactions are skipped and left for the next batch.
+func (r *PostgresURLRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := r.PostgresPool.db.Exec(ctx, purgeDeletedURLs, before, limit)
+	if err != nil {
+		return 0, apperr.NewValueError("query failed", apperr.Caller(), err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// InsertAll inserts URLs missing in PostgreSQL DB, existing URLs (taken IDs or original URLs
+// the user already has) are left untouched and returned as stored.
 //
 // performed in a single transaction with copy protocol and temp table
-func (r *PostgresURLRepository) InsertAllOrUpdate(ctx context.Context, urls []model.URL) ([]model.URL, error) {
+func (r *PostgresURLRepository) InsertAll(ctx context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
 	tx, err := r.PostgresPool.db.Begin(ctx)
 	if err != nil {
-		return nil, apperr.NewValueError("unable to start transaction", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("unable to start transaction", apperr.Caller(), err)
 	}
 
 	defer func() {
-		if errRollback := tx.Rollback(ctx); errRollback != nil {
+		if errRollback := tx.Rollback(ctx); errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
 			r.logger.Error("unable to rollback transaction", zap.Error(errRollback))
 		}
 	}()
 
 	rows := make([][]interface{}, len(urls))
 	for i, url := range urls {
-		row := []interface{}{url.ID, url.Original, url.Shortened, url.CorrelationID, url.UserID, url.DeletedFlag}
+		row := []interface{}{url.ID, url.Original, url.Shortened, url.CorrelationID, url.UserID, url.DeletedFlag, url.CreatedAt, url.UpdatedAt, url.DeletedAt, url.RedirectCode, url.CacheControl, url.QueryRules, url.Rules, url.CountryAccess}
 		rows[i] = row
 	}
 
@@ -228,38 +535,61 @@ func (r *PostgresURLRepository) InsertAllOrUpdate(ctx context.Context, urls []mo
 	createTmpTableQuery := fmt.Sprintf(createTmpTableLikeURL, tempTable)
 	_, err = tx.Exec(ctx, createTmpTableQuery)
 	if err != nil {
-		return nil, apperr.NewValueError("unable to create temp table", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("unable to create temp table", apperr.Caller(), err)
 	}
 
 	count, err := tx.CopyFrom(
 		ctx,
 		pgx.Identifier{"pg_temp", tempTable},
-		[]string{"id", "original_url", "short_url", "correlation_id", "user_id", "deleted_flag"},
+		[]string{"id", "original_url", "short_url", "correlation_id", "user_id", "deleted_flag", "created_at", "updated_at", "deleted_at", "redirect_code", "cache_control", "query_rules", "redirect_rules", "country_access"},
 		pgx.CopyFromRows(rows),
 	)
 	if err != nil {
-		return nil, apperr.NewValueError("copy from failed", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("copy from failed", apperr.Caller(), err)
 	}
 	if count != int64(len(urls)) {
-		return nil, apperr.NewValueError("not all rows were inserted", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("not all rows were inserted", apperr.Caller(), err)
 	}
 
-	upsertFromTmpTableQuery := fmt.Sprintf(upsertAndReturnURLsFromTmpTable, tempTable)
-	queryRows, err := tx.Query(ctx, upsertFromTmpTableQuery)
+	insertFromTmpTableQuery := fmt.Sprintf(insertAndReturnURLsFromTmpTable, tempTable)
+	insertedRows, err := tx.Query(ctx, insertFromTmpTableQuery)
 	if err != nil {
-		return nil, apperr.NewValueError("unable to upsert batch", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("unable to insert batch", apperr.Caller(), err)
 	}
-	defer queryRows.Close()
 
-	savedURLs, err := pgx.CollectRows(queryRows, pgx.RowToStructByPos[model.URL])
+	inserted, err := pgx.CollectRows(insertedRows, pgx.RowToStructByPos[model.URL])
 	if err != nil {
-		return nil, apperr.NewValueError("unable to collect rows", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("unable to collect rows", apperr.Caller(), err)
+	}
+
+	insertedIDs := make([]string, 0, len(inserted))
+	for _, url := range inserted {
+		insertedIDs = append(insertedIDs, url.ID)
+	}
+
+	selectExistingQuery := fmt.Sprintf(selectExistingURLsFromTmpTable, tempTable)
+	existingRows, err := tx.Query(ctx, selectExistingQuery, insertedIDs)
+	if err != nil {
+		return nil, nil, apperr.NewValueError("unable to select existing urls", apperr.Caller(), err)
+	}
+
+	existing, err := pgx.CollectRows(existingRows, pgx.RowToStructByPos[model.URL])
+	if err != nil {
+		return nil, nil, apperr.NewValueError("unable to collect rows", apperr.Caller(), err)
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return nil, apperr.NewValueError("commit failed", apperr.Caller(), err)
+		return nil, nil, apperr.NewValueError("commit failed", apperr.Caller(), err)
 	}
 
-	return savedURLs, nil
+	return inserted, existing, nil
+}
+
+// nullTime returns nil for zero time, so it is passed to query as null.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
 }
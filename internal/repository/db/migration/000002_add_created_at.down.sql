@@ -0,0 +1,3 @@
+drop index if exists url_shortener.idx_url_user_id_created_at;
+
+alter table url_shortener.url drop column if exists created_at;
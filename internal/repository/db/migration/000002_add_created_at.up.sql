@@ -0,0 +1,3 @@
+alter table url_shortener.url add column if not exists created_at timestamptz not null default now();
+
+create index if not exists idx_url_user_id_created_at on url_shortener.url (user_id, created_at, id);
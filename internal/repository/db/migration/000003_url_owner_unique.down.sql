@@ -0,0 +1 @@
+drop index if exists url_shortener.uq_url_user_id_original_url;
\ No newline at end of file
@@ -0,0 +1 @@
+create unique index if not exists uq_url_user_id_original_url on url_shortener.url (user_id, original_url);
\ No newline at end of file
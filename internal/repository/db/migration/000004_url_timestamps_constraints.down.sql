@@ -0,0 +1,16 @@
+drop index if exists url_shortener.idx_url_deleted_at;
+
+drop index if exists url_shortener.idx_url_created_at_id;
+
+alter table url_shortener.url
+    drop constraint if exists chk_url_deleted_at,
+    drop constraint if exists chk_url_updated_at,
+    drop constraint if exists chk_url_original_url_not_empty,
+    drop column if exists deleted_at,
+    drop column if exists updated_at,
+    alter column deleted_flag drop not null,
+    alter column user_id drop not null,
+    alter column correlation_id drop not null,
+    alter column correlation_id drop default,
+    alter column short_url drop not null,
+    alter column original_url drop not null;
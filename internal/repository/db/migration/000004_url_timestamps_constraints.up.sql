@@ -0,0 +1,27 @@
+update url_shortener.url set correlation_id = '' where correlation_id is null;
+update url_shortener.url set user_id = '' where user_id is null;
+update url_shortener.url set deleted_flag = false where deleted_flag is null;
+
+alter table url_shortener.url
+    alter column original_url set not null,
+    alter column short_url set not null,
+    alter column correlation_id set default '',
+    alter column correlation_id set not null,
+    alter column user_id set not null,
+    alter column deleted_flag set not null,
+    add column if not exists updated_at timestamptz,
+    add column if not exists deleted_at timestamptz;
+
+update url_shortener.url set updated_at = created_at where updated_at is null;
+update url_shortener.url set deleted_at = updated_at where deleted_flag and deleted_at is null;
+
+alter table url_shortener.url
+    alter column updated_at set default now(),
+    alter column updated_at set not null,
+    add constraint chk_url_original_url_not_empty check (original_url <> ''),
+    add constraint chk_url_updated_at check (updated_at >= created_at),
+    add constraint chk_url_deleted_at check (deleted_flag = (deleted_at is not null));
+
+create index if not exists idx_url_created_at_id on url_shortener.url (created_at, id);
+
+create index if not exists idx_url_deleted_at on url_shortener.url (deleted_at) where deleted_at is not null;
@@ -0,0 +1,11 @@
+drop trigger if exists trg_url_count_deleted on url_shortener.url;
+
+drop trigger if exists trg_url_count_inserted on url_shortener.url;
+
+drop function if exists url_shortener.count_deleted_urls();
+
+drop function if exists url_shortener.count_inserted_urls();
+
+drop table if exists url_shortener.user_url_count;
+
+drop table if exists url_shortener.url_stats;
@@ -0,0 +1,91 @@
+create table if not exists url_shortener.url_stats
+(
+    id    bool   not null default true,
+    urls  bigint not null default 0,
+    users bigint not null default 0,
+    constraint pk_url_stats primary key (id),
+    constraint chk_url_stats_single_row check (id),
+    constraint chk_url_stats_urls check (urls >= 0),
+    constraint chk_url_stats_users check (users >= 0)
+);
+
+create table if not exists url_shortener.user_url_count
+(
+    user_id text   not null,
+    urls    bigint not null,
+    constraint pk_user_url_count primary key (user_id),
+    constraint chk_user_url_count_urls check (urls >= 0)
+);
+
+lock table url_shortener.url in share mode;
+
+insert into url_shortener.user_url_count (user_id, urls)
+select user_id, count(*) from url_shortener.url group by user_id
+on conflict (user_id) do update set urls = excluded.urls;
+
+insert into url_shortener.url_stats (id, urls, users)
+select true, count(*), count(distinct user_id) from url_shortener.url
+on conflict (id) do update set urls = excluded.urls, users = excluded.users;
+
+-- Counters are updated once per statement from transition tables, a user is counted
+-- when the first URL is inserted and uncounted when the last URL is deleted.
+create or replace function url_shortener.count_inserted_urls() returns trigger
+    language plpgsql as
+$$
+declare
+    inserted_users bigint;
+begin
+    with counts as (
+        select user_id, count(*) as urls from inserted_urls group by user_id
+    ), upserted as (
+        insert into url_shortener.user_url_count as c (user_id, urls)
+        select user_id, urls from counts
+        on conflict (user_id) do update set urls = c.urls + excluded.urls
+        returning c.user_id, c.urls
+    )
+    select count(*) into inserted_users
+    from upserted u join counts n on n.user_id = u.user_id
+    where u.urls = n.urls;
+
+    update url_shortener.url_stats
+    set urls = urls + (select count(*) from inserted_urls), users = users + inserted_users
+    where id;
+
+    return null;
+end
+$$;
+
+create or replace function url_shortener.count_deleted_urls() returns trigger
+    language plpgsql as
+$$
+declare
+    deleted_users bigint;
+begin
+    update url_shortener.user_url_count c
+    set urls = c.urls - d.urls
+    from (select user_id, count(*) as urls from deleted_urls group by user_id) d
+    where c.user_id = d.user_id;
+
+    delete from url_shortener.user_url_count c
+    where c.urls = 0 and c.user_id in (select user_id from deleted_urls);
+    get diagnostics deleted_users = row_count;
+
+    update url_shortener.url_stats
+    set urls = urls - (select count(*) from deleted_urls), users = users - deleted_users
+    where id;
+
+    return null;
+end
+$$;
+
+drop trigger if exists trg_url_count_inserted on url_shortener.url;
+create trigger trg_url_count_inserted
+    after insert on url_shortener.url
+    referencing new table as inserted_urls
+    for each statement execute function url_shortener.count_inserted_urls();
+
+drop trigger if exists trg_url_count_deleted on url_shortener.url;
+create trigger trg_url_count_deleted
+    after delete on url_shortener.url
+    referencing old table as deleted_urls
+    for each statement execute function url_shortener.count_deleted_urls();
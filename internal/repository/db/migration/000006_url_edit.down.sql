@@ -0,0 +1 @@
+drop table if exists url_shortener.url_edit;
@@ -0,0 +1,13 @@
+create table if not exists url_shortener.url_edit
+(
+    id               bigint generated always as identity,
+    url_id           text        not null,
+    user_id          text        not null,
+    old_original_url text        not null,
+    new_original_url text        not null,
+    edited_at        timestamptz not null default now(),
+    constraint pk_url_edit primary key (id),
+    constraint fk_url_edit_url foreign key (url_id) references url_shortener.url (id) on delete cascade
+);
+
+create index if not exists idx_url_edit_url_id_edited_at on url_shortener.url_edit (url_id, edited_at);
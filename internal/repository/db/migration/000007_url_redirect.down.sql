@@ -0,0 +1,4 @@
+alter table url_shortener.url
+    drop constraint if exists chk_url_redirect_code,
+    drop column if exists cache_control,
+    drop column if exists redirect_code;
@@ -0,0 +1,4 @@
+alter table url_shortener.url
+    add column if not exists redirect_code smallint not null default 0,
+    add column if not exists cache_control text not null default '',
+    add constraint chk_url_redirect_code check (redirect_code in (0, 301, 302, 307, 308));
@@ -0,0 +1,2 @@
+alter table url_shortener.url
+    add column if not exists query_rules jsonb not null default '{}';
@@ -0,0 +1,2 @@
+alter table url_shortener.url
+    drop column if exists redirect_rules;
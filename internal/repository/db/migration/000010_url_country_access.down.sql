@@ -0,0 +1,4 @@
+drop table if exists url_shortener.url_country_redirects;
+
+alter table url_shortener.url
+    drop column if exists country_access;
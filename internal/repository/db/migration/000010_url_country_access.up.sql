@@ -0,0 +1,11 @@
+alter table url_shortener.url
+    add column if not exists country_access jsonb not null default '{}';
+
+create table if not exists url_shortener.url_country_redirects
+(
+    url_id    text   not null,
+    country   text   not null,
+    redirects bigint not null default 0,
+    constraint pk_url_country_redirects primary key (url_id, country),
+    constraint fk_url_country_redirects_url foreign key (url_id) references url_shortener.url (id) on delete cascade
+);
@@ -0,0 +1,7 @@
+insert into url_shortener.url_country_redirects as r (url_id, country, redirects)
+select c.url_id, c.country, sum(c.redirects)
+from unnest($1::text[], $2::text[], $3::bigint[]) as c (url_id, country, redirects)
+join url_shortener.url u on u.id = c.url_id
+group by c.url_id, c.country
+order by c.url_id, c.country
+on conflict (url_id, country) do update set redirects = r.redirects + excluded.redirects
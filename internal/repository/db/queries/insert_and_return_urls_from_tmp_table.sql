@@ -0,0 +1,4 @@
+insert into url_shortener.url (id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access) 
+select id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access from pg_temp.%s 
+on conflict do nothing
+returning id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
\ No newline at end of file
@@ -1,3 +1,4 @@
-insert into url_shortener.url (id, original_url, short_url, user_id, deleted_flag) 
-values ($1, $2, $3, $4, $5) 
-returning id, original_url, short_url, user_id, deleted_flag;
\ No newline at end of file
+insert into url_shortener.url (id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access) 
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) 
+on conflict do nothing
+returning id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access;
\ No newline at end of file
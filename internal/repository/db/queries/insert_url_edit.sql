@@ -0,0 +1,2 @@
+insert into url_shortener.url_edit (url_id, user_id, old_original_url, new_original_url, edited_at)
+values ($1, $2, $3, $4, $5)
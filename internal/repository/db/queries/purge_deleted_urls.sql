@@ -0,0 +1,9 @@
+delete from url_shortener.url
+where id in (
+    select id
+    from url_shortener.url
+    where deleted_at < $1
+    order by deleted_at
+    limit $2
+    for update skip locked
+)
@@ -0,0 +1,4 @@
+update url_shortener.url
+set deleted_flag = false, deleted_at = null, updated_at = greatest(now(), updated_at)
+where user_id = $1 and id = $2 and deleted_flag
+    and ($3::timestamptz is null or deleted_at >= $3)
@@ -1,2 +0,0 @@
-select id, original_url, short_url, coalesce(correlation_id, ''), user_id, deleted_flag
-from url_shortener.url
\ No newline at end of file
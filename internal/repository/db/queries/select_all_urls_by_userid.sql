@@ -1,3 +0,0 @@
-select id, original_url, short_url, coalesce(correlation_id, ''), user_id, deleted_flag
-from url_shortener.url
-where user_id = $1;
\ No newline at end of file
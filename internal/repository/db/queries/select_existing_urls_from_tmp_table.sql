@@ -0,0 +1,4 @@
+select u.id, u.original_url, u.short_url, u.correlation_id, u.user_id, u.deleted_flag, u.created_at, u.updated_at, u.deleted_at, u.redirect_code, u.cache_control, u.query_rules, u.redirect_rules, u.country_access
+from url_shortener.url u
+join pg_temp.%s t on (t.user_id = u.user_id and t.original_url = u.original_url) or t.id = u.id
+where u.id <> all($1)
\ No newline at end of file
@@ -1,4 +1,3 @@
-select
-    count (distinct short_url) as urls,
-    count (distinct user_id) as users
-from url_shortener.url
\ No newline at end of file
+select urls, users
+from url_shortener.url_stats
+where id
@@ -1,3 +1,3 @@
-select id, original_url, short_url, coalesce(correlation_id, ''), user_id, deleted_flag
+select id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
 from url_shortener.url
 where id = $1
\ No newline at end of file
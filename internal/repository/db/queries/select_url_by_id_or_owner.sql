@@ -0,0 +1,5 @@
+select id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
+from url_shortener.url
+where (user_id = $2 and original_url = $3) or id = $1
+order by (user_id = $2 and original_url = $3) desc
+limit 1
\ No newline at end of file
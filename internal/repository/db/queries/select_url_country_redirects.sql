@@ -0,0 +1,4 @@
+select country, redirects
+from url_shortener.url_country_redirects
+where url_id = $1
+order by redirects desc, country
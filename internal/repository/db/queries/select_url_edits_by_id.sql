@@ -0,0 +1,4 @@
+select url_id, user_id, old_original_url, new_original_url, edited_at
+from url_shortener.url_edit
+where url_id = $1
+order by edited_at, id
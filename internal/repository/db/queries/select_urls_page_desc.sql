@@ -0,0 +1,10 @@
+select id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
+from url_shortener.url
+where ($1::text = '' or user_id = $1)
+    and ($2::boolean is null or deleted_flag = $2)
+    and ($3::text = '' or strpos(original_url, $3) > 0)
+    and ($4::timestamptz is null or created_at >= $4)
+    and ($5::timestamptz is null or created_at < $5)
+    and ($6::timestamptz is null or (created_at, id) < ($6, $7::text))
+order by created_at desc, id desc
+limit $8;
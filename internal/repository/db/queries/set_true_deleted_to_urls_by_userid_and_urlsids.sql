@@ -1 +1,3 @@
-update url_shortener.url set deleted_flag = true where user_id = $1 and id = $2
\ No newline at end of file
+update url_shortener.url
+set deleted_flag = true, deleted_at = now(), updated_at = greatest(now(), updated_at)
+where user_id = $1 and id = $2 and not deleted_flag
@@ -0,0 +1,4 @@
+update url_shortener.url
+set redirect_code = $3, cache_control = $4, query_rules = $5, country_access = $6, updated_at = greatest($7, updated_at)
+where id = $1 and user_id = $2 and not deleted_flag
+returning id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
@@ -0,0 +1,4 @@
+update url_shortener.url
+set redirect_rules = $2, updated_at = greatest($3, updated_at)
+where id = $1
+returning id, original_url, short_url, correlation_id, user_id, deleted_flag, created_at, updated_at, deleted_at, redirect_code, cache_control, query_rules, redirect_rules, country_access
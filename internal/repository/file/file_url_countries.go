@@ -0,0 +1,153 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// countriesSuffix is appended to the storage file name to get the name of the file with redirects by country.
+const countriesSuffix = ".countries"
+
+// countryRedirects is a stored number of redirects of URL visitors from the country.
+type countryRedirects struct {
+	URLID     string `json:"url_id"`
+	Country   string `json:"country"`
+	Redirects int64  `json:"redirects"`
+}
+
+// AddCountryRedirects adds in file numbers of redirects of URL visitors by country at once,
+// redirects of URLs not found are skipped
+func (r *URLRepository) AddCountryRedirects(ctx context.Context, redirects []model.URLCountryRedirects) error {
+	if len(redirects) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make(map[string]struct{}, len(redirects))
+	for _, redirect := range redirects {
+		ids[redirect.URLID] = struct{}{}
+	}
+	ids, err := r.selectIDs(ids)
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	added := make(map[string]map[string]int64, len(ids))
+	for _, redirect := range redirects {
+		if _, ok := ids[redirect.URLID]; !ok {
+			continue
+		}
+		if added[redirect.URLID] == nil {
+			added[redirect.URLID] = make(map[string]int64)
+		}
+		added[redirect.URLID][redirect.Country] += redirect.Redirects
+	}
+
+	var countries []countryRedirects
+	err = r.readCountries(func(stored countryRedirects) {
+		if n, ok := added[stored.URLID][stored.Country]; ok {
+			stored.Redirects += n
+			delete(added[stored.URLID], stored.Country)
+		}
+		countries = append(countries, stored)
+	})
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	for id, byCountry := range added {
+		for country, n := range byCountry {
+			countries = append(countries, countryRedirects{URLID: id, Country: country, Redirects: n})
+		}
+	}
+
+	if err = r.writeCountries(countries); err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// SelectCountryRedirects retrieves redirects of URL by country from file ordered by number of redirects
+func (r *URLRepository) SelectCountryRedirects(ctx context.Context, id string) ([]model.CountryRedirects, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	countries := make(map[string]int64)
+	err := r.readCountries(func(redirects countryRedirects) {
+		if redirects.URLID == id {
+			countries[redirects.Country] = redirects.Redirects
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return model.SortCountryRedirects(countries), nil
+}
+
+func (r *URLRepository) countriesPath() string {
+	return r.fileStorage.Name() + countriesSuffix
+}
+
+// readCountries calls fn for every stored number of redirects, missing file has no redirects.
+func (r *URLRepository) readCountries(fn func(countryRedirects)) error {
+	file, err := os.OpenFile(r.countriesPath(), os.O_RDONLY, perm)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var redirects countryRedirects
+		err = decoder.Decode(&redirects)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		fn(redirects)
+	}
+}
+
+// writeCountries replaces stored numbers of redirects, the caller must hold write lock.
+func (r *URLRepository) writeCountries(countries []countryRedirects) error {
+	if err := replaceFile(r.countriesPath(), countries); err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// removeCountries removes redirects of URLs with the IDs, the caller must hold write lock.
+func (r *URLRepository) removeCountries(ids map[string]struct{}) error {
+	var countriesToSave []countryRedirects
+	err := r.readCountries(func(redirects countryRedirects) {
+		if _, ok := ids[redirects.URLID]; !ok {
+			countriesToSave = append(countriesToSave, redirects)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if err = r.writeCountries(countriesToSave); err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
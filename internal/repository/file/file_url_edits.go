@@ -0,0 +1,97 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// editsSuffix is appended to the storage file name to get the name of the file with edit history.
+const editsSuffix = ".edits"
+
+// SelectEditsByID retrieves edits of URL from file ordered by edit time
+func (r *URLRepository) SelectEditsByID(ctx context.Context, id string) ([]model.URLEdit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	edits := make([]model.URLEdit, 0)
+	err := r.readEdits(func(edit model.URLEdit) {
+		if edit.URLID == id {
+			edits = append(edits, edit)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return edits, nil
+}
+
+func (r *URLRepository) editsPath() string {
+	return r.fileStorage.Name() + editsSuffix
+}
+
+// appendEdit appends edit to edit history, the caller must hold write lock.
+func (r *URLRepository) appendEdit(edit model.URLEdit) error {
+	file, err := os.OpenFile(r.editsPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
+	if err != nil {
+		return apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	}
+	defer file.Close()
+
+	if err = json.NewEncoder(file).Encode(edit); err != nil {
+		return apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// readEdits calls fn for every edit of edit history, missing history has no edits.
+func (r *URLRepository) readEdits(fn func(model.URLEdit)) error {
+	file, err := os.OpenFile(r.editsPath(), os.O_RDONLY, perm)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var edit model.URLEdit
+		err = decoder.Decode(&edit)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		fn(edit)
+	}
+}
+
+// removeEdits removes edits of URLs with the IDs from edit history, the caller must hold write lock.
+func (r *URLRepository) removeEdits(ids map[string]struct{}) error {
+	var editsToSave []model.URLEdit
+	err := r.readEdits(func(edit model.URLEdit) {
+		if _, ok := ids[edit.URLID]; !ok {
+			editsToSave = append(editsToSave, edit)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if err = replaceFile(r.editsPath(), editsToSave); err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
@@ -9,7 +9,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -20,13 +23,18 @@ import (
 
 const (
 	perm = 0o755
+	// iteratePageSize is a number of URLs IterateByUserID decodes under lock at once.
+	iteratePageSize = 1000
 )
 
 // URLRepository (file) represents a file-based implementation of the URLRepository interface.
 type URLRepository struct {
 	mu          sync.RWMutex
 	fileStorage *os.File
-	logger      *zap.Logger
+	// version is incremented whenever the file is rewritten rather than appended to, so offsets are kept.
+	version  uint64
+	pageSize int
+	logger   *zap.Logger
 }
 
 // NewFileURLRepository creates a new URLRepository from the given path and logger.
@@ -56,6 +64,7 @@ func NewFileURLRepository(path string, logger *zap.Logger) (*URLRepository, erro
 
 	return &URLRepository{
 		fileStorage: file,
+		pageSize:    iteratePageSize,
 		logger:      logger,
 		mu:          sync.RWMutex{},
 	}, nil
@@ -76,6 +85,7 @@ func (r *URLRepository) DeleteURLByUserID(ctx context.Context, userID string, sh
 	// Read all urls from file, update with new flag, store urls to save (with updated ones) in urlsToSave slice
 	decoder := json.NewDecoder(file)
 	var urlsToSave []model.URL
+	deletedAt := time.Now().UTC()
 	for {
 		var existingURL model.URL
 		err := decoder.Decode(&existingURL)
@@ -85,14 +95,15 @@ func (r *URLRepository) DeleteURLByUserID(ctx context.Context, userID string, sh
 		if err != nil {
 			return apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
-		if existingURL.UserID == userID && existingURL.ID == shortURL {
-			existingURL.DeletedFlag = true
+		if existingURL.UserID == userID && existingURL.ID == shortURL && !existingURL.DeletedFlag {
+			existingURL.MarkDeleted(deletedAt)
 		}
 
 		urlsToSave = append(urlsToSave, existingURL)
 	}
 
 	// Clear file in order to prepare for further encoding
+	r.version++
 	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
 		return apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
 	}
@@ -109,36 +120,229 @@ func (r *URLRepository) DeleteURLByUserID(ctx context.Context, userID string, sh
 	return nil
 }
 
-// SelectAllByUserID retrieves all URLs by user ID from file
-func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string) ([]model.URL, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// RestoreURLByUserID restores a URL of the user from the file deleted not before deletedAfter,
+// zero deletedAfter restores URL deleted at any time
+func (r *URLRepository) RestoreURLByUserID(ctx context.Context, userID string, shortURL string, deletedAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	file, err := os.OpenFile(r.fileStorage.Name(), os.O_RDONLY, perm)
-	if err != nil {
-		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	r.logger.Info(fmt.Sprintf("Opening file: %s", r.fileStorage.Name()))
+	file, openFileErr := os.OpenFile(r.fileStorage.Name(), os.O_RDWR|os.O_APPEND, perm)
+	if openFileErr != nil {
+		return apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
 	}
+	defer file.Close()
 
+	// Read all urls from file, restore the matching one, store urls to save (with updated ones) in urlsToSave slice
 	decoder := json.NewDecoder(file)
+	var urlsToSave []model.URL
+	restoredAt := time.Now().UTC()
+	for {
+		var existingURL model.URL
+		err := decoder.Decode(&existingURL)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if existingURL.UserID == userID && existingURL.ID == shortURL && existingURL.DeletedFlag && !existingURL.DeletedBefore(deletedAfter) {
+			existingURL.Restore(restoredAt)
+		}
+
+		urlsToSave = append(urlsToSave, existingURL)
+	}
+
+	// Clear file in order to prepare for further encoding
+	r.version++
+	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
+		return apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
+	}
+
+	// Encode urlsToSave to file
+	encoder := json.NewEncoder(file)
+	for _, url := range urlsToSave {
+		err := encoder.Encode(url)
+		if err != nil {
+			return apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateOriginal changes original URL of the user's URL in file and records the edit
+//
+// Deleted URLs can not be edited, unchanged URL is returned as is without an edit.
+func (r *URLRepository) UpdateOriginal(ctx context.Context, userID string, id string, original string, at time.Time) (*model.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, openFileErr := os.OpenFile(r.fileStorage.Name(), os.O_RDWR|os.O_APPEND, perm)
+	if openFileErr != nil {
+		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
+	}
 	defer file.Close()
 
-	urls := make([]model.URL, 0)
+	// Read all urls from file, find the edited one and check the user has no other url with the new original url
+	decoder := json.NewDecoder(file)
+	var urlsToSave []model.URL
+	edited := -1
+	for {
+		var existingURL model.URL
+		err := decoder.Decode(&existingURL)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if existingURL.ID == id && existingURL.UserID == userID {
+			edited = len(urlsToSave)
+		} else if existingURL.UserID == userID && existingURL.Original == original {
+			return nil, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+		}
+
+		urlsToSave = append(urlsToSave, existingURL)
+	}
+
+	if edited < 0 {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	url := urlsToSave[edited]
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	if url.Original == original {
+		return &url, nil
+	}
+
+	edit := url.Edit(original, at)
+	urlsToSave[edited] = url
+
+	// Clear file in order to prepare for further encoding
+	r.version++
+	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
+		return nil, apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
+	}
+
+	// Encode urlsToSave to file
+	encoder := json.NewEncoder(file)
+	for _, u := range urlsToSave {
+		if err := encoder.Encode(u); err != nil {
+			return nil, apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+		}
+	}
+
+	if err := r.appendEdit(edit); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return &url, nil
+}
+
+// UpdateRedirect changes redirect settings of the user's URL in file, deleted URLs can not be changed
+func (r *URLRepository) UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings, at time.Time) (*model.URL, error) {
+	url, err := r.updateURL(userID, id, func(url *model.URL) error {
+		url.SetRedirect(settings, at)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return url, nil
+}
+
+// UpdateRules applies update to redirect rules of the user's URL in file, deleted URLs can not be changed
+func (r *URLRepository) UpdateRules(ctx context.Context, userID string, id string, update func([]model.RedirectRule) ([]model.RedirectRule, error), at time.Time) (*model.URL, error) {
+	url, err := r.updateURL(userID, id, func(url *model.URL) error {
+		rules, err := update(slices.Clone(url.Rules))
+		if err != nil {
+			return err
+		}
+		url.SetRules(rules, at)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
 
+	return url, nil
+}
+
+// updateURL applies change to the user's URL not deleted and rewrites the file, nothing is written if change fails
+func (r *URLRepository) updateURL(userID string, id string, change func(url *model.URL) error) (*model.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, openFileErr := os.OpenFile(r.fileStorage.Name(), os.O_RDWR|os.O_APPEND, perm)
+	if openFileErr != nil {
+		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
+	}
+	defer file.Close()
+
+	// Read all urls from file, find the changed one
+	decoder := json.NewDecoder(file)
+	var urlsToSave []model.URL
+	changed := -1
 	for {
-		var url model.URL
-		err := decoder.Decode(&url)
+		var existingURL model.URL
+		err := decoder.Decode(&existingURL)
 		if errors.Is(err, io.EOF) {
-			r.logger.Info("Reached end of file while decoding", zap.Error(err))
 			break
 		}
 		if err != nil {
 			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
-		if url.UserID == userID {
-			urls = append(urls, url)
+		if existingURL.ID == id && existingURL.UserID == userID {
+			changed = len(urlsToSave)
+		}
+
+		urlsToSave = append(urlsToSave, existingURL)
+	}
+
+	if changed < 0 {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	url := urlsToSave[changed]
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	if err := change(&url); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	urlsToSave[changed] = url
+
+	// Clear file in order to prepare for further encoding
+	r.version++
+	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
+		return nil, apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
+	}
+
+	// Encode urlsToSave to file
+	encoder := json.NewEncoder(file)
+	for _, u := range urlsToSave {
+		if err := encoder.Encode(u); err != nil {
+			return nil, apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
 		}
 	}
 
+	return &url, nil
+}
+
+// SelectAllByUserID retrieves a page of user URLs matching the query from file
+func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string, query model.URLQuery) ([]model.URL, error) {
+	query.UserID = userID
+	urls, err := r.SelectAll(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
 	if len(urls) == 0 {
 		return nil, apperr.NewValueError(fmt.Sprintf("urls not found by user %s", userID), apperr.Caller(), urlErr.ErrURLNotFound)
 	}
@@ -146,7 +350,113 @@ func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string) ([
 	return urls, nil
 }
 
+// IterateByUserID calls fn for every URL of the user matching the filter in order of the file.
+//
+// URLs are decoded from file under read lock a page at a time, so fn is free to block and write to the repository.
+// The next page is decoded from the offset the previous one ended at unless the file has been rewritten meanwhile,
+// then the file is decoded from the start skipping URLs already passed to fn.
+func (r *URLRepository) IterateByUserID(ctx context.Context, userID string, filter model.URLFilter, fn func(model.URL) error) error {
+	cursor := pageCursor{passed: make(map[string]struct{})}
+	for {
+		urls, err := r.selectPage(userID, filter, &cursor)
+		if err != nil {
+			return fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+
+		for _, url := range urls {
+			if err = fn(url); err != nil {
+				return fmt.Errorf("%s %w", apperr.Caller(), err)
+			}
+		}
+
+		if len(urls) < r.pageSize {
+			return nil
+		}
+	}
+}
+
+// pageCursor is a position of IterateByUserID in file.
+type pageCursor struct {
+	version uint64
+	offset  int64
+	// passed holds IDs of URLs already read.
+	passed map[string]struct{}
+}
+
+// selectPage reads from file a page of the user's URLs matching the filter after the cursor and moves the cursor.
+func (r *URLRepository) selectPage(userID string, filter model.URLFilter, cursor *pageCursor) ([]model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	file, err := os.OpenFile(r.fileStorage.Name(), os.O_RDONLY, perm)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	}
+	defer file.Close()
+
+	if cursor.version != r.version {
+		cursor.version, cursor.offset = r.version, 0
+	}
+	if _, err = file.Seek(cursor.offset, io.SeekStart); err != nil {
+		return nil, apperr.NewValueError("unable to seek file", apperr.Caller(), err)
+	}
+
+	decoder := json.NewDecoder(file)
+	urls := make([]model.URL, 0)
+	for len(urls) < r.pageSize {
+		var url model.URL
+		err = decoder.Decode(&url)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if _, ok := cursor.passed[url.ID]; ok || url.UserID != userID || !filter.Match(url) {
+			continue
+		}
+		cursor.passed[url.ID] = struct{}{}
+		urls = append(urls, url)
+	}
+	cursor.offset += decoder.InputOffset()
+
+	return urls, nil
+}
+
+// replaceFile atomically replaces the file with JSON lines of values written to a temporary file,
+// so readers never see the file partially written.
+func replaceFile[T any](path string, values []T) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return apperr.NewValueError("unable to create file", apperr.Caller(), err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, value := range values {
+		if err = encoder.Encode(value); err != nil {
+			return apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+		}
+	}
+
+	if err = file.Chmod(perm); err == nil {
+		err = file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		return apperr.NewValueError("unable to replace file", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
 // Insert inserts URL to file
+//
+// Stored URLs are never overwritten, if the ID is taken or the user already has the original URL
+// the stored URL is returned with urlerr.ErrURLAlreadyExists.
 func (r *URLRepository) Insert(ctx context.Context, url model.URL) (*model.URL, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -158,6 +468,21 @@ func (r *URLRepository) Insert(ctx context.Context, url model.URL) (*model.URL,
 	}
 	defer file.Close()
 
+	decoder := json.NewDecoder(file)
+	for {
+		var existingURL model.URL
+		err = decoder.Decode(&existingURL)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if sameURL(existingURL, url) {
+			return &existingURL, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+		}
+	}
+
 	encoder := json.NewEncoder(file)
 	err = encoder.Encode(url)
 	if err != nil {
@@ -184,7 +509,8 @@ func (r *URLRepository) SelectStats(ctx context.Context) (*model.URLStats, error
 	uniqueUsers := make(map[string]struct{}, 0)
 
 	for {
-		err := decoder.Decode(&model.URL{})
+		var url model.URL
+		err := decoder.Decode(&url)
 		if errors.Is(err, io.EOF) {
 			break
 		}
@@ -192,7 +518,7 @@ func (r *URLRepository) SelectStats(ctx context.Context) (*model.URLStats, error
 			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
 		counter++
-		uniqueUsers[model.URL{}.UserID] = struct{}{}
+		uniqueUsers[url.UserID] = struct{}{}
 	}
 
 	return &model.URLStats{Urls: counter, Users: len(uniqueUsers)}, nil
@@ -203,6 +529,16 @@ func (r *URLRepository) SelectByID(ctx context.Context, key string) (*model.URL,
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	url, err := r.selectByID(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return url, nil
+}
+
+// selectByID reads URL by ID from file, the caller must hold lock
+func (r *URLRepository) selectByID(key string) (*model.URL, error) {
 	file, err := os.OpenFile(r.fileStorage.Name(), os.O_RDONLY, perm)
 	if err != nil {
 		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), err)
@@ -211,8 +547,8 @@ func (r *URLRepository) SelectByID(ctx context.Context, key string) (*model.URL,
 	decoder := json.NewDecoder(file)
 	defer file.Close()
 
-	var url model.URL
 	for {
+		var url model.URL
 		err := decoder.Decode(&url)
 		if errors.Is(err, io.EOF) {
 			return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", key), apperr.Caller(), urlErr.ErrURLNotFound)
@@ -221,15 +557,39 @@ func (r *URLRepository) SelectByID(ctx context.Context, key string) (*model.URL,
 			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
 		if url.ID == key {
-			break
+			return &url, nil
 		}
 	}
+}
 
-	return &url, nil
+// selectIDs returns the IDs of URLs stored in file, the caller must hold lock
+func (r *URLRepository) selectIDs(ids map[string]struct{}) (map[string]struct{}, error) {
+	file, err := os.OpenFile(r.fileStorage.Name(), os.O_RDONLY, perm)
+	if err != nil {
+		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), err)
+	}
+
+	decoder := json.NewDecoder(file)
+	defer file.Close()
+
+	stored := make(map[string]struct{}, len(ids))
+	for {
+		var url model.URL
+		err := decoder.Decode(&url)
+		if errors.Is(err, io.EOF) {
+			return stored, nil
+		}
+		if err != nil {
+			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if _, ok := ids[url.ID]; ok {
+			stored[url.ID] = struct{}{}
+		}
+	}
 }
 
-// SelectAll retrieves all URLs from file
-func (r *URLRepository) SelectAll(ctx context.Context) ([]model.URL, error) {
+// SelectAll retrieves a page of URLs matching the query from file
+func (r *URLRepository) SelectAll(ctx context.Context, query model.URLQuery) ([]model.URL, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -252,7 +612,14 @@ func (r *URLRepository) SelectAll(ctx context.Context) ([]model.URL, error) {
 		if err != nil {
 			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
-		urls = append(urls, url)
+		if query.Match(url) {
+			urls = append(urls, url)
+		}
+	}
+
+	sort.Slice(urls, func(i, j int) bool { return query.Less(urls[i], urls[j]) })
+	if query.Limit > 0 && len(urls) > query.Limit {
+		urls = urls[:query.Limit]
 	}
 
 	return urls, nil
@@ -263,12 +630,80 @@ func (r *URLRepository) DeleteAll(ctx context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.version++
 	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
 		return apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
 	}
+	if err := os.Remove(r.editsPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return apperr.NewValueError(fmt.Sprintf("Failed to remove file: %s", r.editsPath()), apperr.Caller(), err)
+	}
+	if err := os.Remove(r.countriesPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return apperr.NewValueError(fmt.Sprintf("Failed to remove file: %s", r.countriesPath()), apperr.Caller(), err)
+	}
 	return nil
 }
 
+// PurgeDeleted permanently deletes from file at most limit URLs soft-deleted before the time.
+func (r *URLRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, openFileErr := os.OpenFile(r.fileStorage.Name(), os.O_RDWR|os.O_APPEND, perm)
+	if openFileErr != nil {
+		return 0, apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
+	}
+	defer file.Close()
+
+	// Read all urls from file, store urls to keep in urlsToSave slice
+	decoder := json.NewDecoder(file)
+	var urlsToSave []model.URL
+	purgedIDs := make(map[string]struct{})
+	for {
+		var existingURL model.URL
+		err := decoder.Decode(&existingURL)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+		}
+		if len(purgedIDs) < limit && existingURL.DeletedBefore(before) {
+			purgedIDs[existingURL.ID] = struct{}{}
+			continue
+		}
+
+		urlsToSave = append(urlsToSave, existingURL)
+	}
+
+	if len(purgedIDs) == 0 {
+		return 0, nil
+	}
+
+	// Clear file in order to prepare for further encoding
+	r.version++
+	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
+		return 0, apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
+	}
+
+	// Encode urlsToSave to file
+	encoder := json.NewEncoder(file)
+	for _, url := range urlsToSave {
+		if err := encoder.Encode(url); err != nil {
+			return 0, apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+		}
+	}
+
+	if err := r.removeEdits(purgedIDs); err != nil {
+		return 0, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if err := r.removeCountries(purgedIDs); err != nil {
+		return 0, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return len(purgedIDs), nil
+}
+
 // Ping pings the file storage
 func (r *URLRepository) Ping(ctx context.Context) error {
 	file, err := os.OpenFile(r.fileStorage.Name(), os.O_RDONLY, perm)
@@ -279,15 +714,16 @@ func (r *URLRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
-// InsertAllOrUpdate upserts all URLs to file
-func (r *URLRepository) InsertAllOrUpdate(ctx context.Context, urls []model.URL) ([]model.URL, error) {
+// InsertAll appends URLs missing in file, existing URLs (taken IDs or original URLs
+// the user already has) are left untouched and returned as stored
+func (r *URLRepository) InsertAll(ctx context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.logger.Info(fmt.Sprintf("Opening file: %s", r.fileStorage.Name()))
 	file, openFileErr := os.OpenFile(r.fileStorage.Name(), os.O_RDWR|os.O_APPEND, perm)
 	if openFileErr != nil {
-		return nil, apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
+		return nil, nil, apperr.NewValueError("unable to open file", apperr.Caller(), openFileErr)
 	}
 	defer file.Close()
 
@@ -297,9 +733,15 @@ func (r *URLRepository) InsertAllOrUpdate(ctx context.Context, urls []model.URL)
 		urlMap[url.ID] = url
 	}
 
-	// Read all urls from file, update with new urls to be saved, store urls to save (with updated ones) in urlsToSave slice
+	// Create map of urls to be saved with user and original url as key
+	ownedMap := make(map[string]string, len(urls))
+	for _, url := range urls {
+		ownedMap[ownerKey(url.UserID, url.Original)] = url.ID
+	}
+
+	// Read all urls from file, collect the ones to be saved which already exist
 	decoder := json.NewDecoder(file)
-	var urlsToSave []model.URL //nolint: prealloc
+	var existing []model.URL
 	for {
 		var existingURL model.URL
 		err := decoder.Decode(&existingURL)
@@ -307,48 +749,40 @@ func (r *URLRepository) InsertAllOrUpdate(ctx context.Context, urls []model.URL)
 			break
 		}
 		if err != nil {
-			return nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
+			return nil, nil, apperr.NewValueError("unable to decode from file", apperr.Caller(), err)
 		}
-		if v, ok := urlMap[existingURL.ID]; ok { // If url exists in map, remove it from map and add it to urlsToSave
-			existingURL.Original = v.Original
-			existingURL.Shortened = v.Shortened
-			delete(urlMap, existingURL.ID)
+		id, ok := ownedMap[ownerKey(existingURL.UserID, existingURL.Original)]
+		if !ok {
+			id = existingURL.ID
+		}
+		if _, ok := urlMap[id]; ok {
+			existing = append(existing, existingURL)
+			delete(urlMap, id)
 		}
-
-		urlsToSave = append(urlsToSave, existingURL)
-	}
-
-	// Clear file in order to prepare for further encoding
-	if err := os.Truncate(r.fileStorage.Name(), 0); err != nil {
-		return nil, apperr.NewValueError(fmt.Sprintf("Failed to truncate file: %s", r.fileStorage.Name()), apperr.Caller(), err)
-	}
-
-	for _, v := range urlMap {
-		urlsToSave = append(urlsToSave, v)
 	}
 
-	// Encode urlsToSave to file
+	// Append urls missing in file keeping the order of the request
 	encoder := json.NewEncoder(file)
-	for _, url := range urlsToSave {
-		err := encoder.Encode(url)
-		if err != nil {
-			return nil, apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
+	inserted := make([]model.URL, 0, len(urlMap))
+	for _, url := range urls {
+		if _, ok := urlMap[url.ID]; !ok {
+			continue
+		}
+		if err := encoder.Encode(url); err != nil {
+			return nil, nil, apperr.NewValueError("unable to encode to file", apperr.Caller(), err)
 		}
+		delete(urlMap, url.ID)
+		inserted = append(inserted, url)
 	}
 
-	// Create map of incomed urls to save with id as key (for faster lookup)
-	urlMapIn := make(map[string]model.URL, len(urls))
-	for _, url := range urls {
-		urlMapIn[url.ID] = url
-	}
+	return inserted, existing, nil
+}
 
-	// Take from savedURLs slice only urls with id that have been saved
-	var savedURLs []model.URL
-	for _, url := range urlsToSave {
-		if _, ok := urlMapIn[url.ID]; ok {
-			savedURLs = append(savedURLs, url)
-		}
-	}
+// sameURL reports whether stored URL has the ID of url or the same user and original URL.
+func sameURL(stored model.URL, url model.URL) bool {
+	return stored.ID == url.ID || (stored.UserID == url.UserID && stored.Original == url.Original)
+}
 
-	return savedURLs, nil
+func ownerKey(userID string, original string) string {
+	return userID + "\x00" + original
 }
@@ -0,0 +1,29 @@
+package file
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/repository/repotest"
+	"github.com/msmkdenis/yap-shortener/internal/service"
+)
+
+func TestURLRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		repository, err := NewFileURLRepository(filepath.Join(t.TempDir(), "urls.json"), zap.NewNop())
+		require.NoError(t, err)
+		return repository
+	})
+}
+
+func TestURLRepository_ConformanceSmallPages(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		repository, err := NewFileURLRepository(filepath.Join(t.TempDir(), "urls.json"), zap.NewNop())
+		require.NoError(t, err)
+		repository.pageSize = 1
+		return repository
+	})
+}
@@ -4,7 +4,10 @@ package memory
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -13,19 +16,33 @@ import (
 	"github.com/msmkdenis/yap-shortener/pkg/apperr"
 )
 
+// iteratePageSize is a number of URLs IterateByUserID copies under lock at once.
+const iteratePageSize = 1000
+
 // URLRepository represents in-memory implementation of URLRepository.
 type URLRepository struct {
 	mu      sync.RWMutex
 	storage map[string]model.URL
-	logger  *zap.Logger
+	// owned maps user ID and original URL to URL ID, so a user has at most one URL per original URL.
+	owned map[string]string
+	// edits holds edit history by URL ID.
+	edits map[string][]model.URLEdit
+	// countries holds redirects by URL ID and country.
+	countries map[string]map[string]int64
+	pageSize  int
+	logger    *zap.Logger
 }
 
 // NewURLRepository creates a new URLRepository (hash-map)
 func NewURLRepository(logger *zap.Logger) *URLRepository {
 	return &URLRepository{
-		storage: make(map[string]model.URL),
-		logger:  logger,
-		mu:      sync.RWMutex{},
+		storage:   make(map[string]model.URL),
+		owned:     make(map[string]string),
+		edits:     make(map[string][]model.URLEdit),
+		countries: make(map[string]map[string]int64),
+		pageSize:  iteratePageSize,
+		logger:    logger,
+		mu:        sync.RWMutex{},
 	}
 }
 
@@ -49,8 +66,24 @@ func (r *URLRepository) DeleteURLByUserID(ctx context.Context, userID string, sh
 	defer r.mu.Unlock()
 
 	if url, ok := r.storage[shortURL]; ok {
-		if url.UserID == userID {
-			url.DeletedFlag = true
+		if url.UserID == userID && !url.DeletedFlag {
+			url.MarkDeleted(time.Now().UTC())
+			r.storage[shortURL] = url
+		}
+	}
+
+	return nil
+}
+
+// RestoreURLByUserID restores URL of the user from in-memory storage deleted not before deletedAfter,
+// zero deletedAfter restores URL deleted at any time.
+func (r *URLRepository) RestoreURLByUserID(ctx context.Context, userID string, shortURL string, deletedAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if url, ok := r.storage[shortURL]; ok {
+		if url.UserID == userID && url.DeletedFlag && !url.DeletedBefore(deletedAfter) {
+			url.Restore(time.Now().UTC())
 			r.storage[shortURL] = url
 		}
 	}
@@ -58,16 +91,125 @@ func (r *URLRepository) DeleteURLByUserID(ctx context.Context, userID string, sh
 	return nil
 }
 
-// SelectAllByUserID returns all URLs by user ID from in-memory storage.
-func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string) ([]model.URL, error) {
+// UpdateOriginal changes original URL of the user's URL in in-memory storage and records the edit.
+//
+// Deleted URLs can not be edited, unchanged URL is returned as is without an edit.
+func (r *URLRepository) UpdateOriginal(ctx context.Context, userID string, id string, original string, at time.Time) (*model.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.storage[id]
+	if !ok || url.UserID != userID {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	if url.Original == original {
+		return &url, nil
+	}
+
+	if _, ok := r.owned[ownerKey(userID, original)]; ok {
+		return nil, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+	}
+
+	delete(r.owned, ownerKey(url.UserID, url.Original))
+	edit := url.Edit(original, at)
+	r.store(url)
+	r.edits[id] = append(r.edits[id], edit)
+
+	return &url, nil
+}
+
+// UpdateRedirect changes redirect settings of the user's URL in in-memory storage, deleted URLs can not be changed.
+func (r *URLRepository) UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings, at time.Time) (*model.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.storage[id]
+	if !ok || url.UserID != userID {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	url.SetRedirect(settings, at)
+	r.storage[id] = url
+
+	return &url, nil
+}
+
+// UpdateRules applies update to redirect rules of the user's URL in in-memory storage, deleted URLs can not be changed.
+func (r *URLRepository) UpdateRules(ctx context.Context, userID string, id string, update func([]model.RedirectRule) ([]model.RedirectRule, error), at time.Time) (*model.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.storage[id]
+	if !ok || url.UserID != userID {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	rules, err := update(slices.Clone(url.Rules))
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	url.SetRules(rules, at)
+	r.storage[id] = url
+
+	return &url, nil
+}
+
+// AddCountryRedirects adds in in-memory storage numbers of redirects of URL visitors by country at once,
+// redirects of URLs not found are skipped.
+func (r *URLRepository) AddCountryRedirects(ctx context.Context, redirects []model.URLCountryRedirects) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, redirect := range redirects {
+		if _, ok := r.storage[redirect.URLID]; !ok {
+			continue
+		}
+
+		if r.countries[redirect.URLID] == nil {
+			r.countries[redirect.URLID] = make(map[string]int64)
+		}
+		r.countries[redirect.URLID][redirect.Country] += redirect.Redirects
+	}
+
+	return nil
+}
+
+// SelectCountryRedirects returns redirects of URL by country from in-memory storage ordered by number of redirects.
+func (r *URLRepository) SelectCountryRedirects(ctx context.Context, id string) ([]model.CountryRedirects, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	urls := make([]model.URL, 0)
-	for _, url := range r.storage {
-		if url.UserID == userID {
-			urls = append(urls, url)
-		}
+	return model.SortCountryRedirects(r.countries[id]), nil
+}
+
+// SelectEditsByID returns edits of URL from in-memory storage ordered by edit time.
+func (r *URLRepository) SelectEditsByID(ctx context.Context, id string) ([]model.URLEdit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]model.URLEdit{}, r.edits[id]...), nil
+}
+
+// SelectAllByUserID returns a page of user URLs matching the query from in-memory storage.
+func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string, query model.URLQuery) ([]model.URL, error) {
+	query.UserID = userID
+	urls, err := r.SelectAll(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
 
 	if len(urls) == 0 {
@@ -77,13 +219,60 @@ func (r *URLRepository) SelectAllByUserID(ctx context.Context, userID string) ([
 	return urls, nil
 }
 
+// IterateByUserID calls fn for every URL of the user matching the filter in order of IDs.
+//
+// IDs of the user's URLs are taken under lock, then matching URLs are copied under lock a page at a time,
+// so fn is free to block. URLs inserted during iteration are skipped.
+func (r *URLRepository) IterateByUserID(ctx context.Context, userID string, filter model.URLFilter, fn func(model.URL) error) error {
+	r.mu.RLock()
+	ids := make([]string, 0)
+	for id, url := range r.storage {
+		if url.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(ids)
+
+	for start := 0; start < len(ids); start += r.pageSize {
+		for _, url := range r.selectPage(ids[start:min(start+r.pageSize, len(ids))], filter) {
+			if err := fn(url); err != nil {
+				return fmt.Errorf("%s %w", apperr.Caller(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectPage returns copies of URLs with the IDs matching the filter, URLs deleted meanwhile are skipped.
+func (r *URLRepository) selectPage(ids []string, filter model.URLFilter) []model.URL {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	urls := make([]model.URL, 0, len(ids))
+	for _, id := range ids {
+		if url, ok := r.storage[id]; ok && filter.Match(url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
 // Insert inserts URL into in-memory storage
+//
+// Stored URLs are never overwritten, if the ID is taken or the user already has the original URL
+// the stored URL is returned with urlerr.ErrURLAlreadyExists.
 func (r *URLRepository) Insert(ctx context.Context, u model.URL) (*model.URL, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if stored, ok := r.lookup(u); ok {
+		return &stored, apperr.NewValueError("url already exists", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+	}
+
 	url := u
-	r.storage[u.ID] = u
+	r.store(u)
 
 	return &url, nil
 }
@@ -95,23 +284,29 @@ func (r *URLRepository) SelectByID(ctx context.Context, key string) (*model.URL,
 
 	url, ok := r.storage[key]
 	if !ok {
-		return &url, urlErr.ErrURLNotFound
+		return nil, apperr.NewValueError(fmt.Sprintf("Url with id %s not found", key), apperr.Caller(), urlErr.ErrURLNotFound)
 	}
 
 	return &url, nil
 }
 
-// SelectAll returns all URLs from in-memory storage
-func (r *URLRepository) SelectAll(ctx context.Context) ([]model.URL, error) {
+// SelectAll returns a page of URLs matching the query from in-memory storage
+func (r *URLRepository) SelectAll(ctx context.Context, query model.URLQuery) ([]model.URL, error) {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
+	urls := make([]model.URL, 0)
+	for _, url := range r.storage {
+		if query.Match(url) {
+			urls = append(urls, url)
+		}
+	}
+	r.mu.RUnlock()
 
-	values := make([]model.URL, 0, len(r.storage))
-	for _, v := range r.storage {
-		values = append(values, v)
+	sort.Slice(urls, func(i, j int) bool { return query.Less(urls[i], urls[j]) })
+	if query.Limit > 0 && len(urls) > query.Limit {
+		urls = urls[:query.Limit]
 	}
 
-	return values, nil
+	return urls, nil
 }
 
 // DeleteAll deletes all URLs from in-memory storage
@@ -120,9 +315,34 @@ func (r *URLRepository) DeleteAll(ctx context.Context) error {
 	defer r.mu.Unlock()
 
 	clear(r.storage)
+	clear(r.owned)
+	clear(r.edits)
+	clear(r.countries)
 	return nil
 }
 
+// PurgeDeleted permanently deletes from in-memory storage at most limit URLs soft-deleted before the time.
+func (r *URLRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, url := range r.storage {
+		if purged == limit {
+			break
+		}
+		if url.DeletedBefore(before) {
+			delete(r.storage, id)
+			delete(r.owned, ownerKey(url.UserID, url.Original))
+			delete(r.edits, id)
+			delete(r.countries, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
 // Ping pings the storage
 func (r *URLRepository) Ping(ctx context.Context) error {
 	if r.storage == nil {
@@ -132,14 +352,45 @@ func (r *URLRepository) Ping(ctx context.Context) error {
 	return nil
 }
 
-// InsertAllOrUpdate upserts all URLs into in-memory storage
-func (r *URLRepository) InsertAllOrUpdate(ctx context.Context, urls []model.URL) ([]model.URL, error) {
+// InsertAll inserts URLs missing in in-memory storage, existing URLs (taken IDs or original URLs
+// the user already has) are left untouched and returned as stored
+func (r *URLRepository) InsertAll(ctx context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	inserted := make([]model.URL, 0, len(urls))
+	var existing []model.URL
 	for _, v := range urls {
-		r.storage[v.ID] = v
+		if stored, ok := r.lookup(v); ok {
+			existing = append(existing, stored)
+			continue
+		}
+		r.store(v)
+		inserted = append(inserted, v)
 	}
 
-	return urls, nil
+	return inserted, existing, nil
+}
+
+// lookup returns stored URL with the ID of url or the URL of the same user with the same original URL.
+func (r *URLRepository) lookup(url model.URL) (model.URL, bool) {
+	if stored, ok := r.storage[url.ID]; ok {
+		return stored, true
+	}
+
+	if id, ok := r.owned[ownerKey(url.UserID, url.Original)]; ok {
+		return r.storage[id], true
+	}
+
+	return model.URL{}, false
+}
+
+// store saves url, the caller must hold write lock.
+func (r *URLRepository) store(url model.URL) {
+	r.storage[url.ID] = url
+	r.owned[ownerKey(url.UserID, url.Original)] = url.ID
+}
+
+func ownerKey(userID string, original string) string {
+	return userID + "\x00" + original
 }
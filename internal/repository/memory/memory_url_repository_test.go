@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/repository/repotest"
+	"github.com/msmkdenis/yap-shortener/internal/service"
+)
+
+func TestURLRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		return NewURLRepository(zap.NewNop())
+	})
+}
+
+func TestURLRepository_ConformanceSmallPages(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.URLRepository {
+		repository := NewURLRepository(zap.NewNop())
+		repository.pageSize = 1
+		return repository
+	})
+}
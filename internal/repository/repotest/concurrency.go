@@ -0,0 +1,84 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+const concurrency = 10
+
+func (s *URLRepositorySuite) TestInsert_Concurrent() {
+	ctx := context.Background()
+	url := newURL("id1", "http://example.com/1", "user1", 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.repository.Insert(ctx, url)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	inserted := 0
+	for err := range errs {
+		if err == nil {
+			inserted++
+			continue
+		}
+		s.ErrorIs(err, urlErr.ErrURLAlreadyExists)
+	}
+	s.Equal(1, inserted, "exactly one of concurrent inserts must succeed")
+
+	urls, err := s.repository.SelectAll(ctx, model.URLQuery{})
+	s.Require().NoError(err)
+	s.Len(urls, 1)
+}
+
+func (s *URLRepositorySuite) TestInsertAndDelete_Concurrent() {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrency)
+	for i := 0; i < concurrency; i++ {
+		url := newURL(fmt.Sprintf("id%d", i), fmt.Sprintf("http://example.com/%d", i), "user1", 0)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.repository.Insert(ctx, url)
+			if err == nil {
+				err = s.repository.DeleteURLByUserID(ctx, url.UserID, url.ID)
+			}
+			errs <- err
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.repository.InsertAll(ctx, []model.URL{newURL("batch-"+url.ID, url.Original+"/batch", "user2", 0)})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.NoError(err)
+	}
+
+	deleted := true
+	urls, err := s.repository.SelectAll(ctx, model.URLQuery{URLFilter: model.URLFilter{UserID: "user1", Deleted: &deleted}})
+	s.Require().NoError(err)
+	s.Len(urls, concurrency)
+
+	stats, err := s.repository.SelectStats(ctx)
+	s.Require().NoError(err)
+	s.Equal(model.URLStats{Urls: 2 * concurrency, Users: 2}, *stats)
+}
@@ -0,0 +1,85 @@
+package repotest
+
+import (
+	"context"
+	"time"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+)
+
+func (s *URLRepositorySuite) TestCountryRedirects() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+	)
+
+	redirects, err := s.repository.SelectCountryRedirects(ctx, "id1")
+	s.Require().NoError(err)
+	s.Empty(redirects, "URL without redirects must have no countries")
+
+	s.Require().NoError(s.repository.AddCountryRedirects(ctx, []model.URLCountryRedirects{
+		{URLID: "id1", Country: "DE", Redirects: 1},
+		{URLID: "id1", Country: "US", Redirects: 2},
+		{URLID: "id1", Country: model.UnknownCountry, Redirects: 1},
+		{URLID: "id2", Country: "US", Redirects: 1},
+	}))
+	s.Require().NoError(s.repository.AddCountryRedirects(ctx, []model.URLCountryRedirects{
+		{URLID: "id1", Country: "DE", Redirects: 1},
+		{URLID: "id1", Country: "US", Redirects: 1},
+		{URLID: "id1", Country: "FR", Redirects: 1},
+	}))
+	s.Require().NoError(s.repository.AddCountryRedirects(ctx, nil))
+
+	redirects, err = s.repository.SelectCountryRedirects(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal([]model.CountryRedirects{
+		{Country: "US", Redirects: 3},
+		{Country: "DE", Redirects: 2},
+		{Country: "FR", Redirects: 1},
+		{Country: model.UnknownCountry, Redirects: 1},
+	}, redirects, "countries must be ordered by redirects, then by code")
+
+	redirects, err = s.repository.SelectCountryRedirects(ctx, "id2")
+	s.Require().NoError(err)
+	s.Equal([]model.CountryRedirects{{Country: "US", Redirects: 1}}, redirects)
+
+	redirects, err = s.repository.SelectCountryRedirects(ctx, "unknown")
+	s.Require().NoError(err)
+	s.Empty(redirects)
+}
+
+func (s *URLRepositorySuite) TestCountryRedirects_UnknownURL() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+
+	err := s.repository.AddCountryRedirects(ctx, []model.URLCountryRedirects{
+		{URLID: "unknown", Country: "US", Redirects: 1},
+		{URLID: "id1", Country: "US", Redirects: 2},
+	})
+	s.Require().NoError(err, "redirects of unknown URLs must be skipped")
+
+	redirects, err := s.repository.SelectCountryRedirects(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal([]model.CountryRedirects{{Country: "US", Redirects: 2}}, redirects)
+
+	redirects, err = s.repository.SelectCountryRedirects(ctx, "unknown")
+	s.Require().NoError(err)
+	s.Empty(redirects)
+}
+
+func (s *URLRepositorySuite) TestCountryRedirects_Purged() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+	s.Require().NoError(s.repository.AddCountryRedirects(ctx, []model.URLCountryRedirects{{URLID: "id1", Country: "US", Redirects: 1}}))
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"))
+
+	purged, err := s.repository.PurgeDeleted(ctx, time.Now().Add(time.Minute), 10)
+	s.Require().NoError(err)
+	s.Equal(1, purged)
+
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+	redirects, err := s.repository.SelectCountryRedirects(ctx, "id1")
+	s.Require().NoError(err)
+	s.Empty(redirects, "redirects of purged URL must be removed")
+}
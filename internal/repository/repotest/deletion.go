@@ -0,0 +1,137 @@
+package repotest
+
+import (
+	"context"
+	"time"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+)
+
+func (s *URLRepositorySuite) TestDeleteURLByUserID() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+	)
+
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"))
+
+	deleted, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err, "deletion is soft, deleted URL must be found")
+	s.True(deleted.DeletedFlag)
+	s.Require().NotNil(deleted.DeletedAt)
+	s.True(deleted.DeletedAt.After(testTime))
+	s.False(deleted.UpdatedAt.Before(*deleted.DeletedAt), "deletion must update URL")
+
+	url, err := s.repository.SelectByID(ctx, "id2")
+	s.Require().NoError(err)
+	s.False(url.DeletedFlag)
+	s.Nil(url.DeletedAt)
+	s.Equal(testTime, url.UpdatedAt.UTC())
+
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"), "deletion must be idempotent")
+
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(normalize(*deleted), normalize(*url), "repeated deletion must keep deletion time")
+}
+
+func (s *URLRepositorySuite) TestDeleteURLByUserID_OtherUser() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user2", "id1"))
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.False(url.DeletedFlag)
+}
+
+func (s *URLRepositorySuite) TestDeleteURLByUserID_Unknown() {
+	s.NoError(s.repository.DeleteURLByUserID(context.Background(), "user1", "unknown"))
+}
+
+func (s *URLRepositorySuite) TestInsert_DeletedURLIsNotRestored() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"))
+
+	_, existing, err := s.repository.InsertAll(ctx, []model.URL{newURL("id1", "http://example.com/1", "user1", 0)})
+	s.Require().NoError(err)
+	s.Require().Len(existing, 1)
+	s.True(existing[0].DeletedFlag)
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.True(url.DeletedFlag)
+}
+
+func (s *URLRepositorySuite) TestPurgeDeleted() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+		newURL("id3", "http://example.com/3", "user1", 0),
+		newURL("id4", "http://example.com/4", "user2", 0),
+	)
+	for _, id := range []string{"id1", "id2", "id3"} {
+		s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", id))
+	}
+
+	purged, err := s.repository.PurgeDeleted(ctx, testTime, 10)
+	s.Require().NoError(err)
+	s.Zero(purged, "URLs deleted after the time must be kept")
+
+	before := time.Now().Add(time.Minute)
+	purged, err = s.repository.PurgeDeleted(ctx, before, 2)
+	s.Require().NoError(err)
+	s.Equal(2, purged, "no more than limit URLs must be purged")
+
+	purged, err = s.repository.PurgeDeleted(ctx, before, 2)
+	s.Require().NoError(err)
+	s.Equal(1, purged)
+
+	urls, err := s.repository.SelectAll(ctx, model.URLQuery{})
+	s.Require().NoError(err)
+	s.Equal([]string{"id4"}, ids(urls))
+
+	stats, err := s.repository.SelectStats(ctx)
+	s.Require().NoError(err)
+	s.Equal(model.URLStats{Urls: 1, Users: 1}, *stats)
+
+	_, err = s.repository.Insert(ctx, newURL("id1", "http://example.com/1", "user1", 0))
+	s.NoError(err, "purged URL must be possible to shorten again")
+}
+
+func (s *URLRepositorySuite) TestRestoreURLByUserID() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored, newURL("id2", "http://example.com/2", "user1", 0))
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"))
+
+	s.Require().NoError(s.repository.RestoreURLByUserID(ctx, "user2", "id1", time.Time{}))
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.True(url.DeletedFlag, "URL of other user must not be restored")
+
+	s.Require().NoError(s.repository.RestoreURLByUserID(ctx, "user1", "id1", time.Now().Add(time.Minute)))
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.True(url.DeletedFlag, "URL deleted before the time must not be restored")
+
+	s.Require().NoError(s.repository.RestoreURLByUserID(ctx, "user1", "id1", testTime))
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.False(url.DeletedFlag)
+	s.Nil(url.DeletedAt)
+	s.True(url.UpdatedAt.After(testTime), "restoration must update URL")
+	url.UpdatedAt = stored.UpdatedAt
+	s.Equal(normalize(stored), normalize(*url))
+
+	s.Require().NoError(s.repository.RestoreURLByUserID(ctx, "user1", "id2", time.Time{}), "restoring not deleted URL must be no-op")
+	s.Require().NoError(s.repository.RestoreURLByUserID(ctx, "user1", "unknown", time.Time{}))
+
+	purged, err := s.repository.PurgeDeleted(ctx, time.Now().Add(time.Minute), 10)
+	s.Require().NoError(err)
+	s.Zero(purged, "restored URL must not be purged")
+}
@@ -0,0 +1,281 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+func (s *URLRepositorySuite) TestUpdateOriginal() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored)
+	editedAt := testTime.Add(time.Minute)
+
+	updated, err := s.repository.UpdateOriginal(ctx, "user1", "id1", "http://example.com/edited", editedAt)
+	s.Require().NoError(err)
+
+	expected := stored
+	expected.Original = "http://example.com/edited"
+	expected.UpdatedAt = editedAt
+	s.Equal(normalize(expected), normalize(*updated))
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(normalize(expected), normalize(*url))
+
+	edits, err := s.repository.SelectEditsByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Require().Len(edits, 1)
+	s.Equal(model.URLEdit{
+		URLID:       "id1",
+		UserID:      "user1",
+		OldOriginal: "http://example.com/1",
+		NewOriginal: "http://example.com/edited",
+		EditedAt:    editedAt,
+	}, normalizeEdit(edits[0]))
+
+	_, err = s.repository.UpdateOriginal(ctx, "user1", "id1", "http://example.com/edited", editedAt.Add(time.Minute))
+	s.Require().NoError(err)
+	edits, err = s.repository.SelectEditsByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Len(edits, 1, "unchanged URL must not be recorded")
+}
+
+func (s *URLRepositorySuite) TestUpdateOriginal_Errors() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+		newURL("id3", "http://example.com/3", "user1", 0),
+	)
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id3"))
+
+	_, err := s.repository.UpdateOriginal(ctx, "user2", "id1", "http://example.com/edited", testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound, "URL of other user must not be edited")
+
+	_, err = s.repository.UpdateOriginal(ctx, "user1", "unknown", "http://example.com/edited", testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+
+	_, err = s.repository.UpdateOriginal(ctx, "user1", "id3", "http://example.com/edited", testTime)
+	s.ErrorIs(err, urlErr.ErrURLDeleted)
+
+	_, err = s.repository.UpdateOriginal(ctx, "user1", "id1", "http://example.com/2", testTime)
+	s.ErrorIs(err, urlErr.ErrURLAlreadyExists, "user must have at most one URL per original URL")
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal("http://example.com/1", url.Original)
+
+	edits, err := s.repository.SelectEditsByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Empty(edits)
+}
+
+func (s *URLRepositorySuite) TestUpdateOriginal_ReleasesOldOriginal() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+	_, err := s.repository.UpdateOriginal(ctx, "user1", "id1", "http://example.com/edited", testTime)
+	s.Require().NoError(err)
+
+	existing, err := s.repository.Insert(ctx, newURL("id1", "http://example.com/1", "user1", 0))
+	s.Require().ErrorIs(err, urlErr.ErrURLAlreadyExists, "ID of edited URL must stay taken")
+	s.Equal("http://example.com/edited", existing.Original)
+
+	s.insert(newURL("id2", "http://example.com/1", "user1", 0))
+	urls, err := s.repository.SelectAllByUserID(ctx, "user1", model.URLQuery{})
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"id1", "id2"}, ids(urls))
+}
+
+func (s *URLRepositorySuite) TestPurgeDeleted_RemovesEdits() {
+	ctx := context.Background()
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+	_, err := s.repository.UpdateOriginal(ctx, "user1", "id1", "http://example.com/edited", testTime)
+	s.Require().NoError(err)
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id1"))
+
+	purged, err := s.repository.PurgeDeleted(ctx, time.Now().Add(time.Minute), 10)
+	s.Require().NoError(err)
+	s.Equal(1, purged)
+
+	edits, err := s.repository.SelectEditsByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Empty(edits)
+}
+
+// normalizeEdit returns copy of edit with edit time in UTC.
+func normalizeEdit(edit model.URLEdit) model.URLEdit {
+	edit.EditedAt = edit.EditedAt.UTC()
+	return edit
+}
+
+func (s *URLRepositorySuite) TestUpdateRedirect() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored)
+	changedAt := testTime.Add(time.Minute)
+
+	settings := model.RedirectSettings{
+		Code:         308,
+		CacheControl: "public, max-age=3600",
+		QueryRules: model.QueryRules{
+			Params:      map[string]string{"utm_source": "{ref}", "utm_medium": "link"},
+			PassThrough: true,
+		},
+		CountryAccess: model.CountryAccess{Deny: []string{"KP", "SY"}},
+	}
+	updated, err := s.repository.UpdateRedirect(ctx, "user1", "id1", settings, changedAt)
+	s.Require().NoError(err)
+
+	expected := stored
+	expected.RedirectCode = 308
+	expected.CacheControl = "public, max-age=3600"
+	expected.QueryRules = settings.QueryRules
+	expected.CountryAccess = settings.CountryAccess
+	expected.UpdatedAt = changedAt
+	s.Equal(normalize(expected), normalize(*updated))
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(normalize(expected), normalize(*url))
+
+	settings.QueryRules.Params["utm_medium"] = "changed"
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal("link", url.QueryRules.Params["utm_medium"], "stored rules must not share params with caller")
+
+	settings.CountryAccess.Deny[0] = "RU"
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal([]string{"KP", "SY"}, url.CountryAccess.Deny, "stored access must not share countries with caller")
+
+	_, err = s.repository.UpdateRedirect(ctx, "user1", "id1", model.RedirectSettings{}, changedAt)
+	s.Require().NoError(err)
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Zero(url.RedirectCode)
+	s.Empty(url.CacheControl)
+	s.True(url.QueryRules.Empty())
+	s.True(url.CountryAccess.Empty())
+}
+
+func (s *URLRepositorySuite) TestUpdateRedirect_OtherURLs() {
+	ctx := context.Background()
+	before := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(before, newURL("id2", "http://example.com/2", "user2", 0))
+
+	settings := model.RedirectSettings{
+		QueryRules: model.QueryRules{
+			Params:      map[string]string{"utm_source": "{ref}"},
+			PassThrough: true,
+		},
+		CountryAccess: model.CountryAccess{Deny: []string{"RU"}},
+	}
+	_, err := s.repository.UpdateRedirect(ctx, "user2", "id2", settings, testTime.Add(time.Minute))
+	s.Require().NoError(err)
+
+	after := newURL("id3", "http://example.com/3", "user3", 0)
+	s.insert(after)
+
+	for _, expected := range []model.URL{before, after} {
+		url, err := s.repository.SelectByID(ctx, expected.ID)
+		s.Require().NoError(err)
+		s.True(url.QueryRules.Empty(), "query rules of %s must not be taken from other URL", expected.ID)
+		s.True(url.CountryAccess.Empty(), "country access of %s must not be taken from other URL", expected.ID)
+		s.Equal(normalize(expected), normalize(*url))
+	}
+}
+
+func (s *URLRepositorySuite) TestUpdateRedirect_Errors() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+	)
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id2"))
+
+	_, err := s.repository.UpdateRedirect(ctx, "user2", "id1", model.RedirectSettings{Code: 301}, testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound, "URL of other user must not be changed")
+
+	_, err = s.repository.UpdateRedirect(ctx, "user1", "unknown", model.RedirectSettings{Code: 301}, testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+
+	_, err = s.repository.UpdateRedirect(ctx, "user1", "id2", model.RedirectSettings{Code: 301}, testTime)
+	s.ErrorIs(err, urlErr.ErrURLDeleted)
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Zero(url.RedirectCode)
+}
+
+func (s *URLRepositorySuite) TestUpdateRules() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored)
+	changedAt := testTime.Add(time.Minute)
+	from := testTime.Add(time.Hour)
+	rules := []model.RedirectRule{
+		{ID: "r1", Destination: "https://apps.apple.com/app/id1", Devices: []string{model.DeviceIOS}},
+		{ID: "r2", Destination: "http://example.com/launch", Languages: []string{"de"}, ReferrerHosts: []string{"example.org"}, From: &from, Percent: 50},
+	}
+
+	updated, err := s.repository.UpdateRules(ctx, "user1", "id1", func(current []model.RedirectRule) ([]model.RedirectRule, error) {
+		s.Empty(current)
+		return rules, nil
+	}, changedAt)
+	s.Require().NoError(err)
+
+	expected := stored
+	expected.Rules = rules
+	expected.UpdatedAt = changedAt
+	s.Equal(normalize(expected), normalize(*updated))
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(normalize(expected), normalize(*url))
+
+	_, err = s.repository.UpdateRules(ctx, "user1", "id1", func(current []model.RedirectRule) ([]model.RedirectRule, error) {
+		s.Equal(rules, current)
+		return current[1:], nil
+	}, changedAt)
+	s.Require().NoError(err)
+	url, err = s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(rules[1:], url.Rules)
+}
+
+func (s *URLRepositorySuite) TestUpdateRules_Errors() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+	)
+	s.Require().NoError(s.repository.DeleteURLByUserID(ctx, "user1", "id2"))
+	addRule := func(current []model.RedirectRule) ([]model.RedirectRule, error) {
+		return append(current, model.RedirectRule{ID: "r1", Destination: "http://example.com/rule"}), nil
+	}
+
+	_, err := s.repository.UpdateRules(ctx, "user2", "id1", addRule, testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound, "URL of other user must not be changed")
+
+	_, err = s.repository.UpdateRules(ctx, "user1", "unknown", addRule, testTime)
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+
+	_, err = s.repository.UpdateRules(ctx, "user1", "id2", addRule, testTime)
+	s.ErrorIs(err, urlErr.ErrURLDeleted)
+
+	updateErr := errors.New("update error")
+	_, err = s.repository.UpdateRules(ctx, "user1", "id1", func(current []model.RedirectRule) ([]model.RedirectRule, error) {
+		return append(current, model.RedirectRule{ID: "r2", Destination: "http://example.com/rule"}), updateErr
+	}, testTime.Add(time.Minute))
+	s.ErrorIs(err, updateErr)
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Empty(url.Rules, "rules must not be changed by failed update")
+	s.Equal(testTime, url.UpdatedAt.UTC())
+}
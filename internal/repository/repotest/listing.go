@@ -0,0 +1,137 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+// listingURLs are inserted by listing tests, ordered by creation time.
+var listingURLs = []model.URL{
+	newURL("id1", "http://example.com/a", "user1", 0),
+	newURL("id2", "http://example.com/b", "user2", time.Second),
+	newURL("id4", "http://example.org/c", "user1", 2*time.Second),
+	newURL("id3", "http://example.org/d", "user1", 2*time.Second),
+}
+
+func ids(urls []model.URL) []string {
+	result := make([]string, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, url.ID)
+	}
+	return result
+}
+
+func (s *URLRepositorySuite) TestSelectAll() {
+	s.insert(listingURLs...)
+	s.Require().NoError(s.repository.DeleteURLByUserID(context.Background(), "user1", "id3"))
+	deleted, notDeleted := true, false
+
+	testCases := []struct {
+		name        string
+		query       model.URLQuery
+		expectedIDs []string
+	}{
+		{name: "All", query: model.URLQuery{}, expectedIDs: []string{"id1", "id2", "id3", "id4"}},
+		{name: "Desc", query: model.URLQuery{Desc: true}, expectedIDs: []string{"id4", "id3", "id2", "id1"}},
+		{name: "Limit", query: model.URLQuery{Limit: 2}, expectedIDs: []string{"id1", "id2"}},
+		{
+			name:        "After cursor",
+			query:       model.URLQuery{After: &model.URLCursor{CreatedAt: testTime.Add(2 * time.Second), ID: "id3"}},
+			expectedIDs: []string{"id4"},
+		},
+		{
+			name:        "After cursor desc",
+			query:       model.URLQuery{After: &model.URLCursor{CreatedAt: testTime.Add(2 * time.Second), ID: "id3"}, Desc: true},
+			expectedIDs: []string{"id2", "id1"},
+		},
+		{name: "User", query: model.URLQuery{URLFilter: model.URLFilter{UserID: "user1"}}, expectedIDs: []string{"id1", "id3", "id4"}},
+		{name: "Deleted", query: model.URLQuery{URLFilter: model.URLFilter{Deleted: &deleted}}, expectedIDs: []string{"id3"}},
+		{name: "Not deleted", query: model.URLQuery{URLFilter: model.URLFilter{Deleted: &notDeleted}}, expectedIDs: []string{"id1", "id2", "id4"}},
+		{name: "Search", query: model.URLQuery{URLFilter: model.URLFilter{Search: "example.org"}}, expectedIDs: []string{"id3", "id4"}},
+		{
+			name: "Created range",
+			query: model.URLQuery{URLFilter: model.URLFilter{
+				CreatedFrom: testTime.Add(time.Second),
+				CreatedTo:   testTime.Add(2 * time.Second),
+			}},
+			expectedIDs: []string{"id2"},
+		},
+		{name: "Nothing matches", query: model.URLQuery{URLFilter: model.URLFilter{Search: "unknown"}}, expectedIDs: []string{}},
+	}
+
+	for _, test := range testCases {
+		s.Run(test.name, func() {
+			urls, err := s.repository.SelectAll(context.Background(), test.query)
+			s.Require().NoError(err)
+			s.Equal(test.expectedIDs, ids(urls))
+		})
+	}
+}
+
+func (s *URLRepositorySuite) TestSelectAllByUserID() {
+	s.insert(listingURLs...)
+
+	urls, err := s.repository.SelectAllByUserID(context.Background(), "user1", model.URLQuery{Limit: 2, Desc: true})
+	s.Require().NoError(err)
+	s.Equal([]string{"id4", "id3"}, ids(urls))
+
+	urls, err = s.repository.SelectAllByUserID(context.Background(), "user2", model.URLQuery{URLFilter: model.URLFilter{UserID: "user1"}})
+	s.Require().NoError(err)
+	s.Equal([]string{"id2"}, ids(urls))
+
+	_, err = s.repository.SelectAllByUserID(context.Background(), "unknown", model.URLQuery{})
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+}
+
+func (s *URLRepositorySuite) TestIterateByUserID() {
+	s.insert(listingURLs...)
+	s.Require().NoError(s.repository.DeleteURLByUserID(context.Background(), "user1", "id3"))
+	deleted := true
+
+	var urls []model.URL
+	err := s.repository.IterateByUserID(context.Background(), "user1", model.URLFilter{}, func(url model.URL) error {
+		urls = append(urls, url)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"id1", "id3", "id4"}, ids(urls))
+
+	urls = nil
+	err = s.repository.IterateByUserID(context.Background(), "user1", model.URLFilter{Deleted: &deleted, Search: "example.org"}, func(url model.URL) error {
+		urls = append(urls, url)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]string{"id3"}, ids(urls))
+
+	fnErr := errors.New("fn error")
+	calls := 0
+	err = s.repository.IterateByUserID(context.Background(), "user1", model.URLFilter{}, func(model.URL) error {
+		calls++
+		return fnErr
+	})
+	s.ErrorIs(err, fnErr)
+	s.Equal(1, calls)
+
+	err = s.repository.IterateByUserID(context.Background(), "unknown", model.URLFilter{}, func(model.URL) error {
+		s.Fail("no URLs expected")
+		return nil
+	})
+	s.NoError(err)
+}
+
+func (s *URLRepositorySuite) TestIterateByUserID_Write() {
+	s.insert(listingURLs...)
+
+	var urls []model.URL
+	err := s.repository.IterateByUserID(context.Background(), "user1", model.URLFilter{}, func(url model.URL) error {
+		urls = append(urls, url)
+		return s.repository.DeleteURLByUserID(context.Background(), "user1", url.ID)
+	})
+	s.Require().NoError(err, "fn must be able to write to the repository")
+	s.ElementsMatch([]string{"id1", "id3", "id4"}, ids(urls))
+}
@@ -0,0 +1,86 @@
+package repotest
+
+import (
+	"context"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+func (s *URLRepositorySuite) TestInsert_KeepsURLWithTakenID() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored)
+
+	existing, err := s.repository.Insert(ctx, newURL("id1", "http://example.com/2", "user2", 0))
+	s.Require().ErrorIs(err, urlErr.ErrURLAlreadyExists)
+	s.Equal(normalize(stored), normalize(*existing))
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal(normalize(stored), normalize(*url))
+}
+
+func (s *URLRepositorySuite) TestInsert_ReturnsURLOfUserWithSameOriginal() {
+	ctx := context.Background()
+	stored := newURL("id1", "http://example.com/1", "user1", 0)
+	s.insert(stored)
+
+	existing, err := s.repository.Insert(ctx, newURL("id2", "http://example.com/1", "user1", 0))
+	s.Require().ErrorIs(err, urlErr.ErrURLAlreadyExists)
+	s.Equal(normalize(stored), normalize(*existing))
+
+	_, err = s.repository.SelectByID(ctx, "id2")
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+}
+
+func (s *URLRepositorySuite) TestInsert_UsersShortenSameOriginal() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/1", "user2", 0),
+	)
+
+	for id, userID := range map[string]string{"id1": "user1", "id2": "user2"} {
+		url, err := s.repository.SelectByID(ctx, id)
+		s.Require().NoError(err)
+		s.Equal(userID, url.UserID)
+
+		urls, err := s.repository.SelectAllByUserID(ctx, userID, model.URLQuery{})
+		s.Require().NoError(err)
+		s.Require().Len(urls, 1)
+		s.Equal(id, urls[0].ID)
+	}
+}
+
+func (s *URLRepositorySuite) TestInsertAll_KeepsExistingURLs() {
+	ctx := context.Background()
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user2", 0),
+	)
+
+	inserted, existing, err := s.repository.InsertAll(ctx, []model.URL{
+		newURL("id1", "http://example.com/1", "user2", 0),
+		newURL("id3", "http://example.com/2", "user2", 0),
+		newURL("id4", "http://example.com/3", "user2", 0),
+	})
+	s.Require().NoError(err)
+
+	s.Require().Len(inserted, 1)
+	s.Equal("id4", inserted[0].ID)
+	s.Require().Len(existing, 2)
+	s.ElementsMatch([]string{"id1", "id2"}, []string{existing[0].ID, existing[1].ID})
+
+	url, err := s.repository.SelectByID(ctx, "id1")
+	s.Require().NoError(err)
+	s.Equal("user1", url.UserID)
+
+	_, err = s.repository.SelectByID(ctx, "id3")
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+
+	urls, err := s.repository.SelectAllByUserID(ctx, "user1", model.URLQuery{})
+	s.Require().NoError(err)
+	s.Require().Len(urls, 1)
+	s.Equal("id1", urls[0].ID)
+}
@@ -0,0 +1,154 @@
+// Package repotest provides conformance test suite every URLRepository implementation must pass.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	"github.com/msmkdenis/yap-shortener/internal/service"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+// Factory returns an empty repository under test.
+type Factory func(t *testing.T) service.URLRepository
+
+// URLRepositorySuite is a conformance suite of URLRepository, every test gets an empty repository from the factory.
+type URLRepositorySuite struct {
+	suite.Suite
+	newRepository Factory
+	repository    service.URLRepository
+}
+
+// Run runs URLRepositorySuite against repositories returned by newRepository.
+func Run(t *testing.T, newRepository Factory) {
+	suite.Run(t, &URLRepositorySuite{newRepository: newRepository})
+}
+
+func (s *URLRepositorySuite) SetupTest() {
+	s.repository = s.newRepository(s.T())
+}
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newURL returns URL created at testTime shifted by offset.
+func newURL(id string, original string, userID string, offset time.Duration) model.URL {
+	return model.URL{
+		ID:            id,
+		Original:      original,
+		Shortened:     "http://localhost:8080/" + id,
+		CorrelationID: "correlation-" + id,
+		UserID:        userID,
+		CreatedAt:     testTime.Add(offset),
+		UpdatedAt:     testTime.Add(offset),
+	}
+}
+
+// insert inserts urls one by one.
+func (s *URLRepositorySuite) insert(urls ...model.URL) {
+	for _, url := range urls {
+		_, err := s.repository.Insert(context.Background(), url)
+		s.Require().NoError(err)
+	}
+}
+
+// normalize returns copies of urls with times in UTC and no rules as nil, so URLs read from different backends compare equal.
+func normalize(urls ...model.URL) []model.URL {
+	normalized := make([]model.URL, 0, len(urls))
+	for _, url := range urls {
+		url.CreatedAt = url.CreatedAt.UTC()
+		url.UpdatedAt = url.UpdatedAt.UTC()
+		if url.DeletedAt != nil {
+			deletedAt := url.DeletedAt.UTC()
+			url.DeletedAt = &deletedAt
+		}
+		if len(url.Rules) == 0 {
+			url.Rules = nil
+		}
+		normalized = append(normalized, url)
+	}
+	return normalized
+}
+
+func (s *URLRepositorySuite) TestPing() {
+	s.NoError(s.repository.Ping(context.Background()))
+}
+
+func (s *URLRepositorySuite) TestInsert() {
+	url := newURL("id1", "http://example.com/1", "user1", 0)
+
+	saved, err := s.repository.Insert(context.Background(), url)
+	s.Require().NoError(err)
+	s.Equal(normalize(url), normalize(*saved))
+
+	selected, err := s.repository.SelectByID(context.Background(), url.ID)
+	s.Require().NoError(err)
+	s.Equal(normalize(url), normalize(*selected))
+}
+
+func (s *URLRepositorySuite) TestSelectByID_NotFound() {
+	url, err := s.repository.SelectByID(context.Background(), "unknown")
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+	s.Nil(url)
+
+	var errWithMessage interface{ Message() string }
+	s.True(errors.As(err, &errWithMessage), "error must be wrapped with apperr.ValueError")
+}
+
+func (s *URLRepositorySuite) TestInsertAll() {
+	urls := []model.URL{
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", time.Second),
+	}
+
+	inserted, existing, err := s.repository.InsertAll(context.Background(), urls)
+	s.Require().NoError(err)
+	s.ElementsMatch(normalize(urls...), normalize(inserted...))
+	s.Empty(existing)
+
+	for _, url := range urls {
+		selected, err := s.repository.SelectByID(context.Background(), url.ID)
+		s.Require().NoError(err)
+		s.Equal(normalize(url), normalize(*selected))
+	}
+}
+
+func (s *URLRepositorySuite) TestSelectStats() {
+	stats, err := s.repository.SelectStats(context.Background())
+	s.Require().NoError(err)
+	s.Equal(model.URLStats{}, *stats)
+
+	s.insert(
+		newURL("id1", "http://example.com/1", "user1", 0),
+		newURL("id2", "http://example.com/2", "user1", 0),
+		newURL("id3", "http://example.com/1", "user2", 0),
+	)
+	s.Require().NoError(s.repository.DeleteURLByUserID(context.Background(), "user2", "id3"))
+
+	stats, err = s.repository.SelectStats(context.Background())
+	s.Require().NoError(err)
+	s.Equal(model.URLStats{Urls: 3, Users: 2}, *stats)
+}
+
+func (s *URLRepositorySuite) TestDeleteAll() {
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+
+	s.Require().NoError(s.repository.DeleteAll(context.Background()))
+
+	stats, err := s.repository.SelectStats(context.Background())
+	s.Require().NoError(err)
+	s.Equal(model.URLStats{}, *stats)
+
+	_, err = s.repository.SelectByID(context.Background(), "id1")
+	s.ErrorIs(err, urlErr.ErrURLNotFound)
+
+	urls, err := s.repository.SelectAll(context.Background(), model.URLQuery{})
+	s.Require().NoError(err)
+	s.Empty(urls)
+
+	s.insert(newURL("id1", "http://example.com/1", "user1", 0))
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// DefaultCountryRedirectsInterval is an interval between flushes of redirects counted by country.
+	DefaultCountryRedirectsInterval = 10 * time.Second
+	// maxAccessCountries is a maximum number of countries of URL country access.
+	maxAccessCountries = 250
+)
+
+// countryCode matches ISO 3166-1 alpha-2 country code.
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// CountryResolver represents resolver of visitor countries, e.g. GeoIP database.
+type CountryResolver interface {
+	// Country returns ISO 3166-1 alpha-2 code of the IP address country, empty if unknown.
+	Country(ip string) string
+}
+
+// GetCountryRedirects returns redirects of the user's URL by country from the most redirected country,
+// redirects of visitors of unknown country are counted as model.UnknownCountry.
+//
+// Deleted URLs keep their redirects until purged.
+func (u *URLUseCase) GetCountryRedirects(ctx context.Context, userID string, id string) ([]model.CountryRedirects, error) {
+	url, err := u.repository.SelectByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if url.UserID != userID {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	redirects, err := u.repository.SelectCountryRedirects(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return redirects, nil
+}
+
+// RunCountryRedirects flushes redirects counted by country to repository at intervals until the context is canceled,
+// failures are logged and the redirects are flushed next time.
+//
+// Redirects counted after the last flush are kept in memory, the caller flushes them with FlushCountryRedirects on shutdown.
+func (u *URLUseCase) RunCountryRedirects(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCountryRedirectsInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := u.FlushCountryRedirects(ctx); err != nil && ctx.Err() == nil {
+			u.logger.Warn("Unable to flush redirects by country", zap.Error(err))
+		}
+	}
+}
+
+// FlushCountryRedirects adds redirects counted by country since the last flush to repository,
+// on failure they are kept to be flushed next time.
+func (u *URLUseCase) FlushCountryRedirects(ctx context.Context) error {
+	u.redirectsMu.Lock()
+	counted := u.redirects
+	u.redirects = make(map[countryKey]int64)
+	u.redirectsMu.Unlock()
+
+	if len(counted) == 0 {
+		return nil
+	}
+
+	redirects := make([]model.URLCountryRedirects, 0, len(counted))
+	for key, n := range counted {
+		redirects = append(redirects, model.URLCountryRedirects{URLID: key.id, Country: key.country, Redirects: n})
+	}
+	slices.SortFunc(redirects, func(a, b model.URLCountryRedirects) int {
+		if a.URLID != b.URLID {
+			return strings.Compare(a.URLID, b.URLID)
+		}
+		return strings.Compare(a.Country, b.Country)
+	})
+
+	if err := u.repository.AddCountryRedirects(ctx, redirects); err != nil {
+		u.redirectsMu.Lock()
+		for key, n := range counted {
+			u.redirects[key] += n
+		}
+		u.redirectsMu.Unlock()
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// countryKey identifies redirects of URL visitors from the country.
+type countryKey struct {
+	id      string
+	country string
+}
+
+// countRedirect counts in memory redirect of URL visitor from the country until flushed to repository.
+func (u *URLUseCase) countRedirect(id string, country string) {
+	if country == "" {
+		country = model.UnknownCountry
+	}
+
+	u.redirectsMu.Lock()
+	u.redirects[countryKey{id: id, country: country}]++
+	u.redirectsMu.Unlock()
+}
+
+// prepareCountryAccess validates the country access and returns it with upper-cased country codes.
+func prepareCountryAccess(access model.CountryAccess) (model.CountryAccess, error) {
+	if len(access.Allow) > 0 && len(access.Deny) > 0 {
+		return access, apperr.NewValueError("either allowed or denied countries may be set", apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+
+	if len(access.Allow) > maxAccessCountries || len(access.Deny) > maxAccessCountries {
+		return access, apperr.NewValueError(fmt.Sprintf("at most %d countries are allowed", maxAccessCountries), apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+
+	var err error
+	if access.Allow, err = upperCountries(access.Allow); err != nil {
+		return access, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	if access.Deny, err = upperCountries(access.Deny); err != nil {
+		return access, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return access, nil
+}
+
+// upperCountries returns upper-cased copy of the country codes, nil for no codes.
+func upperCountries(countries []string) ([]string, error) {
+	if len(countries) == 0 {
+		return nil, nil
+	}
+
+	upper := make([]string, len(countries))
+	for i, country := range countries {
+		upper[i] = strings.ToUpper(country)
+		if !countryCode.MatchString(upper[i]) {
+			return nil, apperr.NewValueError(fmt.Sprintf("invalid country %q, ISO 3166-1 alpha-2 code expected", country), apperr.Caller(), urlErr.ErrInvalidRedirect)
+		}
+	}
+	return upper, nil
+}
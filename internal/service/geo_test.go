@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+
+	mock "github.com/msmkdenis/yap-shortener/internal/mocks"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+// countries resolves countries of IP addresses from the map.
+type countries map[string]string
+
+func (c countries) Country(ip string) string {
+	return c[ip]
+}
+
+type GeoTestSuite struct {
+	suite.Suite
+	urlRepository *mock.MockURLRepository
+	urlService    *URLUseCase
+}
+
+func TestGeoSuite(t *testing.T) {
+	suite.Run(t, new(GeoTestSuite))
+}
+
+func (g *GeoTestSuite) SetupTest() {
+	g.urlRepository = mock.NewMockURLRepository(gomock.NewController(g.T()))
+	resolver := countries{"192.0.2.1": "US", "198.51.100.1": "KP"}
+	g.urlService = NewURLService(g.urlRepository, 0, RedirectConfig{}, resolver, zap.NewNop())
+	g.urlService.now = func() time.Time { return testTime }
+}
+
+func (g *GeoTestSuite) TestGetRedirect() {
+	testCases := []struct {
+		name            string
+		access          model.CountryAccess
+		visit           model.Visit
+		expectedCountry string
+		expectedError   error
+	}{
+		{
+			name:            "Resolved country",
+			visit:           model.Visit{IP: "192.0.2.1"},
+			expectedCountry: "US",
+		},
+		{
+			name:            "Unknown country",
+			visit:           model.Visit{IP: "203.0.113.1"},
+			expectedCountry: model.UnknownCountry,
+		},
+		{
+			name:            "Country of visit",
+			visit:           model.Visit{IP: "192.0.2.1", Country: "DE"},
+			expectedCountry: "DE",
+		},
+		{
+			name:            "Allowed country",
+			access:          model.CountryAccess{Allow: []string{"US", "CA"}},
+			visit:           model.Visit{IP: "192.0.2.1"},
+			expectedCountry: "US",
+		},
+		{
+			name:          "Not allowed country",
+			access:        model.CountryAccess{Allow: []string{"CA"}},
+			visit:         model.Visit{IP: "192.0.2.1"},
+			expectedError: urlErr.ErrCountryBlocked,
+		},
+		{
+			name:          "Unknown country not allowed",
+			access:        model.CountryAccess{Allow: []string{"US"}},
+			visit:         model.Visit{IP: "203.0.113.1"},
+			expectedError: urlErr.ErrCountryBlocked,
+		},
+		{
+			name:          "Denied country",
+			access:        model.CountryAccess{Deny: []string{"KP"}},
+			visit:         model.Visit{IP: "198.51.100.1"},
+			expectedError: urlErr.ErrCountryBlocked,
+		},
+		{
+			name:            "Unknown country not denied",
+			access:          model.CountryAccess{Deny: []string{"KP"}},
+			visit:           model.Visit{IP: "203.0.113.1"},
+			expectedCountry: model.UnknownCountry,
+		},
+	}
+
+	for _, test := range testCases {
+		g.Run(test.name, func() {
+			url := &model.URL{ID: "id", Original: "http://example.com", CountryAccess: test.access}
+			g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(url, nil)
+			if test.expectedError == nil {
+				g.urlRepository.EXPECT().AddCountryRedirects(gomock.Any(), []model.URLCountryRedirects{{URLID: "id", Country: test.expectedCountry, Redirects: 1}}).Return(nil)
+			}
+
+			redirect, err := g.urlService.GetRedirect(context.Background(), "id", test.visit)
+			g.ErrorIs(err, test.expectedError)
+			if test.expectedError == nil {
+				g.Equal("http://example.com", redirect.Location)
+			}
+			g.Require().NoError(g.urlService.FlushCountryRedirects(context.Background()))
+		})
+	}
+}
+
+func (g *GeoTestSuite) TestFlushCountryRedirects() {
+	ctx := context.Background()
+	g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id1").Return(&model.URL{ID: "id1", Original: "http://example.com"}, nil).Times(4)
+	g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id2").Return(&model.URL{ID: "id2", Original: "http://example.com"}, nil).Times(2)
+	for _, visit := range []struct {
+		id    string
+		visit model.Visit
+	}{
+		{id: "id2", visit: model.Visit{IP: "192.0.2.1"}},
+		{id: "id1", visit: model.Visit{IP: "192.0.2.1"}},
+		{id: "id1", visit: model.Visit{IP: "203.0.113.1"}},
+		{id: "id1", visit: model.Visit{IP: "192.0.2.1"}},
+		{id: "id1", visit: model.Visit{IP: "192.0.2.1", Head: true}},
+		{id: "id2", visit: model.Visit{IP: "192.0.2.1", Head: true}},
+	} {
+		_, err := g.urlService.GetRedirect(ctx, visit.id, visit.visit)
+		g.Require().NoError(err)
+	}
+
+	counted := []model.URLCountryRedirects{
+		{URLID: "id1", Country: "US", Redirects: 2},
+		{URLID: "id1", Country: model.UnknownCountry, Redirects: 1},
+		{URLID: "id2", Country: "US", Redirects: 1},
+	}
+	g.urlRepository.EXPECT().AddCountryRedirects(gomock.Any(), counted).Return(errors.New("db down"))
+	g.Error(g.urlService.FlushCountryRedirects(ctx))
+
+	g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id2").Return(&model.URL{ID: "id2", Original: "http://example.com"}, nil)
+	_, err := g.urlService.GetRedirect(ctx, "id2", model.Visit{IP: "192.0.2.1"})
+	g.Require().NoError(err)
+
+	counted[2].Redirects = 2
+	g.urlRepository.EXPECT().AddCountryRedirects(gomock.Any(), counted).Return(nil)
+	g.Require().NoError(g.urlService.FlushCountryRedirects(ctx), "failed redirects must be flushed next time")
+	g.Require().NoError(g.urlService.FlushCountryRedirects(ctx), "flushed redirects must not be flushed again")
+}
+
+func (g *GeoTestSuite) TestGetRedirect_NoResolver() {
+	g.urlService.countries = nil
+	url := &model.URL{ID: "id", Original: "http://example.com", CountryAccess: model.CountryAccess{Deny: []string{"US"}}}
+	g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(url, nil)
+
+	redirect, err := g.urlService.GetRedirect(context.Background(), "id", model.Visit{IP: "192.0.2.1"})
+	g.Require().NoError(err, "redirects must neither be blocked nor counted without resolver")
+	g.Equal("http://example.com", redirect.Location)
+}
+
+func (g *GeoTestSuite) TestUpdateRedirect() {
+	testCases := []struct {
+		name           string
+		access         model.CountryAccess
+		expectedAccess model.CountryAccess
+		expectedError  error
+	}{
+		{
+			name:           "Upper-cased countries",
+			access:         model.CountryAccess{Allow: []string{"us", "Ca"}},
+			expectedAccess: model.CountryAccess{Allow: []string{"US", "CA"}},
+		},
+		{
+			name:           "Denied countries",
+			access:         model.CountryAccess{Deny: []string{"KP"}},
+			expectedAccess: model.CountryAccess{Deny: []string{"KP"}},
+		},
+		{
+			name:           "Empty lists",
+			access:         model.CountryAccess{Allow: []string{}, Deny: []string{}},
+			expectedAccess: model.CountryAccess{},
+		},
+		{
+			name:          "Both lists",
+			access:        model.CountryAccess{Allow: []string{"US"}, Deny: []string{"KP"}},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Invalid country",
+			access:        model.CountryAccess{Deny: []string{"USA"}},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Too many countries",
+			access:        model.CountryAccess{Allow: make([]string, maxAccessCountries+1)},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+	}
+
+	for _, test := range testCases {
+		g.Run(test.name, func() {
+			if test.expectedError == nil {
+				settings := model.RedirectSettings{CountryAccess: test.expectedAccess}
+				g.urlRepository.EXPECT().UpdateRedirect(gomock.Any(), "user", "id", settings, testTime).Return(&model.URL{ID: "id"}, nil)
+			}
+
+			_, err := g.urlService.UpdateRedirect(context.Background(), "user", "id", model.RedirectSettings{CountryAccess: test.access})
+			g.ErrorIs(err, test.expectedError)
+		})
+	}
+}
+
+func (g *GeoTestSuite) TestUpdateRedirect_NoResolver() {
+	g.urlService.countries = nil
+
+	_, err := g.urlService.UpdateRedirect(context.Background(), "user", "id", model.RedirectSettings{CountryAccess: model.CountryAccess{Allow: []string{"US"}}})
+	g.ErrorIs(err, urlErr.ErrInvalidRedirect, "allowed countries must be rejected without resolver")
+
+	settings := model.RedirectSettings{CountryAccess: model.CountryAccess{Deny: []string{"KP"}}}
+	g.urlRepository.EXPECT().UpdateRedirect(gomock.Any(), "user", "id", settings, testTime).Return(&model.URL{ID: "id"}, nil)
+	_, err = g.urlService.UpdateRedirect(context.Background(), "user", "id", settings)
+	g.NoError(err, "denied countries must be accepted without resolver")
+}
+
+func (g *GeoTestSuite) TestGetCountryRedirects() {
+	redirects := []model.CountryRedirects{{Country: "US", Redirects: 3}, {Country: model.UnknownCountry, Redirects: 1}}
+
+	testCases := []struct {
+		name              string
+		prepare           func()
+		expectedRedirects []model.CountryRedirects
+		expectedError     error
+	}{
+		{
+			name: "Success",
+			prepare: func() {
+				g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&model.URL{ID: "id", UserID: "user"}, nil)
+				g.urlRepository.EXPECT().SelectCountryRedirects(gomock.Any(), "id").Return(redirects, nil)
+			},
+			expectedRedirects: redirects,
+		},
+		{
+			name: "Deleted URL",
+			prepare: func() {
+				g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&model.URL{ID: "id", UserID: "user", DeletedFlag: true}, nil)
+				g.urlRepository.EXPECT().SelectCountryRedirects(gomock.Any(), "id").Return(redirects, nil)
+			},
+			expectedRedirects: redirects,
+		},
+		{
+			name: "Other user",
+			prepare: func() {
+				g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&model.URL{ID: "id", UserID: "other"}, nil)
+			},
+			expectedError: urlErr.ErrURLNotFound,
+		},
+		{
+			name: "Not found",
+			prepare: func() {
+				g.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(nil, urlErr.ErrURLNotFound)
+			},
+			expectedError: urlErr.ErrURLNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		g.Run(test.name, func() {
+			test.prepare()
+
+			redirects, err := g.urlService.GetCountryRedirects(context.Background(), "user", "id")
+			g.ErrorIs(err, test.expectedError)
+			g.Equal(test.expectedRedirects, redirects)
+		})
+	}
+}
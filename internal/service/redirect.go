@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// DefaultRedirectCode is HTTP status of redirects when neither URL nor server sets one.
+	DefaultRedirectCode = http.StatusTemporaryRedirect
+	// maxCacheControlLength is a maximum length of Cache-Control header of redirects.
+	maxCacheControlLength = 256
+	// maxQueryParams is a maximum number of query parameters set by query rules.
+	maxQueryParams = 20
+	// maxQueryParamLength is a maximum length of names and values of query parameters set by query rules.
+	maxQueryParamLength = 512
+	// countryPlaceholder is a name of placeholder substituted with the visitor country.
+	countryPlaceholder = "country"
+)
+
+var (
+	// cacheDirective matches a single Cache-Control directive with optional numeric argument, e.g. max-age=3600.
+	cacheDirective = regexp.MustCompile(`^[a-z][a-z-]*(=[0-9]+)?$`)
+	// placeholder matches {name} placeholder in values of query parameters.
+	placeholder = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+)
+
+// RedirectConfig represents server defaults of redirects, URLs may override them.
+type RedirectConfig struct {
+	// Code is one of 301, 302, 307 and 308, zero means DefaultRedirectCode.
+	Code int
+	// CacheControl is Cache-Control header of redirects, empty means no header.
+	CacheControl string
+}
+
+// Validate checks the redirect defaults.
+func (c RedirectConfig) Validate() error {
+	if c.Code != 0 && !validRedirectCode(c.Code) {
+		return apperr.NewValueError(fmt.Sprintf("redirect code %d is not one of 301, 302, 307, 308", c.Code), apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+	if !validCacheControl(c.CacheControl) {
+		return apperr.NewValueError(fmt.Sprintf("invalid cache control %q", c.CacheControl), apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+
+	return nil
+}
+
+// GetRedirect returns redirect of the visit to the original URL by ID.
+//
+// The location is the destination of the first redirect rule of URL matching the visit or the original URL,
+// its query is built by query rules of URL. Settings not set by URL are server defaults.
+// Redirects of URLs with rules, query rules or country access are marked visitor dependent.
+//
+// The visitor country is resolved by IP unless the visit has one, visitors of countries not allowed by URL
+// are not redirected. Redirects are counted by country if countries are resolved, HEAD requests are not counted.
+func (u *URLUseCase) GetRedirect(ctx context.Context, key string, visit model.Visit) (*model.Redirect, error) {
+	url, err := u.repository.SelectByID(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError("deleted url", apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	if visit.Country == "" && u.countries != nil {
+		visit.Country = u.countries.Country(visit.IP)
+	}
+
+	if !url.CountryAccess.Allowed(visit.Country) {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s is unavailable in country %q", key, visit.Country), apperr.Caller(), urlErr.ErrCountryBlocked)
+	}
+
+	destination := url.Original
+	if rule := u.matchRule(url, visit); rule != nil {
+		destination = rule.Destination
+	}
+
+	redirect := &model.Redirect{
+		Location:         applyQueryRules(destination, url.QueryRules, visit),
+		Code:             url.RedirectCode,
+		CacheControl:     url.CacheControl,
+		VisitorDependent: url.VisitorDependent(),
+	}
+	if redirect.Code == 0 {
+		redirect.Code = u.redirect.Code
+	}
+	if redirect.Code == 0 {
+		redirect.Code = DefaultRedirectCode
+	}
+	if redirect.CacheControl == "" {
+		redirect.CacheControl = u.redirect.CacheControl
+	}
+
+	if u.countries != nil && !visit.Head {
+		u.countRedirect(url.ID, visit.Country)
+	}
+
+	return redirect, nil
+}
+
+// UpdateRedirect changes redirect settings of the user's URL, zero values mean server defaults.
+//
+// Allowed countries are rejected if countries of visitors are not resolved, since no visitor would be redirected.
+func (u *URLUseCase) UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings) (*model.URL, error) {
+	if err := (RedirectConfig{Code: settings.Code, CacheControl: settings.CacheControl}).Validate(); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	if err := validateQueryRules(settings.QueryRules); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	access, err := prepareCountryAccess(settings.CountryAccess)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	if len(access.Allow) > 0 && u.countries == nil {
+		return nil, apperr.NewValueError("allowed countries require GeoIP database, countries of visitors are not resolved", apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+	settings.CountryAccess = access
+
+	url, err := u.repository.UpdateRedirect(ctx, userID, id, settings, u.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return url, nil
+}
+
+// applyQueryRules returns the original URL with query built by the rules for the visit.
+//
+// Original URL which can not be parsed is returned as is.
+func applyQueryRules(original string, rules model.QueryRules, visit model.Visit) string {
+	if rules.Empty() {
+		return original
+	}
+
+	location, err := url.Parse(original)
+	if err != nil {
+		return original
+	}
+
+	query := location.Query()
+	if rules.PassThrough {
+		for name, values := range visit.Query {
+			query[name] = values
+		}
+	}
+
+	for name, value := range rules.Params {
+		value = placeholder.ReplaceAllStringFunc(value, func(match string) string {
+			return visitVariable(visit, match[1:len(match)-1])
+		})
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	location.RawQuery = query.Encode()
+	return location.String()
+}
+
+// visitVariable returns value of the visit variable substituted for the placeholder with the name.
+//
+// {country} is the visitor country, other placeholders are query parameters of the visit.
+func visitVariable(visit model.Visit, name string) string {
+	if name == countryPlaceholder {
+		return visit.Country
+	}
+	return visit.Query.Get(name)
+}
+
+// validateQueryRules checks number and length of query parameters set by the rules.
+func validateQueryRules(rules model.QueryRules) error {
+	if len(rules.Params) > maxQueryParams {
+		return apperr.NewValueError(fmt.Sprintf("at most %d query params are allowed", maxQueryParams), apperr.Caller(), urlErr.ErrInvalidRedirect)
+	}
+
+	for name, value := range rules.Params {
+		if name == "" || len(name) > maxQueryParamLength || len(value) > maxQueryParamLength {
+			return apperr.NewValueError(fmt.Sprintf("query param %q must have name and value of at most %d bytes", name, maxQueryParamLength), apperr.Caller(), urlErr.ErrInvalidRedirect)
+		}
+	}
+
+	return nil
+}
+
+// validRedirectCode reports whether code is a redirect status URLs may use.
+func validRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// validCacheControl reports whether value is empty or a comma separated list of Cache-Control directives.
+func validCacheControl(value string) bool {
+	if value == "" {
+		return true
+	}
+	if len(value) > maxCacheControlLength {
+		return false
+	}
+
+	for _, directive := range strings.Split(value, ",") {
+		if !cacheDirective.MatchString(strings.ToLower(strings.TrimSpace(directive))) {
+			return false
+		}
+	}
+
+	return true
+}
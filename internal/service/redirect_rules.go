@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// maxRedirectRules is a maximum number of redirect rules of URL.
+	maxRedirectRules = 20
+	// maxRuleValues is a maximum number of values of a rule condition.
+	maxRuleValues = 20
+	// ruleBuckets is a number of visitor buckets percentage splits are made of.
+	ruleBuckets = 100
+)
+
+var (
+	// languageTag matches a language tag of Accept-Language header, e.g. en or pt-BR.
+	languageTag = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+	// hostName matches a host name without port, e.g. news.example.com.
+	hostName = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,62}\.)*[A-Za-z0-9-]{1,63}$`)
+)
+
+// GetRules returns redirect rules of the user's URL in evaluation order.
+func (u *URLUseCase) GetRules(ctx context.Context, userID string, id string) ([]model.RedirectRule, error) {
+	url, err := u.repository.SelectByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	if url.UserID != userID {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s not found", id), apperr.Caller(), urlErr.ErrURLNotFound)
+	}
+
+	if url.DeletedFlag {
+		return nil, apperr.NewValueError(fmt.Sprintf("url with id %s is deleted", id), apperr.Caller(), urlErr.ErrURLDeleted)
+	}
+
+	return url.Rules, nil
+}
+
+// SetRules replaces redirect rules of the user's URL keeping their order, rules without IDs get new ones.
+func (u *URLUseCase) SetRules(ctx context.Context, userID string, id string, rules []model.RedirectRule) ([]model.RedirectRule, error) {
+	if len(rules) > maxRedirectRules {
+		return nil, apperr.NewValueError(fmt.Sprintf("at most %d rules are allowed", maxRedirectRules), apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	prepared := make([]model.RedirectRule, 0, len(rules))
+	ids := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = u.ruleID()
+		}
+		if _, ok := ids[rule.ID]; ok {
+			return nil, apperr.NewValueError(fmt.Sprintf("duplicated rule id %s", rule.ID), apperr.Caller(), urlErr.ErrInvalidRule)
+		}
+		ids[rule.ID] = struct{}{}
+
+		rule, err := prepareRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		prepared = append(prepared, rule)
+	}
+	if err := validateSplits(prepared); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	url, err := u.repository.UpdateRules(ctx, userID, id, func([]model.RedirectRule) ([]model.RedirectRule, error) {
+		return prepared, nil
+	}, u.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return url.Rules, nil
+}
+
+// AddRule appends a redirect rule with a new ID to rules of the user's URL.
+func (u *URLUseCase) AddRule(ctx context.Context, userID string, id string, rule model.RedirectRule) (*model.RedirectRule, error) {
+	rule.ID = u.ruleID()
+	rule, err := prepareRule(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	_, err = u.repository.UpdateRules(ctx, userID, id, func(rules []model.RedirectRule) ([]model.RedirectRule, error) {
+		if len(rules) >= maxRedirectRules {
+			return nil, apperr.NewValueError(fmt.Sprintf("at most %d rules are allowed", maxRedirectRules), apperr.Caller(), urlErr.ErrInvalidRule)
+		}
+		rules = append(rules, rule)
+		if err := validateSplits(rules); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}, u.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return &rule, nil
+}
+
+// UpdateRule replaces the redirect rule of the user's URL keeping its ID and position.
+func (u *URLUseCase) UpdateRule(ctx context.Context, userID string, id string, ruleID string, rule model.RedirectRule) (*model.RedirectRule, error) {
+	rule.ID = ruleID
+	rule, err := prepareRule(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	_, err = u.repository.UpdateRules(ctx, userID, id, func(rules []model.RedirectRule) ([]model.RedirectRule, error) {
+		i, err := ruleIndex(rules, ruleID)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+		if err := validateSplits(rules); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}, u.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return &rule, nil
+}
+
+// DeleteRule deletes the redirect rule of the user's URL.
+func (u *URLUseCase) DeleteRule(ctx context.Context, userID string, id string, ruleID string) error {
+	_, err := u.repository.UpdateRules(ctx, userID, id, func(rules []model.RedirectRule) ([]model.RedirectRule, error) {
+		i, err := ruleIndex(rules, ruleID)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Delete(rules, i, i+1), nil
+	}, u.now().UTC())
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
+// matchRule returns the first of the URL rules matching the visit, nil if none does.
+//
+// The visitor gets a single bucket of the URL, percentage rules take consecutive ranges of buckets in rules order,
+// e.g. two 50% rules split visitors in halves.
+func (u *URLUseCase) matchRule(url *model.URL, visit model.Visit) *model.RedirectRule {
+	at := u.now()
+	bucket := u.visitorBucket(url.ID, visit.ClientID)
+	offset := 0
+	for i, rule := range url.Rules {
+		if rule.Match(visit, at, bucket-offset) {
+			return &url.Rules[i]
+		}
+		offset += rule.Percent
+	}
+
+	return nil
+}
+
+// visitorBucket returns bucket of the visitor for percentage splits of the URL.
+//
+// Identified visitors always get the same bucket of a URL (so visitors sharing client IP behind NAT get the same variant),
+// buckets of anonymous visitors are random.
+func (u *URLUseCase) visitorBucket(urlID string, clientID string) int {
+	if clientID == "" {
+		return u.randomBucket()
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(urlID + "/" + clientID))
+	return int(hash.Sum32() % ruleBuckets)
+}
+
+// validateSplits checks that percentage rules share at most all visitor buckets.
+func validateSplits(rules []model.RedirectRule) error {
+	total := 0
+	for _, rule := range rules {
+		total += rule.Percent
+	}
+	if total > ruleBuckets {
+		return apperr.NewValueError(fmt.Sprintf("percents of rules must sum up to at most %d", ruleBuckets), apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	return nil
+}
+
+// ruleIndex returns index of the rule with the ID.
+func ruleIndex(rules []model.RedirectRule, ruleID string) (int, error) {
+	i := slices.IndexFunc(rules, func(rule model.RedirectRule) bool { return rule.ID == ruleID })
+	if i < 0 {
+		return 0, apperr.NewValueError(fmt.Sprintf("rule with id %s not found", ruleID), apperr.Caller(), urlErr.ErrRuleNotFound)
+	}
+
+	return i, nil
+}
+
+// prepareRule validates the redirect rule and returns it with lower-cased conditions and time window in UTC.
+func prepareRule(rule model.RedirectRule) (model.RedirectRule, error) {
+	if _, err := url.ParseRequestURI(rule.Destination); err != nil {
+		return rule, apperr.NewValueError("destination must be valid URL", apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	if len(rule.Devices) > maxRuleValues || len(rule.Languages) > maxRuleValues || len(rule.ReferrerHosts) > maxRuleValues {
+		return rule, apperr.NewValueError(fmt.Sprintf("conditions must have at most %d values", maxRuleValues), apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	rule.Devices = lowerAll(rule.Devices)
+	for _, device := range rule.Devices {
+		if !slices.Contains(model.Devices, device) {
+			return rule, apperr.NewValueError(fmt.Sprintf("device must be one of %s", strings.Join(model.Devices, ", ")), apperr.Caller(), urlErr.ErrInvalidRule)
+		}
+	}
+
+	rule.Languages = lowerAll(rule.Languages)
+	for _, language := range rule.Languages {
+		if !languageTag.MatchString(language) {
+			return rule, apperr.NewValueError(fmt.Sprintf("invalid language %q", language), apperr.Caller(), urlErr.ErrInvalidRule)
+		}
+	}
+
+	rule.ReferrerHosts = lowerAll(rule.ReferrerHosts)
+	for _, host := range rule.ReferrerHosts {
+		if len(host) > 253 || !hostName.MatchString(host) {
+			return rule, apperr.NewValueError(fmt.Sprintf("invalid referrer host %q", host), apperr.Caller(), urlErr.ErrInvalidRule)
+		}
+	}
+
+	if rule.From != nil {
+		from := rule.From.UTC()
+		rule.From = &from
+	}
+	if rule.Until != nil {
+		until := rule.Until.UTC()
+		rule.Until = &until
+	}
+	if rule.From != nil && rule.Until != nil && !rule.From.Before(*rule.Until) {
+		return rule, apperr.NewValueError("from must be before until", apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	if rule.Percent < 0 || rule.Percent > ruleBuckets {
+		return rule, apperr.NewValueError(fmt.Sprintf("percent must be between 0 and %d", ruleBuckets), apperr.Caller(), urlErr.ErrInvalidRule)
+	}
+
+	return rule, nil
+}
+
+// lowerAll returns lower-cased copy of the values, nil for no values.
+func lowerAll(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
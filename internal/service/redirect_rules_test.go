@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+
+	mock "github.com/msmkdenis/yap-shortener/internal/mocks"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+const (
+	iPhoneUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUserAgent = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	desktopUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+type RulesTestSuite struct {
+	suite.Suite
+	urlRepository *mock.MockURLRepository
+	urlService    *URLUseCase
+	bucket        int
+}
+
+func TestRulesSuite(t *testing.T) {
+	suite.Run(t, new(RulesTestSuite))
+}
+
+func (r *RulesTestSuite) SetupTest() {
+	r.urlRepository = mock.NewMockURLRepository(gomock.NewController(r.T()))
+	r.urlService = NewURLService(r.urlRepository, 0, RedirectConfig{}, nil, zap.NewNop())
+	r.urlService.now = func() time.Time { return testTime }
+	ids := 0
+	r.urlService.ruleID = func() string {
+		ids++
+		return fmt.Sprintf("rule-%d", ids)
+	}
+	r.bucket = 0
+	r.urlService.randomBucket = func() int { return r.bucket }
+}
+
+// expectUpdateRules expects UpdateRules of the user's URL with the current rules and returns rules saved by it.
+func (r *RulesTestSuite) expectUpdateRules(current []model.RedirectRule) *[]model.RedirectRule {
+	var saved []model.RedirectRule
+	r.urlRepository.EXPECT().UpdateRules(gomock.Any(), "user", "id", gomock.Any(), testTime).DoAndReturn(
+		func(_ context.Context, _ string, _ string, update func([]model.RedirectRule) ([]model.RedirectRule, error), _ time.Time) (*model.URL, error) {
+			rules, err := update(slices.Clone(current))
+			if err != nil {
+				return nil, err
+			}
+			saved = rules
+			return &model.URL{ID: "id", UserID: "user", Rules: rules}, nil
+		})
+	return &saved
+}
+
+func (r *RulesTestSuite) TestGetRedirect_Rules() {
+	launch := testTime.Add(time.Hour)
+	url := model.URL{
+		Original: "http://example.com",
+		Rules: []model.RedirectRule{
+			{ID: "ios", Destination: "https://apps.apple.com/app/id1", Devices: []string{model.DeviceIOS}},
+			{ID: "android", Destination: "https://play.google.com/store/apps/details?id=app", Devices: []string{model.DeviceAndroid}},
+			{ID: "launch", Destination: "http://example.com/launch", From: &launch},
+			{ID: "de", Destination: "http://example.com/de", Languages: []string{"de"}},
+			{ID: "news", Destination: "http://example.com/news", ReferrerHosts: []string{"news.example.org"}},
+			{ID: "split", Destination: "http://example.com/b", Percent: 50},
+		},
+		QueryRules: model.QueryRules{Params: map[string]string{"utm_source": "short"}},
+	}
+
+	testCases := []struct {
+		name             string
+		visit            model.Visit
+		at               time.Time
+		bucket           int
+		expectedLocation string
+	}{
+		{
+			name:             "iOS",
+			visit:            model.Visit{UserAgent: iPhoneUserAgent},
+			expectedLocation: "https://apps.apple.com/app/id1?utm_source=short",
+		},
+		{
+			name:             "Android",
+			visit:            model.Visit{UserAgent: androidUserAgent},
+			expectedLocation: "https://play.google.com/store/apps/details?id=app&utm_source=short",
+		},
+		{
+			name:             "No rule matches",
+			visit:            model.Visit{UserAgent: desktopUserAgent},
+			bucket:           50,
+			expectedLocation: "http://example.com?utm_source=short",
+		},
+		{
+			name:             "After launch",
+			visit:            model.Visit{UserAgent: desktopUserAgent, AcceptLanguage: "de"},
+			at:               launch,
+			expectedLocation: "http://example.com/launch?utm_source=short",
+		},
+		{
+			name:             "Preferred language",
+			visit:            model.Visit{AcceptLanguage: "en;q=0.5, de-AT, fr;q=0.8"},
+			bucket:           50,
+			expectedLocation: "http://example.com/de?utm_source=short",
+		},
+		{
+			name:             "Not preferred language",
+			visit:            model.Visit{AcceptLanguage: "en-US, de;q=0.9"},
+			bucket:           50,
+			expectedLocation: "http://example.com?utm_source=short",
+		},
+		{
+			name:             "Referrer subdomain",
+			visit:            model.Visit{Referrer: "https://www.news.example.org/post/1"},
+			bucket:           50,
+			expectedLocation: "http://example.com/news?utm_source=short",
+		},
+		{
+			name:             "Referrer of other host",
+			visit:            model.Visit{Referrer: "https://fakenews.example.org/"},
+			bucket:           50,
+			expectedLocation: "http://example.com?utm_source=short",
+		},
+		{
+			name:             "Anonymous visitor in split",
+			bucket:           49,
+			expectedLocation: "http://example.com/b?utm_source=short",
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			at := testTime
+			if !test.at.IsZero() {
+				at = test.at
+			}
+			r.urlService.now = func() time.Time { return at }
+			r.bucket = test.bucket
+			stored := url
+			r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&stored, nil)
+
+			redirect, err := r.urlService.GetRedirect(context.Background(), "id", test.visit)
+			r.Require().NoError(err)
+			r.Equal(test.expectedLocation, redirect.Location)
+		})
+	}
+}
+
+func (r *RulesTestSuite) TestGetRedirect_RuleWindow() {
+	from, until := testTime, testTime.Add(time.Hour)
+	url := model.URL{
+		Original: "http://example.com",
+		Rules:    []model.RedirectRule{{ID: "sale", Destination: "http://example.com/sale", From: &from, Until: &until}},
+	}
+
+	for at, expected := range map[time.Time]string{
+		testTime.Add(-time.Second):  "http://example.com",
+		testTime:                    "http://example.com/sale",
+		until.Add(-time.Nanosecond): "http://example.com/sale",
+		until:                       "http://example.com",
+	} {
+		r.urlService.now = func() time.Time { return at }
+		stored := url
+		r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&stored, nil)
+
+		redirect, err := r.urlService.GetRedirect(context.Background(), "id", model.Visit{})
+		r.Require().NoError(err)
+		r.Equalf(expected, redirect.Location, "visit at %s", at)
+	}
+}
+
+func (r *RulesTestSuite) TestVisitorBucket() {
+	r.urlService.randomBucket = func() int {
+		r.Fail("identified visitors must not get random buckets")
+		return 0
+	}
+
+	matched := 0
+	split := model.RedirectRule{ID: "split", Destination: "http://example.com/b", Percent: 30}
+	for i := 0; i < 1000; i++ {
+		clientID := fmt.Sprintf("192.0.2.%d/%d", i%256, i)
+		bucket := r.urlService.visitorBucket("id", clientID)
+		r.Equal(bucket, r.urlService.visitorBucket("id", clientID), "visitor must always get the same bucket")
+		r.GreaterOrEqual(bucket, 0)
+		r.Less(bucket, ruleBuckets)
+		if split.Match(model.Visit{ClientID: clientID}, testTime, bucket) {
+			matched++
+		}
+	}
+	r.InDelta(300, matched, 60, "share of matched visitors must be close to percent")
+}
+
+func (r *RulesTestSuite) TestGetRules() {
+	testCases := []struct {
+		name          string
+		url           *model.URL
+		err           error
+		expectedRules []model.RedirectRule
+		expectedError error
+	}{
+		{
+			name:          "Rules of the user",
+			url:           &model.URL{UserID: "user", Rules: []model.RedirectRule{{ID: "r1", Destination: "http://example.com/1"}}},
+			expectedRules: []model.RedirectRule{{ID: "r1", Destination: "http://example.com/1"}},
+		},
+		{
+			name:          "URL of other user",
+			url:           &model.URL{UserID: "other", Rules: []model.RedirectRule{{ID: "r1", Destination: "http://example.com/1"}}},
+			expectedError: urlErr.ErrURLNotFound,
+		},
+		{
+			name:          "Deleted",
+			url:           &model.URL{UserID: "user", DeletedFlag: true},
+			expectedError: urlErr.ErrURLDeleted,
+		},
+		{
+			name:          "Not found",
+			err:           urlErr.ErrURLNotFound,
+			expectedError: urlErr.ErrURLNotFound,
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(test.url, test.err)
+
+			rules, err := r.urlService.GetRules(context.Background(), "user", "id")
+			r.ErrorIs(err, test.expectedError)
+			r.Equal(test.expectedRules, rules)
+		})
+	}
+}
+
+func (r *RulesTestSuite) TestSetRules() {
+	saved := r.expectUpdateRules([]model.RedirectRule{{ID: "old", Destination: "http://example.com/old"}})
+
+	rules, err := r.urlService.SetRules(context.Background(), "user", "id", []model.RedirectRule{
+		{ID: "kept", Destination: "http://example.com/1", Devices: []string{"iOS"}},
+		{Destination: "http://example.com/2", Languages: []string{"PT-br"}, ReferrerHosts: []string{"News.Example.com"}},
+	})
+	r.Require().NoError(err)
+
+	expected := []model.RedirectRule{
+		{ID: "kept", Destination: "http://example.com/1", Devices: []string{"ios"}},
+		{ID: "rule-1", Destination: "http://example.com/2", Languages: []string{"pt-br"}, ReferrerHosts: []string{"news.example.com"}},
+	}
+	r.Equal(expected, rules)
+	r.Equal(expected, *saved)
+}
+
+func (r *RulesTestSuite) TestSetRules_Invalid() {
+	tooMany := make([]model.RedirectRule, maxRedirectRules+1)
+	for i := range tooMany {
+		tooMany[i] = model.RedirectRule{Destination: "http://example.com"}
+	}
+
+	for name, rules := range map[string][]model.RedirectRule{
+		"Too many rules":  tooMany,
+		"Duplicated IDs":  {{ID: "r1", Destination: "http://example.com/1"}, {ID: "r1", Destination: "http://example.com/2"}},
+		"Invalid rule":    {{Destination: "http://example.com/1"}, {Destination: "not a url"}},
+		"Splits over 100": {{Destination: "http://example.com/1", Percent: 60}, {Destination: "http://example.com/2", Percent: 50}},
+	} {
+		r.Run(name, func() {
+			_, err := r.urlService.SetRules(context.Background(), "user", "id", rules)
+			r.ErrorIs(err, urlErr.ErrInvalidRule)
+		})
+	}
+}
+
+func (r *RulesTestSuite) TestAddRule() {
+	current := []model.RedirectRule{{ID: "r1", Destination: "http://example.com/1"}}
+	saved := r.expectUpdateRules(current)
+
+	rule, err := r.urlService.AddRule(context.Background(), "user", "id", model.RedirectRule{ID: "ignored", Destination: "http://example.com/2", Percent: 10})
+	r.Require().NoError(err)
+
+	expected := model.RedirectRule{ID: "rule-1", Destination: "http://example.com/2", Percent: 10}
+	r.Equal(&expected, rule)
+	r.Equal(append(current, expected), *saved)
+}
+
+func (r *RulesTestSuite) TestAddRule_SplitsOver100() {
+	r.expectUpdateRules([]model.RedirectRule{{ID: "r1", Destination: "http://example.com/1", Percent: 60}})
+
+	_, err := r.urlService.AddRule(context.Background(), "user", "id", model.RedirectRule{Destination: "http://example.com/2", Percent: 50})
+	r.ErrorIs(err, urlErr.ErrInvalidRule)
+}
+
+func (r *RulesTestSuite) TestAddRule_TooMany() {
+	current := make([]model.RedirectRule, maxRedirectRules)
+	r.expectUpdateRules(current)
+
+	_, err := r.urlService.AddRule(context.Background(), "user", "id", model.RedirectRule{Destination: "http://example.com"})
+	r.ErrorIs(err, urlErr.ErrInvalidRule)
+}
+
+func (r *RulesTestSuite) TestUpdateRule() {
+	current := []model.RedirectRule{
+		{ID: "r1", Destination: "http://example.com/1"},
+		{ID: "r2", Destination: "http://example.com/2"},
+		{ID: "r3", Destination: "http://example.com/3"},
+	}
+	saved := r.expectUpdateRules(current)
+
+	rule, err := r.urlService.UpdateRule(context.Background(), "user", "id", "r2", model.RedirectRule{Destination: "http://example.com/new", Devices: []string{"android"}})
+	r.Require().NoError(err)
+
+	expected := model.RedirectRule{ID: "r2", Destination: "http://example.com/new", Devices: []string{"android"}}
+	r.Equal(&expected, rule)
+	r.Equal([]model.RedirectRule{current[0], expected, current[2]}, *saved)
+
+	r.expectUpdateRules(current)
+	_, err = r.urlService.UpdateRule(context.Background(), "user", "id", "unknown", model.RedirectRule{Destination: "http://example.com/new"})
+	r.ErrorIs(err, urlErr.ErrRuleNotFound)
+}
+
+func (r *RulesTestSuite) TestDeleteRule() {
+	current := []model.RedirectRule{
+		{ID: "r1", Destination: "http://example.com/1"},
+		{ID: "r2", Destination: "http://example.com/2"},
+	}
+	saved := r.expectUpdateRules(current)
+
+	r.Require().NoError(r.urlService.DeleteRule(context.Background(), "user", "id", "r1"))
+	r.Equal(current[1:], *saved)
+
+	r.expectUpdateRules(current)
+	r.ErrorIs(r.urlService.DeleteRule(context.Background(), "user", "id", "unknown"), urlErr.ErrRuleNotFound)
+
+	r.urlRepository.EXPECT().UpdateRules(gomock.Any(), "user", "id", gomock.Any(), testTime).Return(nil, urlErr.ErrURLDeleted)
+	r.ErrorIs(r.urlService.DeleteRule(context.Background(), "user", "id", "r1"), urlErr.ErrURLDeleted)
+}
+
+func (r *RulesTestSuite) TestPrepareRule() {
+	from, until := testTime, testTime.Add(time.Hour)
+	tooManyDevices := make([]string, maxRuleValues+1)
+	for i := range tooManyDevices {
+		tooManyDevices[i] = model.DeviceIOS
+	}
+
+	testCases := []struct {
+		name  string
+		rule  model.RedirectRule
+		valid bool
+	}{
+		{name: "Only destination", rule: model.RedirectRule{Destination: "http://example.com"}, valid: true},
+		{name: "App scheme destination", rule: model.RedirectRule{Destination: "market://details?id=app"}, valid: true},
+		{name: "All conditions", rule: model.RedirectRule{
+			Destination:   "http://example.com",
+			Devices:       []string{"ios", "Other"},
+			Languages:     []string{"en", "zh-Hant-TW"},
+			ReferrerHosts: []string{"t.co", "news.example.com"},
+			From:          &from,
+			Until:         &until,
+			Percent:       100,
+		}, valid: true},
+		{name: "Empty destination", rule: model.RedirectRule{}},
+		{name: "Relative destination", rule: model.RedirectRule{Destination: "example.com"}},
+		{name: "Unknown device", rule: model.RedirectRule{Destination: "http://example.com", Devices: []string{"blackberry"}}},
+		{name: "Too many values", rule: model.RedirectRule{Destination: "http://example.com", Devices: tooManyDevices}},
+		{name: "Invalid language", rule: model.RedirectRule{Destination: "http://example.com", Languages: []string{"en_US"}}},
+		{name: "Empty language", rule: model.RedirectRule{Destination: "http://example.com", Languages: []string{""}}},
+		{name: "Referrer with port", rule: model.RedirectRule{Destination: "http://example.com", ReferrerHosts: []string{"example.com:443"}}},
+		{name: "Referrer URL", rule: model.RedirectRule{Destination: "http://example.com", ReferrerHosts: []string{"https://example.com"}}},
+		{name: "Empty window", rule: model.RedirectRule{Destination: "http://example.com", From: &until, Until: &from}},
+		{name: "Negative percent", rule: model.RedirectRule{Destination: "http://example.com", Percent: -1}},
+		{name: "Percent over 100", rule: model.RedirectRule{Destination: "http://example.com", Percent: 101}},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			_, err := prepareRule(test.rule)
+			if test.valid {
+				r.NoError(err)
+			} else {
+				r.ErrorIs(err, urlErr.ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func (r *RulesTestSuite) TestDeviceFamily() {
+	for userAgent, expected := range map[string]string{
+		iPhoneUserAgent: model.DeviceIOS,
+		"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)": model.DeviceIOS,
+		androidUserAgent: model.DeviceAndroid,
+		desktopUserAgent: model.DeviceWindows,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Safari/605.1.15": model.DeviceMacOS,
+		"Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0":                model.DeviceLinux,
+		"curl/8.4.0": model.DeviceOther,
+		"":           model.DeviceOther,
+	} {
+		r.Equalf(expected, model.DeviceFamily(userAgent), "user agent %q", userAgent)
+	}
+}
+
+func (r *RulesTestSuite) TestGetRedirect_Splits() {
+	url := model.URL{
+		ID:       "id",
+		Original: "http://example.com",
+		Rules: []model.RedirectRule{
+			{ID: "a", Destination: "http://example.com/a", Percent: 50},
+			{ID: "ios", Destination: "http://example.com/ios", Devices: []string{model.DeviceIOS}},
+			{ID: "b", Destination: "http://example.com/b", Percent: 50},
+		},
+	}
+
+	locations := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		stored := url
+		r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&stored, nil)
+
+		redirect, err := r.urlService.GetRedirect(context.Background(), "id", model.Visit{ClientID: fmt.Sprintf("192.0.2.%d/%d", i%256, i)})
+		r.Require().NoError(err)
+		locations[redirect.Location]++
+	}
+
+	r.InDelta(500, locations["http://example.com/a"], 60, "the first split must get its percent of visitors")
+	r.InDelta(500, locations["http://example.com/b"], 60, "the second split must get its percent of visitors")
+	r.Zero(locations["http://example.com"], "splits of all visitors must leave none to the original URL")
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+
+	mock "github.com/msmkdenis/yap-shortener/internal/mocks"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+)
+
+type RedirectTestSuite struct {
+	suite.Suite
+	urlRepository *mock.MockURLRepository
+	urlService    *URLUseCase
+}
+
+func TestRedirectSuite(t *testing.T) {
+	suite.Run(t, new(RedirectTestSuite))
+}
+
+func (r *RedirectTestSuite) SetupTest() {
+	r.urlRepository = mock.NewMockURLRepository(gomock.NewController(r.T()))
+	r.urlService = NewURLService(r.urlRepository, 0, RedirectConfig{Code: 302, CacheControl: "no-store"}, nil, zap.NewNop())
+	r.urlService.now = func() time.Time { return testTime }
+}
+
+func (r *RedirectTestSuite) TestGetRedirect() {
+	testCases := []struct {
+		name             string
+		url              model.URL
+		config           RedirectConfig
+		expectedRedirect *model.Redirect
+		expectedError    error
+	}{
+		{
+			name:             "Server defaults",
+			url:              model.URL{Original: "http://example.com"},
+			config:           RedirectConfig{Code: 302, CacheControl: "no-store"},
+			expectedRedirect: &model.Redirect{Location: "http://example.com", Code: 302, CacheControl: "no-store"},
+		},
+		{
+			name:             "URL settings",
+			url:              model.URL{Original: "http://example.com", RedirectCode: 308, CacheControl: "public, max-age=86400"},
+			config:           RedirectConfig{Code: 302, CacheControl: "no-store"},
+			expectedRedirect: &model.Redirect{Location: "http://example.com", Code: 308, CacheControl: "public, max-age=86400"},
+		},
+		{
+			name:             "No defaults",
+			url:              model.URL{Original: "http://example.com"},
+			expectedRedirect: &model.Redirect{Location: "http://example.com", Code: DefaultRedirectCode},
+		},
+		{
+			name: "Query rules",
+			url: model.URL{
+				Original:   "http://example.com/?utm_source=direct",
+				QueryRules: model.QueryRules{Params: map[string]string{"utm_source": "short"}},
+			},
+			expectedRedirect: &model.Redirect{Location: "http://example.com/?utm_source=short", Code: DefaultRedirectCode, VisitorDependent: true},
+		},
+		{
+			name: "Redirect rules",
+			url: model.URL{
+				Original:     "http://example.com",
+				CacheControl: "public, max-age=86400",
+				Rules:        []model.RedirectRule{{ID: "rule", Destination: "http://example.org", Devices: []string{model.DeviceIOS}}},
+			},
+			expectedRedirect: &model.Redirect{Location: "http://example.com", Code: DefaultRedirectCode, CacheControl: "public, max-age=86400", VisitorDependent: true},
+		},
+		{
+			name:             "Country access",
+			url:              model.URL{Original: "http://example.com", CountryAccess: model.CountryAccess{Deny: []string{"KP"}}},
+			expectedRedirect: &model.Redirect{Location: "http://example.com", Code: DefaultRedirectCode, VisitorDependent: true},
+		},
+		{
+			name:          "Deleted",
+			url:           model.URL{Original: "http://example.com", DeletedFlag: true},
+			expectedError: urlErr.ErrURLDeleted,
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			r.urlService.redirect = test.config
+			url := test.url
+			r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(&url, nil)
+
+			redirect, err := r.urlService.GetRedirect(context.Background(), "id", model.Visit{})
+			r.ErrorIs(err, test.expectedError)
+			r.Equal(test.expectedRedirect, redirect)
+		})
+	}
+}
+
+func (r *RedirectTestSuite) TestGetRedirect_NotFound() {
+	r.urlRepository.EXPECT().SelectByID(gomock.Any(), "id").Return(nil, urlErr.ErrURLNotFound)
+
+	_, err := r.urlService.GetRedirect(context.Background(), "id", model.Visit{})
+	r.ErrorIs(err, urlErr.ErrURLNotFound)
+}
+
+func (r *RedirectTestSuite) TestUpdateRedirect() {
+	updated := &model.URL{ID: "id", RedirectCode: 301, CacheControl: "public, max-age=3600"}
+	settings := model.RedirectSettings{
+		Code:         301,
+		CacheControl: "public, max-age=3600",
+		QueryRules:   model.QueryRules{Params: map[string]string{"utm_source": "{ref}"}, PassThrough: true},
+	}
+	tooManyParams := make(map[string]string)
+	for i := 0; i <= maxQueryParams; i++ {
+		tooManyParams[fmt.Sprintf("p%d", i)] = "v"
+	}
+
+	testCases := []struct {
+		name          string
+		settings      model.RedirectSettings
+		prepare       func()
+		expectedURL   *model.URL
+		expectedError error
+	}{
+		{
+			name:     "Successful update",
+			settings: settings,
+			prepare: func() {
+				r.urlRepository.EXPECT().UpdateRedirect(gomock.Any(), "user", "id", settings, testTime).Return(updated, nil)
+			},
+			expectedURL: updated,
+		},
+		{
+			name:          "Invalid code",
+			settings:      model.RedirectSettings{Code: 200},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Invalid cache control",
+			settings:      model.RedirectSettings{CacheControl: "max-age=1\r\nSet-Cookie: a=b"},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Too many query params",
+			settings:      model.RedirectSettings{QueryRules: model.QueryRules{Params: tooManyParams}},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Empty query param name",
+			settings:      model.RedirectSettings{QueryRules: model.QueryRules{Params: map[string]string{"": "v"}}},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name:          "Too long query param value",
+			settings:      model.RedirectSettings{QueryRules: model.QueryRules{Params: map[string]string{"p": strings.Repeat("v", maxQueryParamLength+1)}}},
+			expectedError: urlErr.ErrInvalidRedirect,
+		},
+		{
+			name: "Repository error",
+			prepare: func() {
+				r.urlRepository.EXPECT().UpdateRedirect(gomock.Any(), "user", "id", model.RedirectSettings{}, testTime).Return(nil, urlErr.ErrURLDeleted)
+			},
+			expectedError: urlErr.ErrURLDeleted,
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			if test.prepare != nil {
+				test.prepare()
+			}
+
+			url, err := r.urlService.UpdateRedirect(context.Background(), "user", "id", test.settings)
+			r.ErrorIs(err, test.expectedError)
+			r.Equal(test.expectedURL, url)
+		})
+	}
+}
+
+func (r *RedirectTestSuite) TestApplyQueryRules() {
+	visit := model.Visit{
+		Query:   url.Values{"ref": {"newsletter"}, "utm_source": {"visitor"}, "tag": {"a", "b"}},
+		Country: "DE",
+	}
+
+	testCases := []struct {
+		name     string
+		original string
+		rules    model.QueryRules
+		visit    model.Visit
+		expected string
+	}{
+		{
+			name:     "No rules",
+			original: "http://example.com/path?b=2&a=1",
+			visit:    visit,
+			expected: "http://example.com/path?b=2&a=1",
+		},
+		{
+			name:     "Pass through",
+			original: "http://example.com/path?a=1&tag=x",
+			rules:    model.QueryRules{PassThrough: true},
+			visit:    visit,
+			expected: "http://example.com/path?a=1&ref=newsletter&tag=a&tag=b&utm_source=visitor",
+		},
+		{
+			name:     "Static params override passed through ones",
+			original: "http://example.com/path",
+			rules:    model.QueryRules{Params: map[string]string{"utm_source": "short", "utm_medium": "link"}, PassThrough: true},
+			visit:    visit,
+			expected: "http://example.com/path?ref=newsletter&tag=a&tag=b&utm_medium=link&utm_source=short",
+		},
+		{
+			name:     "Placeholders",
+			original: "http://example.com/path#top",
+			rules:    model.QueryRules{Params: map[string]string{"utm_campaign": "{ref}-{country}", "unknown": "{missing}"}},
+			visit:    visit,
+			expected: "http://example.com/path?utm_campaign=newsletter-DE#top",
+		},
+		{
+			name:     "Placeholders without visit",
+			original: "http://example.com/path",
+			rules:    model.QueryRules{Params: map[string]string{"utm_campaign": "{ref}", "utm_medium": "link"}},
+			expected: "http://example.com/path?utm_medium=link",
+		},
+		{
+			name:     "Unparsable original",
+			original: "http://example.com/%zz",
+			rules:    model.QueryRules{PassThrough: true},
+			visit:    visit,
+			expected: "http://example.com/%zz",
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			r.Equal(test.expected, applyQueryRules(test.original, test.rules, test.visit))
+		})
+	}
+}
+
+func (r *RedirectTestSuite) TestValidCacheControl() {
+	for value, expected := range map[string]bool{
+		"":                                 true,
+		"no-store":                         true,
+		"public, max-age=86400, immutable": true,
+		"Private,No-Cache":                 true,
+		"max-age=":                         false,
+		"max-age=-1":                       false,
+		"no-store,":                        false,
+		"max-age=1\r\nSet-Cookie: a=b":     false,
+		"public, max-age=\"1\"":            false,
+		strings.Repeat("no-store, ", 30):   false,
+	} {
+		r.Equalf(expected, validCacheControl(value), "cache control %q", value)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// DefaultRetentionInterval is a mean interval between retention runs.
+	DefaultRetentionInterval = time.Hour
+	// DefaultRetentionBatchSize is a number of URLs purged at once.
+	DefaultRetentionBatchSize = 1000
+	// RetentionLockKey is a key of the lock guarding retention runs.
+	RetentionLockKey int64 = 0x73686f72745f7274
+	// retentionJitter is a share of the interval runs are randomly shifted by, so replicas do not run together.
+	retentionJitter = 0.2
+)
+
+// Locker represents a lock shared by service replicas.
+type Locker interface {
+	// TryLock acquires the lock if it is free, unlock releases it.
+	TryLock(ctx context.Context) (unlock func(), acquired bool, err error)
+}
+
+// RetentionConfig represents retention settings, zero Interval and BatchSize are replaced with defaults.
+type RetentionConfig struct {
+	// Period is how long soft-deleted URLs are kept, zero disables retention.
+	Period    time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Retention permanently purges URLs soft-deleted longer than the retention period.
+type Retention struct {
+	repository URLRepository
+	locker     Locker
+	cfg        RetentionConfig
+	now        func() time.Time
+	jitter     func(time.Duration) time.Duration
+	logger     *zap.Logger
+}
+
+// NewRetention initializes a new Retention, locker may be nil when the repository is not shared by replicas.
+func NewRetention(repository URLRepository, locker Locker, cfg RetentionConfig, logger *zap.Logger) *Retention {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRetentionInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRetentionBatchSize
+	}
+
+	return &Retention{
+		repository: repository,
+		locker:     locker,
+		cfg:        cfg,
+		now:        time.Now,
+		jitter:     jitter,
+		logger:     logger,
+	}
+}
+
+// Run purges URLs at jittered intervals until the context is canceled.
+func (r *Retention) Run(ctx context.Context) {
+	if r.cfg.Period <= 0 {
+		r.logger.Info("Retention is disabled")
+		return
+	}
+
+	r.logger.Info("Retention started", zap.Duration("period", r.cfg.Period), zap.Duration("interval", r.cfg.Interval))
+	timer := time.NewTimer(r.jitter(r.cfg.Interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Retention stopped")
+			return
+		case <-timer.C:
+		}
+
+		purged, err := r.Purge(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("Retention failed", zap.Int("purged", purged), zap.Error(err))
+		} else if purged > 0 {
+			r.logger.Info("Retention purged URLs", zap.Int("purged", purged))
+		}
+
+		timer.Reset(r.jitter(r.cfg.Interval))
+	}
+}
+
+// Purge purges in batches URLs soft-deleted longer than the retention period and returns their number.
+//
+// Nothing is purged if the lock is held by another replica, batches stop when the context is canceled.
+func (r *Retention) Purge(ctx context.Context) (int, error) {
+	if r.locker != nil {
+		unlock, acquired, err := r.locker.TryLock(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		if !acquired {
+			r.logger.Info("Retention is run by another replica")
+			return 0, nil
+		}
+		defer unlock()
+	}
+
+	before := r.now().Add(-r.cfg.Period)
+	total := 0
+	for ctx.Err() == nil {
+		purged, err := r.repository.PurgeDeleted(ctx, before, r.cfg.BatchSize)
+		total += purged
+		if err != nil {
+			return total, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		if purged < r.cfg.BatchSize {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// jitter returns the interval randomly shifted by up to half of retentionJitter share in both directions.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(float64(interval) * retentionJitter)
+	if spread <= 0 {
+		return interval
+	}
+
+	return interval - time.Duration(spread/2) + time.Duration(rand.Int63n(spread))
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+
+	mock "github.com/msmkdenis/yap-shortener/internal/mocks"
+)
+
+type stubLocker struct {
+	acquired bool
+	err      error
+	unlocked int
+}
+
+func (l *stubLocker) TryLock(context.Context) (func(), bool, error) {
+	if l.err != nil || !l.acquired {
+		return nil, false, l.err
+	}
+	return func() { l.unlocked++ }, true, nil
+}
+
+type RetentionTestSuite struct {
+	suite.Suite
+	urlRepository *mock.MockURLRepository
+	locker        *stubLocker
+	retention     *Retention
+}
+
+func TestRetentionSuite(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
+
+func (r *RetentionTestSuite) SetupTest() {
+	r.urlRepository = mock.NewMockURLRepository(gomock.NewController(r.T()))
+	r.locker = &stubLocker{acquired: true}
+	r.retention = NewRetention(r.urlRepository, r.locker, RetentionConfig{Period: 24 * time.Hour, BatchSize: 2}, zap.NewNop())
+	r.retention.now = func() time.Time { return testTime }
+}
+
+func (r *RetentionTestSuite) TestNewRetention_Defaults() {
+	retention := NewRetention(r.urlRepository, nil, RetentionConfig{Period: time.Hour}, zap.NewNop())
+	r.Equal(RetentionConfig{Period: time.Hour, Interval: DefaultRetentionInterval, BatchSize: DefaultRetentionBatchSize}, retention.cfg)
+}
+
+func (r *RetentionTestSuite) TestPurge() {
+	before := testTime.Add(-24 * time.Hour)
+	repoErr := errors.New("repository error")
+
+	testCases := []struct {
+		name            string
+		locker          *stubLocker
+		prepare         func()
+		expectedPurged  int
+		expectedError   error
+		expectedUnlocks int
+	}{
+		{
+			name:   "Purges in batches until batch is not full",
+			locker: &stubLocker{acquired: true},
+			prepare: func() {
+				gomock.InOrder(
+					r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), before, 2).Return(2, nil),
+					r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), before, 2).Return(2, nil),
+					r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), before, 2).Return(1, nil),
+				)
+			},
+			expectedPurged:  5,
+			expectedUnlocks: 1,
+		},
+		{
+			name:   "Lock is held by another replica",
+			locker: &stubLocker{acquired: false},
+			prepare: func() {
+				r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:   "Lock error",
+			locker: &stubLocker{err: repoErr},
+			prepare: func() {
+				r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedError: repoErr,
+		},
+		{
+			name:   "Repository error",
+			locker: &stubLocker{acquired: true},
+			prepare: func() {
+				gomock.InOrder(
+					r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), before, 2).Return(2, nil),
+					r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), before, 2).Return(0, repoErr),
+				)
+			},
+			expectedPurged:  2,
+			expectedError:   repoErr,
+			expectedUnlocks: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		r.Run(test.name, func() {
+			r.retention.locker = test.locker
+			test.prepare()
+
+			purged, err := r.retention.Purge(context.Background())
+			r.ErrorIs(err, test.expectedError)
+			r.Equal(test.expectedPurged, purged)
+			r.Equal(test.expectedUnlocks, test.locker.unlocked)
+		})
+	}
+}
+
+func (r *RetentionTestSuite) TestPurge_StopsOnCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), 2).DoAndReturn(
+		func(context.Context, time.Time, int) (int, error) {
+			cancel()
+			return 2, nil
+		})
+
+	purged, err := r.retention.Purge(ctx)
+	r.NoError(err)
+	r.Equal(2, purged)
+	r.Equal(1, r.locker.unlocked)
+}
+
+func (r *RetentionTestSuite) TestRun() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.retention.jitter = func(time.Duration) time.Duration { return time.Millisecond }
+	r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), 2).Return(1, nil)
+	r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), 2).DoAndReturn(
+		func(context.Context, time.Time, int) (int, error) {
+			cancel()
+			return 0, nil
+		})
+
+	done := make(chan struct{})
+	go func() {
+		r.retention.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		r.Fail("retention must stop on cancel")
+	}
+	r.Equal(2, r.locker.unlocked)
+}
+
+func (r *RetentionTestSuite) TestRun_Disabled() {
+	r.retention.cfg.Period = 0
+	r.urlRepository.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	r.retention.Run(context.Background())
+}
+
+func (r *RetentionTestSuite) TestJitter() {
+	for i := 0; i < 100; i++ {
+		interval := jitter(time.Hour)
+		r.GreaterOrEqual(interval, 54*time.Minute)
+		r.Less(interval, 66*time.Minute)
+	}
+	r.Equal(time.Duration(1), jitter(1))
+}
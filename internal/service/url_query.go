@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/msmkdenis/yap-shortener/internal/dto"
+	"github.com/msmkdenis/yap-shortener/internal/model"
+	urlErr "github.com/msmkdenis/yap-shortener/internal/urlerr"
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	// DefaultPageLimit is a number of URLs in a page when limit is not set.
+	DefaultPageLimit = 100
+	// MaxPageLimit is a maximum number of URLs in a page.
+	MaxPageLimit = 1000
+
+	sortCreatedAt     = "created_at"
+	sortCreatedAtDesc = "-created_at"
+)
+
+// pageToken is an opaque cursor passed to clients as base64 encoded JSON.
+type pageToken struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// newURLQuery validates dto.URLQuery and converts it to model.URLQuery.
+func newURLQuery(in dto.URLQuery) (model.URLQuery, error) {
+	query := model.URLQuery{
+		URLFilter: model.URLFilter{
+			Deleted:     in.Deleted,
+			Search:      in.Search,
+			CreatedFrom: in.CreatedFrom,
+			CreatedTo:   in.CreatedTo,
+		},
+		Limit: in.Limit,
+	}
+
+	switch {
+	case in.Limit < 0:
+		return query, apperr.NewValueError("negative limit", apperr.Caller(), urlErr.ErrInvalidQuery)
+	case in.Limit == 0 && in.Unpaged && in.PageToken == "":
+		// Zero limit of model.URLQuery lists all URLs.
+	case in.Limit == 0:
+		query.Limit = DefaultPageLimit
+	case in.Limit > MaxPageLimit:
+		query.Limit = MaxPageLimit
+	}
+
+	if !in.CreatedFrom.IsZero() && !in.CreatedTo.IsZero() && !in.CreatedFrom.Before(in.CreatedTo) {
+		return query, apperr.NewValueError("empty creation time range", apperr.Caller(), urlErr.ErrInvalidQuery)
+	}
+
+	switch in.Sort {
+	case "", sortCreatedAtDesc:
+		query.Desc = true
+	case sortCreatedAt:
+		query.Desc = false
+	default:
+		return query, apperr.NewValueError("unsupported sort "+in.Sort, apperr.Caller(), urlErr.ErrInvalidQuery)
+	}
+
+	if in.PageToken != "" {
+		cursor, err := decodePageToken(in.PageToken)
+		if err != nil {
+			return query, err
+		}
+		query.After = cursor
+	}
+
+	return query, nil
+}
+
+func encodePageToken(url model.URL) string {
+	token, _ := json.Marshal(pageToken{CreatedAt: url.CreatedAt, ID: url.ID})
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func decodePageToken(s string) (*model.URLCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, apperr.NewValueError("malformed page token", apperr.Caller(), urlErr.ErrInvalidQuery)
+	}
+
+	var token pageToken
+	if err = json.Unmarshal(raw, &token); err != nil || token.ID == "" {
+		return nil, apperr.NewValueError("malformed page token", apperr.Caller(), urlErr.ErrInvalidQuery)
+	}
+
+	return &model.URLCursor{CreatedAt: token.CreatedAt, ID: token.ID}, nil
+}
@@ -3,8 +3,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/msmkdenis/yap-shortener/internal/dto"
@@ -15,29 +22,85 @@ import (
 )
 
 // URLRepository represents URL repository interface.
+//
+// Stored URLs are never overwritten: a user has at most one URL per original URL, Insert and InsertAll
+// leave URLs with taken IDs or original URLs the user already has untouched and return them as stored.
 type URLRepository interface {
 	Insert(ctx context.Context, u model.URL) (*model.URL, error)
-	InsertAllOrUpdate(ctx context.Context, urls []model.URL) ([]model.URL, error)
+	InsertAll(ctx context.Context, urls []model.URL) (inserted []model.URL, existing []model.URL, err error)
 	SelectByID(ctx context.Context, key string) (*model.URL, error)
-	SelectAll(ctx context.Context) ([]model.URL, error)
-	SelectAllByUserID(ctx context.Context, userID string) ([]model.URL, error)
+	SelectAll(ctx context.Context, query model.URLQuery) ([]model.URL, error)
+	SelectAllByUserID(ctx context.Context, userID string, query model.URLQuery) ([]model.URL, error)
+	IterateByUserID(ctx context.Context, userID string, filter model.URLFilter, fn func(model.URL) error) error
 	DeleteAll(ctx context.Context) error
 	DeleteURLByUserID(ctx context.Context, userID string, shortURLs string) error
+	// RestoreURLByUserID restores URL of the user deleted not before deletedAfter, zero deletedAfter means any time.
+	RestoreURLByUserID(ctx context.Context, userID string, shortURL string, deletedAfter time.Time) error
+	// UpdateOriginal changes original URL of the user's URL not deleted and records the edit,
+	// unchanged URL is returned as is without an edit.
+	UpdateOriginal(ctx context.Context, userID string, id string, original string, at time.Time) (*model.URL, error)
+	// UpdateRedirect changes redirect settings of the user's URL not deleted.
+	UpdateRedirect(ctx context.Context, userID string, id string, settings model.RedirectSettings, at time.Time) (*model.URL, error)
+	// UpdateRules applies update to redirect rules of the user's URL not deleted atomically,
+	// nothing is changed if update fails.
+	UpdateRules(ctx context.Context, userID string, id string, update func([]model.RedirectRule) ([]model.RedirectRule, error), at time.Time) (*model.URL, error)
+	// AddCountryRedirects adds numbers of redirects of URL visitors by country at once,
+	// redirects of URLs not found are skipped.
+	AddCountryRedirects(ctx context.Context, redirects []model.URLCountryRedirects) error
+	// SelectCountryRedirects returns redirects of the URL by country ordered by number of redirects
+	// from the most redirected country, countries with equal numbers are ordered by code.
+	SelectCountryRedirects(ctx context.Context, id string) ([]model.CountryRedirects, error)
+	// SelectEditsByID returns edits of the URL ordered by edit time.
+	SelectEditsByID(ctx context.Context, id string) ([]model.URLEdit, error)
 	SelectStats(ctx context.Context) (*model.URLStats, error)
+	// PurgeDeleted permanently deletes at most limit URLs soft-deleted before the time and returns their number.
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error)
 	Ping(ctx context.Context) error
 }
 
+// DefaultImportChunkSize is a number of imported rows saved to repository at once.
+const DefaultImportChunkSize = 1000
+
+// DefaultImportErrorsLimit is a number of the first invalid rows reported in import summary.
+const DefaultImportErrorsLimit = 100
+
 // URLUseCase represents implementation of URL service.
 type URLUseCase struct {
-	repository URLRepository
-	logger     *zap.Logger
+	repository      URLRepository
+	retentionPeriod time.Duration
+	redirect        RedirectConfig
+	countries       CountryResolver
+	importChunkSize int
+	importErrors    int
+	now             func() time.Time
+	// ruleID returns ID of a new redirect rule, randomBucket returns a random visitor bucket of percentage splits.
+	ruleID       func() string
+	randomBucket func() int
+	// redirects are redirects counted by country since the last flush.
+	redirectsMu sync.Mutex
+	redirects   map[countryKey]int64
+	logger      *zap.Logger
 }
 
 // NewURLService initializes a new URLUseCase with the given URLRepository and logger.
-func NewURLService(repository URLRepository, logger *zap.Logger) *URLUseCase {
+//
+// Deleted URLs are restorable within the retention period, zero period means forever.
+// Redirects use the redirect defaults unless URLs set their own.
+// Countries of visitors are resolved by countries, nil disables resolution and counting redirects by country.
+// Redirects are counted by country in memory until flushed to repository, see RunCountryRedirects.
+func NewURLService(repository URLRepository, retentionPeriod time.Duration, redirect RedirectConfig, countries CountryResolver, logger *zap.Logger) *URLUseCase {
 	return &URLUseCase{
-		repository: repository,
-		logger:     logger,
+		repository:      repository,
+		retentionPeriod: retentionPeriod,
+		redirect:        redirect,
+		countries:       countries,
+		importChunkSize: DefaultImportChunkSize,
+		importErrors:    DefaultImportErrorsLimit,
+		now:             time.Now,
+		ruleID:          uuid.NewString,
+		randomBucket:    func() int { return rand.Intn(ruleBuckets) },
+		redirects:       make(map[countryKey]int64),
+		logger:          logger,
 	}
 }
 
@@ -55,22 +118,95 @@ func (u *URLUseCase) GetStats(ctx context.Context) (*dto.URLStats, error) {
 	return response, nil
 }
 
-// GetAllByUserID returns all URLs by user ID.
-func (u *URLUseCase) GetAllByUserID(ctx context.Context, userID string) ([]dto.URLBatchResponseByUserID, error) {
-	urls, err := u.repository.SelectAllByUserID(ctx, userID)
+// GetAllByUserID returns a page of user URLs matching the query.
+func (u *URLUseCase) GetAllByUserID(ctx context.Context, userID string, in dto.URLQuery) (*dto.URLPage, error) {
+	query, err := newURLQuery(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	// One more URL is requested to find out whether the next page exists, unpaged query has no next page.
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
+	urls, err := u.repository.SelectAllByUserID(ctx, userID, query)
 	if err != nil {
 		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
 
-	response := make([]dto.URLBatchResponseByUserID, len(urls))
+	page := &dto.URLPage{}
+	if limit > 0 && len(urls) > limit {
+		urls = urls[:limit]
+		page.NextPageToken = encodePageToken(urls[limit-1])
+	}
+
+	page.URLs = make([]dto.URLBatchResponseByUserID, len(urls))
 	for i, url := range urls {
-		response[i] = dto.URLBatchResponseByUserID{
+		page.URLs[i] = dto.URLBatchResponseByUserID{
 			OriginalURL: url.Original,
 			ShortURL:    url.Shortened,
+			DeletedFlag: url.DeletedFlag,
 		}
 	}
 
-	return response, nil
+	return page, nil
+}
+
+// GetAllURLs returns a page of URL records of all users matching the query.
+func (u *URLUseCase) GetAllURLs(ctx context.Context, in dto.URLQuery) (*dto.URLRecordPage, error) {
+	query, err := newURLQuery(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+	query.UserID = in.UserID
+
+	// One more URL is requested to find out whether the next page exists, unpaged query has no next page.
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
+	urls, err := u.repository.SelectAll(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	page := &dto.URLRecordPage{}
+	if limit > 0 && len(urls) > limit {
+		urls = urls[:limit]
+		page.NextPageToken = encodePageToken(urls[limit-1])
+	}
+
+	page.URLs = make([]dto.URLRecord, len(urls))
+	for i, url := range urls {
+		page.URLs[i] = dto.URLRecord{
+			ID:            url.ID,
+			OriginalURL:   url.Original,
+			ShortURL:      url.Shortened,
+			CorrelationID: url.CorrelationID,
+			UserID:        url.UserID,
+			DeletedFlag:   url.DeletedFlag,
+			CreatedAt:     url.CreatedAt,
+		}
+	}
+
+	return page, nil
+}
+
+// Export calls fn for every URL of the user matching the filter without loading all of them.
+func (u *URLUseCase) Export(ctx context.Context, userID string, filter model.URLFilter, fn func(dto.URLBatchResponseByUserID) error) error {
+	err := u.repository.IterateByUserID(ctx, userID, filter, func(url model.URL) error {
+		return fn(dto.URLBatchResponseByUserID{
+			ShortURL:    url.Shortened,
+			OriginalURL: url.Original,
+			DeletedFlag: url.DeletedFlag,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
 }
 
 // DeleteURLByUserID deletes URL by user ID.
@@ -83,33 +219,76 @@ func (u *URLUseCase) DeleteURLByUserID(ctx context.Context, userID string, short
 	return nil
 }
 
+// RestoreURLByUserID restores URL of the user deleted within the retention period.
+func (u *URLUseCase) RestoreURLByUserID(ctx context.Context, userID string, shortURL string) error {
+	var deletedAfter time.Time
+	if u.retentionPeriod > 0 {
+		deletedAfter = u.now().Add(-u.retentionPeriod)
+	}
+
+	err := u.repository.RestoreURLByUserID(ctx, userID, shortURL, deletedAfter)
+	if err != nil {
+		return fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return nil
+}
+
 // Add adds a new URL.
+//
+// URL whose key is taken by an edited URL of the user gets a random key.
 func (u *URLUseCase) Add(ctx context.Context, s, host string, userID string) (*model.URL, error) {
-	urlKey := hashgen.GenerateMD5Hash(s)
+	urlKey := urlID(userID, s)
+	now := u.now().UTC()
 	url := &model.URL{
 		ID:          urlKey,
 		Original:    s,
 		Shortened:   host + "/" + urlKey,
 		UserID:      userID,
 		DeletedFlag: false,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	for attempt := 1; ; attempt++ {
+		savedURL, err := u.repository.Insert(ctx, *url)
+		if errors.Is(err, urlErr.ErrURLAlreadyExists) {
+			if sameURL(*savedURL, *url) {
+				return savedURL, fmt.Errorf("%s %w", apperr.Caller(), err)
+			}
+			if attempt == maxKeyAttempts {
+				return nil, apperr.NewValueError("unable to find free key", apperr.Caller(), errNoFreeKey)
+			}
+			url.ID = randomURLID()
+			url.Shortened = host + "/" + url.ID
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+
+		return savedURL, nil
 	}
+}
 
-	existingURL, err := u.repository.SelectByID(ctx, urlKey)
-	if err == nil {
-		return existingURL, fmt.Errorf("%s %w", apperr.Caller(), urlErr.ErrURLAlreadyExists)
+// UpdateURL changes original URL of the user's URL, the short URL stays the same.
+func (u *URLUseCase) UpdateURL(ctx context.Context, userID string, id string, original string) (*model.URL, error) {
+	if _, err := url.ParseRequestURI(original); err != nil {
+		return nil, apperr.NewValueError("invalid original url", apperr.Caller(), urlErr.ErrInvalidURL)
 	}
 
-	savedURL, err := u.repository.Insert(ctx, *url)
+	updatedURL, err := u.repository.UpdateOriginal(ctx, userID, id, original, u.now().UTC())
 	if err != nil {
 		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
 
-	return savedURL, nil
+	return updatedURL, nil
 }
 
-// GetAll returns all URLs.
+// GetAll returns original URLs of all users.
 func (u *URLUseCase) GetAll(ctx context.Context) ([]string, error) {
-	urls, err := u.repository.SelectAll(ctx)
+	urls, err := u.repository.SelectAll(ctx, model.URLQuery{})
 	if err != nil {
 		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
@@ -130,18 +309,14 @@ func (u *URLUseCase) DeleteAll(ctx context.Context) error {
 	return nil
 }
 
-// GetByyID returns URL by ID.
+// GetByyID returns redirect location by ID for a visit without request details, see GetRedirect.
 func (u *URLUseCase) GetByyID(ctx context.Context, key string) (string, error) {
-	url, err := u.repository.SelectByID(ctx, key)
+	redirect, err := u.GetRedirect(ctx, key, model.Visit{})
 	if err != nil {
 		return "", fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
 
-	if url.DeletedFlag {
-		return "", apperr.NewValueError("deleted url", apperr.Caller(), urlErr.ErrURLDeleted)
-	}
-
-	return url.Original, nil
+	return redirect.Location, nil
 }
 
 // Ping pings the URL repository.
@@ -150,16 +325,31 @@ func (u *URLUseCase) Ping(ctx context.Context) error {
 	return err
 }
 
-// AddAll adds URLs.
-func (u *URLUseCase) AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string) ([]dto.URLBatchResponse, error) {
+// AddAll adds a batch of URLs, every item of the response has its own status.
+//
+// Invalid items (empty correlation_id, invalid original_url, duplicates of correlation_id or original_url
+// within the batch) get status invalid with error, URLs which are already saved get status exists
+// and are left untouched, so the owner is preserved. In atomic mode any invalid item fails the whole batch.
+func (u *URLUseCase) AddAll(ctx context.Context, urls []dto.URLBatchRequest, host string, userID string, atomic bool) ([]dto.URLBatchResponse, error) {
+	response := make([]dto.URLBatchResponse, len(urls))
 	urlsToSave := make([]model.URL, 0, len(urls))
-	keys := make(map[string]string, len(urls))
-	for _, v := range urls {
-		if _, ok := keys[v.CorrelationID]; ok {
-			return nil, apperr.NewValueError("duplicated keys", apperr.Caller(), urlErr.ErrDuplicatedKeys)
+	keys := make(map[string]struct{}, len(urls))
+	originals := make(map[string]struct{}, len(urls))
+	createdAt := u.now().UTC()
+	for i, v := range urls {
+		response[i].CorrelationID = v.CorrelationID
+		if err := checkBatchItem(v, keys, originals); err != nil {
+			if atomic {
+				return nil, batchItemError(i, err)
+			}
+			response[i].Status = dto.BatchItemInvalid
+			response[i].Error = err.Error()
+			continue
 		}
-		keys[v.CorrelationID] = v.CorrelationID
-		shortURL := hashgen.GenerateMD5Hash(v.OriginalURL)
+
+		shortURL := urlID(userID, v.OriginalURL)
+		keys[v.CorrelationID] = struct{}{}
+		originals[v.OriginalURL] = struct{}{}
 		url := model.URL{
 			ID:            shortURL,
 			Original:      v.OriginalURL,
@@ -167,23 +357,229 @@ func (u *URLUseCase) AddAll(ctx context.Context, urls []dto.URLBatchRequest, hos
 			CorrelationID: v.CorrelationID,
 			UserID:        userID,
 			DeletedFlag:   false,
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
 		}
 		urlsToSave = append(urlsToSave, url)
 	}
 
-	savedURLs, err := u.repository.InsertAllOrUpdate(ctx, urlsToSave)
+	if len(urlsToSave) == 0 {
+		return response, nil
+	}
+
+	inserted, existing, err := u.insertAll(ctx, urlsToSave, host)
 	if err != nil {
 		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
 	}
 
-	response := make([]dto.URLBatchResponse, 0, len(savedURLs))
-	for _, url := range savedURLs {
-		responseURL := dto.URLBatchResponse{
-			CorrelationID: url.CorrelationID,
-			ShortenedURL:  url.Shortened,
+	// statuses are keyed by original URL, stored URLs of the user may have IDs generated differently
+	statuses := make(map[string]dto.URLBatchResponse, len(urlsToSave))
+	for _, url := range inserted {
+		statuses[url.Original] = dto.URLBatchResponse{ShortenedURL: url.Shortened, Status: dto.BatchItemCreated}
+	}
+	for _, url := range existing {
+		statuses[url.Original] = dto.URLBatchResponse{ShortenedURL: url.Shortened, Status: dto.BatchItemExists}
+	}
+
+	for i := range response {
+		if response[i].Status == dto.BatchItemInvalid {
+			continue
 		}
-		response = append(response, responseURL)
+		status, ok := statuses[urls[i].OriginalURL]
+		if !ok {
+			return nil, apperr.NewValueError("batch item is neither inserted nor existing", apperr.Caller(), errUnsavedBatchItem)
+		}
+		response[i].ShortenedURL = status.ShortenedURL
+		response[i].Status = status.Status
 	}
 
 	return response, nil
 }
+
+// batchItemError returns error failing the whole batch because of the invalid item with index i.
+func batchItemError(i int, err error) error {
+	msg := fmt.Sprintf("item %d: %s", i, err)
+	if errors.Is(err, errDuplicatedCorrelationID) {
+		return apperr.NewValueError(msg, apperr.Caller(), urlErr.ErrDuplicatedKeys)
+	}
+	return apperr.NewValueError(msg, apperr.Caller(), urlErr.ErrInvalidBatchItem)
+}
+
+// Import adds URLs read with next until it returns io.EOF.
+//
+// Rows are saved in chunks of importChunkSize, so the whole import is never held in memory.
+// Invalid rows (including errors of next wrapping urlerr.ErrInvalidImportRow) are skipped and counted as failed,
+// errors of the first DefaultImportErrorsLimit of them are reported in summary.
+// Any other error stops import, rows of already saved chunks remain saved.
+// Rows shortening URLs the user already has are counted as existing rather than imported.
+// Duplicates of correlation_id and original_url are detected within a chunk.
+func (u *URLUseCase) Import(ctx context.Context, next func() (dto.URLBatchRequest, error), host string, userID string) (*dto.URLImportSummary, error) {
+	summary := &dto.URLImportSummary{}
+	chunk := make([]model.URL, 0, u.importChunkSize)
+	keys := make(map[string]struct{}, u.importChunkSize)
+	originals := make(map[string]struct{}, u.importChunkSize)
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		inserted, existing, err := u.insertAll(ctx, chunk, host)
+		if err != nil {
+			return fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		summary.Imported += len(inserted)
+		summary.Existing += len(existing)
+		chunk = chunk[:0]
+		clear(keys)
+		clear(originals)
+		return nil
+	}
+
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, urlErr.ErrInvalidImportRow) {
+			return summary, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+
+		summary.Total++
+		if err == nil {
+			if err = checkBatchItem(row, keys, originals); err != nil {
+				err = fmt.Errorf("%w: %w", urlErr.ErrInvalidImportRow, err)
+			}
+		}
+		if err != nil {
+			summary.Failed++
+			if len(summary.Errors) == u.importErrors {
+				continue
+			}
+			summary.Errors = append(summary.Errors, dto.URLImportError{
+				Row:           summary.Total,
+				CorrelationID: row.CorrelationID,
+				Error:         err.Error(),
+			})
+			continue
+		}
+
+		shortURL := urlID(userID, row.OriginalURL)
+		keys[row.CorrelationID] = struct{}{}
+		originals[row.OriginalURL] = struct{}{}
+		now := u.now().UTC()
+		chunk = append(chunk, model.URL{
+			ID:            shortURL,
+			Original:      row.OriginalURL,
+			Shortened:     host + "/" + shortURL,
+			CorrelationID: row.CorrelationID,
+			UserID:        userID,
+			DeletedFlag:   false,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+
+		if len(chunk) == u.importChunkSize {
+			if err = flush(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+// insertAll inserts URLs, URLs whose keys are taken by edited URLs get random keys.
+//
+// Stored URLs reported as existing under the keys of URLs but shortening other original URLs are not returned.
+func (u *URLUseCase) insertAll(ctx context.Context, urls []model.URL, host string) (inserted []model.URL, existing []model.URL, err error) {
+	pending := urls
+	for attempt := 1; ; attempt++ {
+		pendingInserted, pendingExisting, err := u.repository.InsertAll(ctx, pending)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+		}
+		inserted = append(inserted, pendingInserted...)
+
+		byID := make(map[string]model.URL, len(pending))
+		for _, url := range pending {
+			byID[url.ID] = url
+		}
+
+		collided := make([]model.URL, 0)
+		for _, stored := range pendingExisting {
+			url, ok := byID[stored.ID]
+			if !ok || sameURL(stored, url) {
+				existing = append(existing, stored)
+				continue
+			}
+			url.ID = randomURLID()
+			url.Shortened = host + "/" + url.ID
+			collided = append(collided, url)
+		}
+
+		if len(collided) == 0 {
+			return inserted, existing, nil
+		}
+		if attempt == maxKeyAttempts {
+			return nil, nil, apperr.NewValueError("unable to find free key", apperr.Caller(), errNoFreeKey)
+		}
+		pending = collided
+	}
+}
+
+// maxKeyAttempts is a number of attempts to save URL whose keys are taken by edited URLs.
+const maxKeyAttempts = 3
+
+// sameURL reports whether stored URL belongs to the same user and shortens the same original URL.
+func sameURL(stored model.URL, url model.URL) bool {
+	return stored.UserID == url.UserID && stored.Original == url.Original
+}
+
+// randomURLID returns random URL ID.
+//
+// Edited URLs keep their IDs, so IDs derived from the user and original URL may be taken by URLs with other original URLs.
+func randomURLID() string {
+	return hashgen.GenerateMD5Hash(uuid.NewString())
+}
+
+// urlID returns ID of the user's URL shortening the original URL.
+//
+// Every user gets an own URL for the same original URL, so URLs are never shared or reassigned between users.
+func urlID(userID string, original string) string {
+	return hashgen.GenerateMD5Hash(userID + "\x00" + original)
+}
+
+// Reasons of invalid batch items and import rows
+var (
+	errEmptyCorrelationID      = errors.New("empty correlation_id")
+	errInvalidOriginalURL      = errors.New("invalid original_url")
+	errDuplicatedCorrelationID = errors.New("duplicated correlation_id")
+	errDuplicatedOriginalURL   = errors.New("duplicated original_url")
+	errUnsavedBatchItem        = errors.New("unsaved batch item")
+	errNoFreeKey               = errors.New("no free key")
+)
+
+// checkBatchItem validates batch item, keys and originals hold correlation IDs and original URLs of already accepted items.
+func checkBatchItem(item dto.URLBatchRequest, keys map[string]struct{}, originals map[string]struct{}) error {
+	if item.CorrelationID == "" {
+		return errEmptyCorrelationID
+	}
+
+	if _, err := url.ParseRequestURI(item.OriginalURL); err != nil {
+		return errInvalidOriginalURL
+	}
+
+	if _, ok := keys[item.CorrelationID]; ok {
+		return errDuplicatedCorrelationID
+	}
+
+	if _, ok := originals[item.OriginalURL]; ok {
+		return errDuplicatedOriginalURL
+	}
+
+	return nil
+}
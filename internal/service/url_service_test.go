@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,6 +24,8 @@ import (
 	"github.com/msmkdenis/yap-shortener/pkg/hashgen"
 )
 
+var testTime = time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+
 type URLServiceTestSuite struct {
 	suite.Suite
 	logger        *zap.Logger
@@ -36,7 +40,8 @@ func TestSuite(t *testing.T) {
 func (u *URLServiceTestSuite) SetupSuite() {
 	u.logger, _ = zap.NewProduction()
 	u.urlRepository = mock.NewMockURLRepository(gomock.NewController(u.T()))
-	u.urlService = NewURLService(u.urlRepository, u.logger)
+	u.urlService = NewURLService(u.urlRepository, 0, RedirectConfig{}, nil, u.logger)
+	u.urlService.now = func() time.Time { return testTime }
 }
 
 func (u *URLServiceTestSuite) TestGetAllByUserId() {
@@ -56,24 +61,75 @@ func (u *URLServiceTestSuite) TestGetAllByUserId() {
 
 	testCases := []struct {
 		name          string
+		query         dto.URLQuery
 		prepare       func()
-		expectedBody  []dto.URLBatchResponseByUserID
+		expectedBody  *dto.URLPage
 		expectedError error
 	}{
 		{
 			name: "Successful return",
 			prepare: func() {
-				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any()).Return(data, nil)
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), model.URLQuery{Desc: true, Limit: DefaultPageLimit + 1}).Return(data, nil)
 			},
-			expectedBody:  batchResponse,
+			expectedBody:  &dto.URLPage{URLs: batchResponse},
 			expectedError: nil,
 		},
+		{
+			name:  "Next page",
+			query: dto.URLQuery{Limit: 2, Sort: "created_at", Search: "a"},
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), model.URLQuery{URLFilter: model.URLFilter{Search: "a"}, Limit: 3}).Return(data[:3], nil)
+			},
+			expectedBody: &dto.URLPage{URLs: batchResponse[:2], NextPageToken: encodePageToken(data[1])},
+		},
+		{
+			name:  "Page token",
+			query: dto.URLQuery{Limit: 5000, PageToken: encodePageToken(data[1])},
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), model.URLQuery{
+					After: &model.URLCursor{CreatedAt: data[1].CreatedAt, ID: data[1].ID},
+					Desc:  true,
+					Limit: MaxPageLimit + 1,
+				}).Return(data[2:], nil)
+			},
+			expectedBody: &dto.URLPage{URLs: batchResponse[2:]},
+		},
+		{
+			name:  "Unpaged",
+			query: dto.URLQuery{Unpaged: true},
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), model.URLQuery{Desc: true}).Return(data, nil)
+			},
+			expectedBody: &dto.URLPage{URLs: batchResponse},
+		},
+		{
+			name:  "Unpaged page token",
+			query: dto.URLQuery{PageToken: encodePageToken(data[1]), Unpaged: true},
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), model.URLQuery{
+					After: &model.URLCursor{CreatedAt: data[1].CreatedAt, ID: data[1].ID},
+					Desc:  true,
+					Limit: DefaultPageLimit + 1,
+				}).Return(data[2:], nil)
+			},
+			expectedBody: &dto.URLPage{URLs: batchResponse[2:]},
+		},
+		{
+			name:          "Invalid page token",
+			query:         dto.URLQuery{PageToken: "invalid"},
+			expectedError: urlErr.ErrInvalidQuery,
+		},
+		{
+			name:          "Invalid sort",
+			query:         dto.URLQuery{Sort: "original_url"},
+			expectedError: urlErr.ErrInvalidQuery,
+		},
 		{
 			name: "Error return",
 			prepare: func() {
-				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any()).Return(nil, repoErr)
+				u.urlRepository.EXPECT().SelectAllByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, repoErr)
 			},
-			expectedBody: nil,
+			expectedError: repoErr,
 		},
 	}
 	for _, test := range testCases {
@@ -82,12 +138,12 @@ func (u *URLServiceTestSuite) TestGetAllByUserId() {
 				test.prepare()
 			}
 
-			urls, err := u.urlService.GetAllByUserID(context.Background(), uuid.New().String())
-			assert.Equal(t, test.expectedBody, urls)
-			if err != nil {
-				assert.True(t, errors.Is(err, repoErr))
+			page, err := u.urlService.GetAllByUserID(context.Background(), uuid.New().String(), test.query)
+			assert.Equal(t, test.expectedBody, page)
+			if test.expectedError != nil {
+				assert.True(t, errors.Is(err, test.expectedError))
 			} else {
-				assert.Equal(t, test.expectedError, err)
+				assert.NoError(t, err)
 			}
 		})
 	}
@@ -132,34 +188,144 @@ func (u *URLServiceTestSuite) TestDeleteURLByUserID() {
 	}
 }
 
+func (u *URLServiceTestSuite) TestRestoreURLByUserID() {
+	repoErr := errors.New("repository error")
+	userID := uuid.New().String()
+
+	testCases := []struct {
+		name            string
+		retentionPeriod time.Duration
+		prepare         func()
+		expectedError   error
+	}{
+		{
+			name:            "Restores URL deleted within retention period",
+			retentionPeriod: time.Hour,
+			prepare: func() {
+				u.urlRepository.EXPECT().RestoreURLByUserID(gomock.Any(), userID, "shortened", testTime.Add(-time.Hour)).Return(nil)
+			},
+		},
+		{
+			name: "Restores URL deleted at any time without retention",
+			prepare: func() {
+				u.urlRepository.EXPECT().RestoreURLByUserID(gomock.Any(), userID, "shortened", time.Time{}).Return(nil)
+			},
+		},
+		{
+			name: "Error restore",
+			prepare: func() {
+				u.urlRepository.EXPECT().RestoreURLByUserID(gomock.Any(), userID, "shortened", gomock.Any()).Return(repoErr)
+			},
+			expectedError: repoErr,
+		},
+	}
+	for _, test := range testCases {
+		u.T().Run(test.name, func(t *testing.T) {
+			test.prepare()
+			u.urlService.retentionPeriod = test.retentionPeriod
+			defer func() { u.urlService.retentionPeriod = 0 }()
+
+			err := u.urlService.RestoreURLByUserID(context.Background(), userID, "shortened")
+			assert.ErrorIs(t, err, test.expectedError)
+		})
+	}
+}
+
+func (u *URLServiceTestSuite) TestUpdateURL() {
+	updated := model.URL{
+		ID:        "id",
+		Original:  "http://example.com/edited",
+		Shortened: "http://localhost:8080/id",
+		UserID:    "user",
+		CreatedAt: testTime.Add(-time.Hour),
+		UpdatedAt: testTime,
+	}
+	repoErr := errors.New("repository error")
+
+	testCases := []struct {
+		name          string
+		original      string
+		prepare       func()
+		expectedBody  *model.URL
+		expectedError error
+	}{
+		{
+			name:     "Successful update",
+			original: updated.Original,
+			prepare: func() {
+				u.urlRepository.EXPECT().UpdateOriginal(gomock.Any(), "user", "id", updated.Original, testTime).Return(&updated, nil)
+			},
+			expectedBody: &updated,
+		},
+		{
+			name:          "Invalid url",
+			original:      "not url",
+			expectedError: urlErr.ErrInvalidURL,
+		},
+		{
+			name:     "Repository error",
+			original: updated.Original,
+			prepare: func() {
+				u.urlRepository.EXPECT().UpdateOriginal(gomock.Any(), "user", "id", updated.Original, testTime).Return(nil, urlErr.ErrURLNotFound)
+			},
+			expectedError: urlErr.ErrURLNotFound,
+		},
+		{
+			name:     "Unexpected error",
+			original: updated.Original,
+			prepare: func() {
+				u.urlRepository.EXPECT().UpdateOriginal(gomock.Any(), "user", "id", updated.Original, testTime).Return(nil, repoErr)
+			},
+			expectedError: repoErr,
+		},
+	}
+	for _, test := range testCases {
+		u.Run(test.name, func() {
+			if test.prepare != nil {
+				test.prepare()
+			}
+
+			url, err := u.urlService.UpdateURL(context.Background(), "user", "id", test.original)
+			u.Equal(test.expectedBody, url)
+			if test.expectedError != nil {
+				u.ErrorIs(err, test.expectedError)
+			} else {
+				u.NoError(err)
+			}
+		})
+	}
+}
+
 func (u *URLServiceTestSuite) TestAdd() {
 	rnd := rand.NewSource(time.Now().Unix())
 	s := generateString(10, rnd)
 	host := generateString(4, rnd)
 	userID := uuid.New().String()
-	urlKey := hashgen.GenerateMD5Hash(s)
+	urlKey := urlID(userID, s)
 	url := &model.URL{
 		ID:          urlKey,
 		Original:    s,
 		Shortened:   host + "/" + urlKey,
 		UserID:      userID,
 		DeletedFlag: false,
+		CreatedAt:   testTime,
+		UpdatedAt:   testTime,
 	}
 
 	repoErr := errors.New("repository error")
 
+	existingURL := *url
+	existingURL.ID = hashgen.GenerateMD5Hash(s)
+	existingURL.Shortened = host + "/" + existingURL.ID
+
 	testCases := []struct {
 		name          string
-		prepareSelect func()
 		prepareInsert func()
 		expectedBody  *model.URL
 		expectedError error
 	}{
 		{
 			name: "Successful add",
-			prepareSelect: func() {
-				u.urlRepository.EXPECT().SelectByID(gomock.Any(), urlKey).Return(nil, repoErr)
-			},
 			prepareInsert: func() {
 				u.urlRepository.EXPECT().Insert(gomock.Any(), *url).Return(url, nil)
 			},
@@ -168,17 +334,43 @@ func (u *URLServiceTestSuite) TestAdd() {
 		},
 		{
 			name: "Successful return existing url",
-			prepareSelect: func() {
-				u.urlRepository.EXPECT().SelectByID(gomock.Any(), urlKey).Return(url, nil)
+			prepareInsert: func() {
+				u.urlRepository.EXPECT().Insert(gomock.Any(), *url).Return(&existingURL, urlErr.ErrURLAlreadyExists)
 			},
-			expectedBody:  url,
+			expectedBody:  &existingURL,
 			expectedError: urlErr.ErrURLAlreadyExists,
 		},
 		{
-			name: "Error while add",
-			prepareSelect: func() {
-				u.urlRepository.EXPECT().SelectByID(gomock.Any(), urlKey).Return(nil, repoErr)
+			name: "Key taken by edited url",
+			prepareInsert: func() {
+				editedURL := *url
+				editedURL.Original = "http://example.com/edited"
+				gomock.InOrder(
+					u.urlRepository.EXPECT().Insert(gomock.Any(), *url).Return(&editedURL, urlErr.ErrURLAlreadyExists),
+					u.urlRepository.EXPECT().Insert(gomock.Any(), gomock.Any()).DoAndReturn(
+						func(_ context.Context, saved model.URL) (*model.URL, error) {
+							if saved.ID == url.ID || saved.Shortened != host+"/"+saved.ID {
+								return nil, repoErr
+							}
+							return url, nil
+						}),
+				)
 			},
+			expectedBody:  url,
+			expectedError: nil,
+		},
+		{
+			name: "No free key",
+			prepareInsert: func() {
+				editedURL := *url
+				editedURL.Original = "http://example.com/edited"
+				u.urlRepository.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(&editedURL, urlErr.ErrURLAlreadyExists).Times(maxKeyAttempts)
+			},
+			expectedBody:  nil,
+			expectedError: errNoFreeKey,
+		},
+		{
+			name: "Error while add",
 			prepareInsert: func() {
 				u.urlRepository.EXPECT().Insert(gomock.Any(), *url).Return(nil, repoErr)
 			},
@@ -188,9 +380,6 @@ func (u *URLServiceTestSuite) TestAdd() {
 	}
 	for _, test := range testCases {
 		u.T().Run(test.name, func(t *testing.T) {
-			if test.prepareSelect != nil {
-				test.prepareSelect()
-			}
 			if test.prepareInsert != nil {
 				test.prepareInsert()
 			}
@@ -226,7 +415,7 @@ func (u *URLServiceTestSuite) TestGetAll() {
 		{
 			name: "Successful return",
 			prepare: func() {
-				u.urlRepository.EXPECT().SelectAll(gomock.Any()).Return(data, nil)
+				u.urlRepository.EXPECT().SelectAll(gomock.Any(), model.URLQuery{}).Return(data, nil)
 			},
 			expectedBody:  original,
 			expectedError: nil,
@@ -234,7 +423,7 @@ func (u *URLServiceTestSuite) TestGetAll() {
 		{
 			name: "Error return",
 			prepare: func() {
-				u.urlRepository.EXPECT().SelectAll(gomock.Any()).Return(nil, repoErr)
+				u.urlRepository.EXPECT().SelectAll(gomock.Any(), model.URLQuery{}).Return(nil, repoErr)
 			},
 			expectedBody: nil,
 		},
@@ -256,6 +445,86 @@ func (u *URLServiceTestSuite) TestGetAll() {
 	}
 }
 
+func (u *URLServiceTestSuite) TestGetAllURLs() {
+	rnd := rand.NewSource(time.Now().Unix())
+	data := make([]model.URL, 0, 10)
+	records := make([]dto.URLRecord, 0, 10)
+	for i := 0; i < 10; i++ {
+		data = append(data, generateURL(rnd))
+		records = append(records, dto.URLRecord{
+			ID:            data[i].ID,
+			OriginalURL:   data[i].Original,
+			ShortURL:      data[i].Shortened,
+			CorrelationID: data[i].CorrelationID,
+			UserID:        data[i].UserID,
+			DeletedFlag:   data[i].DeletedFlag,
+			CreatedAt:     data[i].CreatedAt,
+		})
+	}
+
+	deleted := true
+	from := testTime.Add(-time.Hour)
+	repoErr := errors.New("repository error")
+
+	testCases := []struct {
+		name          string
+		query         dto.URLQuery
+		prepare       func()
+		expectedBody  *dto.URLRecordPage
+		expectedError error
+	}{
+		{
+			name: "Successful return",
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAll(gomock.Any(), model.URLQuery{Desc: true, Limit: DefaultPageLimit + 1}).Return(data, nil)
+			},
+			expectedBody: &dto.URLRecordPage{URLs: records},
+		},
+		{
+			name:  "Filtered next page",
+			query: dto.URLQuery{Limit: 2, UserID: "user", Deleted: &deleted, CreatedFrom: from, CreatedTo: testTime},
+			prepare: func() {
+				u.urlRepository.EXPECT().SelectAll(gomock.Any(), model.URLQuery{
+					URLFilter: model.URLFilter{UserID: "user", Deleted: &deleted, CreatedFrom: from, CreatedTo: testTime},
+					Desc:      true,
+					Limit:     3,
+				}).Return(data[:3], nil)
+			},
+			expectedBody: &dto.URLRecordPage{URLs: records[:2], NextPageToken: encodePageToken(data[1])},
+		},
+		{
+			name:         "Empty result",
+			prepare:      func() { u.urlRepository.EXPECT().SelectAll(gomock.Any(), gomock.Any()).Return([]model.URL{}, nil) },
+			expectedBody: &dto.URLRecordPage{URLs: []dto.URLRecord{}},
+		},
+		{
+			name:          "Invalid date range",
+			query:         dto.URLQuery{CreatedFrom: testTime, CreatedTo: from},
+			expectedError: urlErr.ErrInvalidQuery,
+		},
+		{
+			name:          "Error return",
+			prepare:       func() { u.urlRepository.EXPECT().SelectAll(gomock.Any(), gomock.Any()).Return(nil, repoErr) },
+			expectedError: repoErr,
+		},
+	}
+	for _, test := range testCases {
+		u.T().Run(test.name, func(t *testing.T) {
+			if test.prepare != nil {
+				test.prepare()
+			}
+
+			page, err := u.urlService.GetAllURLs(context.Background(), test.query)
+			assert.Equal(t, test.expectedBody, page)
+			if test.expectedError != nil {
+				assert.True(t, errors.Is(err, test.expectedError))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func (u *URLServiceTestSuite) TestDeleteAll() {
 	repoErr := errors.New("repository error")
 
@@ -308,6 +577,7 @@ func (u *URLServiceTestSuite) TestGetByID() {
 		Shortened:   host + "/" + urlKey,
 		UserID:      userID,
 		DeletedFlag: false,
+		CreatedAt:   testTime,
 	}
 
 	repoErr := errors.New("repository error")
@@ -359,68 +629,122 @@ func (u *URLServiceTestSuite) TestGetByID() {
 }
 
 func (u *URLServiceTestSuite) TestAddAll() {
-	rnd := rand.NewSource(time.Now().Unix())
-	host := generateString(4, rnd)
+	host := "http://localhost:8080"
 	userID := uuid.New().String()
-
-	urlBatchRequest := make([]dto.URLBatchRequest, 0, 20)
-	urlBatchResponse := make([]dto.URLBatchResponse, 0, 20)
-	urls := make([]model.URL, 0, 20)
-	for i := 0; i < 20; i++ {
-		s := generateString(10, rnd)
-		request := dto.URLBatchRequest{
-			CorrelationID: uuid.New().String(),
-			OriginalURL:   s,
-		}
-		urlBatchRequest = append(urlBatchRequest, request)
-
-		shortURL := hashgen.GenerateMD5Hash(request.OriginalURL)
-		url := model.URL{
-			ID:            shortURL,
-			Original:      request.OriginalURL,
-			Shortened:     host + "/" + shortURL,
-			CorrelationID: request.CorrelationID,
+	newURL := func(original string, correlationID string, userID string) model.URL {
+		id := urlID(userID, original)
+		return model.URL{
+			ID:            id,
+			Original:      original,
+			Shortened:     host + "/" + id,
+			CorrelationID: correlationID,
 			UserID:        userID,
-			DeletedFlag:   false,
+			CreatedAt:     testTime,
+			UpdatedAt:     testTime,
 		}
-		urls = append(urls, url)
-
-		response := dto.URLBatchResponse{
-			CorrelationID: request.CorrelationID,
-			ShortenedURL:  host + "/" + hashgen.GenerateMD5Hash(request.OriginalURL),
-		}
-		urlBatchResponse = append(urlBatchResponse, response)
 	}
-
+	created := newURL("http://example.com/1", "1", userID)
+	// existing URL of the user saved before URL IDs included user ID
+	existing := newURL("http://example.com/2", "other", userID)
+	existing.ID = hashgen.GenerateMD5Hash(existing.Original)
+	existing.Shortened = host + "/" + existing.ID
+
+	request := []dto.URLBatchRequest{
+		{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+		{CorrelationID: "", OriginalURL: "http://example.com/3"},
+		{CorrelationID: "4", OriginalURL: "not url"},
+		{CorrelationID: "1", OriginalURL: "http://example.com/5"},
+		{CorrelationID: "6", OriginalURL: "http://example.com/1"},
+	}
 	repoErr := errors.New("repository error")
 
 	testCases := []struct {
 		name          string
+		request       []dto.URLBatchRequest
+		atomic        bool
 		prepare       func()
 		expectedBody  []dto.URLBatchResponse
 		expectedError error
 	}{
 		{
-			name: "Successful add all",
+			name:    "Per item statuses",
+			request: request,
 			prepare: func() {
-				u.urlRepository.EXPECT().InsertAllOrUpdate(gomock.Any(), gomock.Any()).Return(urls, nil)
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), []model.URL{created, newURL("http://example.com/2", "2", userID)}).
+					Return([]model.URL{created}, []model.URL{existing}, nil)
+			},
+			expectedBody: []dto.URLBatchResponse{
+				{CorrelationID: "1", ShortenedURL: created.Shortened, Status: dto.BatchItemCreated},
+				{CorrelationID: "2", ShortenedURL: existing.Shortened, Status: dto.BatchItemExists},
+				{CorrelationID: "", Status: dto.BatchItemInvalid, Error: "empty correlation_id"},
+				{CorrelationID: "4", Status: dto.BatchItemInvalid, Error: "invalid original_url"},
+				{CorrelationID: "1", Status: dto.BatchItemInvalid, Error: "duplicated correlation_id"},
+				{CorrelationID: "6", Status: dto.BatchItemInvalid, Error: "duplicated original_url"},
+			},
+		},
+		{
+			name:    "Key taken by edited url",
+			request: request[:2],
+			prepare: func() {
+				edited := newURL("http://example.com/2", "", userID)
+				edited.Original = "http://example.com/edited"
+				var rekeyed model.URL
+				gomock.InOrder(
+					u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Return([]model.URL{created}, []model.URL{edited}, nil),
+					u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).DoAndReturn(
+						func(_ context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
+							if len(urls) != 1 || urls[0].ID == edited.ID || urls[0].Original != "http://example.com/2" {
+								return nil, nil, repoErr
+							}
+							rekeyed = urls[0]
+							rekeyed.Shortened = host + "/rekeyed"
+							return []model.URL{rekeyed}, nil, nil
+						}),
+				)
+			},
+			expectedBody: []dto.URLBatchResponse{
+				{CorrelationID: "1", ShortenedURL: created.Shortened, Status: dto.BatchItemCreated},
+				{CorrelationID: "2", ShortenedURL: host + "/rekeyed", Status: dto.BatchItemCreated},
+			},
+		},
+		{
+			name:    "All items invalid",
+			request: request[2:4],
+			expectedBody: []dto.URLBatchResponse{
+				{CorrelationID: "", Status: dto.BatchItemInvalid, Error: "empty correlation_id"},
+				{CorrelationID: "4", Status: dto.BatchItemInvalid, Error: "invalid original_url"},
 			},
-			expectedBody:  urlBatchResponse,
-			expectedError: nil,
 		},
 		{
-			name: "Error duplicated key",
+			name:    "Successful atomic add all",
+			request: request[:2],
+			atomic:  true,
 			prepare: func() {
-				urlBatchRequest[0].CorrelationID = uuid.New().String()
-				urlBatchRequest[1].CorrelationID = urlBatchRequest[0].CorrelationID
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Return([]model.URL{created}, []model.URL{existing}, nil)
 			},
+			expectedBody: []dto.URLBatchResponse{
+				{CorrelationID: "1", ShortenedURL: created.Shortened, Status: dto.BatchItemCreated},
+				{CorrelationID: "2", ShortenedURL: existing.Shortened, Status: dto.BatchItemExists},
+			},
+		},
+		{
+			name:          "Atomic error duplicated key",
+			request:       []dto.URLBatchRequest{request[0], request[1], request[4]},
+			atomic:        true,
 			expectedError: urlErr.ErrDuplicatedKeys,
 		},
 		{
-			name: "Error",
+			name:          "Atomic error invalid item",
+			request:       request,
+			atomic:        true,
+			expectedError: urlErr.ErrInvalidBatchItem,
+		},
+		{
+			name:    "Error",
+			request: request[:2],
 			prepare: func() {
-				urlBatchRequest[1].CorrelationID = uuid.New().String()
-				u.urlRepository.EXPECT().InsertAllOrUpdate(gomock.Any(), gomock.Any()).Return(nil, repoErr)
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Return(nil, nil, repoErr)
 			},
 			expectedError: repoErr,
 		},
@@ -431,13 +755,12 @@ func (u *URLServiceTestSuite) TestAddAll() {
 				test.prepare()
 			}
 
-			savedURL, err := u.urlService.AddAll(context.Background(), urlBatchRequest, host, userID)
-			fmt.Println(err)
+			savedURL, err := u.urlService.AddAll(context.Background(), test.request, host, userID, test.atomic)
 			assert.Equal(t, test.expectedBody, savedURL)
-			if err != nil {
-				assert.True(t, errors.Is(err, test.expectedError))
+			if test.expectedError != nil {
+				assert.ErrorIs(t, err, test.expectedError)
 			} else {
-				assert.Equal(t, test.expectedError, err)
+				assert.NoError(t, err)
 			}
 		})
 	}
@@ -456,13 +779,194 @@ func BenchmarkURLUseCase_GetAll(b *testing.B) {
 	b.StartTimer()
 	b.Run("UrlServiceGetAll", func(b *testing.B) {
 		for j := 0; j < b.N; j++ {
-			s.urlRepository.EXPECT().SelectAll(gomock.Any()).Return(data, nil)
+			s.urlRepository.EXPECT().SelectAll(gomock.Any(), model.URLQuery{}).Return(data, nil)
 			_, _ = s.urlService.GetAll(context.Background())
 		}
 	})
 	b.StopTimer()
 }
 
+func (u *URLServiceTestSuite) TestExport() {
+	rnd := rand.NewSource(time.Now().Unix())
+	data := []model.URL{generateURL(rnd), generateURL(rnd)}
+	data[1].DeletedFlag = true
+	repoErr := errors.New("repository error")
+	deleted := true
+
+	u.urlRepository.EXPECT().IterateByUserID(gomock.Any(), "user", model.URLFilter{Deleted: &deleted}, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ model.URLFilter, fn func(model.URL) error) error {
+			for _, url := range data {
+				if err := fn(url); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Times(2)
+
+	var exported []dto.URLBatchResponseByUserID
+	err := u.urlService.Export(context.Background(), "user", model.URLFilter{Deleted: &deleted}, func(url dto.URLBatchResponseByUserID) error {
+		exported = append(exported, url)
+		return nil
+	})
+	assert.NoError(u.T(), err)
+	assert.Equal(u.T(), []dto.URLBatchResponseByUserID{
+		{ShortURL: data[0].Shortened, OriginalURL: data[0].Original},
+		{ShortURL: data[1].Shortened, OriginalURL: data[1].Original, DeletedFlag: true},
+	}, exported)
+
+	err = u.urlService.Export(context.Background(), "user", model.URLFilter{Deleted: &deleted}, func(dto.URLBatchResponseByUserID) error {
+		return repoErr
+	})
+	assert.ErrorIs(u.T(), err, repoErr)
+}
+
+func (u *URLServiceTestSuite) TestImport() {
+	rows := []dto.URLBatchRequest{
+		{CorrelationID: "1", OriginalURL: "http://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "http://example.com/2"},
+		{CorrelationID: "", OriginalURL: "http://example.com/3"},
+		{CorrelationID: "4", OriginalURL: "not url"},
+		{CorrelationID: "5", OriginalURL: "http://example.com/5"},
+		{CorrelationID: "5", OriginalURL: "http://example.com/6"},
+		{CorrelationID: "7", OriginalURL: "http://example.com/5"},
+		{CorrelationID: "8", OriginalURL: "http://example.com/8"},
+		{CorrelationID: "9", OriginalURL: "http://example.com/9"},
+	}
+	repoErr := errors.New("repository error")
+
+	source := func(rows []dto.URLBatchRequest, last error) func() (dto.URLBatchRequest, error) {
+		i := 0
+		return func() (dto.URLBatchRequest, error) {
+			if i == len(rows) {
+				return dto.URLBatchRequest{}, last
+			}
+			i++
+			return rows[i-1], nil
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		next             func() (dto.URLBatchRequest, error)
+		prepare          func(chunks *[][]string)
+		errorsLimit      int
+		expectedChunks   [][]string
+		expectedSummary  *dto.URLImportSummary
+		expectedErrorIs  error
+		expectedErrorRow []int
+	}{
+		{
+			name: "Successful import in chunks",
+			next: source(rows, io.EOF),
+			prepare: func(chunks *[][]string) {
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Times(3).
+					DoAndReturn(func(_ context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
+						chunk := make([]string, 0, len(urls))
+						for _, url := range urls {
+							chunk = append(chunk, url.CorrelationID)
+						}
+						*chunks = append(*chunks, chunk)
+						return urls, nil, nil
+					})
+			},
+			expectedChunks:   [][]string{{"1", "2"}, {"5", "8"}, {"9"}},
+			expectedSummary:  &dto.URLImportSummary{Total: 9, Imported: 5, Failed: 4},
+			expectedErrorRow: []int{3, 4, 6, 7},
+		},
+		{
+			name: "Existing rows",
+			next: source(rows[:2], io.EOF),
+			prepare: func(_ *[][]string) {
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
+						return urls[:1], urls[1:], nil
+					})
+			},
+			expectedSummary:  &dto.URLImportSummary{Total: 2, Imported: 1, Existing: 1},
+			expectedErrorRow: []int{},
+		},
+		{
+			name: "Errors limit",
+			next: source(rows, io.EOF),
+			prepare: func(_ *[][]string) {
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Times(3).
+					DoAndReturn(func(_ context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
+						return urls, nil, nil
+					})
+			},
+			errorsLimit:      2,
+			expectedSummary:  &dto.URLImportSummary{Total: 9, Imported: 5, Failed: 4},
+			expectedErrorRow: []int{3, 4},
+		},
+		{
+			name: "Invalid row from source",
+			next: func() func() (dto.URLBatchRequest, error) {
+				called := false
+				return func() (dto.URLBatchRequest, error) {
+					if called {
+						return dto.URLBatchRequest{}, io.EOF
+					}
+					called = true
+					return dto.URLBatchRequest{}, fmt.Errorf("%w: malformed json", urlErr.ErrInvalidImportRow)
+				}
+			}(),
+			expectedSummary:  &dto.URLImportSummary{Total: 1, Failed: 1},
+			expectedErrorRow: []int{1},
+		},
+		{
+			name: "Repository error stops import",
+			next: source(rows, io.EOF),
+			prepare: func(_ *[][]string) {
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, urls []model.URL) ([]model.URL, []model.URL, error) {
+						return urls, nil, nil
+					})
+				u.urlRepository.EXPECT().InsertAll(gomock.Any(), gomock.Any()).Return(nil, nil, repoErr)
+			},
+			expectedSummary:  &dto.URLImportSummary{Total: 8, Imported: 2, Failed: 4},
+			expectedErrorIs:  repoErr,
+			expectedErrorRow: []int{3, 4, 6, 7},
+		},
+		{
+			name:             "Source error stops import",
+			next:             source(rows[:1], repoErr),
+			expectedSummary:  &dto.URLImportSummary{Total: 1},
+			expectedErrorIs:  repoErr,
+			expectedErrorRow: []int{},
+		},
+	}
+	for _, test := range testCases {
+		u.T().Run(test.name, func(t *testing.T) {
+			var chunks [][]string
+			if test.prepare != nil {
+				test.prepare(&chunks)
+			}
+
+			service := NewURLService(u.urlRepository, 0, RedirectConfig{}, nil, u.logger)
+			service.importChunkSize = 2
+			if test.errorsLimit != 0 {
+				service.importErrors = test.errorsLimit
+			}
+			summary, err := service.Import(context.Background(), test.next, "http://localhost:8080", "user")
+			if test.expectedErrorIs != nil {
+				assert.ErrorIs(t, err, test.expectedErrorIs)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedChunks, chunks)
+
+			rows := make([]int, 0, len(summary.Errors))
+			for _, v := range summary.Errors {
+				assert.True(t, strings.HasPrefix(v.Error, urlErr.ErrInvalidImportRow.Error()))
+				rows = append(rows, v.Row)
+			}
+			assert.Equal(t, test.expectedErrorRow, rows)
+			summary.Errors = nil
+			assert.Equal(t, test.expectedSummary, summary)
+		})
+	}
+}
+
 func generateURL(rnd rand.Source) model.URL {
 	url := model.URL{
 		ID:            uuid.New().String(),
@@ -471,6 +975,7 @@ func generateURL(rnd rand.Source) model.URL {
 		CorrelationID: generateString(5, rnd),
 		UserID:        uuid.New().String(),
 		DeletedFlag:   false,
+		CreatedAt:     time.Now().UTC(),
 	}
 
 	return url
@@ -11,4 +11,12 @@ var (
 	ErrEmptyRequest                 = errors.New("unable to handle empty request")
 	ErrDuplicatedKeys               = errors.New("duplicated keys in batch")
 	ErrURLAlreadyExists             = errors.New("url already exists")
+	ErrInvalidImportRow             = errors.New("invalid import row")
+	ErrInvalidBatchItem             = errors.New("invalid batch item")
+	ErrInvalidQuery                 = errors.New("invalid query")
+	ErrInvalidURL                   = errors.New("invalid url")
+	ErrInvalidRedirect              = errors.New("invalid redirect")
+	ErrInvalidRule                  = errors.New("invalid redirect rule")
+	ErrRuleNotFound                 = errors.New("redirect rule not found")
+	ErrCountryBlocked               = errors.New("url unavailable in visitor country")
 )
@@ -42,6 +42,14 @@ func (v *ValueError) Error() string {
 	return fmt.Sprintf("%s %s %s", v.caller, v.message, v.err)
 }
 
+// Message returns the error message without caller and wrapped error.
+//
+// No parameters.
+// Returns a string.
+func (v *ValueError) Message() string {
+	return v.message
+}
+
 // Unwrap returns the error that has been wrapped by ValueError.
 // No parameters. Returns an error.
 func (v *ValueError) Unwrap() error {
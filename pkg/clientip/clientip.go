@@ -0,0 +1,218 @@
+// Package clientip resolves client IP addresses of HTTP and gRPC requests passed through trusted proxies.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// Headers carrying client IP addresses, in order of precedence.
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderForwarded    = "Forwarded"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+// Subnets represents a list of IP networks.
+type Subnets []*net.IPNet
+
+// ParseSubnets parses comma separated list of CIDRs skipping empty values.
+func ParseSubnets(s string) (Subnets, error) {
+	var subnets Subnets
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, apperr.NewValueError(fmt.Sprintf("invalid subnet %q", v), apperr.Caller(), err)
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+// Contains reports whether the IP address belongs to any of the subnets, false for invalid addresses.
+func (s Subnets) Contains(ip string) bool {
+	return s.contains(net.ParseIP(ip))
+}
+
+func (s Subnets) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, subnet := range s {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver represents resolver of client IP addresses.
+//
+// Client IP headers are honored only for requests of trusted proxies, otherwise the peer address is the client IP.
+// X-Forwarded-For and Forwarded chains are walked from the nearest hop, the first hop not of a trusted proxy
+// is the client IP, so addresses prepended by clients are ignored.
+type Resolver struct {
+	proxies Subnets
+	// network is the network of trusted gRPC peers, empty if none.
+	network string
+}
+
+// NewResolver returns a new instance of Resolver trusting proxies of the subnets.
+func NewResolver(proxies Subnets) *Resolver {
+	return &Resolver{proxies: proxies}
+}
+
+// TrustNetwork returns a copy of the resolver also trusting gRPC peers connected over the network,
+// e.g. an in-process listener only the gRPC-Gateway of the process connects to.
+func (r *Resolver) TrustNetwork(network string) *Resolver {
+	return &Resolver{proxies: r.proxies, network: network}
+}
+
+// FromRequest returns client IP of the HTTP request, empty if the remote address is invalid.
+//
+// It can be used as echo.IPExtractor.
+func (r *Resolver) FromRequest(req *http.Request) string {
+	return r.resolve(parseIP(req.RemoteAddr), req.Header.Values)
+}
+
+// FromContext returns client IP of the incoming gRPC request, empty if the peer address is not an IP address
+// and the peer is not of the trusted network.
+//
+// Client IP headers are taken from the incoming metadata, peers of the trusted network must pass them.
+func (r *Resolver) FromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if r.network != "" && p.Addr.Network() == r.network {
+		return r.fromHeaders(nil, md.Get)
+	}
+
+	var addr net.IP
+	if tcpAddr, ok := p.Addr.(*net.TCPAddr); ok {
+		addr = tcpAddr.IP
+	} else {
+		addr = parseIP(p.Addr.String())
+	}
+
+	return r.resolve(addr, md.Get)
+}
+
+// resolve returns client IP of the request from the peer address and values of the request headers.
+func (r *Resolver) resolve(addr net.IP, header func(string) []string) string {
+	if addr == nil {
+		return ""
+	}
+
+	if !r.proxies.contains(addr) {
+		return addr.String()
+	}
+
+	return r.fromHeaders(addr, header)
+}
+
+// fromHeaders returns client IP passed by the trusted proxy of the address in values of the request headers,
+// the proxy address if none is passed, empty for unknown address.
+func (r *Resolver) fromHeaders(addr net.IP, header func(string) []string) string {
+	for _, hops := range [][]string{
+		forwardedFor(header(HeaderForwardedFor)),
+		forwarded(header(HeaderForwarded)),
+		realIP(header(HeaderRealIP)),
+	} {
+		if len(hops) == 0 {
+			continue
+		}
+
+		client := addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := parseIP(hops[i])
+			if ip == nil {
+				break
+			}
+			client = ip
+			if !r.proxies.contains(ip) {
+				break
+			}
+		}
+		return ipString(client)
+	}
+
+	return ipString(addr)
+}
+
+// ipString returns the IP address as a string, empty for nil.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// forwardedFor returns hops of X-Forwarded-For header values from the farthest one.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwarded returns "for" parameters of RFC 7239 Forwarded header values from the farthest one,
+// elements without the parameter are empty hops.
+func forwarded(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			var hop string
+			for _, pair := range strings.Split(element, ";") {
+				key, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hop = strings.Trim(v, `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// realIP returns the last X-Real-IP header value as a single hop.
+func realIP(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1:]
+}
+
+// parseIP parses IP address optionally with port or in brackets, returns nil for invalid addresses.
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+}
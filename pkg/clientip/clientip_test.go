@@ -0,0 +1,230 @@
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestParseSubnets(t *testing.T) {
+	subnets, err := ParseSubnets(" 10.0.0.0/8, ,2001:db8::/32,")
+	require.NoError(t, err)
+	assert.Len(t, subnets, 2)
+	assert.True(t, subnets.Contains("10.1.2.3"))
+	assert.True(t, subnets.Contains("2001:db8::1"))
+	assert.False(t, subnets.Contains("192.0.2.1"))
+	assert.False(t, subnets.Contains("not an ip"))
+
+	subnets, err = ParseSubnets("")
+	require.NoError(t, err)
+	assert.Empty(t, subnets)
+
+	_, err = ParseSubnets("10.0.0.0/8,10.0.0.1")
+	assert.Error(t, err)
+}
+
+func TestFromRequest(t *testing.T) {
+	proxies, err := ParseSubnets("10.0.0.0/8,2001:db8:1::/48")
+	require.NoError(t, err)
+	resolver := NewResolver(proxies)
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		expectedIP string
+	}{
+		{
+			name:       "Direct client",
+			remoteAddr: "192.0.2.1:1234",
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "Headers of untrusted peer are ignored",
+			remoteAddr: "192.0.2.1:1234",
+			headers: map[string][]string{
+				HeaderForwardedFor: {"198.51.100.1"},
+				HeaderForwarded:    {"for=198.51.100.2"},
+				HeaderRealIP:       {"198.51.100.3"},
+			},
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "Trusted proxy without headers",
+			remoteAddr: "10.0.0.1:1234",
+			expectedIP: "10.0.0.1",
+		},
+		{
+			name:       "X-Forwarded-For",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwardedFor: {"198.51.100.1"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For prepended by client is ignored",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwardedFor: {"203.0.113.1, 198.51.100.1, 10.0.0.2"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For in several headers",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwardedFor: {"203.0.113.1", "198.51.100.1, 10.0.0.2"}},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For of trusted proxies only",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwardedFor: {"10.0.0.3, 10.0.0.2"}},
+			expectedIP: "10.0.0.3",
+		},
+		{
+			name:       "Invalid X-Forwarded-For hop",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwardedFor: {"198.51.100.1, unknown, 10.0.0.2"}},
+			expectedIP: "10.0.0.2",
+		},
+		{
+			name:       "X-Forwarded-For takes precedence",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				HeaderForwardedFor: {"198.51.100.1"},
+				HeaderForwarded:    {"for=198.51.100.2"},
+				HeaderRealIP:       {"198.51.100.3"},
+			},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "Forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwarded: {`for=203.0.113.1, for="[2001:db8:cafe::17]:4711";proto=https, For=10.0.0.2;by=10.0.0.1`}},
+			expectedIP: "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded obfuscated hop",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderForwarded: {"for=_hidden, for=10.0.0.2"}},
+			expectedIP: "10.0.0.2",
+		},
+		{
+			name:       "Forwarded takes precedence over X-Real-IP",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				HeaderForwarded: {"for=198.51.100.2"},
+				HeaderRealIP:    {"198.51.100.3"},
+			},
+			expectedIP: "198.51.100.2",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderRealIP: {"198.51.100.3"}},
+			expectedIP: "198.51.100.3",
+		},
+		{
+			name:       "Invalid X-Real-IP",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{HeaderRealIP: {"unknown"}},
+			expectedIP: "10.0.0.1",
+		},
+		{
+			name:       "IPv6 trusted proxy",
+			remoteAddr: "[2001:db8:1::1]:1234",
+			headers:    map[string][]string{HeaderRealIP: {"198.51.100.3"}},
+			expectedIP: "198.51.100.3",
+		},
+		{
+			name:       "Invalid remote address",
+			remoteAddr: "pipe",
+			headers:    map[string][]string{HeaderRealIP: {"198.51.100.3"}},
+			expectedIP: "",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			request.RemoteAddr = test.remoteAddr
+			for name, values := range test.headers {
+				for _, value := range values {
+					request.Header.Add(name, value)
+				}
+			}
+
+			assert.Equal(t, test.expectedIP, resolver.FromRequest(request))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	proxies, err := ParseSubnets("10.0.0.0/8,::1/128")
+	require.NoError(t, err)
+	resolver := NewResolver(proxies).TrustNetwork("bufconn")
+	md := metadata.Pairs("x-forwarded-for", "203.0.113.1, 198.51.100.1")
+
+	testCases := []struct {
+		name       string
+		ctx        context.Context
+		expectedIP string
+	}{
+		{
+			name:       "Untrusted peer",
+			ctx:        peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234}}),
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:       "Loopback peer is not trusted",
+			ctx:        peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}}),
+			expectedIP: "127.0.0.1",
+		},
+		{
+			name:       "Trusted peer",
+			ctx:        peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}}),
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "Peer of trusted network",
+			ctx:        peer.NewContext(metadata.NewIncomingContext(context.Background(), md), &peer.Peer{Addr: networkAddr("bufconn")}),
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "Peer of trusted network without metadata",
+			ctx:        peer.NewContext(context.Background(), &peer.Peer{Addr: networkAddr("bufconn")}),
+			expectedIP: "",
+		},
+		{
+			name:       "Trusted peer without metadata",
+			ctx:        peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv6loopback, Port: 1234}}),
+			expectedIP: "::1",
+		},
+		{
+			name:       "Non-IP peer of untrusted network",
+			ctx:        peer.NewContext(context.Background(), &peer.Peer{Addr: &net.UnixAddr{Name: "/tmp/grpc.sock", Net: "unix"}}),
+			expectedIP: "",
+		},
+		{
+			name:       "No peer",
+			ctx:        metadata.NewIncomingContext(context.Background(), md),
+			expectedIP: "",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expectedIP, resolver.FromContext(test.ctx))
+		})
+	}
+}
+
+// networkAddr is a non-IP address of the network.
+type networkAddr string
+
+func (a networkAddr) Network() string { return string(a) }
+func (a networkAddr) String() string  { return string(a) }
@@ -0,0 +1,139 @@
+// Package geoip resolves countries of IP addresses from a local MaxMind DB file, e.g. GeoLite2-Country.
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+// DefaultReloadInterval is an interval the database file is checked for changes at by default.
+const DefaultReloadInterval = time.Minute
+
+// record represents country fields of MaxMind country and city databases.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Database represents MaxMind DB file loaded into memory and reloaded when the file changes.
+//
+// Lookups are served by the last successfully loaded file.
+type Database struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+	logger  *zap.Logger
+}
+
+// Open loads the MaxMind DB file at the path.
+func Open(path string, logger *zap.Logger) (*Database, error) {
+	d := &Database{path: path, logger: logger}
+	if _, err := d.Reload(); err != nil {
+		return nil, fmt.Errorf("%s %w", apperr.Caller(), err)
+	}
+
+	return d, nil
+}
+
+// Country returns upper-cased ISO 3166-1 alpha-2 code of the IP address country,
+// the registered country is returned for addresses without one.
+//
+// Empty code is returned for invalid addresses and addresses missing in the database.
+func (d *Database) Country(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+
+	d.mu.RLock()
+	reader := d.reader
+	d.mu.RUnlock()
+
+	var r record
+	if err := reader.Lookup(addr, &r); err != nil {
+		d.logger.Debug("unable to look up country", zap.String("ip", ip), zap.Error(err))
+		return ""
+	}
+
+	if r.Country.ISOCode != "" {
+		return r.Country.ISOCode
+	}
+	return r.RegisteredCountry.ISOCode
+}
+
+// Reload loads the file again if its modification time or size changed and reports whether it was loaded.
+//
+// The loaded database is kept if the file can not be loaded.
+func (d *Database) Reload() (bool, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return false, apperr.NewValueError(fmt.Sprintf("unable to stat file %s", d.path), apperr.Caller(), err)
+	}
+
+	d.mu.RLock()
+	unchanged := d.reader != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size
+	d.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	// The file is read into memory rather than mapped, so it may be replaced while lookups are served.
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return false, apperr.NewValueError(fmt.Sprintf("unable to read file %s", d.path), apperr.Caller(), err)
+	}
+
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return false, apperr.NewValueError(fmt.Sprintf("invalid MaxMind DB file %s", d.path), apperr.Caller(), err)
+	}
+
+	d.mu.Lock()
+	d.reader = reader
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.mu.Unlock()
+
+	return true, nil
+}
+
+// Run reloads the database on changes of the file checked every interval until the context is canceled,
+// zero interval means DefaultReloadInterval.
+func (d *Database) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := d.Reload()
+		if err != nil {
+			d.logger.Error("Unable to reload GeoIP database, previous one is used", zap.String("path", d.path), zap.Error(err))
+		} else if reloaded {
+			d.logger.Info("GeoIP database reloaded", zap.String("path", d.path))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package geoip
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/geoip/geoiptest"
+)
+
+// fixture is a path of the database generated from fixtureNetworks, regenerate it with
+// GEOIP_UPDATE_FIXTURE=1 go test ./pkg/geoip.
+const fixture = "testdata/countries.mmdb"
+
+var fixtureNetworks = map[string]string{
+	"192.0.2.0/24":    "US",
+	"198.51.100.0/25": "DE",
+	"203.0.113.7/32":  "JP",
+	"2001:db8::/32":   "FR",
+}
+
+func TestFixture(t *testing.T) {
+	generated, err := geoiptest.Countries(fixtureNetworks)
+	require.NoError(t, err)
+
+	if os.Getenv("GEOIP_UPDATE_FIXTURE") != "" {
+		require.NoError(t, os.WriteFile(fixture, generated, 0o644))
+	}
+
+	stored, err := os.ReadFile(fixture)
+	require.NoError(t, err)
+	assert.Equal(t, generated, stored, "fixture is outdated, regenerate it")
+}
+
+func TestCountry(t *testing.T) {
+	db, err := Open(fixture, zap.NewNop())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		ip      string
+		country string
+	}{
+		{ip: "192.0.2.1", country: "US"},
+		{ip: "192.0.2.255", country: "US"},
+		{ip: "198.51.100.127", country: "DE"},
+		{ip: "198.51.100.128", country: ""},
+		{ip: "203.0.113.7", country: "JP"},
+		{ip: "203.0.113.8", country: ""},
+		{ip: "2001:db8::1", country: "FR"},
+		{ip: "2001:db9::1", country: ""},
+		{ip: "::ffff:192.0.2.1", country: "US"},
+		{ip: "10.0.0.1", country: ""},
+		{ip: "not an ip", country: ""},
+		{ip: "", country: ""},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.country, db.Country(test.ip), test.ip)
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"), zap.NewNop())
+	assert.Error(t, err)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0o644))
+	_, err = Open(invalid, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.mmdb")
+	require.NoError(t, geoiptest.WriteCountries(path, map[string]string{"192.0.2.0/24": "US"}))
+
+	db, err := Open(path, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "US", db.Country("192.0.2.1"))
+
+	reloaded, err := db.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file must not be reloaded")
+
+	require.NoError(t, geoiptest.WriteCountries(path, map[string]string{"192.0.2.0/24": "CA", "198.51.100.0/24": "MX"}))
+	reloaded, err = db.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "CA", db.Country("192.0.2.1"))
+	assert.Equal(t, "MX", db.Country("198.51.100.1"))
+
+	require.NoError(t, os.WriteFile(path, []byte("broken database"), 0o644))
+	_, err = db.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "CA", db.Country("192.0.2.1"), "previous database must be used after failed reload")
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.mmdb")
+	require.NoError(t, geoiptest.WriteCountries(path, map[string]string{"192.0.2.0/24": "US"}))
+
+	db, err := Open(path, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.Run(ctx, 10*time.Millisecond)
+	}()
+
+	require.NoError(t, geoiptest.WriteCountries(path, map[string]string{"192.0.2.0/24": "GB", "198.51.100.0/24": "GB"}))
+	assert.Eventually(t, func() bool { return db.Country("192.0.2.1") == "GB" }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
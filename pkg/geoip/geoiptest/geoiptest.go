@@ -0,0 +1,223 @@
+// Package geoiptest writes tiny MaxMind DB files for tests.
+//
+// Files have the layout of GeoLite2-Country databases: an IPv6 search tree of 24-bit records
+// with IPv4 networks at ::/96 and {"country": {"iso_code": code}} data records.
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+)
+
+// MaxMind DB data types used by the files.
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+const (
+	recordSize         = 24
+	dataSeparatorSize  = 16
+	maxRecordValue     = 1<<recordSize - 1
+	ipv4SubtreeDepth   = 96
+	metadataStartBytes = "\xAB\xCD\xEFMaxMind.com"
+)
+
+// record is a search tree record pointing to nothing, to another node or to data at the offset.
+type record struct {
+	kind  int
+	value int
+}
+
+const (
+	recordEmpty = iota
+	recordNode
+	recordData
+)
+
+// WriteCountries writes to the path MaxMind DB file resolving addresses of the networks to countries.
+//
+// Networks are IPv4 or IPv6 CIDRs mapped to ISO 3166-1 alpha-2 codes, they must not overlap.
+func WriteCountries(path string, countries map[string]string) error {
+	db, err := Countries(countries)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, db, 0o644)
+}
+
+// Countries returns MaxMind DB file resolving addresses of the networks to countries, see WriteCountries.
+func Countries(countries map[string]string) ([]byte, error) {
+	networks := make([]string, 0, len(countries))
+	for network := range countries {
+		networks = append(networks, network)
+	}
+	slices.Sort(networks)
+
+	var data bytes.Buffer
+	offsets := make(map[string]int)
+	nodes := [][2]record{{}}
+	for _, network := range networks {
+		code := countries[network]
+		offset, ok := offsets[code]
+		if !ok {
+			offset = data.Len()
+			offsets[code] = offset
+			writeMap(&data, 1)
+			writeString(&data, "country")
+			writeMap(&data, 1)
+			writeString(&data, "iso_code")
+			writeString(&data, code)
+		}
+
+		address, prefix, err := treePath(network)
+		if err != nil {
+			return nil, err
+		}
+		if nodes, err = insert(nodes, address, prefix, offset); err != nil {
+			return nil, fmt.Errorf("network %s: %w", network, err)
+		}
+	}
+
+	nodeCount := len(nodes)
+	if nodeCount+dataSeparatorSize+data.Len() > maxRecordValue {
+		return nil, fmt.Errorf("database is too large for %d-bit records", recordSize)
+	}
+
+	var db bytes.Buffer
+	for _, node := range nodes {
+		for _, r := range node {
+			value := nodeCount
+			switch r.kind {
+			case recordNode:
+				value = r.value
+			case recordData:
+				value = nodeCount + dataSeparatorSize + r.value
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	db.Write(make([]byte, dataSeparatorSize))
+	db.Write(data.Bytes())
+
+	db.WriteString(metadataStartBytes)
+	writeMap(&db, 9)
+	writeString(&db, "binary_format_major_version")
+	writeUint(&db, typeUint16, 2)
+	writeString(&db, "binary_format_minor_version")
+	writeUint(&db, typeUint16, 0)
+	writeString(&db, "build_epoch")
+	writeUint(&db, typeUint64, 0)
+	writeString(&db, "database_type")
+	writeString(&db, "GeoLite2-Country")
+	writeString(&db, "description")
+	writeMap(&db, 1)
+	writeString(&db, "en")
+	writeString(&db, "geoiptest countries")
+	writeString(&db, "ip_version")
+	writeUint(&db, typeUint16, 6)
+	writeString(&db, "languages")
+	writeControl(&db, typeArray, 1)
+	writeString(&db, "en")
+	writeString(&db, "node_count")
+	writeUint(&db, typeUint32, uint64(nodeCount))
+	writeString(&db, "record_size")
+	writeUint(&db, typeUint16, recordSize)
+
+	return db.Bytes(), nil
+}
+
+// treePath returns 128-bit search tree path of the network and its length, IPv4 networks are placed at ::/96.
+func treePath(network string) (net.IP, int, error) {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	if ip4 := ipNet.IP.To4(); ip4 != nil {
+		address := make(net.IP, net.IPv6len)
+		copy(address[ipv4SubtreeDepth/8:], ip4)
+		return address, ipv4SubtreeDepth + ones, nil
+	}
+
+	return ipNet.IP.To16(), ones, nil
+}
+
+// insert points records along the path of the address prefix to the data offset.
+func insert(nodes [][2]record, address net.IP, prefix int, offset int) ([][2]record, error) {
+	if prefix == 0 {
+		return nil, fmt.Errorf("network must have non-zero prefix")
+	}
+
+	node := 0
+	for i := 0; i < prefix; i++ {
+		bit := int(address[i/8]>>(7-i%8)) & 1
+		r := nodes[node][bit]
+
+		if i == prefix-1 {
+			if r.kind != recordEmpty {
+				return nil, fmt.Errorf("overlapping networks are not supported")
+			}
+			nodes[node][bit] = record{kind: recordData, value: offset}
+			return nodes, nil
+		}
+
+		switch r.kind {
+		case recordData:
+			return nil, fmt.Errorf("overlapping networks are not supported")
+		case recordEmpty:
+			nodes = append(nodes, [2]record{})
+			r = record{kind: recordNode, value: len(nodes) - 1}
+			nodes[node][bit] = r
+		}
+		node = r.value
+	}
+
+	return nodes, nil
+}
+
+// writeControl writes control byte of the data type and size, sizes up to 284 are supported.
+func writeControl(buf *bytes.Buffer, dataType int, size int) {
+	sizeBits, sizeExtension := size, -1
+	if size >= 29 {
+		sizeBits, sizeExtension = 29, size-29
+	}
+
+	if dataType > typeMap {
+		buf.WriteByte(byte(sizeBits))
+		buf.WriteByte(byte(dataType - typeMap))
+	} else {
+		buf.WriteByte(byte(dataType<<5 | sizeBits))
+	}
+
+	if sizeExtension >= 0 {
+		buf.WriteByte(byte(sizeExtension))
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeControl(buf, typeString, len(s))
+	buf.WriteString(s)
+}
+
+func writeMap(buf *bytes.Buffer, size int) {
+	writeControl(buf, typeMap, size)
+}
+
+// writeUint writes unsigned integer of the data type in the fewest big-endian bytes.
+func writeUint(buf *bytes.Buffer, dataType int, value uint64) {
+	b := binary.BigEndian.AppendUint64(nil, value)
+	b = bytes.TrimLeft(b, "\x00")
+	writeControl(buf, dataType, len(b))
+	buf.Write(b)
+}
@@ -0,0 +1,121 @@
+// Package idempotency stores responses of requests by idempotency keys for a limited time.
+//
+// Responses are kept in memory of a single process, so retries are recognized only by the instance
+// which served the first request. Deployments of several instances must route requests of a user
+// to the same instance (e.g. by sticky sessions), otherwise retries are executed again.
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Errors
+var (
+	ErrKeyReused  = errors.New("idempotency key is reused with a different request")
+	ErrInProgress = errors.New("request with the idempotency key is in progress")
+	ErrStoreFull  = errors.New("idempotency store is full")
+)
+
+// record represents request reserved by idempotency key, response is nil until the request is completed.
+type record struct {
+	requestHash string
+	response    any
+	expiresAt   time.Time
+}
+
+// Store represents in-memory store of responses by user and idempotency key.
+type Store struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxRecords int
+	records    map[string]*record
+	nextPurge  time.Time
+	now        func() time.Time
+}
+
+// NewStore returns a new instance of Store, responses are kept for ttl since the request started.
+//
+// At most maxRecords keys are kept, keys are never evicted before they expire.
+func NewStore(ttl time.Duration, maxRecords int) *Store {
+	s := &Store{
+		ttl:        ttl,
+		maxRecords: maxRecords,
+		records:    make(map[string]*record),
+		now:        time.Now,
+	}
+	return s
+}
+
+// Begin starts request of the user with the key.
+//
+// Returns stored response if the same request is completed, ErrKeyReused if the key is used
+// with a different request and ErrInProgress if the same request is not completed yet.
+// Otherwise the key is reserved and nil is returned, the caller must Complete or Release the key then.
+// ErrStoreFull is returned if a new key can't be reserved until other keys expire.
+func (s *Store) Begin(userID string, key string, requestHash string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.purge(now)
+
+	if r, ok := s.records[recordKey(userID, key)]; ok && now.Before(r.expiresAt) {
+		switch {
+		case r.requestHash != requestHash:
+			return nil, ErrKeyReused
+		case r.response == nil:
+			return nil, ErrInProgress
+		default:
+			return r.response, nil
+		}
+	}
+
+	if len(s.records) >= s.maxRecords {
+		s.nextPurge = time.Time{}
+		s.purge(now)
+		if len(s.records) >= s.maxRecords {
+			return nil, ErrStoreFull
+		}
+	}
+
+	s.records[recordKey(userID, key)] = &record{requestHash: requestHash, expiresAt: now.Add(s.ttl)}
+	return nil, nil
+}
+
+// Complete stores response of the request reserved by Begin.
+func (s *Store) Complete(userID string, key string, response any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[recordKey(userID, key)]; ok {
+		r.response = response
+	}
+}
+
+// Release removes the key reserved by Begin, so the request can be retried.
+func (s *Store) Release(userID string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, recordKey(userID, key))
+}
+
+// purge removes expired records, at most once per half of ttl.
+func (s *Store) purge(now time.Time) {
+	if now.Before(s.nextPurge) {
+		return
+	}
+
+	for k, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, k)
+		}
+	}
+	s.nextPurge = now.Add(s.ttl / 2)
+}
+
+func recordKey(userID string, key string) string {
+	return userID + "\x00" + key
+}
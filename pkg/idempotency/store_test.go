@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore(time.Hour, 10)
+	store.now = func() time.Time { return now }
+
+	response, err := store.Begin("user", "key", "hash")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	_, err = store.Begin("user", "key", "hash")
+	assert.ErrorIs(t, err, ErrInProgress)
+
+	store.Complete("user", "key", "response")
+
+	response, err = store.Begin("user", "key", "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "response", response)
+
+	_, err = store.Begin("user", "key", "other hash")
+	assert.ErrorIs(t, err, ErrKeyReused)
+
+	response, err = store.Begin("other user", "key", "other hash")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	now = now.Add(time.Hour)
+	response, err = store.Begin("user", "key", "other hash")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+	assert.Len(t, store.records, 1)
+}
+
+func TestStore_Release(t *testing.T) {
+	store := NewStore(time.Hour, 10)
+
+	_, err := store.Begin("user", "key", "hash")
+	require.NoError(t, err)
+	store.Release("user", "key")
+
+	response, err := store.Begin("user", "key", "other hash")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+func TestStore_Full(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore(time.Hour, 2)
+	store.now = func() time.Time { return now }
+
+	_, err := store.Begin("user", "key1", "hash")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = store.Begin("user", "key2", "hash")
+	require.NoError(t, err)
+
+	_, err = store.Begin("user", "key3", "hash")
+	assert.ErrorIs(t, err, ErrStoreFull)
+
+	_, err = store.Begin("user", "key1", "hash")
+	assert.ErrorIs(t, err, ErrInProgress, "reserved keys are found in full store")
+
+	now = now.Add(time.Hour - time.Second)
+	_, err = store.Begin("user", "key3", "hash")
+	require.NoError(t, err, "expired keys are purged from full store")
+	assert.Len(t, store.records, 2)
+}
@@ -13,11 +13,28 @@ import (
 	"go.uber.org/zap"
 )
 
+// Role represents user role granted by token.
+type Role string
+
+// Roles
+const (
+	RoleUser        Role = "user"
+	RoleAdmin       Role = "admin"
+	RoleStatsReader Role = "stats-reader"
+)
+
 // JWTManager represents the JWT manager.
 type JWTManager struct {
 	logger    *zap.Logger
 	TokenName string
 	secretKey string
+	userRoles map[string][]Role
+}
+
+// UserClaims represents user data stored in token.
+type UserClaims struct {
+	UserID string
+	Roles  []Role
 }
 
 const (
@@ -27,6 +44,7 @@ const (
 type claims struct {
 	jwt.RegisteredClaims
 	UserID string
+	Roles  []Role
 }
 
 // InitJWTManager returns a new instance of JWTManager.
@@ -35,17 +53,29 @@ func InitJWTManager(tokenName string, secretKey string, logger *zap.Logger) *JWT
 		logger:    logger,
 		TokenName: tokenName,
 		secretKey: secretKey,
+		userRoles: make(map[string][]Role),
 	}
 	return j
 }
 
-// BuildJWTString creates JWT token with userID.
+// GrantRoles grants roles to user, roles are added to tokens built for the user.
+func (j *JWTManager) GrantRoles(userID string, roles ...Role) {
+	j.userRoles[userID] = append(j.userRoles[userID], roles...)
+}
+
+// BuildJWTString creates JWT token with new random userID.
 func (j *JWTManager) BuildJWTString() (string, error) {
+	return j.BuildJWTStringWithUserID(uuid.New().String())
+}
+
+// BuildJWTStringWithUserID creates JWT token with given userID.
+func (j *JWTManager) BuildJWTStringWithUserID(userID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExp)),
 		},
-		UserID: uuid.New().String(),
+		UserID: userID,
+		Roles:  append([]Role{RoleUser}, j.userRoles[userID]...),
 	})
 
 	// создаём строку токена
@@ -59,6 +89,16 @@ func (j *JWTManager) BuildJWTString() (string, error) {
 
 // GetUserID returns userID from JWT token.
 func (j *JWTManager) GetUserID(tokenString string) (string, error) {
+	userClaims, err := j.ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	return userClaims.UserID, nil
+}
+
+// ParseToken returns userID and roles from JWT token.
+func (j *JWTManager) ParseToken(tokenString string) (*UserClaims, error) {
 	claims := &claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims,
 		func(t *jwt.Token) (interface{}, error) {
@@ -68,13 +108,31 @@ func (j *JWTManager) GetUserID(tokenString string) (string, error) {
 			return []byte(j.secretKey), nil
 		})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !token.Valid {
 		j.logger.Warn("token is not valid", zap.Error(err))
-		return "", apperr.NewValueError("token is not valid", apperr.Caller(), errors.New("token is not valid"))
+		return nil, apperr.NewValueError("token is not valid", apperr.Caller(), errors.New("token is not valid"))
+	}
+
+	// Tokens issued before roles were introduced belong to regular users
+	if len(claims.Roles) == 0 {
+		claims.Roles = []Role{RoleUser}
+	}
+
+	return &UserClaims{UserID: claims.UserID, Roles: claims.Roles}, nil
+}
+
+// HasAnyRole reports whether user has at least one of the roles.
+func (u *UserClaims) HasAnyRole(roles ...Role) bool {
+	for _, required := range roles {
+		for _, role := range u.Roles {
+			if role == required {
+				return true
+			}
+		}
 	}
 
-	return claims.UserID, nil
+	return false
 }
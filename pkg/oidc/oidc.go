@@ -0,0 +1,344 @@
+// Package oidc implements OpenID Connect authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/apperr"
+)
+
+const (
+	discoveryPath  = "/.well-known/openid-configuration"
+	requestTimeout = 10 * time.Second
+	// minKeysRefreshInterval limits refreshes of provider keys triggered by unknown key IDs.
+	minKeysRefreshInterval = time.Minute
+)
+
+// Errors
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrNonceMismatch  = errors.New("id token nonce mismatch")
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrTokenExchange  = errors.New("unable to exchange authorization code")
+)
+
+// Config represents OpenID Connect client configuration.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// IDTokenClaims represents claims of a validated ID token.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// Provider represents a discovered OpenID Connect provider.
+type Provider struct {
+	config   Config
+	metadata metadata
+	client   *http.Client
+	mu       sync.RWMutex
+	keys     map[string]*rsa.PublicKey
+	// refreshMu serializes refreshes of keys, refreshedAt is the time of the last one.
+	refreshMu   sync.Mutex
+	refreshedAt time.Time
+	logger      *zap.Logger
+}
+
+// NewProvider performs provider discovery and loads its signing keys.
+func NewProvider(ctx context.Context, config Config, logger *zap.Logger) (*Provider, error) {
+	p := &Provider{
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+		keys:   make(map[string]*rsa.PublicKey),
+		logger: logger,
+	}
+
+	if len(p.config.Scopes) == 0 {
+		p.config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + discoveryPath
+	if err := p.getJSON(ctx, discoveryURL, &p.metadata); err != nil {
+		return nil, apperr.NewValueError("unable to discover provider", apperr.Caller(), err)
+	}
+
+	if strings.TrimSuffix(p.metadata.Issuer, "/") != strings.TrimSuffix(config.Issuer, "/") {
+		return nil, apperr.NewValueError(fmt.Sprintf("issuer mismatch: expected %s, got %s", config.Issuer, p.metadata.Issuer), apperr.Caller(), ErrInvalidIDToken)
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	logger.Info("OIDC provider discovered", zap.String("issuer", p.metadata.Issuer))
+	return p, nil
+}
+
+// Issuer returns issuer identifier of the provider.
+func (p *Provider) Issuer() string {
+	return p.metadata.Issuer
+}
+
+// AuthCodeURL returns provider URL the user agent is redirected to in order to log in.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange exchanges authorization code for the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", apperr.NewValueError("unable to create token request", apperr.Caller(), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", apperr.NewValueError("token request failed", apperr.Caller(), err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", apperr.NewValueError("unable to decode token response", apperr.Caller(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", apperr.NewValueError(fmt.Sprintf("token endpoint returned %d %s", resp.StatusCode, token.Error), apperr.Caller(), ErrTokenExchange)
+	}
+
+	if token.IDToken == "" {
+		return "", apperr.NewValueError("token response has no id_token", apperr.Caller(), ErrTokenExchange)
+	}
+
+	return token.IDToken, nil
+}
+
+// VerifyIDToken validates signature, issuer, audience, expiration and nonce of the ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			return p.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(p.metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, apperr.NewValueError(err.Error(), apperr.Caller(), ErrInvalidIDToken)
+	}
+
+	if claims.Subject == "" {
+		return nil, apperr.NewValueError("id token has no subject", apperr.Caller(), ErrInvalidIDToken)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, apperr.NewValueError("nonce mismatch", apperr.Caller(), ErrNonceMismatch)
+	}
+
+	return claims, nil
+}
+
+// key returns signing key by id, refreshing provider keys once if key is unknown (key rotation).
+//
+// Keys are refreshed at most once per minKeysRefreshInterval, so tokens with unknown keys do not flood the provider.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if err := p.refreshStaleKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, apperr.NewValueError(fmt.Sprintf("key %s not found", kid), apperr.Caller(), ErrUnknownKey)
+}
+
+func (p *Provider) lookupKey(kid string) (*rsa.PublicKey, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// Providers with a single key may omit kid in token header
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// refreshStaleKeys refreshes provider keys unless they were refreshed within minKeysRefreshInterval,
+// concurrent callers wait for a single refresh.
+func (p *Provider) refreshStaleKeys(ctx context.Context) error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	if !p.refreshedAt.IsZero() && time.Since(p.refreshedAt) < minKeysRefreshInterval {
+		return nil
+	}
+
+	// Failed refreshes count too, so an unavailable provider is not retried on every token.
+	p.refreshedAt = time.Now()
+	return p.refreshKeys(ctx)
+}
+
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &jwks); err != nil {
+		return apperr.NewValueError("unable to load provider keys", apperr.Caller(), err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := k.rsaPublicKey()
+		if err != nil {
+			p.logger.Warn("skipping malformed provider key", zap.String("kid", k.Kid), zap.Error(err))
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", target, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// NewRandomString returns url safe random string, used for state, nonce and PKCE code verifier.
+func NewRandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", apperr.NewValueError("unable to generate random string", apperr.Caller(), err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallengeS256 returns PKCE S256 code challenge for the code verifier.
+func CodeChallengeS256(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
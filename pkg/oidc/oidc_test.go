@@ -0,0 +1,175 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/msmkdenis/yap-shortener/pkg/oidc"
+	"github.com/msmkdenis/yap-shortener/pkg/oidc/oidctest"
+)
+
+const (
+	clientID     = "shortener"
+	clientSecret = "secret"
+	subject      = "user-42"
+	redirectURL  = "http://localhost:8080/api/auth/oidc/callback"
+)
+
+func newProvider(t *testing.T, server *oidctest.Server) *oidc.Provider {
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	return provider
+}
+
+func login(t *testing.T, server *oidctest.Server, provider *oidc.Provider, nonce, verifier string) url.Values {
+	callback, err := server.Login(provider.AuthCodeURL("state", nonce, oidc.CodeChallengeS256(verifier)))
+	require.NoError(t, err)
+	return callback.Query()
+}
+
+func TestProvider_Flow(t *testing.T) {
+	server := oidctest.NewServer(clientID, clientSecret, subject)
+	defer server.Close()
+	provider := newProvider(t, server)
+
+	verifier, err := oidc.NewRandomString()
+	require.NoError(t, err)
+
+	callback := login(t, server, provider, "nonce", verifier)
+	assert.Equal(t, "state", callback.Get("state"))
+
+	rawIDToken, err := provider.Exchange(context.Background(), callback.Get("code"), verifier)
+	require.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, subject, claims.Subject)
+	assert.Equal(t, server.URL, provider.Issuer())
+}
+
+func TestProvider_Errors(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		prepare       func(server *oidctest.Server)
+		verifier      string
+		nonce         string
+		exchangeError error
+		verifyError   error
+	}{
+		{
+			name:          "Wrong PKCE verifier",
+			verifier:      "wrong",
+			nonce:         "nonce",
+			exchangeError: oidc.ErrTokenExchange,
+		},
+		{
+			name:        "Nonce mismatch",
+			nonce:       "other",
+			verifyError: oidc.ErrNonceMismatch,
+		},
+		{
+			name: "Wrong audience",
+			prepare: func(server *oidctest.Server) {
+				server.Audience = "someone-else"
+			},
+			nonce:       "nonce",
+			verifyError: oidc.ErrInvalidIDToken,
+		},
+		{
+			name: "Invalid signature",
+			prepare: func(server *oidctest.Server) {
+				server.SigningKey = otherKey
+			},
+			nonce:       "nonce",
+			verifyError: oidc.ErrInvalidIDToken,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			server := oidctest.NewServer(clientID, clientSecret, subject)
+			defer server.Close()
+			if test.prepare != nil {
+				test.prepare(server)
+			}
+			provider := newProvider(t, server)
+
+			verifier, err := oidc.NewRandomString()
+			require.NoError(t, err)
+			callback := login(t, server, provider, "nonce", verifier)
+
+			exchangeVerifier := verifier
+			if test.verifier != "" {
+				exchangeVerifier = test.verifier
+			}
+
+			rawIDToken, err := provider.Exchange(context.Background(), callback.Get("code"), exchangeVerifier)
+			if test.exchangeError != nil {
+				assert.True(t, errors.Is(err, test.exchangeError))
+				return
+			}
+			require.NoError(t, err)
+
+			_, err = provider.VerifyIDToken(context.Background(), rawIDToken, test.nonce)
+			assert.True(t, errors.Is(err, test.verifyError))
+		})
+	}
+}
+
+func TestProvider_UnknownKeyRefreshLimit(t *testing.T) {
+	server := oidctest.NewServer(clientID, clientSecret, subject)
+	defer server.Close()
+	server.KeyID = "rotated"
+	provider := newProvider(t, server)
+	require.Equal(t, 1, server.JWKSRequests())
+
+	verifier, err := oidc.NewRandomString()
+	require.NoError(t, err)
+	callback := login(t, server, provider, "nonce", verifier)
+	rawIDToken, err := provider.Exchange(context.Background(), callback.Get("code"), verifier)
+	require.NoError(t, err)
+
+	_, err = provider.VerifyIDToken(context.Background(), rawIDToken, "nonce")
+	assert.Error(t, err)
+	assert.Equal(t, 2, server.JWKSRequests(), "unknown key must refresh provider keys")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, verifyErr := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce")
+			assert.Error(t, verifyErr)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, server.JWKSRequests(), "provider keys must not be refreshed again within the interval")
+}
+
+func TestNewProvider_IssuerMismatch(t *testing.T) {
+	server := oidctest.NewServer(clientID, clientSecret, subject)
+	defer server.Close()
+
+	_, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:   server.URL + "/other",
+		ClientID: clientID,
+	}, zap.NewNop())
+	assert.Error(t, err)
+}